@@ -38,6 +38,9 @@ type Client struct {
 	Credentials *CredentialsClient // this replaces the registry endpoint
 	Worklog     *WorklogClient
 	Updates     *UpdatesClient
+	Keyrings    *KeyringsClient
+	Metrics     *MetricsClient
+	Debug       *DebugClient
 
 	// Cryptography related registry endpoints that should be accessed
 	// via the daemon.
@@ -77,6 +80,9 @@ func NewClient(cfg *config.Config) *Client {
 	c.Credentials = &CredentialsClient{client: rt}
 	c.Worklog = &WorklogClient{client: rt}
 	c.Updates = &UpdatesClient{client: rt}
+	c.Keyrings = &KeyringsClient{client: rt}
+	c.Metrics = &MetricsClient{client: rt}
+	c.Debug = &DebugClient{client: rt}
 
 	return c
 }
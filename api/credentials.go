@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/url"
+	"time"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 )
@@ -23,6 +24,34 @@ func (c *CredentialsClient) Search(ctx context.Context, pathexp string) ([]apity
 	return c.listWorker(ctx, v)
 }
 
+// SearchHeads returns the most recent version of every credential at the
+// given pathexp, including credentials that have been unset.
+func (c *CredentialsClient) SearchHeads(ctx context.Context, pathexp string) ([]apitypes.CredentialEnvelope, error) {
+	v := &url.Values{}
+	v.Set("pathexp", pathexp)
+	v.Set("include_unset", "true")
+
+	return c.listWorker(ctx, v)
+}
+
+// SearchHistory returns every version of every credential at the given
+// pathexp, including versions that have been replaced or unset, and the
+// number of versions that could not be decrypted.
+func (c *CredentialsClient) SearchHistory(ctx context.Context, pathexp string) ([]apitypes.CredentialEnvelope, int, error) {
+	v := &url.Values{}
+	v.Set("pathexp", pathexp)
+	v.Set("include_history", "true")
+
+	var resp apitypes.CredentialHistoryResp
+	err := c.client.DaemonRoundTrip(ctx, "GET", "/credentials", v, nil, &resp, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	creds, err := createEnvelopesFromResp(resp.Credentials)
+	return creds, resp.Skipped, err
+}
+
 // Get returns all credentials at the given path.
 func (c *CredentialsClient) Get(ctx context.Context, path string) ([]apitypes.CredentialEnvelope, error) {
 	v := &url.Values{}
@@ -31,6 +60,55 @@ func (c *CredentialsClient) Get(ctx context.Context, path string) ([]apitypes.Cr
 	return c.listWorker(ctx, v)
 }
 
+// GetHeads returns the most recent version of every credential at the given
+// path, including credentials that have been unset.
+func (c *CredentialsClient) GetHeads(ctx context.Context, path string) ([]apitypes.CredentialEnvelope, error) {
+	v := &url.Values{}
+	v.Set("path", path)
+	v.Set("include_unset", "true")
+
+	return c.listWorker(ctx, v)
+}
+
+// Audit checks the values of every credential at the given pathexp, returning
+// those that are weak, reused across environments, or older than maxAge.
+func (c *CredentialsClient) Audit(ctx context.Context, pathexp string,
+	maxAge time.Duration) ([]apitypes.SecretAuditResult, error) {
+
+	v := &url.Values{}
+	v.Set("pathexp", pathexp)
+	v.Set("max_age", maxAge.String())
+
+	var resp []apitypes.SecretAuditResult
+	err := c.client.DaemonRoundTrip(ctx, "GET", "/credentials/audit", v, nil, &resp, nil)
+	return resp, err
+}
+
+// FindValue returns every version of every credential, in every org, holding
+// the given value. If markForRotation is true, the current credentials
+// holding the value are marked for rotation in the worklog.
+func (c *CredentialsClient) FindValue(ctx context.Context, value string, markForRotation bool,
+	progress ProgressFunc) (*apitypes.FindValueResult, error) {
+
+	req := apitypes.FindValueRequest{Value: value, MarkForRotation: markForRotation}
+
+	resp := apitypes.FindValueResult{}
+	err := c.client.DaemonRoundTrip(ctx, "POST", "/credentials/find", nil, &req, &resp, progress)
+	return &resp, err
+}
+
+// Compromise marks every current secret in a keyring contained by pathexp
+// for rotation, and gives each keyring a new master encryption key.
+func (c *CredentialsClient) Compromise(ctx context.Context, pathexp string,
+	progress ProgressFunc) (*apitypes.CompromiseResult, error) {
+
+	req := apitypes.CompromiseRequest{PathExp: pathexp}
+
+	resp := apitypes.CompromiseResult{}
+	err := c.client.DaemonRoundTrip(ctx, "POST", "/credentials/compromise", nil, &req, &resp, progress)
+	return &resp, err
+}
+
 func (c *CredentialsClient) listWorker(ctx context.Context, v *url.Values) ([]apitypes.CredentialEnvelope, error) {
 	var resp []apitypes.CredentialResp
 	err := c.client.DaemonRoundTrip(ctx, "GET", "/credentials", v, nil, &resp, nil)
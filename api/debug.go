@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+)
+
+// DebugClient provides access to the daemon's internal state, for diagnosing
+// problems.
+type DebugClient struct {
+	client *apiRoundTripper
+}
+
+// Get returns the daemon's recent events, db statistics and update state.
+func (d *DebugClient) Get(ctx context.Context) (*apitypes.DaemonDebug, error) {
+	debug := &apitypes.DaemonDebug{}
+	err := d.client.DaemonRoundTrip(ctx, "GET", "/debug", nil, nil, debug, nil)
+	return debug, err
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"context"
+	"net/url"
 
+	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/identity"
 	"github.com/manifoldco/torus-cli/registry"
 )
@@ -32,7 +34,34 @@ func (k *KeyPairsClient) Revoke(ctx context.Context, orgID *identity.ID, output
 	return k.worker(ctx, "revoke", orgID, output)
 }
 
+// Rotate replaces the keypairs for the user in the given org, sharing their
+// keyring memberships to the new keys before revoking the old ones.
+func (k *KeyPairsClient) Rotate(ctx context.Context, orgID *identity.ID, output ProgressFunc) error {
+	return k.worker(ctx, "rotate", orgID, output)
+}
+
 func (k *KeyPairsClient) worker(ctx context.Context, action string, orgID *identity.ID, output ProgressFunc) error {
 	kpr := keyPairsRequest{OrgID: orgID}
 	return k.client.DaemonRoundTrip(ctx, "POST", "/keypairs/"+action, nil, &kpr, nil, output)
 }
+
+// Graph returns the public keys and claims in the given org's claim tree.
+func (k *KeyPairsClient) Graph(ctx context.Context, orgID *identity.ID) (*apitypes.ClaimTreeGraph, error) {
+	v := &url.Values{}
+	v.Set("org_id", orgID.String())
+
+	resp := apitypes.ClaimTreeGraph{}
+	err := k.client.DaemonRoundTrip(ctx, "GET", "/keypairs/graph", v, nil, &resp, nil)
+	return &resp, err
+}
+
+// Verify checks the signatures and claim chains of every public key in the
+// given org's claim tree, returning the anomalies found.
+func (k *KeyPairsClient) Verify(ctx context.Context, orgID *identity.ID) (*apitypes.ClaimTreeVerification, error) {
+	v := &url.Values{}
+	v.Set("org_id", orgID.String())
+
+	resp := apitypes.ClaimTreeVerification{}
+	err := k.client.DaemonRoundTrip(ctx, "GET", "/keypairs/verify", v, nil, &resp, nil)
+	return &resp, err
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+)
+
+// KeyringsClient inspects and re-keys keyrings through the daemon.
+type KeyringsClient struct {
+	client *apiRoundTripper
+}
+
+// List returns a summary of the most recent version of every keyring at the
+// given pathexp.
+func (k *KeyringsClient) List(ctx context.Context, pathexp string) ([]apitypes.KeyringSummary, error) {
+	v := &url.Values{}
+	v.Set("pathexp", pathexp)
+
+	var resp []apitypes.KeyringSummary
+	err := k.client.DaemonRoundTrip(ctx, "GET", "/keyrings", v, nil, &resp, nil)
+	return resp, err
+}
+
+// View returns the details of a version of the keyring at the given pathexp.
+// If version is 0, the most recent version is returned.
+func (k *KeyringsClient) View(ctx context.Context, pathexp string, version int) (*apitypes.KeyringDetails, error) {
+	v := &url.Values{}
+	v.Set("pathexp", pathexp)
+	if version != 0 {
+		v.Set("version", strconv.Itoa(version))
+	}
+
+	resp := apitypes.KeyringDetails{}
+	err := k.client.DaemonRoundTrip(ctx, "GET", "/keyrings/view", v, nil, &resp, nil)
+	return &resp, err
+}
+
+// Rekey creates a new version of the keyring at the given pathexp, shared
+// only with the org's current members, and re-encrypts its secrets.
+func (k *KeyringsClient) Rekey(ctx context.Context, pathexp string,
+	progress ProgressFunc) (*apitypes.KeyringSummary, error) {
+
+	req := apitypes.RekeyRequest{PathExp: pathexp}
+
+	resp := apitypes.KeyringSummary{}
+	err := k.client.DaemonRoundTrip(ctx, "POST", "/keyrings/rekey", nil, &req, &resp, progress)
+	return &resp, err
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+)
+
+// MetricsClient provides access to the daemon's metrics.
+type MetricsClient struct {
+	client *apiRoundTripper
+}
+
+// Get returns the daemon's metrics, in the Prometheus text format.
+func (m *MetricsClient) Get(ctx context.Context) ([]byte, error) {
+	req, err := m.client.DefaultRequestDoer.NewRequest("GET", "/metrics", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.client.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		rErr := &apitypes.Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(rErr); err != nil {
+			return nil, err
+		}
+		return nil, rErr
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -202,3 +202,23 @@ func performLogin(ctx context.Context, s *SessionClient, loginType apitypes.Sess
 func (s *SessionClient) Logout(ctx context.Context) error {
 	return s.client.DaemonRoundTrip(ctx, "POST", "/logout", nil, nil, nil, nil)
 }
+
+// ExportRecoveryKit returns a recovery kit for the logged in user, and the
+// recovery code its secrets are encrypted under.
+func (s *SessionClient) ExportRecoveryKit(ctx context.Context) (*apitypes.RecoveryKit, error) {
+	resp := &apitypes.RecoveryKit{}
+	err := s.client.DaemonRoundTrip(ctx, "POST", "/recovery/export", nil, nil, resp, nil)
+	return resp, err
+}
+
+// RestoreRecoveryKit resets the password of the user the recovery kit
+// belongs to, logging them in.
+func (s *SessionClient) RestoreRecoveryKit(ctx context.Context, kit, code, passphrase string) error {
+	req := apitypes.RecoveryRestore{
+		Kit:        kit,
+		Code:       code,
+		Passphrase: passphrase,
+	}
+
+	return s.client.DaemonRoundTrip(ctx, "POST", "/recovery/restore", nil, &req, nil, nil)
+}
@@ -17,12 +17,16 @@ type WorklogClient struct {
 
 var errUnknownWorklogType = errors.New("Unknown worklog item type")
 
-// List returns the list of all worklog items in the given org.
-func (w *WorklogClient) List(ctx context.Context, orgID *identity.ID) ([]apitypes.WorklogItem, error) {
+// List returns the list of all worklog items in the given org. Items found by
+// auditing secret values are only included if audit is true.
+func (w *WorklogClient) List(ctx context.Context, orgID *identity.ID, audit bool) ([]apitypes.WorklogItem, error) {
 	v := &url.Values{}
 	if orgID != nil {
 		v.Set("org_id", orgID.String())
 	}
+	if audit {
+		v.Set("audit", "true")
+	}
 
 	var resp []rawWorklogItem
 	err := w.client.DaemonRoundTrip(ctx, "GET", "/worklog", v, nil, &resp, nil)
@@ -89,6 +93,9 @@ func (w *rawWorklogItem) setDetails() error {
 		fallthrough
 	case apitypes.MachineKeyringMembersWorklogType:
 		w.WorklogItem.Details = &apitypes.KeyringMembersWorklogDetails{}
+	case apitypes.SecretReusedWorklogType, apitypes.SecretWeakWorklogType,
+		apitypes.SecretStaleWorklogType:
+		w.WorklogItem.Details = &apitypes.SecretAuditWorklogDetails{}
 	default:
 		return errUnknownWorklogType
 	}
@@ -4,6 +4,7 @@ package apitypes
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/manifoldco/go-base64"
 
@@ -19,6 +20,7 @@ type ErrorType string
 const (
 	BadRequestError     = "bad_request"
 	UnauthorizedError   = "unauthorized"
+	ForbiddenError      = "forbidden"
 	NotFoundError       = "not_found"
 	InternalServerError = "internal_server"
 	NotImplementedError = "not_implemented"
@@ -129,6 +131,7 @@ type Version struct {
 type SessionStatus struct {
 	Token      bool `json:"token"`
 	Passphrase bool `json:"passphrase"`
+	ReadOnly   bool `json:"read_only"`
 }
 
 // Login is a wrapper around a login request from the CLI to the Daemon
@@ -244,3 +247,28 @@ type UpdateInfo struct {
 	NeedsUpdate bool   `json:"needs_update"`
 	Version     string `json:"version"`
 }
+
+// DaemonDebug contains the daemon's internal state, for diagnosing problems.
+type DaemonDebug struct {
+	LastUpdateCheck time.Time       `json:"last_update_check"`
+	Update          UpdateInfo      `json:"update"`
+	Events          []ObservedEvent `json:"events"`
+	Buckets         []BucketStats   `json:"buckets"`
+}
+
+// ObservedEvent is a progress event recently published by the daemon.
+type ObservedEvent struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Completed uint      `json:"completed"`
+	Total     uint      `json:"total"`
+}
+
+// BucketStats describes the contents of a bucket of the daemon's db.
+type BucketStats struct {
+	Name  string `json:"name"`
+	Keys  int    `json:"keys"`
+	Bytes int    `json:"bytes"`
+}
@@ -0,0 +1,107 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+// SecretAuditIssue is a problem found with the value of a secret
+type SecretAuditIssue string
+
+// The issues that can be found by a secret audit
+const (
+	ReusedSecretAuditIssue      SecretAuditIssue = "reused"
+	ShortSecretAuditIssue       SecretAuditIssue = "short"
+	LowEntropySecretAuditIssue  SecretAuditIssue = "low_entropy"
+	PlaceholderSecretAuditIssue SecretAuditIssue = "placeholder"
+	StaleSecretAuditIssue       SecretAuditIssue = "stale"
+)
+
+// SecretAuditResult holds the issues found with a single secret.
+//
+// Values are never included. Fingerprint is a short keyed hash of the value,
+// and can only be compared with other fingerprints from the same audit.
+type SecretAuditResult struct {
+	PathExp     *pathexp.PathExp   `json:"pathexp"`
+	Name        string             `json:"name"`
+	Fingerprint string             `json:"fingerprint"`
+	Issues      []SecretAuditIssue `json:"issues"`
+	ReusedBy    []string           `json:"reused_by,omitempty"`
+	FirstSeen   time.Time          `json:"first_seen"`
+}
+
+// Path returns the full path of the secret.
+func (r *SecretAuditResult) Path() string {
+	return r.PathExp.String() + "/" + r.Name
+}
+
+// Has returns whether the result contains the given issue.
+func (r *SecretAuditResult) Has(issue SecretAuditIssue) bool {
+	for _, i := range r.Issues {
+		if i == issue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindValueRequest is the request to find every credential holding Value.
+type FindValueRequest struct {
+	Value           string `json:"value"`
+	MarkForRotation bool   `json:"mark_for_rotation"`
+}
+
+// FindValueResult lists the versions of credentials found holding a value.
+// Skipped is the number of keyring versions that could not be decrypted, such
+// as versions shared with a revoked keypair, and so were not searched.
+type FindValueResult struct {
+	Locations []ValueLocation `json:"locations"`
+	Skipped   int             `json:"skipped"`
+}
+
+// ValueLocation is a version of a credential found holding a value.
+type ValueLocation struct {
+	PathExp           *pathexp.PathExp `json:"pathexp"`
+	Name              string           `json:"name"`
+	CredentialVersion int              `json:"credential_version"`
+	Current           bool             `json:"current"`
+	Marked            bool             `json:"marked"`
+}
+
+// Path returns the full path of the credential.
+func (l *ValueLocation) Path() string {
+	return l.PathExp.String() + "/" + l.Name
+}
+
+// CompromiseRequest is the request to treat every secret under PathExp as
+// compromised.
+type CompromiseRequest struct {
+	PathExp string `json:"pathexp"`
+}
+
+// CompromiseResult lists the keyrings given a new version, and the secrets
+// marked for rotation, when a pathexp is compromised.
+type CompromiseResult struct {
+	Keyrings []RekeyedKeyring    `json:"keyrings"`
+	Secrets  []CompromisedSecret `json:"secrets"`
+}
+
+// RekeyedKeyring is a keyring that was given a new version, with a new master
+// encryption key.
+type RekeyedKeyring struct {
+	PathExp        *pathexp.PathExp `json:"pathexp"`
+	KeyringVersion int              `json:"keyring_version"`
+}
+
+// CompromisedSecret is a secret marked for rotation.
+type CompromisedSecret struct {
+	PathExp *pathexp.PathExp `json:"pathexp"`
+	Name    string           `json:"name"`
+}
+
+// Path returns the full path of the secret.
+func (s *CompromisedSecret) Path() string {
+	return s.PathExp.String() + "/" + s.Name
+}
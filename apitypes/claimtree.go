@@ -2,8 +2,10 @@ package apitypes
 
 import (
 	"errors"
+	"time"
 
 	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
 	"github.com/manifoldco/torus-cli/primitive"
 )
 
@@ -48,3 +50,53 @@ outerLoop:
 
 	return nil, ErrClaimCycleFound
 }
+
+// ClaimTreeGraph describes the public keys in an org's claim tree, and the
+// claims made against them.
+type ClaimTreeGraph struct {
+	OrgID   *identity.ID   `json:"org_id"`
+	OrgName string         `json:"org_name"`
+	Keys    []ClaimTreeKey `json:"keys"`
+}
+
+// ClaimTreeKey is a public key in a ClaimTreeGraph.
+type ClaimTreeKey struct {
+	ID        *identity.ID      `json:"id"`
+	KeyType   primitive.KeyType `json:"type"`
+	OwnerID   *identity.ID      `json:"owner_id"`
+	OwnerType string            `json:"owner_type"`
+	OwnerName string            `json:"owner_name,omitempty"`
+	Created   time.Time         `json:"created_at"`
+	Expires   time.Time         `json:"expires_at"`
+	Revoked   bool              `json:"revoked"`
+
+	// SignedBy is the id of the public key that signed this key. It is the
+	// key's own id when the key is self-signed.
+	SignedBy *identity.ID     `json:"signed_by"`
+	Claims   []ClaimTreeClaim `json:"claims"`
+}
+
+// ClaimTreeClaim is a signature or revocation claim in a ClaimTreeGraph.
+type ClaimTreeClaim struct {
+	ID        *identity.ID        `json:"id"`
+	ClaimType primitive.ClaimType `json:"type"`
+	Created   time.Time           `json:"created_at"`
+	Previous  *identity.ID        `json:"previous"`
+	SignedBy  *identity.ID        `json:"signed_by"`
+}
+
+// ClaimTreeAnomaly is a problem found while verifying a claim tree.
+type ClaimTreeAnomaly struct {
+	PublicKeyID *identity.ID `json:"public_key_id"`
+	ClaimID     *identity.ID `json:"claim_id,omitempty"`
+	Message     string       `json:"message"`
+}
+
+// ClaimTreeVerification is the result of verifying every public key and
+// claim in an org's claim tree.
+type ClaimTreeVerification struct {
+	OrgID      *identity.ID       `json:"org_id"`
+	KeyCount   int                `json:"key_count"`
+	ClaimCount int                `json:"claim_count"`
+	Anomalies  []ClaimTreeAnomaly `json:"anomalies"`
+}
@@ -34,6 +34,14 @@ type CredentialResp struct {
 	Body    json.RawMessage `json:"body"`
 }
 
+// CredentialHistoryResp is used to facilitate unmarshalling every version of
+// the credentials at a path expression. Skipped is the number of versions
+// that could not be decrypted.
+type CredentialHistoryResp struct {
+	Credentials []CredentialResp `json:"credentials"`
+	Skipped     int              `json:"skipped"`
+}
+
 // Credential interface is either a v1 or v2 credential object
 type Credential interface {
 	GetName() string
@@ -41,6 +49,7 @@ type Credential interface {
 	GetPathExp() *pathexp.PathExp
 	GetProjectID() *identity.ID
 	GetValue() *CredentialValue
+	GetCredentialVersion() int
 }
 
 // BaseCredential is the body of an unencrypted Credential
@@ -50,6 +59,8 @@ type BaseCredential struct {
 	PathExp   *pathexp.PathExp `json:"pathexp"`
 	ProjectID *identity.ID     `json:"project_id"`
 	Value     *CredentialValue `json:"value"`
+
+	CredentialVersion int `json:"credential_version,omitempty"`
 }
 
 // GetName returns the name
@@ -72,6 +83,12 @@ func (c *BaseCredential) GetProjectID() *identity.ID {
 	return c.ProjectID
 }
 
+// GetCredentialVersion returns the version of this credential within its
+// history. It is 0 if the daemon did not report it.
+func (c *BaseCredential) GetCredentialVersion() int {
+	return c.CredentialVersion
+}
+
 // GetValue returns the value object, unless unset then returns nil
 func (c *BaseCredential) GetValue() *CredentialValue {
 	if c.Value.cvtype == unsetCV {
@@ -0,0 +1,64 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+// KeyringSummary describes a version of a keyring.
+type KeyringSummary struct {
+	ID              *identity.ID     `json:"id"`
+	PathExp         *pathexp.PathExp `json:"pathexp"`
+	KeyringVersion  int              `json:"keyring_version"`
+	Created         time.Time        `json:"created_at"`
+	MemberCount     int              `json:"member_count"`
+	RevocationCount int              `json:"revocation_count"`
+
+	// CredentialCount is the number of current secrets at the keyring's
+	// pathexp, in this or any earlier version of the keyring.
+	CredentialCount int `json:"credential_count"`
+}
+
+// KeyringDetails describes a version of a keyring, its members, and the
+// credentials stored in it.
+type KeyringDetails struct {
+	KeyringSummary
+	Members     []KeyringMemberDetails `json:"members"`
+	Credentials []KeyringCredential    `json:"credentials"`
+}
+
+// KeyringMemberDetails describes a user or machine's membership in a keyring.
+//
+// The registry only returns the caller's own MEKShare, so MEKShare is only
+// ever true for the caller's memberships.
+type KeyringMemberDetails struct {
+	OwnerID          *identity.ID `json:"owner_id"`
+	OwnerType        string       `json:"owner_type"`
+	Name             string       `json:"name"`
+	EncryptingKeyID  *identity.ID `json:"encrypting_key_id"`
+	MEKShare         bool         `json:"mekshare"`
+	Revoked          bool         `json:"revoked"`
+	RevocationReason string       `json:"revocation_reason,omitempty"`
+}
+
+// KeyringCredential is a version of a credential stored in a keyring.
+type KeyringCredential struct {
+	PathExp           *pathexp.PathExp `json:"pathexp"`
+	Name              string           `json:"name"`
+	CredentialVersion int              `json:"credential_version"`
+	Current           bool             `json:"current"`
+	Unset             bool             `json:"unset"`
+}
+
+// Path returns the full path of the credential.
+func (c *KeyringCredential) Path() string {
+	return c.PathExp.String() + "/" + c.Name
+}
+
+// RekeyRequest is the request to create a new version of the keyring at
+// PathExp.
+type RekeyRequest struct {
+	PathExp string `json:"pathexp"`
+}
@@ -0,0 +1,16 @@
+package apitypes
+
+// RecoveryKit is a printable recovery kit, and the recovery code its secrets
+// are encrypted under.
+type RecoveryKit struct {
+	Kit  string `json:"kit"`
+	Code string `json:"code"`
+}
+
+// RecoveryRestore is a request to reset a user's password with a recovery
+// kit.
+type RecoveryRestore struct {
+	Kit        string `json:"kit"`
+	Code       string `json:"code"`
+	Passphrase string `json:"passphrase"`
+}
@@ -3,6 +3,8 @@ package apitypes
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dchest/blake2b"
 
@@ -22,8 +24,17 @@ const (
 	InviteApproveWorklogType
 	UserKeyringMembersWorklogType
 	MachineKeyringMembersWorklogType
+	SecretReusedWorklogType
+	SecretWeakWorklogType
+	SecretStaleWorklogType
 
 	AnyWorklogType WorklogType = 0xff
+
+	// SecretAuditWorklogType matches the types of items found by auditing
+	// secret values. Finding them decrypts every secret in the org, so they
+	// are only listed when asked for.
+	SecretAuditWorklogType = SecretReusedWorklogType | SecretWeakWorklogType |
+		SecretStaleWorklogType
 )
 
 // ErrIncorrectWorklogIDLen is returned when a base32 encoded worklog id is the
@@ -164,10 +175,14 @@ type SecretRotateWorklogDetails struct {
 }
 
 // SecretRotateWorklogReason holds the username and claim revocation type
-// for a secret rotation reason.
+// for a secret rotation reason. For secrets that were marked for rotation,
+// Mark holds the reason given instead. For secrets whose keyring was given a
+// new master encryption key, Rekeyed holds the keyring version with the key.
 type SecretRotateWorklogReason struct {
 	Username string                                `json:"username"`
 	Type     primitive.KeyringMemberRevocationType `json:"type"`
+	Mark     string                                `json:"mark,omitempty"`
+	Rekeyed  int                                   `json:"rekeyed,omitempty"`
 }
 
 // Subject returns the human readable subject of this WorklogItem.
@@ -177,7 +192,55 @@ func (s *SecretRotateWorklogDetails) Subject() string {
 
 // Summary returns the human readable summary of this WorklogItem.
 func (s *SecretRotateWorklogDetails) Summary() string {
-	return "A user's access was revoked. This secret's value should be changed."
+	for _, r := range s.Reasons {
+		if r.Mark == "" && r.Rekeyed == 0 {
+			return "A user's access was revoked. This secret's value should be changed."
+		}
+	}
+	for _, r := range s.Reasons {
+		if r.Rekeyed != 0 {
+			return "This secret's keyring was given a new key. This secret's value should be changed."
+		}
+	}
+
+	return "This secret was marked for rotation. This secret's value should be changed."
+}
+
+// SecretAuditWorklogDetails holds WorklogItem details for the
+// SecretReusedWorklogType, SecretWeakWorklogType and SecretStaleWorklogType.
+// Issues holds only the audit issues relevant to the item's type.
+type SecretAuditWorklogDetails struct {
+	PathExp   *pathexp.PathExp   `json:"pathexp"`
+	Name      string             `json:"name"`
+	Issues    []SecretAuditIssue `json:"issues"`
+	ReusedBy  []string           `json:"reused_by,omitempty"`
+	FirstSeen time.Time          `json:"first_seen"`
+}
+
+// Subject returns the human readable subject of this WorklogItem.
+func (s *SecretAuditWorklogDetails) Subject() string {
+	return s.PathExp.String() + "/" + s.Name
+}
+
+// Summary returns the human readable summary of this WorklogItem.
+func (s *SecretAuditWorklogDetails) Summary() string {
+	if len(s.Issues) == 0 {
+		return "This secret's value should be changed."
+	}
+
+	switch s.Issues[0] {
+	case ReusedSecretAuditIssue:
+		return "This secret's value is also used in another environment. It should be changed."
+	case StaleSecretAuditIssue:
+		return "This secret's value has not changed recently. It should be rotated."
+	default:
+		issues := make([]string, len(s.Issues))
+		for i, issue := range s.Issues {
+			issues[i] = strings.Replace(string(issue), "_", " ", -1)
+		}
+		return fmt.Sprintf("This secret's value is weak (%s). It should be changed.",
+			strings.Join(issues, ", "))
+	}
 }
 
 // Type returns this item's type
@@ -197,6 +260,8 @@ func (t WorklogType) String() string {
 	case UserKeyringMembersWorklogType:
 		fallthrough
 	case MachineKeyringMembersWorklogType:
+		fallthrough
+	case SecretReusedWorklogType, SecretWeakWorklogType, SecretStaleWorklogType:
 		return "secret"
 	default:
 		return "n/a"
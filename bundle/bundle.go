@@ -0,0 +1,229 @@
+// Package bundle seals data for storage or transfer outside of Torus, either
+// with a passphrase, or to the holder of an X25519 secret key.
+//
+// Keys are read and written in the age format, so age-keygen can create them,
+// but bundles are Torus files sealed with NaCl box or secretbox. They can't be
+// opened by age.
+package bundle
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	base64url "github.com/manifoldco/go-base64"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+// Algorithms used to seal a bundle
+const (
+	PassphraseAlg = "argon2id-secretbox"
+	X25519Alg     = "x25519-box"
+)
+
+const (
+	bundleVersion = 1
+	saltSize      = 16
+	nonceSize     = 24
+)
+
+// ErrDecrypt is returned when a bundle can't be opened with the given
+// passphrase or identities.
+var ErrDecrypt = errors.New("Could not decrypt bundle")
+
+// ErrMalformed is returned when a bundle can't be parsed.
+var ErrMalformed = errors.New("Bundle is malformed")
+
+// Bundle is a sealed blob of data, and the details needed to open it.
+type Bundle struct {
+	Version int    `json:"version"`
+	Alg     string `json:"alg"`
+
+	// Set for passphrase bundles
+	Salt   *base64url.Value     `json:"salt,omitempty"`
+	Params *primitive.KDFParams `json:"params,omitempty"`
+
+	// Set for X25519 bundles
+	Recipient    string           `json:"recipient,omitempty"`
+	EphemeralKey *base64url.Value `json:"ephemeral_key,omitempty"`
+
+	Nonce *base64url.Value `json:"nonce"`
+	Value *base64url.Value `json:"value"`
+}
+
+// SealWithPassphrase encrypts data with a key derived from the passphrase.
+func SealWithPassphrase(data []byte, passphrase string) (*Bundle, error) {
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	params := primitive.DefaultKDFParams
+	key := passphraseKey(passphrase, salt, &params)
+
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Version: bundleVersion,
+		Alg:     PassphraseAlg,
+		Salt:    base64url.New(salt),
+		Params:  &params,
+		Nonce:   base64url.New(nonce[:]),
+		Value:   base64url.New(secretbox.Seal(nil, data, nonce, key)),
+	}, nil
+}
+
+// SealToRecipient encrypts data so that it can only be opened by the holder
+// of the recipient's secret key.
+func SealToRecipient(data []byte, r *Recipient) (*Bundle, error) {
+	ephemeralPub, ephemeralPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	pub := [keySize]byte(*r)
+	return &Bundle{
+		Version:      bundleVersion,
+		Alg:          X25519Alg,
+		Recipient:    r.String(),
+		EphemeralKey: base64url.New(ephemeralPub[:]),
+		Nonce:        base64url.New(nonce[:]),
+		Value:        base64url.New(box.Seal(nil, data, nonce, &pub, ephemeralPriv)),
+	}, nil
+}
+
+// OpenWithPassphrase decrypts a bundle sealed with a passphrase.
+func (b *Bundle) OpenWithPassphrase(passphrase string) ([]byte, error) {
+	err := b.validate(PassphraseAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := b.Params
+	if p == nil || p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return nil, ErrMalformed
+	}
+	if p.Time > primitive.MaxKDFTime || p.Memory > primitive.MaxKDFMemory {
+		return nil, errors.New("Bundle key derivation costs exceed the maximum allowed")
+	}
+
+	key := passphraseKey(passphrase, *b.Salt, p)
+	nonce := b.nonce()
+
+	data, ok := secretbox.Open(nil, *b.Value, nonce, key)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+
+	return data, nil
+}
+
+// OpenWithIdentities decrypts a bundle sealed to the recipient of any of the
+// given identities.
+func (b *Bundle) OpenWithIdentities(ids []*Identity) ([]byte, error) {
+	err := b.validate(X25519Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*b.EphemeralKey) != keySize {
+		return nil, ErrMalformed
+	}
+
+	var ephemeralPub [keySize]byte
+	copy(ephemeralPub[:], *b.EphemeralKey)
+	nonce := b.nonce()
+
+	for _, id := range ids {
+		data, ok := box.Open(nil, *b.Value, nonce, &ephemeralPub, &id.secret)
+		if ok {
+			return data, nil
+		}
+	}
+
+	return nil, ErrDecrypt
+}
+
+// Encode returns the bundle in the format read by Decode.
+func (b *Bundle) Encode() ([]byte, error) {
+	out, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
+
+// Decode parses a bundle written by Encode.
+func Decode(data []byte) (*Bundle, error) {
+	b := &Bundle{}
+	err := json.Unmarshal(data, b)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if b.Version != bundleVersion {
+		return nil, fmt.Errorf("Unsupported bundle version: %d", b.Version)
+	}
+
+	return b, nil
+}
+
+func (b *Bundle) validate(alg string) error {
+	if b.Alg != alg {
+		switch b.Alg {
+		case PassphraseAlg:
+			return errors.New("Bundle is sealed with a passphrase")
+		case X25519Alg:
+			return fmt.Errorf("Bundle is sealed to recipient %s", b.Recipient)
+		default:
+			return fmt.Errorf("Unknown bundle algorithm: %s", b.Alg)
+		}
+	}
+
+	if b.Nonce == nil || len(*b.Nonce) != nonceSize || b.Value == nil {
+		return ErrMalformed
+	}
+
+	if alg == PassphraseAlg && b.Salt == nil {
+		return ErrMalformed
+	}
+	if alg == X25519Alg && b.EphemeralKey == nil {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+func (b *Bundle) nonce() *[nonceSize]byte {
+	var nonce [nonceSize]byte
+	copy(nonce[:], *b.Nonce)
+	return &nonce
+}
+
+func newNonce() (*[nonceSize]byte, error) {
+	var nonce [nonceSize]byte
+	_, err := io.ReadFull(rand.Reader, nonce[:])
+	return &nonce, err
+}
+
+func passphraseKey(passphrase string, salt []byte, p *primitive.KDFParams) *[keySize]byte {
+	var key [keySize]byte
+	copy(key[:], argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, keySize))
+	return &key
+}
@@ -0,0 +1,166 @@
+package bundle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+func TestBech32(t *testing.T) {
+	// Test vectors from BIP 173
+	valid := []string{
+		"A12UEL5L",
+		"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	}
+	for _, s := range valid {
+		if _, _, err := bech32Decode(s); err != nil {
+			t.Errorf("Expected %q to be valid, got %s", s, err)
+		}
+	}
+
+	invalid := []string{
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"A1G7SGD8",
+		"abcdef1Qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	}
+	for _, s := range invalid {
+		if _, _, err := bech32Decode(s); err == nil {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := id.Recipient()
+	if !strings.HasPrefix(r.String(), "age1") {
+		t.Errorf("Expected an age recipient, got %s", r)
+	}
+
+	parsed, err := ParseRecipient(r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed != *r {
+		t.Error("Expected parsed recipient to match")
+	}
+
+	if !strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1") {
+		t.Errorf("Expected an age secret key, got %s", id)
+	}
+
+	file := "# created: 2017-10-01T00:00:00Z\n# public key: " + r.String() + "\n" + id.String() + "\n"
+	ids, err := ParseIdentities([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || *ids[0].Recipient() != *r {
+		t.Error("Expected identity file to contain the identity")
+	}
+
+	if _, err := ParseRecipient(id.String()); err != ErrInvalidRecipient {
+		t.Errorf("Expected a secret key to be an invalid recipient, got %v", err)
+	}
+
+	if _, err := ParseIdentities([]byte("# nothing here\n")); err != ErrNoIdentity {
+		t.Errorf("Expected no identity error, got %v", err)
+	}
+}
+
+func roundTrip(t *testing.T, b *Bundle) *Bundle {
+	enc, err := b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := Decode(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dec
+}
+
+func TestPassphraseBundle(t *testing.T) {
+	data := []byte("secret data")
+
+	b, err := SealWithPassphrase(data, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = roundTrip(t, b)
+
+	opened, err := b.OpenWithPassphrase("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, data) {
+		t.Error("Expected opened data to match")
+	}
+
+	if _, err := b.OpenWithPassphrase("battery staple"); err != ErrDecrypt {
+		t.Errorf("Expected decrypt error for the wrong passphrase, got %v", err)
+	}
+
+	if _, err := b.OpenWithIdentities(nil); err == nil {
+		t.Error("Expected an error opening a passphrase bundle with identities")
+	}
+
+	b.Params.Memory = primitive.MaxKDFMemory + 1
+	if _, err := b.OpenWithPassphrase("correct horse"); err == nil {
+		t.Error("Expected an error for excessive argon2id memory")
+	}
+}
+
+func TestRecipientBundle(t *testing.T) {
+	data := []byte("secret data")
+
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := SealToRecipient(data, id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = roundTrip(t, b)
+
+	if b.Recipient != id.Recipient().String() {
+		t.Errorf("Expected bundle recipient %s, got %s", id.Recipient(), b.Recipient)
+	}
+
+	opened, err := b.OpenWithIdentities([]*Identity{other, id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, data) {
+		t.Error("Expected opened data to match")
+	}
+
+	if _, err := b.OpenWithIdentities([]*Identity{other}); err != ErrDecrypt {
+		t.Errorf("Expected decrypt error for the wrong identity, got %v", err)
+	}
+
+	v := append([]byte{}, *b.Value...)
+	v[0] ^= 0xff
+	copy(*b.Value, v)
+	if _, err := b.OpenWithIdentities([]*Identity{id}); err != ErrDecrypt {
+		t.Errorf("Expected decrypt error for altered ciphertext, got %v", err)
+	}
+}
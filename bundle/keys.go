@@ -0,0 +1,133 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Human readable parts of age X25519 recipients and identities.
+const (
+	recipientHRP = "age"
+	identityHRP  = "age-secret-key-"
+)
+
+const keySize = 32
+
+// ErrInvalidRecipient is returned when a recipient is not an age X25519
+// public key.
+var ErrInvalidRecipient = errors.New("Recipient must be an age X25519 public key (age1...)")
+
+// ErrNoIdentity is returned when an identity file does not contain an age
+// X25519 secret key.
+var ErrNoIdentity = errors.New("No age X25519 secret key (AGE-SECRET-KEY-1...) found")
+
+// Recipient is an X25519 public key that bundles may be sealed to.
+type Recipient [keySize]byte
+
+// String returns the recipient in the age format.
+func (r *Recipient) String() string {
+	s, _ := bech32Encode(recipientHRP, r[:])
+	return s
+}
+
+// ParseRecipient parses an age X25519 public key, as printed by age-keygen.
+func ParseRecipient(s string) (*Recipient, error) {
+	hrp, data, err := bech32Decode(strings.TrimSpace(s))
+	if err != nil || hrp != recipientHRP || len(data) != keySize {
+		return nil, ErrInvalidRecipient
+	}
+
+	r := &Recipient{}
+	copy(r[:], data)
+	return r, nil
+}
+
+// ParseRecipientFile returns the first age X25519 public key in a recipients
+// file. Blank lines and lines starting with # are ignored.
+func ParseRecipientFile(b []byte) (*Recipient, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		return ParseRecipient(line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrInvalidRecipient
+}
+
+// Identity is an X25519 secret key that can open bundles sealed to its
+// recipient.
+type Identity struct {
+	secret [keySize]byte
+}
+
+// GenerateIdentity returns a new random identity.
+func GenerateIdentity() (*Identity, error) {
+	id := &Identity{}
+	_, err := io.ReadFull(rand.Reader, id.secret[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// Recipient returns the public key for this identity.
+func (id *Identity) Recipient() *Recipient {
+	r := &Recipient{}
+	curve25519.ScalarBaseMult((*[keySize]byte)(r), &id.secret)
+	return r
+}
+
+// String returns the identity in the age format.
+func (id *Identity) String() string {
+	s, _ := bech32Encode(identityHRP, id.secret[:])
+	return strings.ToUpper(s)
+}
+
+// ParseIdentities returns every age X25519 secret key in an identity file,
+// such as one written by age-keygen. Blank lines and lines starting with #
+// are ignored.
+func ParseIdentities(b []byte) ([]*Identity, error) {
+	var ids []*Identity
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hrp, data, err := bech32Decode(line)
+		if err != nil || hrp != identityHRP || len(data) != keySize {
+			return nil, errors.New("Identity file contains an invalid secret key")
+		}
+
+		id := &Identity{}
+		copy(id.secret[:], data)
+		ids = append(ids, id)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, ErrNoIdentity
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+)
+
+const defaultMaxAgeDays = 90
+
+func init() {
+	audit := cli.Command{
+		Name:     "audit",
+		Usage:    "Audit the secrets in a project",
+		Category: "SECRETS",
+		Subcommands: []cli.Command{
+			{
+				Name:  "secrets",
+				Usage: "Report weak, reused and stale secret values",
+				Flags: []cli.Flag{
+					stdOrgFlag,
+					stdProjectFlag,
+					cli.IntFlag{
+						Name:  "max-age",
+						Usage: "Report values that have not changed in `DAYS` days",
+						Value: defaultMaxAgeDays,
+					},
+					formatFlag("table", "Format used to display data (table, json)"),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+					checkRequiredFlags, auditSecretsCmd,
+				),
+			},
+		},
+	}
+
+	Cmds = append(Cmds, audit)
+}
+
+func auditSecretsCmd(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	maxAge := ctx.Int("max-age")
+	if maxAge < 1 {
+		return errs.NewUsageExitError("--max-age must be at least 1 day", ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	path := "/" + ctx.String("org") + "/" + ctx.String("project") + "/*/*/*/*"
+	results, err := client.Credentials.Audit(c, path, time.Duration(maxAge)*24*time.Hour)
+	if err != nil {
+		return errs.NewErrorExitError("Error auditing secrets", err)
+	}
+
+	if format == "json" {
+		return writeAuditJSON(os.Stdout, results)
+	}
+
+	return writeAuditTable(os.Stdout, path, maxAge, results)
+}
+
+// auditIssueDescription returns a human readable description of an issue
+// found with the given result.
+func auditIssueDescription(r *apitypes.SecretAuditResult, issue apitypes.SecretAuditIssue, maxAge int) string {
+	switch issue {
+	case apitypes.ReusedSecretAuditIssue:
+		return "same value as " + strings.Join(r.ReusedBy, ", ")
+	case apitypes.ShortSecretAuditIssue:
+		return "value is short"
+	case apitypes.LowEntropySecretAuditIssue:
+		return "value has low entropy"
+	case apitypes.PlaceholderSecretAuditIssue:
+		return "value looks like a placeholder"
+	case apitypes.StaleSecretAuditIssue:
+		return fmt.Sprintf("unchanged for over %d days (first seen %s)",
+			maxAge, r.FirstSeen.Format("2006-01-02"))
+	default:
+		return string(issue)
+	}
+}
+
+func writeAuditTable(w io.Writer, path string, maxAge int, results []apitypes.SecretAuditResult) error {
+	fmt.Fprintf(w, "Credential path: %s\n\n", path)
+
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No issues found. 👍")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "PATH\tFINGERPRINT\tISSUE\n")
+	for i := range results {
+		r := &results[i]
+		for j, issue := range r.Issues {
+			// Only show the path and fingerprint on a secret's first line
+			secret, fingerprint := r.Path(), r.Fingerprint
+			if j > 0 {
+				secret, fingerprint = "", ""
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", secret, fingerprint,
+				auditIssueDescription(r, issue, maxAge))
+		}
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d secret(s) with issues. Fingerprints are only comparable within this audit.\n",
+		len(results))
+	return nil
+}
+
+func writeAuditJSON(w io.Writer, results []apitypes.SecretAuditResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(results)
+	if err != nil {
+		return errs.NewErrorExitError("Could not marshal to json", err)
+	}
+
+	return nil
+}
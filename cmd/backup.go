@@ -0,0 +1,502 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/bundle"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+const (
+	backupArchiveType    = "torus-backup"
+	backupArchiveVersion = 1
+)
+
+// backupArchive is the plaintext contents of a sealed backup.
+type backupArchive struct {
+	Type      string             `json:"type"`
+	Version   int                `json:"version"`
+	Created   time.Time          `json:"created"`
+	Org       string             `json:"org"`
+	OrgID     *identity.ID       `json:"org_id"`
+	Project   string             `json:"project"`
+	ProjectID *identity.ID       `json:"project_id"`
+	History   bool               `json:"history"`
+	Secrets   []backupCredential `json:"secrets"`
+}
+
+// backupCredential is a single version of a credential in a backup.
+type backupCredential struct {
+	ID                *identity.ID              `json:"id"`
+	PathExp           *pathexp.PathExp          `json:"pathexp"`
+	Name              string                    `json:"name"`
+	State             string                    `json:"state"`
+	Value             *apitypes.CredentialValue `json:"value,omitempty"`
+	CredentialVersion int                       `json:"credential_version"`
+}
+
+func init() {
+	backup := cli.Command{
+		Name:      "backup",
+		Usage:     "Write an encrypted backup of the secrets in a project",
+		ArgsUsage: "<project>",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			stdOrgFlag,
+			cli.BoolFlag{
+				Name:  "history",
+				Usage: "Include every previous version of each secret",
+			},
+			newPlaceholder("recipient, r", "RECIPIENT",
+				"Encrypt to RECIPIENT, a public key in the age format (age1...), or the first key in the file RECIPIENT",
+				"", "", false),
+			newPlaceholder("output", "FILE", "Write the backup to FILE", "", "", false),
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+			checkRequiredFlags, backupCmd,
+		),
+	}
+
+	restore := cli.Command{
+		Name:      "restore",
+		Usage:     "Restore the secrets in an encrypted backup to a project",
+		ArgsUsage: "<backup-file>",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			orgFlag("Restore to this organization, instead of the original", false),
+			projectFlag("Restore to this project, instead of the original", false),
+			newPlaceholder("identity, i", "FILE",
+				"Decrypt with the secret key in FILE, in the age format (AGE-SECRET-KEY-1...)", "", "", false),
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the secrets that would be restored, without restoring them",
+			},
+			stdAutoAcceptFlag,
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, restoreCmd,
+		),
+	}
+
+	Cmds = append(Cmds, backup, restore)
+}
+
+func backupCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A project name is required", ctx)
+	}
+
+	orgName := ctx.String("org")
+	projectName := args[0]
+
+	var recipient *bundle.Recipient
+	if r := ctx.String("recipient"); r != "" {
+		var err error
+		recipient, err = readRecipient(r)
+		if err != nil {
+			return errs.NewUsageExitError(err.Error(), ctx)
+		}
+	}
+
+	output := ctx.String("output")
+	if output == "" {
+		output = fmt.Sprintf("%s-%s-%s.torus-backup", orgName, projectName,
+			time.Now().UTC().Format("20060102"))
+	}
+	if _, err := os.Stat(output); err == nil {
+		return errs.NewExitError("A file already exists at " + output + ".")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	org, err := client.Orgs.GetByName(c, orgName)
+	if org == nil || err != nil {
+		return errs.NewExitError("Org not found")
+	}
+
+	projects, err := listProjects(&c, client, org.ID, &projectName)
+	if len(projects) != 1 || err != nil {
+		return errs.NewExitError("Project not found")
+	}
+
+	path := "/" + orgName + "/" + projectName + "/*/*/*/*"
+
+	var creds []apitypes.CredentialEnvelope
+	skipped := 0
+	if ctx.Bool("history") {
+		creds, skipped, err = client.Credentials.SearchHistory(c, path)
+	} else {
+		creds, err = client.Credentials.Search(c, path)
+	}
+	if err != nil {
+		return errs.NewErrorExitError("Could not retrieve secrets.", err)
+	}
+
+	archive := &backupArchive{
+		Type:      backupArchiveType,
+		Version:   backupArchiveVersion,
+		Created:   time.Now().UTC(),
+		Org:       orgName,
+		OrgID:     org.ID,
+		Project:   projectName,
+		ProjectID: projects[0].ID,
+		History:   ctx.Bool("history"),
+		Secrets:   backupCredentials(creds),
+	}
+
+	plaintext, err := json.Marshal(archive)
+	if err != nil {
+		return errs.NewErrorExitError("Could not create backup.", err)
+	}
+
+	var sealed *bundle.Bundle
+	if recipient != nil {
+		sealed, err = bundle.SealToRecipient(plaintext, recipient)
+	} else {
+		label := "Backup Passphrase"
+		var passphrase string
+		passphrase, err = PasswordPrompt(true, &label)
+		if err != nil {
+			return handleSelectError(err, "A backup passphrase is required.")
+		}
+
+		sealed, err = bundle.SealWithPassphrase(plaintext, passphrase)
+	}
+	if err != nil {
+		return errs.NewErrorExitError("Could not encrypt backup.", err)
+	}
+
+	b, err := sealed.Encode()
+	if err != nil {
+		return errs.NewErrorExitError("Could not encrypt backup.", err)
+	}
+
+	err = ioutil.WriteFile(output, b, 0600)
+	if err != nil {
+		return errs.NewErrorExitError("Could not write backup.", err)
+	}
+
+	fmt.Println("")
+	fmt.Printf("%d secret(s) from %s/%s backed up to %s\n", len(archive.Secrets),
+		orgName, projectName, output)
+	if skipped > 0 {
+		fmt.Printf("%d secret version(s) could not be decrypted and were not backed up.\n", skipped)
+	}
+	if recipient != nil {
+		fmt.Printf("The backup can only be restored with the secret key for %s\n", recipient)
+	} else {
+		fmt.Println("The backup can only be restored with its passphrase.")
+	}
+
+	return nil
+}
+
+// backupCredentials converts credentials returned by the daemon into their
+// backup form.
+func backupCredentials(creds []apitypes.CredentialEnvelope) []backupCredential {
+	secrets := make([]backupCredential, 0, len(creds))
+	for _, cred := range creds {
+		body := *cred.Body
+
+		state := "set"
+		if v2, ok := body.(*apitypes.CredentialV2); ok && v2.State != "" {
+			state = v2.State
+		}
+
+		value := body.GetValue()
+		if value == nil {
+			state = "unset"
+		}
+
+		secrets = append(secrets, backupCredential{
+			ID:                cred.ID,
+			PathExp:           body.GetPathExp(),
+			Name:              body.GetName(),
+			State:             state,
+			Value:             value,
+			CredentialVersion: body.GetCredentialVersion(),
+		})
+	}
+
+	sort.Sort(backupCredentialSorter(secrets))
+	return secrets
+}
+
+func restoreCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A backup file is required", ctx)
+	}
+
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Could not read backup.", err)
+	}
+
+	sealed, err := bundle.Decode(b)
+	if err != nil {
+		return errs.NewErrorExitError("Could not read backup.", err)
+	}
+
+	var plaintext []byte
+	if sealed.Alg == bundle.X25519Alg {
+		if ctx.String("identity") == "" {
+			return errs.NewUsageExitError(
+				"This backup is encrypted to "+sealed.Recipient+"; --identity is required", ctx)
+		}
+
+		idFile, err := ioutil.ReadFile(ctx.String("identity"))
+		if err != nil {
+			return errs.NewErrorExitError("Could not read identity file.", err)
+		}
+
+		ids, err := bundle.ParseIdentities(idFile)
+		if err != nil {
+			return errs.NewErrorExitError("Could not read identity file.", err)
+		}
+
+		plaintext, err = sealed.OpenWithIdentities(ids)
+		if err != nil {
+			return errs.NewErrorExitError("Could not decrypt backup.", err)
+		}
+	} else {
+		passphrase, err := SecretValuePrompt("Backup Passphrase")
+		if err != nil {
+			return handleSelectError(err, "The backup passphrase is required.")
+		}
+
+		plaintext, err = sealed.OpenWithPassphrase(passphrase)
+		if err != nil {
+			return errs.NewErrorExitError("Could not decrypt backup.", err)
+		}
+	}
+
+	archive := &backupArchive{}
+	err = json.Unmarshal(plaintext, archive)
+	if err != nil || archive.Type != backupArchiveType {
+		return errs.NewExitError("File is not a Torus backup.")
+	}
+	if archive.Version != backupArchiveVersion {
+		return errs.NewExitError(fmt.Sprintf("Unsupported backup version: %d", archive.Version))
+	}
+
+	orgName := ctx.String("org")
+	if orgName == "" {
+		orgName = archive.Org
+	}
+	projectName := ctx.String("project")
+	if projectName == "" {
+		projectName = archive.Project
+	}
+
+	batches, err := restoreBatches(archive.Secrets, orgName, projectName)
+	if err != nil {
+		return errs.NewErrorExitError("Could not restore backup.", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	org, err := client.Orgs.GetByName(c, orgName)
+	if org == nil || err != nil {
+		return errs.NewExitError("Org not found")
+	}
+
+	projects, err := listProjects(&c, client, org.ID, &projectName)
+	if len(projects) != 1 || err != nil {
+		return errs.NewExitError("Project not found")
+	}
+	project := projects[0]
+
+	fmt.Printf("Backup of %s/%s created %s\n\n", archive.Org, archive.Project,
+		archive.Created.Format(time.RFC3339))
+	err = writeRestorePlan(os.Stdout, batches)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("dry-run") {
+		fmt.Printf("\nDry run: %d secret version(s) would be restored to %s/%s.\n",
+			len(archive.Secrets), orgName, projectName)
+		return nil
+	}
+
+	preamble := fmt.Sprintf("You are about to restore %d secret version(s) to %s/%s.\n"+
+		"Each will be added as a new version on top of any existing secrets.",
+		len(archive.Secrets), orgName, projectName)
+	abortErr := ConfirmDialogue(ctx, nil, &preamble, "", true)
+	if abortErr != nil {
+		return abortErr
+	}
+
+	restored := 0
+	for _, batch := range batches {
+		creds := make([]*apitypes.CredentialEnvelope, 0, len(batch))
+		for _, secret := range batch {
+			var cred apitypes.Credential = &apitypes.CredentialV2{
+				BaseCredential: apitypes.BaseCredential{
+					OrgID:     org.ID,
+					ProjectID: project.ID,
+					Name:      secret.Name,
+					PathExp:   secret.PathExp,
+					Value:     secret.Value,
+				},
+				State: secret.State,
+			}
+			creds = append(creds, &apitypes.CredentialEnvelope{Version: 2, Body: &cred})
+		}
+
+		_, err = client.Credentials.Create(c, creds, progress)
+		if err != nil {
+			return errs.NewErrorExitError(fmt.Sprintf(
+				"Could not restore secrets at %s; %d secret version(s) were restored.",
+				batch[0].PathExp, restored), err)
+		}
+		restored += len(batch)
+	}
+
+	fmt.Printf("\n%d secret version(s) restored to %s/%s.\n", restored, orgName, projectName)
+	return nil
+}
+
+// restoreBatches moves the backed up secrets into the given org and project,
+// and groups them for appending. Each batch holds secrets for a single
+// pathexp, with at most one version of each secret. Versions of a secret are
+// placed in successive batches, oldest first.
+func restoreBatches(secrets []backupCredential, org, project string) ([][]backupCredential, error) {
+	moved := make([]backupCredential, 0, len(secrets))
+	for _, secret := range secrets {
+		pe, err := rebasePathExp(secret.PathExp, org, project)
+		if err != nil {
+			return nil, err
+		}
+
+		secret.PathExp = pe
+		if secret.State == "unset" {
+			secret.Value = nil
+		}
+		moved = append(moved, secret)
+	}
+
+	sort.Stable(backupCredentialSorter(moved))
+
+	var batches [][]backupCredential
+	var pathBatches [][]backupCredential
+	versions := make(map[string]int)
+	for i, secret := range moved {
+		if i > 0 && !moved[i-1].PathExp.Equal(secret.PathExp) {
+			batches = append(batches, pathBatches...)
+			pathBatches = nil
+			versions = make(map[string]int)
+		}
+
+		n := versions[secret.Name]
+		versions[secret.Name]++
+		if n == len(pathBatches) {
+			pathBatches = append(pathBatches, nil)
+		}
+		pathBatches[n] = append(pathBatches[n], secret)
+	}
+
+	return append(batches, pathBatches...), nil
+}
+
+// rebasePathExp returns the pathexp with its org and project replaced.
+func rebasePathExp(pe *pathexp.PathExp, org, project string) (*pathexp.PathExp, error) {
+	parts := strings.Split(pe.String(), "/")
+	if len(parts) != 7 {
+		return nil, fmt.Errorf("Invalid path expression in backup: %s", pe)
+	}
+
+	parts[1] = org
+	parts[2] = project
+	return pathexp.Parse(strings.Join(parts, "/"))
+}
+
+func writeRestorePlan(w io.Writer, batches [][]backupCredential) error {
+	type planned struct {
+		path     string
+		versions int
+		unset    bool
+	}
+
+	var order []string
+	plan := make(map[string]*planned)
+	for _, batch := range batches {
+		for _, secret := range batch {
+			path := secret.PathExp.String() + "/" + secret.Name
+			p, ok := plan[path]
+			if !ok {
+				p = &planned{path: path}
+				plan[path] = p
+				order = append(order, path)
+			}
+			p.versions++
+			p.unset = secret.State == "unset"
+		}
+	}
+
+	if len(order) == 0 {
+		fmt.Fprintln(w, "The backup contains no secrets.")
+		return nil
+	}
+
+	sort.Strings(order)
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "SECRET\tVERSIONS\tSTATE\n")
+	for _, path := range order {
+		p := plan[path]
+		state := "set"
+		if p.unset {
+			state = "unset"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", p.path, p.versions, state)
+	}
+
+	return tw.Flush()
+}
+
+type backupCredentialSorter []backupCredential
+
+func (s backupCredentialSorter) Len() int      { return len(s) }
+func (s backupCredentialSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s backupCredentialSorter) Less(i, j int) bool {
+	pi, pj := s[i].PathExp.String(), s[j].PathExp.String()
+	if pi != pj {
+		return pi < pj
+	}
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	return s[i].CredentialVersion < s[j].CredentialVersion
+}
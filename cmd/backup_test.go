@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+func backupSecret(t *testing.T, pe, name string, version int, value *apitypes.CredentialValue) backupCredential {
+	p, err := pathexp.Parse(pe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := "set"
+	if value == nil {
+		state = "unset"
+	}
+
+	return backupCredential{
+		PathExp:           p,
+		Name:              name,
+		State:             state,
+		Value:             value,
+		CredentialVersion: version,
+	}
+}
+
+func TestRestoreBatches(t *testing.T) {
+	secrets := []backupCredential{
+		backupSecret(t, "/o/p/prod/api/*/*", "db", 2, apitypes.NewStringCredentialValue("b")),
+		backupSecret(t, "/o/p/prod/api/*/*", "port", 1, apitypes.NewIntCredentialValue(80)),
+		backupSecret(t, "/o/p/dev/api/*/*", "db", 1, apitypes.NewStringCredentialValue("dev")),
+		backupSecret(t, "/o/p/prod/api/*/*", "db", 1, apitypes.NewStringCredentialValue("a")),
+		backupSecret(t, "/o/p/prod/api/*/*", "db", 3, nil),
+	}
+
+	batches, err := restoreBatches(secrets, "other", "proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type restored struct {
+		path  string
+		name  string
+		state string
+	}
+
+	expected := [][]restored{
+		{{"/other/proj/dev/api/*/*", "db", "set"}},
+		{{"/other/proj/prod/api/*/*", "db", "set"}, {"/other/proj/prod/api/*/*", "port", "set"}},
+		{{"/other/proj/prod/api/*/*", "db", "set"}},
+		{{"/other/proj/prod/api/*/*", "db", "unset"}},
+	}
+
+	if len(batches) != len(expected) {
+		t.Fatalf("Expected %d batches, got %d: %+v", len(expected), len(batches), batches)
+	}
+
+	for i, batch := range batches {
+		if len(batch) != len(expected[i]) {
+			t.Fatalf("Expected %d secrets in batch %d, got %d", len(expected[i]), i, len(batch))
+		}
+
+		for j, secret := range batch {
+			got := restored{secret.PathExp.String(), secret.Name, secret.State}
+			if got != expected[i][j] {
+				t.Errorf("Expected %+v in batch %d, got %+v", expected[i][j], i, got)
+			}
+		}
+	}
+
+	// Versions are restored oldest first
+	if batches[1][0].Value.String() != "a" || batches[2][0].Value.String() != "b" {
+		t.Error("Expected versions of db to be restored in order")
+	}
+
+	if batches[3][0].Value != nil {
+		t.Error("Expected unset secret to have no value")
+	}
+}
+
+func TestBackupArchiveRoundTrip(t *testing.T) {
+	archive := &backupArchive{
+		Type:    backupArchiveType,
+		Version: backupArchiveVersion,
+		Org:     "o",
+		Project: "p",
+		Secrets: []backupCredential{
+			backupSecret(t, "/o/p/prod/api/*/*", "port", 1, apitypes.NewIntCredentialValue(80)),
+			backupSecret(t, "/o/p/prod/api/*/*", "db", 2, nil),
+		},
+	}
+
+	b, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &backupArchive{}
+	err = json.Unmarshal(b, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := decoded.Secrets[0]
+	if port.Value == nil || port.Value.String() != "80" {
+		t.Errorf("Expected port value to survive a round trip, got %+v", port.Value)
+	}
+
+	raw, err := port.Value.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw.(int64); !ok {
+		t.Errorf("Expected port to remain a number, got %T", raw)
+	}
+
+	if decoded.Secrets[1].Value != nil || decoded.Secrets[1].State != "unset" {
+		t.Error("Expected unset secret to remain unset")
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+func init() {
+	compromise := cli.Command{
+		Name:      "compromise",
+		Usage:     "Require every secret under a path to be rotated, and replace its keys",
+		ArgsUsage: "<path>",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			stdAutoAcceptFlag,
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, compromiseCmd,
+		),
+	}
+
+	Cmds = append(Cmds, compromise)
+}
+
+func compromiseCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A path expression is required", ctx)
+	}
+
+	pe, err := pathexp.Parse(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Invalid path expression", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	preamble := fmt.Sprintf("You are about to mark every secret under %s as compromised.\n"+
+		"New keys will be created for every keyring holding secrets under this\n"+
+		"path, and each of their secrets will need to be rotated.", pe)
+	abortErr := ConfirmDialogue(ctx, nil, &preamble, "", true)
+	if abortErr != nil {
+		return abortErr
+	}
+
+	result, err := client.Credentials.Compromise(c, pe.String(), progress)
+	if err != nil {
+		return errs.NewErrorExitError("Error marking path as compromised", err)
+	}
+
+	return writeCompromiseResult(os.Stdout, result)
+}
+
+func writeCompromiseResult(w io.Writer, result *apitypes.CompromiseResult) error {
+	fmt.Fprintln(w)
+	if len(result.Keyrings) == 0 {
+		fmt.Fprintln(w, "No keyrings found under this path.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "KEYRING\tVERSION\n")
+	for _, k := range result.Keyrings {
+		fmt.Fprintf(tw, "%s\t%d\n", k.PathExp, k.KeyringVersion)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if len(result.Secrets) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+		fmt.Fprintf(tw, "MARKED FOR ROTATION\n")
+		for _, s := range result.Secrets {
+			fmt.Fprintf(tw, "%s\n", s.Path())
+		}
+		err = tw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d keyring(s) given new keys, %d secret(s) marked for rotation.\n",
+		len(result.Keyrings), len(result.Secrets))
+	fmt.Fprintln(w, "Run 'torus worklog list' to see the secrets that must be rotated.")
+	return nil
+}
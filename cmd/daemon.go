@@ -3,11 +3,12 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
-	"path"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/manifoldco/torus-cli/api"
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/logging"
 
 	"github.com/manifoldco/torus-cli/daemon"
 )
@@ -47,17 +49,38 @@ func init() {
 						Usage:  "Run as a background session daemon",
 						Hidden: true, // not displayed in help; used internally
 					},
+					cli.BoolFlag{
+						Name:  "read-only",
+						Usage: "Only serve requests that read secrets, for machines that never write",
+					},
 					cli.BoolFlag{
 						Name:   "no-permission-check",
 						Usage:  "Skip Torus root dir permission checks",
 						Hidden: true, // Just for system daemon use
 					},
+					cli.StringFlag{
+						Name:   "log-level",
+						Usage:  "Only log entries at `LEVEL` or above (debug, info, warn, error)",
+						Value:  "info",
+						EnvVar: "TORUS_LOG_LEVEL",
+					},
+					cli.StringFlag{
+						Name:   "log-format",
+						Usage:  "Write log entries in `FORMAT` (logfmt, json)",
+						Value:  "logfmt",
+						EnvVar: "TORUS_LOG_FORMAT",
+					},
+					cli.IntFlag{
+						Name:  "log-max-size",
+						Usage: "Rotate the log file once it reaches `MB` megabytes",
+						Value: 10,
+					},
 				},
 				Action: func(ctx *cli.Context) error {
 					if ctx.Bool("foreground") {
 						return startDaemon(ctx)
 					}
-					return spawnDaemonCmd()
+					return spawnDaemonCmd(ctx)
 				},
 			},
 			{
@@ -65,6 +88,60 @@ func init() {
 				Usage:  "Stop the session daemon",
 				Action: stopDaemonCmd,
 			},
+			{
+				Name:  "logs",
+				Usage: "Display the daemon's log",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "lines, n",
+						Usage: "Display the last `N` matching entries, or all if 0",
+						Value: 50,
+					},
+					cli.BoolFlag{
+						Name:  "follow, f",
+						Usage: "Keep displaying new entries as they are written",
+					},
+					cli.StringFlag{
+						Name:  "level",
+						Usage: "Only display entries at `LEVEL` or above (debug, info, warn, error)",
+						Value: "debug",
+					},
+				},
+				Action: daemonLogsCmd,
+			},
+			{
+				Name:   "metrics",
+				Usage:  "Display the daemon's metrics, in the Prometheus text format",
+				Action: chain(ensureDaemon, daemonMetricsCmd),
+			},
+			{
+				Name:  "audit",
+				Usage: "Display the log of operations performed through the daemon",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "tail, n",
+						Usage: "Display the last `N` matching entries, or all if 0",
+						Value: 20,
+					},
+					newPlaceholder("route", "PREFIX", "Only display routes starting with PREFIX", "", "", false),
+					newPlaceholder("pathexp", "TEXT", "Only display paths containing TEXT", "", "", false),
+					cli.IntFlag{
+						Name:  "uid",
+						Usage: "Only display operations by the user with `UID`",
+						Value: -1,
+					},
+					cli.DurationFlag{
+						Name:  "since",
+						Usage: "Only display operations in the last `DURATION` (e.g. 24h)",
+					},
+					cli.BoolFlag{
+						Name:  "verify",
+						Usage: "Verify the log has not been altered, instead of displaying it",
+					},
+					formatFlag("table", "Format used to display data (table, json)"),
+				},
+				Action: daemonAuditCmd,
+			},
 		},
 	}
 	Cmds = append(Cmds, daemon)
@@ -97,7 +174,23 @@ func daemonStatus(ctx *cli.Context) error {
 	return nil
 }
 
-func spawnDaemonCmd() error {
+func daemonMetricsCmd(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	m, err := client.Metrics.Get(context.Background())
+	if err != nil {
+		return errs.NewErrorExitError("Error fetching daemon metrics", err)
+	}
+
+	_, err = os.Stdout.Write(m)
+	return err
+}
+
+func spawnDaemonCmd(ctx *cli.Context) error {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return err
@@ -113,7 +206,16 @@ func spawnDaemonCmd() error {
 		return nil
 	}
 
-	err = spawnDaemon()
+	args := []string{
+		"--log-level", ctx.String("log-level"),
+		"--log-format", ctx.String("log-format"),
+		"--log-max-size", strconv.Itoa(ctx.Int("log-max-size")),
+	}
+	if ctx.Bool("read-only") {
+		args = append(args, "--read-only")
+	}
+
+	err = spawnDaemon(args...)
 	if err != nil {
 		return err
 	}
@@ -122,13 +224,13 @@ func spawnDaemonCmd() error {
 	return nil
 }
 
-func spawnDaemon() error {
+func spawnDaemon(extraArgs ...string) error {
 	executable, err := osext.Executable()
 	if err != nil {
 		return errs.NewErrorExitError("Unable to find executable.", err)
 	}
 
-	cmd := daemonCommand(executable)
+	cmd := daemonCommand(executable, extraArgs...)
 
 	// Clone the current env, removing email and password if they exist.
 	// no need to keep those hanging around in a long lived-process!
@@ -149,16 +251,14 @@ func startDaemon(ctx *cli.Context) error {
 		return errs.NewErrorExitError("Failed to initialize Torus root dir.", err)
 	}
 
-	if ctx.Bool("daemonize") {
-		log.SetOutput(&lumberjack.Logger{
-			Filename:   path.Join(torusRoot, "daemon.log"),
-			MaxSize:    10, // megabytes
-			MaxBackups: 3,
-			MaxAge:     28, // days
-		})
-	} else {
-		// re-enable logging, as by default its silenced for foreground use.
-		log.SetOutput(os.Stdout)
+	level, err := logging.ParseLevel(ctx.String("log-level"))
+	if err != nil {
+		return errs.NewUsageExitError("Unknown log level: "+ctx.String("log-level"), ctx)
+	}
+
+	format, err := logging.ParseFormat(ctx.String("log-format"))
+	if err != nil {
+		return errs.NewUsageExitError("Unknown log format: "+ctx.String("log-format"), ctx)
 	}
 
 	cfg, err := config.NewConfig(torusRoot)
@@ -166,7 +266,26 @@ func startDaemon(ctx *cli.Context) error {
 		return errs.NewErrorExitError("Failed to load config.", err)
 	}
 
-	daemon, err := daemon.New(cfg, noPermissionCheck)
+	// re-enable logging, as by default its silenced for foreground use.
+	var out io.Writer = os.Stdout
+	if ctx.Bool("daemonize") {
+		out = &lumberjack.Logger{
+			Filename:   cfg.DaemonLogPath,
+			MaxSize:    ctx.Int("log-max-size"), // megabytes
+			MaxBackups: 3,
+			MaxAge:     28, // days
+		}
+	}
+
+	logger := logging.New(out, level, format)
+	logging.SetDefault(logger)
+
+	// Anything still logging through the standard library is written as an
+	// info entry.
+	log.SetFlags(0)
+	log.SetOutput(logger.StdWriter(logging.InfoLevel))
+
+	daemon, err := daemon.New(cfg, noPermissionCheck, ctx.Bool("read-only"))
 	if err != nil {
 		return errs.NewErrorExitError("Failed to create daemon.", err)
 	}
@@ -174,10 +293,10 @@ func startDaemon(ctx *cli.Context) error {
 	go watch(daemon)
 	defer daemon.Shutdown()
 
-	log.Printf("v%s of the Daemon is now listening on %s", cfg.Version, daemon.Addr())
+	logging.Default().Infof("v%s of the Daemon is now listening on %s", cfg.Version, daemon.Addr())
 	err = daemon.Run()
 	if err != nil {
-		log.Printf("Error while running daemon.\n%s", err)
+		logging.Default().Errorf("Error while running daemon: %s", err)
 	}
 
 	return err
@@ -188,18 +307,18 @@ func watch(daemon *daemon.Daemon) {
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	s := <-c
 
-	log.Printf("Caught a signal: %s", s)
+	logging.Default().Infof("Caught a signal: %s", s)
 	shutdown(daemon)
 }
 
 func shutdown(daemon *daemon.Daemon) {
 	err := daemon.Shutdown()
 	if err != nil {
-		log.Printf("Did not shutdown cleanly.\n%s", err)
+		logging.Default().Errorf("Did not shutdown cleanly: %s", err)
 	}
 
 	if r := recover(); r != nil {
-		log.Printf("Failed shutting down; caught panic.\n%v", r)
+		logging.Default().Errorf("Failed shutting down; caught panic: %v", r)
 		panic(r)
 	}
 }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+
+	"github.com/manifoldco/torus-cli/daemon/audit"
+)
+
+// daemonAuditFilter selects entries from the daemon's audit log.
+type daemonAuditFilter struct {
+	route   string
+	pathexp string
+	uid     int // -1 matches any user
+	since   time.Time
+	tail    int // 0 keeps every matching entry
+}
+
+func (f *daemonAuditFilter) matches(e *audit.Entry) bool {
+	if f.route != "" && !strings.HasPrefix(e.Route, f.route) {
+		return false
+	}
+	if f.pathexp != "" && !strings.Contains(e.PathExp, f.pathexp) {
+		return false
+	}
+	if f.uid >= 0 && (e.Peer == nil || e.Peer.UID != f.uid) {
+		return false
+	}
+	if !f.since.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+	return true
+}
+
+// apply returns the matching entries, keeping only the last f.tail of them.
+func (f *daemonAuditFilter) apply(entries []audit.Entry) []audit.Entry {
+	matched := []audit.Entry{}
+	for i := range entries {
+		if f.matches(&entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+
+	if f.tail > 0 && len(matched) > f.tail {
+		matched = matched[len(matched)-f.tail:]
+	}
+	return matched
+}
+
+func daemonAuditCmd(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	if ctx.Int("tail") < 0 {
+		return errs.NewUsageExitError("--tail must not be negative", ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The log is read directly, so that it can be inspected even when the
+	// daemon is not running.
+	entries, malformed, err := audit.ReadFile(cfg.AuditLogPath)
+	if os.IsNotExist(err) {
+		return errs.NewExitError("No audit log found at " + cfg.AuditLogPath + ".")
+	}
+	if err != nil {
+		return errs.NewErrorExitError("Could not read audit log.", err)
+	}
+
+	if ctx.Bool("verify") {
+		return verifyDaemonAudit(os.Stdout, entries, malformed)
+	}
+
+	filter := daemonAuditFilter{
+		route:   ctx.String("route"),
+		pathexp: ctx.String("pathexp"),
+		uid:     ctx.Int("uid"),
+		tail:    ctx.Int("tail"),
+	}
+	if since := ctx.Duration("since"); since > 0 {
+		filter.since = time.Now().Add(-since)
+	}
+
+	entries = filter.apply(entries)
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	return writeDaemonAuditTable(os.Stdout, entries)
+}
+
+func verifyDaemonAudit(w io.Writer, entries []audit.Entry, malformed []int) error {
+	problems := audit.Verify(entries)
+
+	for _, line := range malformed {
+		fmt.Fprintf(w, "Line %d could not be read\n", line)
+	}
+	for _, p := range problems {
+		fmt.Fprintf(w, "Entry %d: %s\n", p.Seq, p.Message)
+	}
+
+	if len(problems) > 0 || len(malformed) > 0 {
+		return errs.NewExitError(fmt.Sprintf(
+			"Audit log failed verification with %d problem(s).", len(problems)+len(malformed)))
+	}
+
+	fmt.Fprintf(w, "Verified %d audit log entries.\n", len(entries))
+	return nil
+}
+
+func writeDaemonAuditTable(w io.Writer, entries []audit.Entry) error {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No audit log entries found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "TIME\tUID\tPID\tEXE\tMETHOD\tROUTE\tPATHEXP\tNAMES\tOUTCOME\n")
+	for i := range entries {
+		e := &entries[i]
+
+		uid, pid, exe := "-", "-", "-"
+		if e.Peer != nil {
+			uid = strconv.Itoa(e.Peer.UID)
+			pid = strconv.Itoa(e.Peer.PID)
+			if e.Peer.Exe != "" {
+				exe = e.Peer.Exe
+			}
+		}
+
+		pathexp, names := e.PathExp, strings.Join(e.Names, ",")
+		if pathexp == "" {
+			pathexp = "-"
+		}
+		if names == "" {
+			names = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Local().Format(time.RFC3339), uid, pid, exe, e.Method, e.Route,
+			pathexp, names, e.Outcome)
+	}
+
+	return tw.Flush()
+}
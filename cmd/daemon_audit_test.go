@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manifoldco/torus-cli/daemon/audit"
+	"github.com/manifoldco/torus-cli/daemon/peer"
+)
+
+func TestDaemonAuditFilter(t *testing.T) {
+	now := time.Now()
+	entries := []audit.Entry{
+		{Seq: 1, Time: now.Add(-48 * time.Hour), Peer: &peer.Peer{UID: 1}, Route: "/credentials", PathExp: "/o/p/prod/*/*/*"},
+		{Seq: 2, Time: now.Add(-time.Hour), Peer: &peer.Peer{UID: 2}, Route: "/keypairs/box"},
+		{Seq: 3, Time: now, Route: "/credentials", PathExp: "/o/p/dev/*/*/*"},
+		{Seq: 4, Time: now, Peer: &peer.Peer{UID: 1}, Route: "/worklog"},
+	}
+
+	tcs := []struct {
+		name   string
+		filter daemonAuditFilter
+		seqs   []uint64
+	}{
+		{"all", daemonAuditFilter{uid: -1}, []uint64{1, 2, 3, 4}},
+		{"tail", daemonAuditFilter{uid: -1, tail: 2}, []uint64{3, 4}},
+		{"route", daemonAuditFilter{uid: -1, route: "/credentials"}, []uint64{1, 3}},
+		{"pathexp", daemonAuditFilter{uid: -1, pathexp: "prod"}, []uint64{1}},
+		{"uid", daemonAuditFilter{uid: 1}, []uint64{1, 4}},
+		{"since", daemonAuditFilter{uid: -1, since: now.Add(-2 * time.Hour)}, []uint64{2, 3, 4}},
+		{"tail after filter", daemonAuditFilter{uid: 1, tail: 1}, []uint64{4}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.filter.apply(entries)
+			if len(got) != len(tc.seqs) {
+				t.Fatalf("Expected %d entries, got %d", len(tc.seqs), len(got))
+			}
+			for i, e := range got {
+				if e.Seq != tc.seqs[i] {
+					t.Errorf("Expected entry %d, got %d", tc.seqs[i], e.Seq)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+const daemonLogPollInterval = 500 * time.Millisecond
+
+func daemonLogsCmd(ctx *cli.Context) error {
+	level, err := logging.ParseLevel(ctx.String("level"))
+	if err != nil {
+		return errs.NewUsageExitError("Unknown log level: "+ctx.String("level"), ctx)
+	}
+
+	if ctx.Int("lines") < 0 {
+		return errs.NewUsageExitError("--lines must not be negative", ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The log is read directly, so that it can be inspected even when the
+	// daemon is not running.
+	follower := &daemonLogFollower{path: cfg.DaemonLogPath, level: level}
+	defer follower.Close()
+
+	lines, err := follower.poll()
+	if err != nil {
+		return errs.NewErrorExitError("Could not read daemon log.", err)
+	}
+	if lines == nil && !ctx.Bool("follow") {
+		return errs.NewExitError("No daemon log found at " + cfg.DaemonLogPath + ".")
+	}
+
+	if n := ctx.Int("lines"); n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	writeDaemonLogLines(os.Stdout, lines)
+
+	for ctx.Bool("follow") {
+		time.Sleep(daemonLogPollInterval)
+
+		lines, err = follower.poll()
+		if err != nil {
+			return errs.NewErrorExitError("Could not read daemon log.", err)
+		}
+		writeDaemonLogLines(os.Stdout, lines)
+	}
+
+	return nil
+}
+
+func writeDaemonLogLines(w io.Writer, lines []string) {
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// daemonLogFollower reads the lines added to the daemon's log since it was
+// last polled. When the log is rotated or truncated, it starts again from the
+// beginning of the new file.
+type daemonLogFollower struct {
+	path  string
+	level logging.Level
+
+	f       *os.File
+	fi      os.FileInfo
+	offset  int64
+	partial string // an incomplete line, held until it is finished
+}
+
+// poll returns the complete lines at or above the follower's level written
+// since the last poll. It returns nil if the log does not exist.
+func (d *daemonLogFollower) poll() ([]string, error) {
+	fi, err := os.Stat(d.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if d.f == nil || !os.SameFile(fi, d.fi) || fi.Size() < d.offset {
+		d.Close()
+
+		d.f, err = os.Open(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.fi = fi
+		d.offset = 0
+		d.partial = ""
+	}
+
+	buf := make([]byte, fi.Size()-d.offset)
+	n, err := d.f.ReadAt(buf, d.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	d.offset += int64(n)
+
+	text := d.partial + string(buf[:n])
+	end := strings.LastIndex(text, "\n")
+	d.partial = text[end+1:]
+
+	lines := []string{}
+	if end < 0 {
+		return lines, nil
+	}
+
+	for _, line := range strings.Split(text[:end], "\n") {
+		if logging.ParseLine(line).Level >= d.level {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Close closes the log file, if it is open.
+func (d *daemonLogFollower) Close() error {
+	if d.f == nil {
+		return nil
+	}
+
+	err := d.f.Close()
+	d.f = nil
+	return err
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+func TestDaemonLogFollower(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-daemon-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := filepath.Join(dir, "daemon.log")
+	d := &daemonLogFollower{path: logPath, level: logging.WarnLevel}
+	defer d.Close()
+
+	poll := func(expected ...string) {
+		lines, err := d.poll()
+		if err != nil {
+			t.Fatalf("poll failed: %s", err)
+		}
+		if len(expected) == 0 && len(lines) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("expected %q, got %q", expected, lines)
+		}
+	}
+
+	write := func(flag int, s string) {
+		f, err := os.OpenFile(logPath, flag|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("missing log", func(t *testing.T) {
+		lines, err := d.poll()
+		if err != nil || lines != nil {
+			t.Fatalf("expected no lines or error, got %q, %v", lines, err)
+		}
+	})
+
+	t.Run("filters by level", func(t *testing.T) {
+		write(os.O_APPEND, "level=info msg=a\nlevel=error msg=b\n{\"level\":\"warn\",\"msg\":\"c\"}\n")
+		poll("level=error msg=b", `{"level":"warn","msg":"c"}`)
+	})
+
+	t.Run("holds partial lines", func(t *testing.T) {
+		write(os.O_APPEND, "level=error msg=")
+		poll()
+		write(os.O_APPEND, "d\n")
+		poll("level=error msg=d")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		write(os.O_TRUNC, "level=warn msg=e\n")
+		poll("level=warn msg=e")
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("open files can't be renamed on Windows")
+		}
+
+		if err := os.Rename(logPath, logPath+".1"); err != nil {
+			t.Fatal(err)
+		}
+		write(os.O_APPEND, "level=error msg=f\nlevel=error msg=g\n")
+		poll("level=error msg=f", "level=error msg=g")
+	})
+}
@@ -49,6 +49,16 @@ func init() {
 		},
 		Action: chain(ensureDaemon, loadDirPrefs, loadPrefDefaults, setUserEnv, debugInfoCmd),
 		Hidden: true,
+		Subcommands: []cli.Command{
+			{
+				Name:  "bundle",
+				Usage: "Write a tarball of diagnostic information, free of secrets, for support",
+				Flags: []cli.Flag{
+					newPlaceholder("output, o", "FILE", "Write the bundle to FILE (default: torus-debug-TIMESTAMP.tar.gz)", "", "", false),
+				},
+				Action: debugBundleCmd,
+			},
+		},
 	}
 	Cmds = append(Cmds, version)
 }
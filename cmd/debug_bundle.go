@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/dirprefs"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/prefs"
+)
+
+// debugBundleLogLines is the number of recent daemon log lines included in a
+// debug bundle.
+const debugBundleLogLines = 1000
+
+func debugBundleCmd(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := "torus-debug-" + now.UTC().Format("20060102T150405Z")
+
+	output := ctx.String("output")
+	if output == "" {
+		output = name + ".tar.gz"
+	}
+
+	b := newDebugBundle(name)
+	collectDebugBundle(context.Background(), cfg, b, now)
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errs.NewErrorExitError("Could not create debug bundle.", err)
+	}
+	defer f.Close()
+
+	err = b.write(f)
+	if err != nil {
+		return errs.NewErrorExitError("Could not write debug bundle.", err)
+	}
+
+	fmt.Printf("Debug bundle written to %s\n", output)
+	fmt.Println("It contains no secrets or credentials, but review it before sharing it with support.")
+	return nil
+}
+
+// collectDebugBundle adds everything useful for diagnosing problems to b.
+// Problems collecting any part are recorded in the bundle, rather than
+// stopping collection, as the bundle is most needed when things are broken.
+func collectDebugBundle(c context.Context, cfg *config.Config, b *debugBundle, now time.Time) {
+	versions := map[string]string{"cli": cfg.Version}
+	b.addJSON("system.json", map[string]interface{}{
+		"timestamp":  now.UTC(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"go_version": runtime.Version(),
+		"num_cpu":    runtime.NumCPU(),
+	})
+
+	if rcPath, err := prefs.RcPath(); err == nil {
+		b.addRedactedIni("torusrc", rcPath)
+	} else {
+		b.addError("torusrc", err)
+	}
+
+	if dp, err := dirprefs.Load(true); err != nil {
+		b.addError("torus.json", err)
+	} else if dp.Path != "" {
+		b.addRedactedJSONFile("torus.json", dp.Path)
+	}
+
+	b.addEnv("environment.json", os.Environ())
+
+	daemonStatus := map[string]interface{}{
+		"torus_root": describeFile(cfg.TorusRoot),
+		"socket":     describeFile(cfg.TransportAddress),
+		"db":         describeFile(cfg.DBPath),
+		"running":    false,
+	}
+
+	proc, err := findDaemon(cfg)
+	if err != nil {
+		b.addError("daemon.json", err)
+	}
+
+	if proc != nil {
+		daemonStatus["running"] = true
+		daemonStatus["pid"] = proc.Pid
+
+		client := api.NewClient(cfg)
+		collectDaemonDebug(c, client, b, daemonStatus, versions)
+	}
+	b.addJSON("daemon.json", daemonStatus)
+
+	if lastCheck, err := ioutil.ReadFile(cfg.LastUpdatePath); err == nil {
+		b.add("last_update", lastCheck)
+	} else if !os.IsNotExist(err) {
+		b.addError("last_update", err)
+	}
+
+	b.addLog("daemon.log", cfg.DaemonLogPath, debugBundleLogLines)
+
+	b.addJSON("registry.json", checkRegistry(c, cfg))
+	b.addJSON("version.json", versions)
+}
+
+// collectDaemonDebug adds the state of a running daemon to b.
+func collectDaemonDebug(c context.Context, client *api.Client, b *debugBundle,
+	status map[string]interface{}, versions map[string]string) {
+
+	if v, err := client.Version.GetDaemon(c); err == nil {
+		versions["daemon"] = v.Version
+	} else {
+		b.addError("version.json", err)
+	}
+
+	if v, err := client.Version.Get(c); err == nil {
+		versions["registry"] = v.Version
+	} else {
+		b.addError("version.json", err)
+	}
+
+	if s, err := client.Session.Get(c); err == nil {
+		status["logged_in"] = s.Token
+		status["read_only"] = s.ReadOnly
+
+		if s.Token {
+			if who, err := client.Session.Who(c); err == nil {
+				status["session_type"] = who.Type()
+			} else {
+				b.addError("daemon.json", err)
+			}
+		}
+	} else {
+		b.addError("daemon.json", err)
+	}
+
+	debug, err := client.Debug.Get(c)
+	if err != nil {
+		b.addError("daemon_debug", err)
+		return
+	}
+
+	b.addJSON("updates.json", map[string]interface{}{
+		"last_check":   debug.LastUpdateCheck,
+		"needs_update": debug.Update.NeedsUpdate,
+		"version":      debug.Update.Version,
+	})
+	b.addJSON("events.json", debug.Events)
+	b.addJSON("db.json", debug.Buckets)
+}
+
+// fileDescription is the mode and ownership of a file in a debug bundle.
+type fileDescription struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	Mode   string `json:"mode,omitempty"`
+	UID    *int   `json:"uid,omitempty"`
+	GID    *int   `json:"gid,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	perm os.FileMode
+}
+
+func describeFile(name string) *fileDescription {
+	d := &fileDescription{Path: name}
+
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return d
+	}
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	d.Exists = true
+	d.Mode = fi.Mode().String()
+	d.perm = fi.Mode().Perm()
+	if uid, gid, ok := fileOwner(fi); ok {
+		d.UID = &uid
+		d.GID = &gid
+	}
+	return d
+}
+
+// debugBundle is a tarball of diagnostic information. Everything added to it
+// must be free of secrets; the add methods for files that could hold secrets
+// redact them.
+type debugBundle struct {
+	dir    string
+	files  []debugBundleFile
+	errors map[string][]string
+}
+
+type debugBundleFile struct {
+	name string
+	data []byte
+}
+
+func newDebugBundle(dir string) *debugBundle {
+	return &debugBundle{dir: dir, errors: make(map[string][]string)}
+}
+
+// add adds the file to the bundle as is.
+func (b *debugBundle) add(name string, data []byte) {
+	b.files = append(b.files, debugBundleFile{name: name, data: data})
+}
+
+func (b *debugBundle) addJSON(name string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+
+	b.add(name, append(data, '\n'))
+}
+
+// addError records a problem collecting the named part of the bundle.
+func (b *debugBundle) addError(name string, err error) {
+	b.errors[name] = append(b.errors[name], logging.RedactLine(err.Error()))
+}
+
+var iniField = regexp.MustCompile(`^(\s*)([^=;#\[\s]+)(\s*=\s*).*$`)
+
+// addRedactedIni adds the ini file at p, such as the .torusrc, with the
+// values of sensitive keys redacted.
+func (b *debugBundle) addRedactedIni(name, p string) {
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		m := iniField.FindStringSubmatch(line)
+		if m != nil && logging.Sensitive(m[2]) {
+			lines[i] = m[1] + m[2] + m[3] + logging.Redacted
+		}
+	}
+
+	b.add(name, []byte(strings.Join(lines, "\n")))
+}
+
+// addRedactedJSONFile adds the JSON object at p, such as a .torus.json, with
+// the values of sensitive keys redacted.
+func (b *debugBundle) addRedactedJSONFile(name, p string) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		// The file's contents can't be redacted, so they aren't included.
+		b.addError(name, err)
+		return
+	}
+
+	logging.RedactMap(obj)
+	b.addJSON(name, map[string]interface{}{"path": p, "contents": obj})
+}
+
+// addEnv adds the TORUS_ environment variables, with the values of sensitive
+// variables redacted.
+func (b *debugBundle) addEnv(name string, environ []string) {
+	env := map[string]interface{}{}
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "TORUS_") {
+			continue
+		}
+
+		if isFilteredEnv(kv) || logging.Sensitive(parts[0]) {
+			env[parts[0]] = logging.Redacted
+		} else {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	b.addJSON(name, env)
+}
+
+// addLog adds the last n lines of the log at p, redacting any sensitive
+// values written by older versions of the daemon.
+func (b *debugBundle) addLog(name, p string, n int) {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		b.addError(name, err)
+		return
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, logging.RedactLine(scanner.Text()))
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		b.addError(name, err)
+	}
+
+	b.add(name, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// write writes the bundle to w as a gzipped tarball.
+func (b *debugBundle) write(w io.Writer) error {
+	files := b.files
+	if len(b.errors) > 0 {
+		data, err := json.MarshalIndent(b.errors, "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append(files, debugBundleFile{name: "errors.json", data: data})
+	}
+
+	sort.Sort(debugBundleFiles(files))
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	now := time.Now()
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    path.Join(b.dir, f.name),
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type debugBundleFiles []debugBundleFile
+
+func (f debugBundleFiles) Len() int           { return len(f) }
+func (f debugBundleFiles) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f debugBundleFiles) Less(i, j int) bool { return f[i].name < f[j].name }
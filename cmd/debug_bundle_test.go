@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readDebugBundle(t *testing.T, b *debugBundle) map[string]string {
+	buf := &bytes.Buffer{}
+	if err := b.write(buf); err != nil {
+		t.Fatalf("write failed: %s", err)
+	}
+
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(data)
+	}
+	return files
+}
+
+func TestDebugBundleRedactsSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-debug-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secrets := []string{
+		"rc-token-value", "rc-password", "dir-secret", "env-password",
+		"env-token-secret", "env-email@example.com", "log-passphrase",
+		"log-bearer-token", "log-value", "json-log-token", "error-token",
+	}
+
+	write := func(name, contents string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	rc := write(".torusrc", "[core]\nregistry_uri = https://registry.torus.sh\n"+
+		"auth_token = rc-token-value\n  password=rc-password\n\n[defaults]\norg = myorg\n")
+	dirPrefs := write(".torus.json", `{"org":"myorg","project":"proj","secret":"dir-secret"}`)
+	log := write("daemon.log", strings.Join([]string{
+		"2017/01/02 03:04:05 Attempting to login with passphrase=log-passphrase",
+		"2017/01/02 03:04:05 Authorization: Bearer log-bearer-token",
+		`time=2017-01-02T03:04:05Z level=info msg=set value="log-value"`,
+		`{"level":"info","msg":"x","token":"json-log-token"}`,
+		"time=2017-01-02T03:04:05Z level=info msg=kept",
+	}, "\n")+"\n")
+
+	b := newDebugBundle("bundle")
+	b.addRedactedIni("torusrc", rc)
+	b.addRedactedJSONFile("torus.json", dirPrefs)
+	b.addEnv("environment.json", []string{
+		"TORUS_PASSWORD=env-password",
+		"TORUS_TOKEN_SECRET=env-token-secret",
+		"TORUS_EMAIL=env-email@example.com",
+		"TORUS_ORG=myorg",
+		"HOME=/home/jeff",
+	})
+	b.addLog("daemon.log", log, 100)
+	b.addError("daemon.json", errors.New("Authorization: Bearer error-token"))
+
+	files := readDebugBundle(t, b)
+
+	for name, contents := range files {
+		for _, secret := range secrets {
+			if strings.Contains(contents, secret) {
+				t.Errorf("%s contains secret %q:\n%s", name, secret, contents)
+			}
+		}
+	}
+
+	expected := map[string]string{
+		"bundle/torusrc":          "registry_uri = https://registry.torus.sh",
+		"bundle/torus.json":       `"project": "proj"`,
+		"bundle/environment.json": `"TORUS_ORG": "myorg"`,
+		"bundle/daemon.log":       "msg=kept",
+		"bundle/errors.json":      "daemon.json",
+	}
+	for name, substr := range expected {
+		if !strings.Contains(files[name], substr) {
+			t.Errorf("expected %s to contain %q, got:\n%s", name, substr, files[name])
+		}
+	}
+
+	if strings.Contains(files["bundle/environment.json"], "HOME") {
+		t.Error("expected only TORUS_ environment variables")
+	}
+}
+
+func TestDebugBundleLogTail(t *testing.T) {
+	f, err := ioutil.TempFile("", "torus-debug-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("one\ntwo\nthree\n")
+	f.Close()
+
+	b := newDebugBundle("bundle")
+	b.addLog("daemon.log", f.Name(), 2)
+
+	if got := readDebugBundle(t, b)["bundle/daemon.log"]; got != "two\nthree\n" {
+		t.Errorf("expected the last two lines, got %q", got)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	sent := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	received := sent.Add(200 * time.Millisecond)
+
+	tcs := []struct {
+		date     time.Time
+		expected time.Duration
+	}{
+		{sent, 0},
+		{sent.Add(90 * time.Second), 90 * time.Second},
+		{sent.Add(-5 * time.Minute), -5 * time.Minute},
+	}
+
+	for _, tc := range tcs {
+		if skew := clockSkew(tc.date, sent, received); skew != tc.expected {
+			t.Errorf("expected skew of %s, got %s", tc.expected, skew)
+		}
+	}
+}
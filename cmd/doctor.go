@@ -0,0 +1,608 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/dirprefs"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/promptui"
+)
+
+func init() {
+	doctor := cli.Command{
+		Name:     "doctor",
+		Usage:    "Check your environment for problems, and how to fix them",
+		Category: "SYSTEM",
+		Flags: []cli.Flag{
+			formatFlag("table", "Format used to display data (table, json)"),
+		},
+		Action: doctorCmd,
+	}
+	Cmds = append(Cmds, doctor)
+}
+
+type doctorStatus string
+
+const (
+	doctorPass doctorStatus = "pass"
+	doctorWarn doctorStatus = "warn"
+	doctorFail doctorStatus = "fail"
+	doctorSkip doctorStatus = "skip"
+)
+
+var (
+	green = promptui.Styler(promptui.FGGreen)
+	red   = promptui.Styler(promptui.FGRed)
+)
+
+// label returns the status, colored for display.
+func (s doctorStatus) label() string {
+	label := strings.ToUpper(string(s))
+	switch s {
+	case doctorPass:
+		return green(label)
+	case doctorWarn:
+		return yellow(label)
+	case doctorFail:
+		return red(label)
+	default:
+		return faint(label)
+	}
+}
+
+// doctorResult is the outcome of a single check, with a hint on how to fix
+// any problem found.
+type doctorResult struct {
+	Name    string       `json:"name"`
+	Status  doctorStatus `json:"status"`
+	Message string       `json:"message"`
+	Hint    string       `json:"hint,omitempty"`
+}
+
+// doctorState is shared between checks, so later checks can build on, or be
+// skipped because of, the results of earlier ones.
+type doctorState struct {
+	c      context.Context
+	cfg    *config.Config
+	client *api.Client
+
+	daemonUp bool
+	loggedIn bool
+	registry *registryCheck
+	orgs     []envelope.Org
+}
+
+type doctorCheck struct {
+	name string
+	run  func(*doctorState) doctorResult
+}
+
+var doctorChecks = []doctorCheck{
+	{"Daemon", doctorDaemon},
+	{"Torus root", doctorTorusRoot},
+	{"Daemon socket", doctorSocket},
+	{"Registry", doctorRegistry},
+	{"Clock", doctorClock},
+	{"Session", doctorSession},
+	{"Keypairs", doctorKeypairs},
+	{"Worklog", doctorWorklog},
+	{"CLI version", doctorVersion},
+	{"Environment", doctorEnv},
+}
+
+func doctorCmd(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	state := &doctorState{
+		c:      context.Background(),
+		cfg:    cfg,
+		client: api.NewClient(cfg),
+	}
+
+	results := make([]doctorResult, len(doctorChecks))
+	for i, check := range doctorChecks {
+		results[i] = check.run(state)
+		results[i].Name = check.name
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		writeDoctorResults(os.Stdout, results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == doctorFail {
+			failed++
+		}
+	}
+
+	switch failed {
+	case 0:
+		return nil
+	case 1:
+		return errs.NewExitError("1 check failed.")
+	default:
+		return errs.NewExitError(fmt.Sprintf("%d checks failed.", failed))
+	}
+}
+
+func writeDoctorResults(out io.Writer, results []doctorResult) {
+	// The table is aligned before it is colored, as the escape codes of the
+	// colors differ in length.
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(string(r.Status)), r.Name, r.Message)
+		if r.Hint != "" && r.Status != doctorPass {
+			fmt.Fprintf(w, "\t\t%s\n", r.Hint)
+		}
+	}
+	w.Flush()
+
+	i := 0
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") {
+			fmt.Fprint(out, faint(strings.TrimRight(line, "\n"))+"\n")
+			continue
+		}
+
+		label := strings.ToUpper(string(results[i].Status))
+		fmt.Fprint(out, results[i].Status.label()+strings.TrimPrefix(line, label))
+		i++
+	}
+}
+
+func doctorDaemon(s *doctorState) doctorResult {
+	restart := "Restart the daemon with `torus daemon stop` and `torus daemon start`"
+
+	proc, err := findDaemon(s.cfg)
+	if err != nil {
+		return doctorResult{Status: doctorFail, Message: "Could not find the daemon: " + err.Error(),
+			Hint: "Check for a stale pid file at " + s.cfg.PidPath}
+	}
+	if proc == nil {
+		return doctorResult{Status: doctorFail, Message: "The daemon is not running",
+			Hint: "Start the daemon with `torus daemon start`"}
+	}
+
+	v, err := s.client.Version.GetDaemon(s.c)
+	if err != nil {
+		return doctorResult{Status: doctorFail,
+			Message: "Could not communicate with the daemon: " + err.Error(), Hint: restart}
+	}
+	s.daemonUp = true
+
+	if v.Version != s.cfg.Version {
+		return doctorResult{Status: doctorFail,
+			Message: fmt.Sprintf("The daemon is v%s, but the CLI is v%s", v.Version, s.cfg.Version),
+			Hint:    restart}
+	}
+
+	return doctorResult{Status: doctorPass,
+		Message: fmt.Sprintf("v%s is running, pid %d", v.Version, proc.Pid)}
+}
+
+func doctorTorusRoot(s *doctorState) doctorResult {
+	return checkFilePermissions(describeFile(s.cfg.TorusRoot), os.Getuid(), 0700)
+}
+
+func doctorSocket(s *doctorState) doctorResult {
+	if runtime.GOOS == "windows" {
+		return doctorResult{Status: doctorSkip, Message: "The daemon uses a named pipe on Windows"}
+	}
+
+	d := describeFile(s.cfg.TransportAddress)
+	if !d.Exists && !s.daemonUp {
+		return doctorResult{Status: doctorSkip, Message: "The daemon is not running"}
+	}
+
+	// The socket is shared with its group when the daemon is started as a
+	// system daemon, possibly by another user.
+	if d.UID != nil && *d.UID != os.Getuid() && d.perm == 0760 {
+		return doctorResult{Status: doctorPass,
+			Message: fmt.Sprintf("Shared with its group by uid %d", *d.UID)}
+	}
+
+	r := checkFilePermissions(d, os.Getuid(), 0700, 0760)
+	if r.Status != doctorPass || d.perm != 0760 {
+		return r
+	}
+
+	// A socket shared with its group should have a policy limiting what
+	// the group's members can do.
+	if _, err := os.Stat(s.cfg.PeerPolicyPath); os.IsNotExist(err) {
+		return doctorResult{Status: doctorWarn,
+			Message: "The socket is shared with its group, but no peer policy is set",
+			Hint:    "Write a peer policy to " + s.cfg.PeerPolicyPath}
+	}
+
+	r.Message = "Shared with its group, limited by a peer policy"
+	return r
+}
+
+// checkFilePermissions checks that a file is owned by uid, and has one of the
+// allowed permissions.
+func checkFilePermissions(d *fileDescription, uid int, allowed ...os.FileMode) doctorResult {
+	if runtime.GOOS == "windows" {
+		return doctorResult{Status: doctorSkip, Message: "Permissions are not checked on Windows"}
+	}
+	if d.Error != "" {
+		return doctorResult{Status: doctorFail, Message: "Could not read " + d.Path + ": " + d.Error}
+	}
+	if !d.Exists {
+		return doctorResult{Status: doctorFail, Message: d.Path + " does not exist",
+			Hint: "Start the daemon with `torus daemon start` to create it"}
+	}
+
+	if d.UID != nil && *d.UID != uid {
+		return doctorResult{Status: doctorFail,
+			Message: fmt.Sprintf("%s is owned by uid %d, not you (uid %d)", d.Path, *d.UID, uid),
+			Hint:    fmt.Sprintf("Change its owner with `chown %d %s`", uid, d.Path)}
+	}
+
+	for _, perm := range allowed {
+		if d.perm == perm {
+			return doctorResult{Status: doctorPass, Message: fmt.Sprintf("Permissions are %s", d.perm)}
+		}
+	}
+
+	return doctorResult{Status: doctorFail,
+		Message: fmt.Sprintf("%s has permissions %s", d.Path, d.perm),
+		Hint:    fmt.Sprintf("Restrict its permissions with `chmod %o %s`", allowed[0], d.Path)}
+}
+
+func doctorRegistry(s *doctorState) doctorResult {
+	s.registry = checkRegistry(s.c, s.cfg)
+	return checkRegistryResult(s.registry)
+}
+
+func checkRegistryResult(r *registryCheck) doctorResult {
+	switch {
+	case !r.Reachable:
+		return doctorResult{Status: doctorFail,
+			Message: "Could not reach " + r.URI + ": " + r.Error,
+			Hint:    "Check your network connection, and registry_uri with `torus prefs list`"}
+	case !r.CAValid:
+		return doctorResult{Status: doctorFail,
+			Message: "The certificate of " + r.URI + " is not trusted: " + r.Error,
+			Hint:    "Check ca_bundle_file with `torus prefs list`, or for a proxy intercepting TLS"}
+	case r.Status != 200:
+		return doctorResult{Status: doctorWarn,
+			Message: fmt.Sprintf("%s responded with status %d", r.URI, r.Status),
+			Hint:    "The registry may be having problems; try again shortly"}
+	}
+
+	return doctorResult{Status: doctorPass,
+		Message: fmt.Sprintf("%s is reachable (%s)", r.URI, r.Latency.Round(time.Millisecond))}
+}
+
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+func doctorClock(s *doctorState) doctorResult {
+	if s.registry == nil || s.registry.Status == 0 {
+		return doctorResult{Status: doctorSkip, Message: "The registry could not be reached"}
+	}
+	return checkClockSkew(s.registry.ClockSkew)
+}
+
+// checkClockSkew checks skew, the registry's time less ours.
+func checkClockSkew(skew time.Duration) doctorResult {
+	msg := fmt.Sprintf("Your clock is %s behind the registry's", skew)
+	if skew < 0 {
+		skew = -skew
+		msg = fmt.Sprintf("Your clock is %s ahead of the registry's", skew)
+	}
+
+	hint := "Synchronize your clock with a time server, such as with NTP"
+	switch {
+	case skew >= clockSkewFail:
+		return doctorResult{Status: doctorFail, Message: msg + "; requests may be rejected", Hint: hint}
+	case skew >= clockSkewWarn:
+		return doctorResult{Status: doctorWarn, Message: msg, Hint: hint}
+	}
+	return doctorResult{Status: doctorPass, Message: "In sync with the registry"}
+}
+
+func doctorSession(s *doctorState) doctorResult {
+	if !s.daemonUp {
+		return doctorResult{Status: doctorSkip, Message: "The daemon is not running"}
+	}
+
+	login := "Log in with `torus login`, or set TORUS_TOKEN_ID and TORUS_TOKEN_SECRET for a machine"
+
+	status, err := s.client.Session.Get(s.c)
+	if err != nil {
+		if cerr, ok := err.(*apitypes.Error); ok && cerr.Type == apitypes.UnauthorizedError {
+			return doctorResult{Status: doctorWarn, Message: "You are not logged in", Hint: login}
+		}
+		return doctorResult{Status: doctorFail, Message: "Could not fetch the session: " + err.Error()}
+	}
+	if !status.Token {
+		return doctorResult{Status: doctorWarn, Message: "You are not logged in", Hint: login}
+	}
+
+	session, err := s.client.Session.Who(s.c)
+	if err != nil {
+		return doctorResult{Status: doctorFail, Message: "Could not fetch your identity: " + err.Error(),
+			Hint: login}
+	}
+	s.loggedIn = true
+
+	mode := ""
+	if status.ReadOnly {
+		mode = ", with a read-only daemon"
+	}
+
+	if session.Type() == apitypes.MachineSession {
+		return doctorResult{Status: doctorPass,
+			Message: fmt.Sprintf("Logged in as machine %s%s", session.Username(), mode)}
+	}
+	return doctorResult{Status: doctorPass,
+		Message: fmt.Sprintf("Logged in as %s (%s)%s", session.Username(), session.Email(), mode)}
+}
+
+// loadOrgs fetches the orgs of the logged in user, once.
+func (s *doctorState) loadOrgs() error {
+	if s.orgs != nil {
+		return nil
+	}
+
+	orgs, err := s.client.Orgs.List(s.c)
+	if err != nil {
+		return err
+	}
+	if orgs == nil {
+		orgs = []envelope.Org{}
+	}
+	s.orgs = orgs
+	return nil
+}
+
+func doctorKeypairs(s *doctorState) doctorResult {
+	if !s.loggedIn {
+		return doctorResult{Status: doctorSkip, Message: "You are not logged in"}
+	}
+
+	if err := s.loadOrgs(); err != nil {
+		return doctorResult{Status: doctorFail, Message: "Could not fetch your orgs: " + err.Error()}
+	}
+
+	missing := []string{}
+	for _, org := range s.orgs {
+		keypairs, err := s.client.KeyPairs.List(s.c, org.ID)
+		if err != nil {
+			return doctorResult{Status: doctorFail,
+				Message: "Could not fetch keypairs for " + org.Body.Name + ": " + err.Error()}
+		}
+
+		hasKey := make(map[primitive.KeyType]bool)
+		for _, kp := range keypairs.All() {
+			if !kp.Revoked() {
+				hasKey[kp.PublicKey.Body.KeyType] = true
+			}
+		}
+
+		if !hasKey[primitive.EncryptionKeyType] || !hasKey[primitive.SigningKeyType] {
+			missing = append(missing, org.Body.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return doctorResult{Status: doctorFail,
+			Message: "Missing keypairs for: " + strings.Join(missing, ", "),
+			Hint:    "Generate them with `torus keypairs generate --all`"}
+	}
+
+	return doctorResult{Status: doctorPass,
+		Message: fmt.Sprintf("Present for all %d orgs", len(s.orgs))}
+}
+
+func doctorWorklog(s *doctorState) doctorResult {
+	if !s.loggedIn {
+		return doctorResult{Status: doctorSkip, Message: "You are not logged in"}
+	}
+
+	if err := s.loadOrgs(); err != nil {
+		return doctorResult{Status: doctorFail, Message: "Could not fetch your orgs: " + err.Error()}
+	}
+
+	pending := 0
+	for _, org := range s.orgs {
+		items, err := s.client.Worklog.List(s.c, org.ID, false)
+		if err != nil {
+			return doctorResult{Status: doctorWarn,
+				Message: "Could not fetch the worklog for " + org.Body.Name + ": " + err.Error()}
+		}
+		pending += len(items)
+	}
+
+	switch pending {
+	case 0:
+		return doctorResult{Status: doctorPass, Message: "No pending items"}
+	case 1:
+		return doctorResult{Status: doctorWarn, Message: "1 pending item",
+			Hint: "Review it with `torus worklog list`"}
+	default:
+		return doctorResult{Status: doctorWarn, Message: fmt.Sprintf("%d pending items", pending),
+			Hint: "Review them with `torus worklog list`"}
+	}
+}
+
+func doctorVersion(s *doctorState) doctorResult {
+	if !s.daemonUp {
+		return doctorResult{Status: doctorSkip, Message: "The daemon is not running"}
+	}
+
+	info, err := s.client.Updates.Check(s.c)
+	if err != nil {
+		return doctorResult{Status: doctorWarn, Message: "Could not check for updates: " + err.Error()}
+	}
+
+	if info.NeedsUpdate {
+		return doctorResult{Status: doctorWarn,
+			Message: fmt.Sprintf("v%s is available; you have v%s", info.Version, s.cfg.Version),
+			Hint:    "Download it from " + downloadURL}
+	}
+
+	return doctorResult{Status: doctorPass, Message: fmt.Sprintf("v%s is up to date", s.cfg.Version)}
+}
+
+func doctorEnv(s *doctorState) doctorResult {
+	linked, err := dirprefs.Load(true)
+	if err != nil {
+		return doctorResult{Status: doctorWarn, Message: "Could not read .torus.json: " + err.Error()}
+	}
+
+	return checkEnv(os.Environ(), knownEnvVars(Cmds), linked)
+}
+
+// checkEnv looks for TORUS_ environment variables that conflict with each
+// other or with the linked project, or that torus does not use.
+func checkEnv(environ []string, known map[string]bool, linked *dirprefs.DirPreferences) doctorResult {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], "TORUS_") {
+			env[parts[0]] = parts[1]
+		}
+	}
+	has := func(k string) bool { _, ok := env[k]; return ok }
+
+	r := doctorResult{Status: doctorPass, Message: "No conflicting TORUS_ variables"}
+	problems := []string{}
+	problem := func(status doctorStatus, msg, hint string) {
+		if r.Status != doctorFail {
+			r.Status = status
+			r.Hint = hint
+		}
+		problems = append(problems, msg)
+	}
+
+	userLogin := has("TORUS_EMAIL") || has("TORUS_PASSWORD")
+	machineLogin := has("TORUS_TOKEN_ID") || has("TORUS_TOKEN_SECRET")
+	if userLogin && machineLogin {
+		problem(doctorFail, "Both user and machine credentials are set",
+			"Unset either TORUS_EMAIL and TORUS_PASSWORD, or TORUS_TOKEN_ID and TORUS_TOKEN_SECRET")
+	}
+	if has("TORUS_EMAIL") != has("TORUS_PASSWORD") {
+		problem(doctorWarn, "Only one of TORUS_EMAIL and TORUS_PASSWORD is set",
+			"Set both TORUS_EMAIL and TORUS_PASSWORD to log in automatically")
+	}
+	if has("TORUS_TOKEN_ID") != has("TORUS_TOKEN_SECRET") {
+		problem(doctorWarn, "Only one of TORUS_TOKEN_ID and TORUS_TOKEN_SECRET is set",
+			"Set both TORUS_TOKEN_ID and TORUS_TOKEN_SECRET to log in automatically")
+	}
+
+	if linked != nil && linked.Path != "" {
+		if v, ok := env["TORUS_ORG"]; ok && linked.Organization != "" && v != linked.Organization {
+			problem(doctorWarn, fmt.Sprintf("TORUS_ORG (%s) overrides the linked org (%s)", v, linked.Organization),
+				"Unset TORUS_ORG to use the org linked in "+linked.Path)
+		}
+		if v, ok := env["TORUS_PROJECT"]; ok && linked.Project != "" && v != linked.Project {
+			problem(doctorWarn, fmt.Sprintf("TORUS_PROJECT (%s) overrides the linked project (%s)", v, linked.Project),
+				"Unset TORUS_PROJECT to use the project linked in "+linked.Path)
+		}
+	}
+
+	unknown := []string{}
+	for k := range env {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		problem(doctorWarn, "Unknown variables: "+strings.Join(unknown, ", "),
+			"Check these variables for typos")
+	}
+
+	if len(problems) > 0 {
+		r.Message = strings.Join(problems, "; ")
+	}
+	return r
+}
+
+// knownEnvVars returns the environment variables read by torus, including
+// those of every command's flags.
+func knownEnvVars(cmds []cli.Command) map[string]bool {
+	known := map[string]bool{"TORUS_ROOT": true, "TORUS_DEBUG": true}
+	for _, k := range filteredEnv {
+		known[k] = true
+	}
+
+	var walk func([]cli.Command)
+	walk = func(cmds []cli.Command) {
+		for _, c := range cmds {
+			for _, f := range c.Flags {
+				for _, k := range flagEnvVars(f) {
+					known[k] = true
+				}
+			}
+			walk(c.Subcommands)
+		}
+	}
+	walk(cmds)
+
+	return known
+}
+
+// flagEnvVars returns the environment variables a flag can be set from.
+func flagEnvVars(f cli.Flag) []string {
+	v := reflect.Indirect(reflect.ValueOf(f))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	ev := v.FieldByName("EnvVar")
+	if !ev.IsValid() || ev.Kind() != reflect.String {
+		return nil
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(ev.String(), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
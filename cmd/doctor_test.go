@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/dirprefs"
+)
+
+func TestCheckEnv(t *testing.T) {
+	known := map[string]bool{
+		"TORUS_EMAIL": true, "TORUS_PASSWORD": true, "TORUS_TOKEN_ID": true,
+		"TORUS_TOKEN_SECRET": true, "TORUS_ORG": true, "TORUS_PROJECT": true,
+	}
+	linked := &dirprefs.DirPreferences{Organization: "acme", Project: "api", Path: "/src/.torus.json"}
+
+	tcs := []struct {
+		name    string
+		environ []string
+		status  doctorStatus
+		message string
+	}{
+		{"none", []string{"HOME=/home/jeff"}, doctorPass, "No conflicting"},
+		{"user login", []string{"TORUS_EMAIL=a@b.c", "TORUS_PASSWORD=x"}, doctorPass, "No conflicting"},
+		{"user and machine", []string{"TORUS_EMAIL=a@b.c", "TORUS_PASSWORD=x",
+			"TORUS_TOKEN_ID=id", "TORUS_TOKEN_SECRET=s"}, doctorFail, "Both user and machine"},
+		{"half machine", []string{"TORUS_TOKEN_ID=id"}, doctorWarn, "Only one of TORUS_TOKEN_ID"},
+		{"half user", []string{"TORUS_PASSWORD=x"}, doctorWarn, "Only one of TORUS_EMAIL"},
+		{"matching org", []string{"TORUS_ORG=acme"}, doctorPass, "No conflicting"},
+		{"overridden org", []string{"TORUS_ORG=other"}, doctorWarn, "TORUS_ORG (other) overrides the linked org (acme)"},
+		{"overridden project", []string{"TORUS_PROJECT=web"}, doctorWarn, "TORUS_PROJECT (web)"},
+		{"unknown", []string{"TORUS_PROJCET=api", "TORUS_ORGG=acme"}, doctorWarn, "Unknown variables: TORUS_ORGG, TORUS_PROJCET"},
+		{"fail wins", []string{"TORUS_EMAIL=a@b.c", "TORUS_TOKEN_ID=id", "TORUS_TOKEN_SECRET=s"}, doctorFail, "Both user and machine"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := checkEnv(tc.environ, known, linked)
+			if r.Status != tc.status {
+				t.Errorf("expected status %s, got %s (%s)", tc.status, r.Status, r.Message)
+			}
+			if !strings.Contains(r.Message, tc.message) {
+				t.Errorf("expected message to contain %q, got %q", tc.message, r.Message)
+			}
+			if r.Status != doctorPass && r.Hint == "" {
+				t.Error("expected a hint")
+			}
+		})
+	}
+
+	t.Run("not linked", func(t *testing.T) {
+		r := checkEnv([]string{"TORUS_ORG=other"}, known, &dirprefs.DirPreferences{})
+		if r.Status != doctorPass {
+			t.Errorf("expected pass outside a linked directory, got %s (%s)", r.Status, r.Message)
+		}
+	})
+}
+
+func TestKnownEnvVars(t *testing.T) {
+	cmds := []cli.Command{
+		{
+			Flags: []cli.Flag{cli.StringFlag{Name: "a", EnvVar: "TORUS_A, TORUS_B"}},
+			Subcommands: []cli.Command{
+				{Flags: []cli.Flag{
+					newPlaceholder("c", "C", "", "", "TORUS_C", false),
+					cli.BoolFlag{Name: "d"},
+				}},
+			},
+		},
+	}
+
+	known := knownEnvVars(cmds)
+	for _, k := range []string{"TORUS_A", "TORUS_B", "TORUS_C", "TORUS_ROOT", "TORUS_TOKEN_SECRET"} {
+		if !known[k] {
+			t.Errorf("expected %s to be known", k)
+		}
+	}
+
+	// Every variable read by the real commands' flags should be known.
+	known = knownEnvVars(Cmds)
+	for _, k := range []string{"TORUS_ORG", "TORUS_ENVIRONMENT", "TORUS_FORMAT", "TORUS_LOG_LEVEL"} {
+		if !known[k] {
+			t.Errorf("expected %s to be known", k)
+		}
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	tcs := []struct {
+		skew    time.Duration
+		status  doctorStatus
+		message string
+	}{
+		{0, doctorPass, "In sync"},
+		{-10 * time.Second, doctorPass, "In sync"},
+		{45 * time.Second, doctorWarn, "45s behind"},
+		{-45 * time.Second, doctorWarn, "45s ahead"},
+		{10 * time.Minute, doctorFail, "10m0s behind"},
+	}
+
+	for _, tc := range tcs {
+		r := checkClockSkew(tc.skew)
+		if r.Status != tc.status || !strings.Contains(r.Message, tc.message) {
+			t.Errorf("checkClockSkew(%s) = %s %q, expected %s %q",
+				tc.skew, r.Status, r.Message, tc.status, tc.message)
+		}
+	}
+}
+
+func TestCheckFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+
+	uid := 501
+	other := 0
+
+	tcs := []struct {
+		name   string
+		d      *fileDescription
+		status doctorStatus
+	}{
+		{"ok", &fileDescription{Path: "p", Exists: true, UID: &uid, perm: 0700}, doctorPass},
+		{"group allowed", &fileDescription{Path: "p", Exists: true, UID: &uid, perm: 0760}, doctorPass},
+		{"too open", &fileDescription{Path: "p", Exists: true, UID: &uid, perm: 0755}, doctorFail},
+		{"wrong owner", &fileDescription{Path: "p", Exists: true, UID: &other, perm: 0700}, doctorFail},
+		{"missing", &fileDescription{Path: "p"}, doctorFail},
+		{"unreadable", &fileDescription{Path: "p", Error: "permission denied"}, doctorFail},
+	}
+
+	for _, tc := range tcs {
+		r := checkFilePermissions(tc.d, uid, 0700, 0760)
+		if r.Status != tc.status {
+			t.Errorf("%s: expected %s, got %s (%s)", tc.name, tc.status, r.Status, r.Message)
+		}
+	}
+}
+
+func TestCheckRegistryResult(t *testing.T) {
+	tcs := []struct {
+		check  registryCheck
+		status doctorStatus
+	}{
+		{registryCheck{Reachable: true, CAValid: true, Status: 200}, doctorPass},
+		{registryCheck{Reachable: true, CAValid: true, Status: 503}, doctorWarn},
+		{registryCheck{Reachable: true, Error: "x509: certificate signed by unknown authority"}, doctorFail},
+		{registryCheck{Error: "dial tcp: no such host"}, doctorFail},
+	}
+
+	for _, tc := range tcs {
+		if r := checkRegistryResult(&tc.check); r.Status != tc.status {
+			t.Errorf("%+v: expected %s, got %s", tc.check, tc.status, r.Status)
+		}
+	}
+}
+
+func TestIsCertError(t *testing.T) {
+	if isCertError(errors.New("dial tcp: no such host")) {
+		t.Error("expected a network error not to be a certificate error")
+	}
+}
+
+func TestWriteDoctorResults(t *testing.T) {
+	results := []doctorResult{
+		{Name: "Daemon", Status: doctorPass, Message: "running", Hint: "not shown"},
+		{Name: "Clock", Status: doctorSkip, Message: "skipped"},
+		{Name: "Environment", Status: doctorFail, Message: "conflict", Hint: "unset it"},
+	}
+
+	buf := &bytes.Buffer{}
+	writeDoctorResults(buf, results)
+	out := buf.String()
+
+	if strings.Contains(out, "not shown") {
+		t.Error("expected hints of passing checks to be hidden")
+	}
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d:\n%s", len(lines), out)
+	}
+
+	// Each check's message and hint should be aligned, despite the labels'
+	// colors having escape codes of different lengths.
+	column := func(line, s string) int {
+		return len([]rune(stripANSI(line[:strings.Index(line, s)])))
+	}
+	want := column(lines[0], "running")
+	for i, s := range []string{"skipped", "conflict", "unset it"} {
+		if got := column(lines[i+1], s); got != want {
+			t.Errorf("expected %q at column %d, got %d", s, want, got)
+		}
+	}
+}
+
+func stripANSI(s string) string {
+	out := []rune{}
+	esc := false
+	for _, r := range s {
+		switch {
+		case r == '\033':
+			esc = true
+		case esc && r == 'm':
+			esc = false
+		case !esc:
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
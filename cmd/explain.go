@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+)
+
+// specificitySegments names the segments compared when ranking pathexps
+var specificitySegments = []string{"environment", "service", "identity", "instance"}
+
+func init() {
+	explain := cli.Command{
+		Name:      "explain",
+		Usage:     "Explain which secret is used for a name, and which secrets it shadows",
+		ArgsUsage: "<name>",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			stdOrgFlag,
+			stdProjectFlag,
+			stdEnvFlag,
+			serviceFlag("Use this service.", "default", true),
+			userFlag("Use this user.", false),
+			machineFlag("Use this machine.", false),
+			stdInstanceFlag,
+			formatFlag("table", "Format used to display data (table, json)"),
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+			setUserEnv, checkRequiredFlags, explainCmd,
+		),
+	}
+
+	Cmds = append(Cmds, explain)
+}
+
+// explainCandidate is a credential that could provide the value for a name
+type explainCandidate struct {
+	PathExp     string `json:"pathexp"`
+	Version     int    `json:"version"`
+	Unset       bool   `json:"unset"`
+	Specificity string `json:"specificity"`
+	Winner      bool   `json:"winner"`
+	Reason      string `json:"reason"`
+}
+
+func explainCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		msg := "A secret name is required."
+		if len(args) > 1 {
+			msg = "Too many arguments provided."
+		}
+		return errs.NewUsageExitError(msg, ctx)
+	}
+
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	path, err := secretsPath(c, ctx, client)
+	if err != nil {
+		return err
+	}
+
+	creds, err := client.Credentials.GetHeads(c, path)
+	if err != nil {
+		return errs.NewErrorExitError("Error fetching secrets", err)
+	}
+
+	name := strings.ToLower(args[0])
+	candidates, err := explainCandidates(name, creds)
+	if err != nil {
+		return errs.NewErrorExitError("Error ranking secrets", err)
+	}
+
+	if format == "json" {
+		return writeExplainJSON(os.Stdout, candidates)
+	}
+
+	return writeExplainTable(os.Stdout, name, path, candidates)
+}
+
+// explainCandidates returns every credential named name, ordered from most to
+// least specific, marking the one that would be used by view and run.
+//
+// The winner is chosen the same way as in getSecrets: unset credentials are
+// ignored, and the most specific set credential wins, with the first one
+// returned by the daemon winning ties.
+func explainCandidates(name string, creds []apitypes.CredentialEnvelope) ([]explainCandidate, error) {
+	var matching []apitypes.CredentialEnvelope
+	cset := credentialSet{}
+	for _, cred := range creds {
+		if (*cred.Body).GetName() != name {
+			continue
+		}
+
+		matching = append(matching, cred)
+		if (*cred.Body).GetValue() == nil {
+			continue
+		}
+
+		if err := cset.Add(cred); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Stable(bySpecificity(matching))
+
+	winner, hasWinner := cset[name]
+	candidates := make([]explainCandidate, len(matching))
+	for i, cred := range matching {
+		body := *cred.Body
+		pe := body.GetPathExp()
+
+		candidate := explainCandidate{
+			PathExp:     pe.String() + "/" + name,
+			Version:     body.GetCredentialVersion(),
+			Unset:       body.GetValue() == nil,
+			Specificity: pe.Specificity().String(),
+		}
+
+		switch {
+		case hasWinner && cred.Body == winner.Body:
+			candidate.Winner = true
+			candidate.Reason = "most specific set value"
+		case candidate.Unset:
+			candidate.Reason = "unset values are ignored"
+		default:
+			candidate.Reason = shadowReason(*winner.Body, body)
+		}
+
+		candidates[i] = candidate
+	}
+
+	return candidates, nil
+}
+
+// shadowReason describes why the winning credential was chosen over the
+// shadowed one.
+func shadowReason(winner, shadowed apitypes.Credential) string {
+	ws := winner.GetPathExp().Specificity()
+	ss := shadowed.GetPathExp().Specificity()
+	wNames := ws.Names()
+	sNames := ss.Names()
+
+	for i := range ws {
+		if ws[i] != ss[i] {
+			return fmt.Sprintf("shadowed by a more specific %s (%s over %s)",
+				specificitySegments[i], wNames[i], sNames[i])
+		}
+	}
+
+	return "equally specific; shadowed by the value returned first"
+}
+
+func writeExplainTable(w io.Writer, name, path string, candidates []explainCandidate) error {
+	fmt.Fprintf(w, "Credential path: %s\n\n", path)
+
+	if len(candidates) == 0 {
+		fmt.Fprintf(w, "No secrets named %s were found.\n", strings.ToUpper(name))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, " \tPATH\tVERSION\tSTATE\tSPECIFICITY\tREASON\n")
+	for _, c := range candidates {
+		winner := ""
+		if c.Winner {
+			winner = "*"
+		}
+
+		state := "set"
+		if c.Unset {
+			state = "unset"
+		}
+
+		version := "-"
+		if c.Version > 0 {
+			version = fmt.Sprintf("%d", c.Version)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", winner, c.PathExp, version,
+			state, c.Specificity, c.Reason)
+	}
+
+	return tw.Flush()
+}
+
+func writeExplainJSON(w io.Writer, candidates []explainCandidate) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(candidates)
+	if err != nil {
+		return errs.NewErrorExitError("Could not marshal to json", err)
+	}
+
+	return nil
+}
+
+// bySpecificity implements sort.Interface, for sorting credentials from most
+// to least specific PathExp.
+type bySpecificity []apitypes.CredentialEnvelope
+
+func (b bySpecificity) Len() int      { return len(b) }
+func (b bySpecificity) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySpecificity) Less(i, j int) bool {
+	a := (*b[i].Body).GetPathExp()
+	o := (*b[j].Body).GetPathExp()
+	return a.CompareSpecificity(o) > 0
+}
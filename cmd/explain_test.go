@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+func explainCredential(t *testing.T, path, name, state string, version int) apitypes.CredentialEnvelope {
+	pe, err := pathexp.Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := map[string]interface{}{
+		"version": 2,
+		"body": map[string]interface{}{
+			"type":  "string",
+			"value": "value",
+		},
+	}
+
+	cval, err := interfaceToCredentialValue(t, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cBody apitypes.Credential = &apitypes.CredentialV2{
+		State: state,
+		BaseCredential: apitypes.BaseCredential{
+			Name:              name,
+			PathExp:           pe,
+			Value:             cval,
+			CredentialVersion: version,
+		},
+	}
+
+	return apitypes.CredentialEnvelope{Body: &cBody}
+}
+
+func TestExplainCandidates(t *testing.T) {
+	t.Run("most specific set value wins", func(t *testing.T) {
+		creds := []apitypes.CredentialEnvelope{
+			explainCredential(t, "/o/p/*/*/*/*", "db_url", "set", 4),
+			explainCredential(t, "/o/p/e/s/*/*", "db_url", "unset", 2),
+			explainCredential(t, "/o/p/e/*/*/*", "db_url", "set", 1),
+			explainCredential(t, "/o/p/e/s/*/*", "other", "set", 1),
+		}
+
+		candidates, err := explainCandidates("db_url", creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(candidates) != 3 {
+			t.Fatalf("Expected 3 candidates, got %d", len(candidates))
+		}
+
+		expected := []explainCandidate{
+			{
+				PathExp:     "/o/p/e/s/*/*/db_url",
+				Version:     2,
+				Unset:       true,
+				Specificity: "literal/literal/fullglob/fullglob",
+				Reason:      "unset values are ignored",
+			},
+			{
+				PathExp:     "/o/p/e/*/*/*/db_url",
+				Version:     1,
+				Specificity: "literal/fullglob/fullglob/fullglob",
+				Winner:      true,
+				Reason:      "most specific set value",
+			},
+			{
+				PathExp:     "/o/p/*/*/*/*/db_url",
+				Version:     4,
+				Specificity: "fullglob/fullglob/fullglob/fullglob",
+				Reason:      "shadowed by a more specific environment (literal over fullglob)",
+			},
+		}
+
+		for i, c := range candidates {
+			if c != expected[i] {
+				t.Errorf("Candidate %d: expected %+v, got %+v", i, expected[i], c)
+			}
+		}
+	})
+
+	t.Run("ties go to the first returned", func(t *testing.T) {
+		creds := []apitypes.CredentialEnvelope{
+			explainCredential(t, "/o/p/e/[a|s]/*/*", "port", "set", 1),
+			explainCredential(t, "/o/p/e/[b|s]/*/*", "port", "set", 1),
+		}
+
+		candidates, err := explainCandidates("port", creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !candidates[0].Winner || candidates[1].Winner {
+			t.Error("Expected the first returned credential to win")
+		}
+
+		reason := "equally specific; shadowed by the value returned first"
+		if candidates[1].Reason != reason {
+			t.Errorf("Expected reason %q, got %q", reason, candidates[1].Reason)
+		}
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		creds := []apitypes.CredentialEnvelope{
+			explainCredential(t, "/o/p/e/s/*/*", "other", "set", 1),
+		}
+
+		candidates, err := explainCandidates("port", creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(candidates) != 0 {
+			t.Errorf("Expected no candidates, got %d", len(candidates))
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/bundle"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+const (
+	exportBundleType    = "torus-export"
+	exportBundleVersion = 1
+)
+
+// exportPayload is the plaintext contents of an exported secrets bundle.
+//
+// Bundles are sealed with a single use key, so nothing in them is
+// authenticated. They don't record a sender, as it could be forged.
+type exportPayload struct {
+	Type    string         `json:"type"`
+	Version int            `json:"version"`
+	Created time.Time      `json:"created"`
+	Path    string         `json:"path"`
+	Secrets []exportSecret `json:"secrets"`
+}
+
+// exportSecret is a single resolved secret in an exported bundle.
+type exportSecret struct {
+	Name    string                    `json:"name"`
+	PathExp *pathexp.PathExp          `json:"pathexp"`
+	Value   *apitypes.CredentialValue `json:"value"`
+}
+
+func init() {
+	export := cli.Command{
+		Name:     "export",
+		Usage:    "Encrypt the secrets for the current service and environment for a recipient",
+		Category: "SECRETS",
+		Flags: []cli.Flag{
+			stdOrgFlag,
+			stdProjectFlag,
+			stdEnvFlag,
+			serviceFlag("Use this service.", "default", true),
+			userFlag("Use this user.", false),
+			machineFlag("Use this machine.", false),
+			stdInstanceFlag,
+			newPlaceholder("recipient, r", "RECIPIENT",
+				"Encrypt to RECIPIENT, a public key in the age format (age1...), or the first key in the file RECIPIENT",
+				"", "", true),
+			newPlaceholder("output", "FILE", "Write the bundle to FILE, instead of stdout", "", "", false),
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+			setUserEnv, checkRequiredFlags, exportCmd,
+		),
+	}
+
+	decrypt := cli.Command{
+		Name:      "decrypt-bundle",
+		Usage:     "Decrypt a bundle of secrets, without a Torus account",
+		ArgsUsage: "<bundle-file>",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			newPlaceholder("key, k", "FILE", "Decrypt with the secret key in FILE, in the age format (AGE-SECRET-KEY-1...)",
+				"", "", true),
+			cli.BoolFlag{
+				Name:  "generate",
+				Usage: "Write a new secret key to the --key FILE, and print its public key",
+			},
+			formatFlag("env", "Format used to display data (json, env, verbose)"),
+		},
+		Action: chain(
+			checkRequiredFlags, decryptBundleCmd,
+		),
+	}
+
+	Cmds = append(Cmds, export, decrypt)
+}
+
+// readRecipient parses a public key in the age format, or reads the first one from
+// a file if s is not a public key.
+func readRecipient(s string) (*bundle.Recipient, error) {
+	if strings.HasPrefix(s, "age1") {
+		return bundle.ParseRecipient(s)
+	}
+
+	b, err := ioutil.ReadFile(s)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read recipient file: %s", err)
+	}
+
+	return bundle.ParseRecipientFile(b)
+}
+
+func exportCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) > 0 {
+		return errs.NewUsageExitError("Too many arguments were provided", ctx)
+	}
+
+	recipient, err := readRecipient(ctx.String("recipient"))
+	if err != nil {
+		return errs.NewUsageExitError(err.Error(), ctx)
+	}
+
+	output := ctx.String("output")
+	if output != "" {
+		if _, err := os.Stat(output); err == nil {
+			return errs.NewExitError("A file already exists at " + output + ".")
+		}
+	}
+
+	secrets, path, err := getSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload := exportPayload{
+		Type:    exportBundleType,
+		Version: exportBundleVersion,
+		Created: time.Now().UTC(),
+		Path:    path,
+		Secrets: make([]exportSecret, 0, len(secrets)),
+	}
+	for _, secret := range secrets {
+		payload.Secrets = append(payload.Secrets, exportSecret{
+			Name:    (*secret.Body).GetName(),
+			PathExp: (*secret.Body).GetPathExp(),
+			Value:   (*secret.Body).GetValue(),
+		})
+	}
+
+	plaintext, err := json.Marshal(&payload)
+	if err != nil {
+		return errs.NewErrorExitError("Could not create bundle.", err)
+	}
+
+	sealed, err := bundle.SealToRecipient(plaintext, recipient)
+	if err != nil {
+		return errs.NewErrorExitError("Could not encrypt bundle.", err)
+	}
+
+	b, err := sealed.Encode()
+	if err != nil {
+		return errs.NewErrorExitError("Could not encrypt bundle.", err)
+	}
+
+	// The summary goes to stderr when the bundle is written to stdout, so
+	// that it can be redirected on its own.
+	summary := io.Writer(os.Stdout)
+	if output == "" {
+		summary = os.Stderr
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(output, b, 0600)
+	}
+	if err != nil {
+		return errs.NewErrorExitError("Could not write bundle.", err)
+	}
+
+	fmt.Fprintln(summary, "")
+	fmt.Fprintf(summary, "%d secret(s) from %s encrypted for %s\n", len(payload.Secrets), path, recipient)
+	return nil
+}
+
+func decryptBundleCmd(ctx *cli.Context) error {
+	keyFile := ctx.String("key")
+	if ctx.Bool("generate") {
+		return generateBundleKey(keyFile)
+	}
+
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A bundle file is required", ctx)
+	}
+
+	format := ctx.String("format")
+	if format != "env" && format != "json" && format != "verbose" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	idFile, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return errs.NewErrorExitError("Could not read key file.", err)
+	}
+
+	ids, err := bundle.ParseIdentities(idFile)
+	if err != nil {
+		return errs.NewErrorExitError("Could not read key file.", err)
+	}
+
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Could not read bundle.", err)
+	}
+
+	sealed, err := bundle.Decode(b)
+	if err != nil {
+		return errs.NewErrorExitError("Could not read bundle.", err)
+	}
+
+	plaintext, err := sealed.OpenWithIdentities(ids)
+	if err != nil {
+		return errs.NewErrorExitError("Could not decrypt bundle.", err)
+	}
+
+	payload := exportPayload{}
+	err = json.Unmarshal(plaintext, &payload)
+	if err != nil || payload.Type != exportBundleType {
+		if payload.Type == backupArchiveType {
+			return errs.NewExitError(fmt.Sprintf(
+				"File is a backup; use '%s restore' to restore it.", ctx.App.Name))
+		}
+		return errs.NewExitError("File is not a Torus bundle.")
+	}
+	if payload.Version != exportBundleVersion {
+		return errs.NewExitError(fmt.Sprintf("Unsupported bundle version: %d", payload.Version))
+	}
+
+	secrets := payload.envelopes()
+	w := os.Stdout
+	switch format {
+	case "env":
+		return writeEnvFormat(w, secrets, payload.Path)
+	case "json":
+		return writeJSONFormat(w, secrets, payload.Path)
+	default:
+		fmt.Fprintf(w, "Exported at %s\n", payload.Created.Format(time.RFC3339))
+		fmt.Fprintln(w, "The sender is not authenticated: anyone with your public key could have created this bundle.")
+		return writeVerboseFormat(w, secrets, payload.Path)
+	}
+}
+
+// envelopes returns the exported secrets in the form used by view.
+func (p *exportPayload) envelopes() []apitypes.CredentialEnvelope {
+	secrets := make([]apitypes.CredentialEnvelope, 0, len(p.Secrets))
+	for _, s := range p.Secrets {
+		var cred apitypes.Credential = &apitypes.CredentialV2{
+			BaseCredential: apitypes.BaseCredential{
+				Name:    s.Name,
+				PathExp: s.PathExp,
+				Value:   s.Value,
+			},
+			State: "set",
+		}
+		secrets = append(secrets, apitypes.CredentialEnvelope{Version: 2, Body: &cred})
+	}
+	return secrets
+}
+
+func generateBundleKey(keyFile string) error {
+	if _, err := os.Stat(keyFile); err == nil {
+		return errs.NewExitError("A file already exists at " + keyFile + ".")
+	}
+
+	id, err := bundle.GenerateIdentity()
+	if err != nil {
+		return errs.NewErrorExitError("Could not generate key.", err)
+	}
+
+	contents := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), id.Recipient(), id)
+	err = ioutil.WriteFile(keyFile, []byte(contents), 0600)
+	if err != nil {
+		return errs.NewErrorExitError("Could not write key.", err)
+	}
+
+	fmt.Printf("Secret key written to %s\n", keyFile)
+	fmt.Printf("Public key: %s\n", id.Recipient())
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/bundle"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+func TestReadRecipient(t *testing.T) {
+	id, err := bundle.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := id.Recipient()
+
+	dir, err := ioutil.TempDir("", "torus-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "recipient.txt")
+	err = ioutil.WriteFile(file, []byte("# vendor deploy host\n"+want.String()+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{want.String(), file} {
+		r, err := readRecipient(s)
+		if err != nil {
+			t.Fatalf("Expected %s to be read, got %s", s, err)
+		}
+		if *r != *want {
+			t.Errorf("Expected recipient %s, got %s", want, r)
+		}
+	}
+
+	if _, err := readRecipient(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing recipient file")
+	}
+}
+
+func TestExportPayloadEnvelopes(t *testing.T) {
+	pe, err := pathexp.Parse("/o/p/prod/api/*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := exportPayload{
+		Type:    exportBundleType,
+		Version: exportBundleVersion,
+		Path:    "/o/p/prod/api/u-alice/1",
+		Secrets: []exportSecret{
+			{Name: "db", PathExp: pe, Value: apitypes.NewStringCredentialValue("postgres://db")},
+			{Name: "port", PathExp: pe, Value: apitypes.NewIntCredentialValue(5432)},
+		},
+	}
+
+	b, err := json.Marshal(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := exportPayload{}
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	err = writeEnvFormat(out, decoded.envelopes(), decoded.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DB=postgres://db\nPORT=5432\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
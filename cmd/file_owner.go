@@ -0,0 +1,17 @@
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the user and group that own the file described by fi.
+func fileOwner(fi os.FileInfo) (uid int, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
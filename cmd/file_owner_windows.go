@@ -0,0 +1,9 @@
+package cmd
+
+import "os"
+
+// fileOwner returns the user and group that own the file described by fi.
+// Ownership is not reported on Windows.
+func fileOwner(fi os.FileInfo) (uid int, gid int, ok bool) {
+	return 0, 0, false
+}
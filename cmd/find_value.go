@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+)
+
+func init() {
+	findValue := cli.Command{
+		Name:     "find-value",
+		Usage:    "Find every secret, in every org, holding a leaked value",
+		Category: "SECRETS",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "rotate-worklog",
+				Usage: "Mark every current secret holding the value for rotation in the worklog",
+			},
+			formatFlag("table", "Format used to display data (table, json)"),
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, findValueCmd,
+		),
+	}
+
+	Cmds = append(Cmds, findValue)
+}
+
+func findValueCmd(ctx *cli.Context) error {
+	if len(ctx.Args()) > 0 {
+		return errs.NewUsageExitError("The value is read from a prompt, not arguments.", ctx)
+	}
+
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	value, err := SecretValuePrompt("Leaked value")
+	if err != nil {
+		return handleSelectError(err, "Could not read value")
+	}
+
+	mark := ctx.Bool("rotate-worklog")
+	result, err := client.Credentials.FindValue(c, value, mark, progress)
+	if err != nil {
+		return errs.NewErrorExitError("Error searching for value", err)
+	}
+
+	if format == "json" {
+		return writeValueLocationsJSON(os.Stdout, result)
+	}
+
+	return writeValueLocationsTable(os.Stdout, result, mark)
+}
+
+func writeValueLocationsTable(w io.Writer, result *apitypes.FindValueResult, mark bool) error {
+	locations := result.Locations
+
+	fmt.Fprintln(w)
+	if len(locations) == 0 {
+		fmt.Fprintln(w, "The value was not found in any secret you can read.")
+		writeSkipped(w, result.Skipped)
+		return nil
+	}
+
+	marked := 0
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "PATH\tVERSION\tSTATUS\n")
+	for _, l := range locations {
+		status := "previous version"
+		if l.Current {
+			status = "current"
+		}
+		if l.Marked {
+			status += ", marked for rotation"
+			marked++
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", l.Path(), l.CredentialVersion, status)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nFound the value in %d secret version(s).\n", len(locations))
+	if mark {
+		fmt.Fprintf(w, "Marked %d secret(s) for rotation. Run `torus worklog list` to see them.\n", marked)
+	}
+	writeSkipped(w, result.Skipped)
+
+	return nil
+}
+
+// writeSkipped notes the number of keyring versions that could not be
+// searched, if any.
+func writeSkipped(w io.Writer, skipped int) {
+	if skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d keyring version(s) that you can no longer decrypt.\n", skipped)
+	}
+}
+
+func writeValueLocationsJSON(w io.Writer, result *apitypes.FindValueResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(result)
+	if err != nil {
+		return errs.NewErrorExitError("Could not marshal to json", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// fingerprintLen is the number of hex characters shown for a fingerprint
+const fingerprintLen = 8
+
+// fingerprinter derives short keyed fingerprints of secret values, so values
+// can be compared without displaying them.
+//
+// The key is random, so fingerprints are only comparable with others from the
+// same fingerprinter, and can't be used to brute force short values offline.
+type fingerprinter struct {
+	key []byte
+}
+
+func newFingerprinter() (*fingerprinter, error) {
+	key := make([]byte, sha256.Size)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fingerprinter{key: key}, nil
+}
+
+// Sum returns the full keyed hash of value.
+func (f *fingerprinter) Sum(value string) []byte {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Short returns the shortened, hex encoded, fingerprint of value.
+func (f *fingerprinter) Short(value string) string {
+	return hex.EncodeToString(f.Sum(value))[:fingerprintLen]
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/errs"
 	"github.com/manifoldco/torus-cli/gatekeeper"
+	"github.com/manifoldco/torus-cli/logging"
 )
 
 var (
@@ -47,6 +48,7 @@ func init() {
 // startGatekeeper starts the machine Gatekeeper
 func startGatekeeperCmd(ctx *cli.Context) error {
 	log.SetOutput(os.Stdout)
+	logging.SetDefault(logging.New(os.Stdout, logging.InfoLevel, logging.LogfmtFormat))
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"text/tabwriter"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/urfave/cli"
 
 	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/errs"
@@ -49,6 +51,41 @@ func init() {
 					setUserEnv, checkRequiredFlags, generateKeypairs,
 				),
 			},
+			{
+				Name:  "rotate",
+				Usage: "Replace your keypairs for an organization, keeping access to its secrets",
+				Flags: []cli.Flag{
+					orgFlag("org to rotate keypairs for", true),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+					setUserEnv, checkRequiredFlags, rotateKeypairs,
+				),
+			},
+			{
+				Name:  "graph",
+				Usage: "Show the public keys and claims in an organization's claim tree",
+				Flags: []cli.Flag{
+					orgFlag("org to show the claim tree for", true),
+					formatFlag("dot", "Format used to display data (dot, json)"),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+					setUserEnv, checkRequiredFlags, graphKeypairs,
+				),
+			},
+			{
+				Name:  "verify",
+				Usage: "Verify the signatures and claims in an organization's claim tree",
+				Flags: []cli.Flag{
+					orgFlag("org to verify the claim tree for", true),
+					formatFlag("table", "Format used to display data (table, json)"),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+					setUserEnv, checkRequiredFlags, verifyKeypairs,
+				),
+			},
 			{
 				Name:  "revoke",
 				Usage: "Revoke the keypairs for an organization (used for testing only)",
@@ -284,3 +321,177 @@ func revokeKeypairs(ctx *cli.Context) error {
 	fmt.Println("Keypairs revoked.")
 	return nil
 }
+
+func rotateKeypairs(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	orgName := ctx.String("org")
+	org, err := client.Orgs.GetByName(c, orgName)
+	if err != nil || org == nil {
+		return errs.NewExitError("Org '" + orgName + "' not found.")
+	}
+
+	fmt.Println("Rotating signing and encryption keypairs for org: " + orgName)
+	err = client.KeyPairs.Rotate(c, org.ID, progress)
+	if err != nil {
+		return errs.NewErrorExitError(fmt.Sprintf(
+			"Error while rotating keypairs. Run '%s keypairs rotate' again to resume.",
+			ctx.App.Name), err)
+	}
+
+	fmt.Println("Keypairs rotated.")
+	return nil
+}
+
+func graphKeypairs(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "dot" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	orgName := ctx.String("org")
+	org, err := client.Orgs.GetByName(c, orgName)
+	if err != nil || org == nil {
+		return errs.NewExitError("Org '" + orgName + "' not found.")
+	}
+
+	graph, err := client.KeyPairs.Graph(c, org.ID)
+	if err != nil {
+		return errs.NewErrorExitError("Could not retrieve claim tree.", err)
+	}
+
+	if format == "json" {
+		return writeKeyringsJSON(os.Stdout, graph)
+	}
+
+	return writeClaimTreeDOT(os.Stdout, graph)
+}
+
+// writeClaimTreeDOT writes the claim tree as a Graphviz digraph. Claims point
+// to the claim or key they follow, and dashed edges point from a signing key
+// to everything it signed.
+func writeClaimTreeDOT(w io.Writer, graph *apitypes.ClaimTreeGraph) error {
+	fmt.Fprintf(w, "digraph %q {\n", graph.OrgName)
+	fmt.Fprintf(w, "  label=%q;\n", "Claim tree for "+graph.OrgName)
+	fmt.Fprintln(w, "  node [fontname=\"monospace\"];")
+
+	for _, k := range graph.Keys {
+		owner := k.OwnerName
+		if owner == "" {
+			owner = k.OwnerID.String()
+		}
+
+		label := fmt.Sprintf("%s key\n%s (%s)\n%s\ncreated %s", k.KeyType, owner,
+			k.OwnerType, k.ID, k.Created.Format(time.RFC3339))
+		color := "black"
+		if k.Revoked {
+			color = "red"
+		}
+
+		fmt.Fprintf(w, "  %q [shape=box, color=%s, label=%q];\n", k.ID.String(), color, label)
+		if k.SignedBy != nil && *k.SignedBy != *k.ID {
+			fmt.Fprintf(w, "  %q -> %q [style=dashed, label=\"signed\"];\n",
+				k.SignedBy.String(), k.ID.String())
+		}
+
+		for _, claim := range k.Claims {
+			label := fmt.Sprintf("%s\n%s\n%s", claim.ClaimType, claim.ID,
+				claim.Created.Format(time.RFC3339))
+			color := "black"
+			if claim.ClaimType == primitive.RevocationClaimType {
+				color = "red"
+			}
+
+			fmt.Fprintf(w, "  %q [shape=ellipse, color=%s, label=%q];\n", claim.ID.String(),
+				color, label)
+			fmt.Fprintf(w, "  %q -> %q;\n", claim.ID.String(), claim.Previous.String())
+			if claim.SignedBy != nil {
+				fmt.Fprintf(w, "  %q -> %q [style=dashed, label=\"signed\"];\n",
+					claim.SignedBy.String(), claim.ID.String())
+			}
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func verifyKeypairs(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	orgName := ctx.String("org")
+	org, err := client.Orgs.GetByName(c, orgName)
+	if err != nil || org == nil {
+		return errs.NewExitError("Org '" + orgName + "' not found.")
+	}
+
+	result, err := client.KeyPairs.Verify(c, org.ID)
+	if err != nil {
+		return errs.NewErrorExitError("Could not verify claim tree.", err)
+	}
+
+	if format == "json" {
+		err = writeKeyringsJSON(os.Stdout, result)
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("")
+		fmt.Printf("Verified %d public key(s) and %d claim(s) for org: %s\n",
+			result.KeyCount, result.ClaimCount, orgName)
+
+		if len(result.Anomalies) > 0 {
+			fmt.Println("")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PUBLIC KEY\tCLAIM\tANOMALY")
+			for _, a := range result.Anomalies {
+				keyID, claimID := "-", "-"
+				if a.PublicKeyID != nil {
+					keyID = a.PublicKeyID.String()
+				}
+				if a.ClaimID != nil {
+					claimID = a.ClaimID.String()
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\n", keyID, claimID, a.Message)
+			}
+			w.Flush()
+		}
+		fmt.Println("")
+	}
+
+	if len(result.Anomalies) > 0 {
+		return errs.NewExitError(fmt.Sprintf("Found %d anomalies in the claim tree.",
+			len(result.Anomalies)))
+	}
+
+	if format == "table" {
+		fmt.Println("No anomalies found.")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+func TestWriteClaimTreeDOT(t *testing.T) {
+	sigID := &identity.ID{0x01, 0x06, 0x01}
+	encID := &identity.ID{0x01, 0x06, 0x02}
+	claimID := &identity.ID{0x01, 0x08, 0x03}
+	ownerID := &identity.ID{0x01, 0x04, 0x04}
+
+	graph := &apitypes.ClaimTreeGraph{
+		OrgName: "knotty-buoy",
+		Keys: []apitypes.ClaimTreeKey{
+			{ID: sigID, KeyType: primitive.SigningKeyType, OwnerID: ownerID, SignedBy: sigID},
+			{
+				ID:       encID,
+				KeyType:  primitive.EncryptionKeyType,
+				OwnerID:  ownerID,
+				Revoked:  true,
+				SignedBy: sigID,
+				Claims: []apitypes.ClaimTreeClaim{{
+					ID:        claimID,
+					ClaimType: primitive.RevocationClaimType,
+					Created:   time.Now(),
+					Previous:  encID,
+					SignedBy:  sigID,
+				}},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := writeClaimTreeDOT(buf, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	edges := []string{
+		`"` + sigID.String() + `" -> "` + encID.String() + `" [style=dashed`,
+		`"` + claimID.String() + `" -> "` + encID.String() + `";`,
+		`"` + sigID.String() + `" -> "` + claimID.String() + `" [style=dashed`,
+	}
+	for _, edge := range edges {
+		if !strings.Contains(out, edge) {
+			t.Errorf("Expected edge %s in:\n%s", edge, out)
+		}
+	}
+
+	if strings.Contains(out, `"`+sigID.String()+`" -> "`+sigID.String()+`"`) {
+		t.Error("Expected no edge for self-signed key")
+	}
+
+	if !strings.HasPrefix(out, `digraph "knotty-buoy" {`) || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("Expected a digraph, got:\n%s", out)
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+func init() {
+	keyrings := cli.Command{
+		Name:     "keyrings",
+		Usage:    "Inspect and re-key the keyrings that encrypt secrets",
+		Category: "SECRETS",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the keyrings in a project",
+				Flags: []cli.Flag{
+					stdOrgFlag,
+					stdProjectFlag,
+					formatFlag("table", "Format used to display data (table, json)"),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+					checkRequiredFlags, listKeyringsCmd,
+				),
+			},
+			{
+				Name:      "view",
+				Usage:     "Show the members and secrets of a keyring",
+				ArgsUsage: "<path>",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "version",
+						Usage: "Show keyring version `VERSION`, rather than the most recent",
+					},
+					formatFlag("table", "Format used to display data (table, json)"),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, viewKeyringCmd,
+				),
+			},
+			{
+				Name:      "rekey",
+				Usage:     "Create a new keyring version for the current members, and re-encrypt its secrets",
+				ArgsUsage: "<path>",
+				Flags: []cli.Flag{
+					stdAutoAcceptFlag,
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, rekeyKeyringCmd,
+				),
+			},
+		},
+	}
+
+	Cmds = append(Cmds, keyrings)
+}
+
+func listKeyringsCmd(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	path := "/" + ctx.String("org") + "/" + ctx.String("project") + "/*/*/*/*"
+	summaries, err := client.Keyrings.List(c, path)
+	if err != nil {
+		return errs.NewErrorExitError("Could not list keyrings", err)
+	}
+
+	if format == "json" {
+		return writeKeyringsJSON(os.Stdout, summaries)
+	}
+
+	fmt.Println()
+	if len(summaries) == 0 {
+		fmt.Println("No keyrings found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVERSION\tMEMBERS\tREVOKED\tSECRETS\tCREATED")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", s.PathExp, s.KeyringVersion,
+			s.MemberCount, s.RevocationCount, s.CredentialCount, s.Created.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+func viewKeyringCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A keyring path is required", ctx)
+	}
+
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	pe, err := pathexp.Parse(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Invalid path expression", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	details, err := client.Keyrings.View(c, pe.String(), ctx.Int("version"))
+	if err != nil {
+		return errs.NewErrorExitError("Could not retrieve keyring", err)
+	}
+
+	if format == "json" {
+		return writeKeyringsJSON(os.Stdout, details)
+	}
+
+	return writeKeyringDetails(os.Stdout, details)
+}
+
+func writeKeyringDetails(w io.Writer, details *apitypes.KeyringDetails) error {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Keyring:  %s\n", details.PathExp)
+	fmt.Fprintf(w, "Version:  %d\n", details.KeyringVersion)
+	fmt.Fprintf(w, "Created:  %s\n", details.Created.Format(time.RFC3339))
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "MEMBER\tTYPE\tENCRYPTING KEY\tMEKSHARE\tSTATUS")
+	for _, m := range details.Members {
+		name := m.Name
+		if name == "" {
+			name = m.OwnerID.String()
+		}
+
+		mekshare := "-"
+		if m.MEKShare {
+			mekshare = "yes"
+		}
+
+		status := "active"
+		if m.Revoked {
+			status = "revoked"
+			if m.RevocationReason != "" {
+				status += " (" + m.RevocationReason + ")"
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, m.OwnerType, m.EncryptingKeyID,
+			mekshare, status)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	if len(details.Credentials) == 0 {
+		fmt.Fprintln(w, "No secrets are stored in this keyring version.")
+	} else {
+		tw = tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "SECRET\tVERSION\tSTATUS")
+		for _, cred := range details.Credentials {
+			status := "previous version"
+			if cred.Current {
+				status = "current"
+			}
+			if cred.Unset {
+				status += ", unset"
+			}
+
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", cred.Path(), cred.CredentialVersion, status)
+		}
+		err = tw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d active member(s), %d revoked. Only your own MEKShares are visible.\n",
+		details.MemberCount, details.RevocationCount)
+	return nil
+}
+
+func rekeyKeyringCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A keyring path is required", ctx)
+	}
+
+	pe, err := pathexp.Parse(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Invalid path expression", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	preamble := fmt.Sprintf("You are about to create a new version of the keyring at %s.\n"+
+		"Only current members of the org will be able to read the secrets it holds.", pe)
+	abortErr := ConfirmDialogue(ctx, nil, &preamble, "", true)
+	if abortErr != nil {
+		return abortErr
+	}
+
+	summary, err := client.Keyrings.Rekey(c, pe.String(), progress)
+	if err != nil {
+		return errs.NewErrorExitError("Could not re-key keyring", err)
+	}
+
+	fmt.Printf("\nCreated version %d of %s with %d member(s), and re-encrypted %d secret(s).\n",
+		summary.KeyringVersion, summary.PathExp, summary.MemberCount, summary.CredentialCount)
+	return nil
+}
+
+func writeKeyringsJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(v)
+	if err != nil {
+		return errs.NewErrorExitError("Could not marshal to json", err)
+	}
+
+	return nil
+}
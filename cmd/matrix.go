@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/pathexp"
+)
+
+// Cell states for the secret matrix
+const (
+	matrixSet       = "set"
+	matrixInherited = "inherited"
+	matrixUnset     = "unset"
+	matrixMissing   = "missing"
+)
+
+func init() {
+	matrix := cli.Command{
+		Name:     "matrix",
+		Usage:    "Compare secrets across every environment and service in a project",
+		Category: "SECRETS",
+		Flags: []cli.Flag{
+			stdOrgFlag,
+			stdProjectFlag,
+			userFlag("Use this user.", false),
+			machineFlag("Use this machine.", false),
+			stdInstanceFlag,
+			formatFlag("table", "Format used to display data (table, json, csv)"),
+			cli.BoolFlag{
+				Name:  "missing-only",
+				Usage: "Only show secrets that are missing from some environments or services",
+			},
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+			setUserEnv, checkRequiredFlags, matrixCmd,
+		),
+	}
+
+	Cmds = append(Cmds, matrix)
+}
+
+// matrixCell describes how a secret resolves for one environment and service
+type matrixCell struct {
+	State       string `json:"state"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Source      string `json:"source,omitempty"`
+}
+
+// matrixRow holds the cells for a single secret, keyed by column
+type matrixRow struct {
+	Name  string                 `json:"name"`
+	Cells map[string]*matrixCell `json:"cells"`
+}
+
+// secretMatrix is a table of secrets against environment/service columns
+type secretMatrix struct {
+	Path    string       `json:"path"`
+	Columns []string     `json:"columns"`
+	Rows    []*matrixRow `json:"rows"`
+}
+
+func matrixCmd(ctx *cli.Context) error {
+	format := ctx.String("format")
+	if format != "table" && format != "json" && format != "csv" {
+		return errs.NewUsageExitError("Unknown format: "+format, ctx)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	session, err := client.Session.Who(c)
+	if err != nil {
+		return err
+	}
+
+	identity, err := deriveIdentity(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	org := ctx.String("org")
+	project := ctx.String("project")
+	pe, err := pathexp.ParsePartial("/" + org + "/" + project)
+	if err != nil {
+		return errs.NewErrorExitError("Invalid org or project", err)
+	}
+
+	tree, err := projectTreeForOrg(c, client, pe)
+	if err != nil {
+		return err
+	}
+
+	projectMap := matchingProjects(pe, *tree)
+	if len(projectMap) == 0 {
+		return errs.NewExitError("Project not found")
+	}
+
+	var envs, services []string
+	for _, e := range tree.Envs {
+		if _, ok := projectMap[e.Body.ProjectID.String()]; ok {
+			envs = append(envs, e.Body.Name)
+		}
+	}
+	for _, s := range tree.Services {
+		if _, ok := projectMap[s.Body.ProjectID.String()]; ok {
+			services = append(services, s.Body.Name)
+		}
+	}
+
+	search := strings.Join([]string{"", org, project, "*", "*", "*", "*"}, "/")
+	creds, err := client.Credentials.SearchHeads(c, search)
+	if err != nil {
+		return errs.NewErrorExitError("Error fetching secrets", err)
+	}
+
+	fp, err := newFingerprinter()
+	if err != nil {
+		return errs.NewErrorExitError("Could not create fingerprint key", err)
+	}
+
+	m := buildSecretMatrix(search, envs, services, identity, ctx.String("instance"), creds, fp.Short)
+	if ctx.Bool("missing-only") {
+		m = m.MissingOnly()
+	}
+
+	w := os.Stdout
+	switch format {
+	case "json":
+		err = writeMatrixJSON(w, m)
+	case "csv":
+		err = writeMatrixCSV(w, m)
+	default:
+		err = writeMatrixTable(w, m)
+	}
+
+	return err
+}
+
+// buildSecretMatrix resolves every secret found in creds for each combination
+// of envs and services, for the given identity and instance.
+//
+// Resolution matches getSecrets: the most specific set credential wins, and
+// unset credentials never provide a value.
+func buildSecretMatrix(path string, envs, services []string, identity, instance string,
+	creds []apitypes.CredentialEnvelope, fingerprint func(string) string) *secretMatrix {
+
+	sort.Strings(envs)
+	sort.Strings(services)
+
+	m := &secretMatrix{Path: path}
+	rows := make(map[string]*matrixRow)
+	for _, cred := range creds {
+		name := (*cred.Body).GetName()
+		if _, ok := rows[name]; !ok {
+			rows[name] = &matrixRow{Name: name, Cells: make(map[string]*matrixCell)}
+			m.Rows = append(m.Rows, rows[name])
+		}
+	}
+	sort.Sort(matrixRowSorter(m.Rows))
+
+	for _, env := range envs {
+		for _, service := range services {
+			column := env + "/" + service
+			m.Columns = append(m.Columns, column)
+
+			cset := credentialSet{}
+			unset := make(map[string]bool)
+			for _, cred := range creds {
+				pe := (*cred.Body).GetPathExp()
+				if !pe.Envs.Contains(env) || !pe.Services.Contains(service) ||
+					!pe.Identities.Contains(identity) || !pe.Instances.Contains(instance) {
+					continue
+				}
+
+				if (*cred.Body).GetValue() == nil {
+					unset[(*cred.Body).GetName()] = true
+					continue
+				}
+
+				// Unset values are skipped above, so Add can't fail
+				cset.Add(cred)
+			}
+
+			for name, row := range rows {
+				cell := &matrixCell{State: matrixMissing}
+				if cred, ok := cset[name]; ok {
+					body := *cred.Body
+					pe := body.GetPathExp()
+
+					cell.State = matrixInherited
+					if pe.Envs.String() == env && pe.Services.String() == service {
+						cell.State = matrixSet
+					}
+					cell.Fingerprint = fingerprint(body.GetValue().String())
+					cell.Source = pe.String() + "/" + name
+				} else if unset[name] {
+					cell.State = matrixUnset
+				}
+
+				row.Cells[column] = cell
+			}
+		}
+	}
+
+	return m
+}
+
+// MissingOnly returns a copy of the matrix containing only the rows that have
+// a value in some columns, but not in others.
+func (m *secretMatrix) MissingOnly() *secretMatrix {
+	res := &secretMatrix{Path: m.Path, Columns: m.Columns}
+	for _, row := range m.Rows {
+		var present, absent bool
+		for _, cell := range row.Cells {
+			switch cell.State {
+			case matrixSet, matrixInherited:
+				present = true
+			default:
+				absent = true
+			}
+		}
+
+		if present && absent {
+			res.Rows = append(res.Rows, row)
+		}
+	}
+
+	return res
+}
+
+// String returns the short display form of a cell.
+func (c *matrixCell) String() string {
+	switch c.State {
+	case matrixSet:
+		return c.Fingerprint
+	case matrixInherited:
+		return c.Fingerprint + "*"
+	case matrixUnset:
+		return "unset"
+	default:
+		return "-"
+	}
+}
+
+func writeMatrixTable(w io.Writer, m *secretMatrix) error {
+	fmt.Fprintf(w, "Credential path: %s\n\n", m.Path)
+
+	if len(m.Rows) == 0 {
+		fmt.Fprintln(w, "No secrets found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 2, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "NAME\t%s\n", strings.Join(m.Columns, "\t"))
+	for _, row := range m.Rows {
+		cells := make([]string, len(m.Columns))
+		for i, col := range m.Columns {
+			cells[i] = row.Cells[col].String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(row.Name), strings.Join(cells, "\t"))
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nValues are shown as fingerprints. * inherited from a shared path, - missing.")
+	return nil
+}
+
+func writeMatrixJSON(w io.Writer, m *secretMatrix) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(m)
+	if err != nil {
+		return errs.NewErrorExitError("Could not marshal to json", err)
+	}
+
+	return nil
+}
+
+func writeMatrixCSV(w io.Writer, m *secretMatrix) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(append([]string{"name"}, m.Columns...))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range m.Rows {
+		record := []string{row.Name}
+		for _, col := range m.Columns {
+			cell := row.Cells[col]
+			value := cell.State
+			if cell.Fingerprint != "" {
+				value += ":" + cell.Fingerprint
+			}
+			record = append(record, value)
+		}
+
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// matrixRowSorter implements sort.Interface, for sorting matrix rows
+// lexicographically by name.
+type matrixRowSorter []*matrixRow
+
+func (m matrixRowSorter) Len() int           { return len(m) }
+func (m matrixRowSorter) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m matrixRowSorter) Less(i, j int) bool { return m[i].Name < m[j].Name }
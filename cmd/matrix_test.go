@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+)
+
+func TestBuildSecretMatrix(t *testing.T) {
+	creds := []apitypes.CredentialEnvelope{
+		explainCredential(t, "/o/p/*/*/*/*", "port", "set", 1),
+		explainCredential(t, "/o/p/prod/api/*/*", "port", "unset", 2),
+		explainCredential(t, "/o/p/dev/api/*/*", "db_url", "set", 1),
+		explainCredential(t, "/o/p/prod/api/*/*", "db_url", "set", 1),
+		explainCredential(t, "/o/p/prod/[api|web]/*/*", "token", "set", 1),
+		explainCredential(t, "/o/p/prod/web/*/*", "token", "unset", 3),
+		explainCredential(t, "/o/p/dev/api/bob/*", "secret", "set", 1),
+		explainCredential(t, "/o/p/dev/*/*/*", "legacy", "unset", 2),
+	}
+
+	fingerprint := func(string) string { return "fp" }
+	m := buildSecretMatrix("/o/p/*/*/*/*", []string{"prod", "dev"}, []string{"web", "api"},
+		"alice", "1", creds, fingerprint)
+
+	columns := []string{"dev/api", "dev/web", "prod/api", "prod/web"}
+	if len(m.Columns) != len(columns) {
+		t.Fatalf("Expected columns %v, got %v", columns, m.Columns)
+	}
+	for i, c := range columns {
+		if m.Columns[i] != c {
+			t.Errorf("Expected column %d to be %s, got %s", i, c, m.Columns[i])
+		}
+	}
+
+	names := []string{"db_url", "legacy", "port", "secret", "token"}
+	if len(m.Rows) != len(names) {
+		t.Fatalf("Expected %d rows, got %d", len(names), len(m.Rows))
+	}
+
+	expected := map[string]map[string]matrixCell{
+		"db_url": {
+			"dev/api":  {State: matrixSet, Fingerprint: "fp", Source: "/o/p/dev/api/*/*/db_url"},
+			"dev/web":  {State: matrixMissing},
+			"prod/api": {State: matrixSet, Fingerprint: "fp", Source: "/o/p/prod/api/*/*/db_url"},
+			"prod/web": {State: matrixMissing},
+		},
+		"legacy": {
+			"dev/api":  {State: matrixUnset},
+			"dev/web":  {State: matrixUnset},
+			"prod/api": {State: matrixMissing},
+			"prod/web": {State: matrixMissing},
+		},
+		"port": {
+			"dev/api":  {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/*/*/*/*/port"},
+			"dev/web":  {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/*/*/*/*/port"},
+			"prod/api": {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/*/*/*/*/port"},
+			"prod/web": {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/*/*/*/*/port"},
+		},
+		"secret": {
+			"dev/api":  {State: matrixMissing},
+			"dev/web":  {State: matrixMissing},
+			"prod/api": {State: matrixMissing},
+			"prod/web": {State: matrixMissing},
+		},
+		"token": {
+			"dev/api":  {State: matrixMissing},
+			"dev/web":  {State: matrixMissing},
+			"prod/api": {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/prod/[api|web]/*/*/token"},
+			"prod/web": {State: matrixInherited, Fingerprint: "fp", Source: "/o/p/prod/[api|web]/*/*/token"},
+		},
+	}
+
+	for i, row := range m.Rows {
+		if row.Name != names[i] {
+			t.Errorf("Expected row %d to be %s, got %s", i, names[i], row.Name)
+			continue
+		}
+
+		for col, cell := range expected[row.Name] {
+			if *row.Cells[col] != cell {
+				t.Errorf("%s in %s: expected %+v, got %+v", row.Name, col, cell, *row.Cells[col])
+			}
+		}
+	}
+
+	t.Run("missing only", func(t *testing.T) {
+		missing := m.MissingOnly()
+
+		names := []string{"db_url", "token"}
+		if len(missing.Rows) != len(names) {
+			t.Fatalf("Expected %d rows, got %d", len(names), len(missing.Rows))
+		}
+		for i, row := range missing.Rows {
+			if row.Name != names[i] {
+				t.Errorf("Expected row %d to be %s, got %s", i, names[i], row.Name)
+			}
+		}
+	})
+}
@@ -454,6 +454,29 @@ func PasswordPrompt(shouldConfirm bool, labelOverride *string) (string, error) {
 	return password, nil
 }
 
+// SecretValuePrompt prompts the user to input a secret value, masking it
+func SecretValuePrompt(label string) (string, error) {
+	preferences, err := prefs.NewPreferences()
+	if err != nil {
+		return "", err
+	}
+
+	prompt := promptui.Prompt{
+		Label: label,
+		Mask:  PasswordMask,
+		Validate: func(input string) error {
+			if len(input) > 0 {
+				return nil
+			}
+
+			return promptui.NewValidationError("Please enter a value")
+		},
+		IsVimMode: preferences.Core.Vim,
+	}
+
+	return prompt.Run()
+}
+
 // EmailPrompt prompts the user to input an email
 func EmailPrompt(defaultValue string) (string, error) {
 	preferences, err := prefs.NewPreferences()
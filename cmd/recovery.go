@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/api"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/errs"
+)
+
+const defaultRecoveryKitFile = "torus-recovery-kit.txt"
+
+func init() {
+	recovery := cli.Command{
+		Name:     "recovery",
+		Usage:    "Export and restore an account recovery kit",
+		Category: "ACCOUNT",
+		Subcommands: []cli.Command{
+			{
+				Name:  "export",
+				Usage: "Write a recovery kit that can reset your password without losing access to secrets",
+				Flags: []cli.Flag{
+					newPlaceholder("output, o", "FILE", "Write the recovery kit to FILE",
+						defaultRecoveryKitFile, "", false),
+				},
+				Action: chain(
+					ensureDaemon, ensureSession, exportRecoveryKitCmd,
+				),
+			},
+			{
+				Name:      "restore",
+				Usage:     "Reset your password with a recovery kit and its recovery code",
+				ArgsUsage: "<kit-file>",
+				Action: chain(
+					ensureDaemon, restoreRecoveryKitCmd,
+				),
+			},
+		},
+	}
+	Cmds = append(Cmds, recovery)
+}
+
+func exportRecoveryKitCmd(ctx *cli.Context) error {
+	output := ctx.String("output")
+	if _, err := os.Stat(output); err == nil {
+		return errs.NewExitError("A file already exists at " + output + ".")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	kit, err := client.Session.ExportRecoveryKit(c)
+	if err != nil {
+		return errs.NewErrorExitError("Could not export recovery kit.", err)
+	}
+
+	err = ioutil.WriteFile(output, []byte(kit.Kit), 0600)
+	if err != nil {
+		return errs.NewErrorExitError("Could not write recovery kit.", err)
+	}
+
+	fmt.Println("")
+	fmt.Printf("Recovery kit written to %s\n", output)
+	fmt.Println("")
+	fmt.Printf("Recovery code: %s\n", kit.Code)
+	fmt.Println("")
+	fmt.Println("This code will not be shown again. Write it down, and store it apart")
+	fmt.Println("from the recovery kit. Anyone with both can access your account.")
+	fmt.Println("")
+	fmt.Println("Changing your password makes this kit unusable; export a new one after")
+	fmt.Println("each password change.")
+
+	return nil
+}
+
+func restoreRecoveryKitCmd(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return errs.NewUsageExitError("A recovery kit file is required", ctx)
+	}
+
+	kit, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return errs.NewErrorExitError("Could not read recovery kit.", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	client := api.NewClient(cfg)
+	c := context.Background()
+
+	session, err := client.Session.Get(c)
+	if err == nil && session.Token {
+		return errs.NewExitError(fmt.Sprintf(
+			"You are logged in. Run '%s logout' before restoring a recovery kit.", ctx.App.Name))
+	}
+
+	code, err := SecretValuePrompt("Recovery Code")
+	if err != nil {
+		return handleSelectError(err, "Recovery code is required.")
+	}
+
+	label := "New Password"
+	password, err := PasswordPrompt(true, &label)
+	if err != nil {
+		return handleSelectError(err, "New password is required.")
+	}
+
+	err = client.Session.RestoreRecoveryKit(c, string(kit), code, password)
+	if err != nil {
+		return errs.NewErrorExitError("Could not restore recovery kit.", err)
+	}
+
+	fmt.Println("")
+	fmt.Println("Your password has been reset, and you are now logged in.")
+	fmt.Println("Export a new recovery kit, as this one can no longer be used.")
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/manifoldco/torus-cli/config"
+
+	"github.com/manifoldco/torus-cli/daemon/utils"
+)
+
+// registryCheck is the result of contacting the registry directly, rather
+// than through the daemon.
+type registryCheck struct {
+	URI       string        `json:"uri"`
+	Reachable bool          `json:"reachable"`
+	CAValid   bool          `json:"ca_valid"`
+	Status    int           `json:"status,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	ClockSkew time.Duration `json:"clock_skew"` // the registry's time less ours
+	Error     string        `json:"error,omitempty"`
+}
+
+const registryCheckTimeout = 10 * time.Second
+
+// checkRegistry requests the registry's version, verifying its certificate
+// against the configured CA bundle, and compares its Date header to the
+// local clock.
+func checkRegistry(ctx context.Context, cfg *config.Config) *registryCheck {
+	check := &registryCheck{URI: cfg.RegistryURI.String()}
+
+	ctx, cancel := context.WithTimeout(ctx, registryCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", strings.TrimRight(check.URI, "/")+"/version", nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	transport := utils.CreateHTTPTransport(cfg.CABundle, strings.Split(cfg.RegistryURI.Host, ":")[0])
+	client := &http.Client{Transport: transport}
+
+	sent := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	received := time.Now()
+	if err != nil {
+		check.Error = err.Error()
+		check.Reachable = !isCertError(err)
+		return check
+	}
+	resp.Body.Close()
+
+	check.Reachable = true
+	check.CAValid = true
+	check.Status = resp.StatusCode
+	check.Latency = received.Sub(sent)
+
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		check.ClockSkew = clockSkew(date, sent, received)
+	}
+
+	return check
+}
+
+// clockSkew estimates how far the registry's clock is ahead of ours, given
+// the time it reported in a response to a request sent and received at the
+// given local times.
+func clockSkew(date, sent, received time.Time) time.Duration {
+	local := sent.Add(received.Sub(sent) / 2)
+
+	// The Date header has a resolution of a second, so smaller differences
+	// are not meaningful.
+	return date.Sub(local).Round(time.Second)
+}
+
+func isCertError(err error) bool {
+	if uErr, ok := err.(*url.Error); ok {
+		err = uErr.Err
+	}
+
+	switch err.(type) {
+	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError:
+		return true
+	}
+	return false
+}
@@ -99,11 +99,14 @@ func runCmd(ctx *cli.Context) error {
 	return nil
 }
 
+// filteredEnv are the environment variables holding credentials, which are
+// removed from the environment of processes started by torus.
+var filteredEnv = []string{"TORUS_EMAIL", "TORUS_PASSWORD", "TORUS_TOKEN_ID", "TORUS_TOKEN_SECRET"}
+
 func filterEnv() []string {
 	env := []string{}
 	for _, e := range os.Environ() {
-		if strings.HasPrefix(e, "TORUS_EMAIL=") || strings.HasPrefix(e, "TORUS_PASSWORD=") ||
-			strings.HasPrefix(e, "TORUS_TOKEN_ID=") || strings.HasPrefix(e, "TORUS_TOKEN_SECRET=") {
+		if isFilteredEnv(e) {
 			continue
 		}
 		env = append(env, e)
@@ -111,3 +114,12 @@ func filterEnv() []string {
 
 	return env
 }
+
+func isFilteredEnv(e string) bool {
+	for _, k := range filteredEnv {
+		if strings.HasPrefix(e, k+"=") {
+			return true
+		}
+	}
+	return false
+}
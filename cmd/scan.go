@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/ui"
+)
+
+const (
+	// minScanLength is the length of the shortest value scanned for. Shorter
+	// values, like ports and booleans, would match far too often.
+	minScanLength = 8
+
+	// binarySniffLen is the number of bytes checked for a NUL byte when
+	// deciding if a file is binary
+	binarySniffLen = 8000
+
+	// rollingBase is the base of the polynomial rolling hash used to find
+	// candidate matches before checking their fingerprints
+	rollingBase = 257
+)
+
+func init() {
+	scan := cli.Command{
+		Name:      "scan",
+		Usage:     "Scan files for secret values, such as before a commit",
+		ArgsUsage: "[paths...]",
+		Category:  "SECRETS",
+		Flags: []cli.Flag{
+			stdOrgFlag,
+			stdProjectFlag,
+			stdEnvFlag,
+			serviceFlag("Use this service.", "default", true),
+			userFlag("Use this user.", false),
+			machineFlag("Use this machine.", false),
+			stdInstanceFlag,
+			cli.BoolFlag{
+				Name:  "staged",
+				Usage: "Scan the lines added in the staged diff, rather than the working tree",
+			},
+		},
+		Action: chain(
+			ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
+			setUserEnv, checkRequiredFlags, scanCmd,
+		),
+	}
+
+	Cmds = append(Cmds, scan)
+}
+
+// scanFinding is an occurrence of a secret value in a file
+type scanFinding struct {
+	File     string
+	Line     int
+	Column   int
+	Name     string
+	Encoding string
+}
+
+func (f *scanFinding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.File, f.Line, f.Column,
+		strings.ToUpper(f.Name), f.Encoding)
+}
+
+// scanContent is content to be scanned, along with the line number in its
+// file of each line of the content.
+type scanContent struct {
+	File  string
+	Data  []byte
+	Lines []int // nil if the content is the whole file
+}
+
+func scanCmd(ctx *cli.Context) error {
+	staged := ctx.Bool("staged")
+	paths := []string(ctx.Args())
+	if staged && len(paths) > 0 {
+		return errs.NewUsageExitError("Paths cannot be used with --staged", ctx)
+	}
+
+	secrets, _, err := getSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	fp, err := newFingerprinter()
+	if err != nil {
+		return errs.NewErrorExitError("Could not create fingerprint key", err)
+	}
+
+	values := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		body := *secret.Body
+		values[body.GetName()] = body.GetValue().String()
+	}
+
+	scanner := newLeakScanner(fp, values)
+
+	var contents []scanContent
+	if staged {
+		contents, err = stagedContents()
+	} else {
+		contents, err = workingTreeContents(paths)
+	}
+	if err != nil {
+		return errs.NewErrorExitError("Could not read files to scan", err)
+	}
+
+	files := make(map[string]bool)
+	var findings []scanFinding
+	for _, content := range contents {
+		for _, f := range scanner.Scan(&content) {
+			findings = append(findings, f)
+			files[f.File] = true
+		}
+	}
+
+	if len(findings) == 0 {
+		ui.Line("No secrets found in %d file(s).", len(contents))
+		return nil
+	}
+
+	for _, f := range findings {
+		fmt.Println(f.String())
+	}
+
+	return errs.NewExitError(fmt.Sprintf("\nFound %d secret(s) in %d file(s).",
+		len(findings), len(files)))
+}
+
+// leakScanner finds occurrences of secret values in content, without holding
+// the values themselves.
+//
+// Each encoding of each value is stored as a keyed fingerprint, and as a
+// rolling hash of its length. Windows of content with a matching rolling hash
+// are then confirmed by their fingerprint.
+type leakScanner struct {
+	fp      *fingerprinter
+	lengths []int
+	rolling map[int]map[uint32]bool
+	known   map[string]leakSource
+}
+
+// leakSource names the secret and encoding that a fingerprint was derived from
+type leakSource struct {
+	Name     string
+	Encoding string
+}
+
+func newLeakScanner(fp *fingerprinter, values map[string]string) *leakScanner {
+	s := &leakScanner{
+		fp:      fp,
+		rolling: make(map[int]map[uint32]bool),
+		known:   make(map[string]leakSource),
+	}
+
+	for name, value := range values {
+		if len(value) < minScanLength {
+			continue
+		}
+
+		for _, enc := range leakEncodings(value) {
+			n := len(enc.value)
+			if _, ok := s.rolling[n]; !ok {
+				s.rolling[n] = make(map[uint32]bool)
+				s.lengths = append(s.lengths, n)
+			}
+
+			s.rolling[n][rollingHash([]byte(enc.value))] = true
+			s.known[string(fp.Sum(enc.value))] = leakSource{Name: name, Encoding: enc.name}
+		}
+	}
+
+	sort.Ints(s.lengths)
+	return s
+}
+
+type leakEncoding struct {
+	name  string
+	value string
+}
+
+// leakEncodings returns the distinct forms a value is likely to be committed
+// in. Base64 values are unpadded, so padded occurrences are found too.
+func leakEncodings(value string) []leakEncoding {
+	candidates := []leakEncoding{
+		{"plaintext", value},
+		{"base64", base64.RawStdEncoding.EncodeToString([]byte(value))},
+		{"base64url", base64.RawURLEncoding.EncodeToString([]byte(value))},
+		{"url encoded", url.QueryEscape(value)},
+		{"url encoded", url.PathEscape(value)},
+	}
+
+	seen := make(map[string]bool)
+	var encodings []leakEncoding
+	for _, c := range candidates {
+		if seen[c.value] {
+			continue
+		}
+
+		seen[c.value] = true
+		encodings = append(encodings, c)
+	}
+
+	return encodings
+}
+
+// Scan returns every occurrence of a secret value in the given content.
+func (s *leakScanner) Scan(content *scanContent) []scanFinding {
+	var findings []scanFinding
+	data := content.Data
+	for _, n := range s.lengths {
+		if len(data) < n {
+			break
+		}
+
+		hashes := s.rolling[n]
+		pow := uint32(1)
+		for i := 1; i < n; i++ {
+			pow *= rollingBase
+		}
+
+		h := rollingHash(data[:n])
+		for i := 0; ; i++ {
+			if hashes[h] {
+				if src, ok := s.known[string(s.fp.Sum(string(data[i:i+n])))]; ok {
+					line, col := content.position(i)
+					findings = append(findings, scanFinding{
+						File:     content.File,
+						Line:     line,
+						Column:   col,
+						Name:     src.Name,
+						Encoding: src.Encoding,
+					})
+				}
+			}
+
+			if i+n >= len(data) {
+				break
+			}
+			h = (h-uint32(data[i])*pow)*rollingBase + uint32(data[i+n])
+		}
+	}
+
+	sort.Sort(scanFindingSorter(findings))
+	return findings
+}
+
+// position returns the line and column, in the original file, of the given
+// offset into the content.
+func (c *scanContent) position(offset int) (int, int) {
+	idx := bytes.Count(c.Data[:offset], []byte{'\n'})
+	col := offset - (bytes.LastIndexByte(c.Data[:offset], '\n') + 1) + 1
+
+	if c.Lines == nil {
+		return idx + 1, col
+	}
+	return c.Lines[idx], col
+}
+
+func rollingHash(b []byte) uint32 {
+	var h uint32
+	for _, c := range b {
+		h = h*rollingBase + uint32(c)
+	}
+	return h
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// workingTreeContents reads the files at the given paths, or in the current
+// directory if none are given.
+//
+// Inside a git repository, files are listed by git, so files ignored by
+// .gitignore are skipped. Outside of one, every file is read.
+func workingTreeContents(paths []string) ([]scanContent, error) {
+	args := append([]string{"ls-files", "-z", "--cached", "--others", "--exclude-standard", "--"}, paths...)
+	out, err := exec.Command("git", args...).Output()
+
+	var files []string
+	if err == nil {
+		for _, f := range strings.Split(string(out), "\x00") {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	} else {
+		files, err = walkFiles(paths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var contents []scanContent
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) { // deleted, but not yet staged
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isBinary(data) {
+			continue
+		}
+
+		contents = append(contents, scanContent{File: f, Data: data})
+	}
+
+	return contents, nil
+}
+
+func walkFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, p := range paths {
+		err := filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.Mode().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// stagedContents returns the lines added in the staged diff, grouped by file.
+func stagedContents() ([]scanContent, error) {
+	out, err := exec.Command("git", "diff", "--cached", "--unified=0", "--no-color",
+		"--no-ext-diff", "--diff-filter=ACMR", "--src-prefix=a/", "--dst-prefix=b/").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStagedDiff(bytes.NewReader(out))
+}
+
+// parseStagedDiff returns the added lines of each file in a unified diff
+// created with no context lines.
+//
+// A "+++ " line is only a file header when it follows the "--- " line of a
+// file's header, before its first hunk. Anywhere else it is an added line
+// starting with "++ ".
+func parseStagedDiff(r io.Reader) ([]scanContent, error) {
+	var contents []scanContent
+	var cur *scanContent
+	line := 0
+	inHeader := false
+	prev := ""
+
+	br := bufio.NewReader(r)
+	for {
+		raw, err := br.ReadBytes('\n')
+		if len(raw) > 0 {
+			text := strings.TrimSuffix(string(raw), "\n")
+			switch {
+			case strings.HasPrefix(text, "diff --git "):
+				inHeader = true
+				cur = nil
+			case inHeader && strings.HasPrefix(text, "+++ ") && strings.HasPrefix(prev, "--- "):
+				contents = append(contents, scanContent{
+					File: strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/"),
+				})
+				cur = &contents[len(contents)-1]
+			case inHeader && !strings.HasPrefix(text, "@@ "):
+				// Other header lines, like "index" or "--- a/file".
+			case strings.HasPrefix(text, "@@ "):
+				inHeader = false
+				line, err = hunkStart(text)
+				if err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(text, "+") && cur != nil:
+				cur.Data = append(cur.Data, text[1:]...)
+				cur.Data = append(cur.Data, '\n')
+				cur.Lines = append(cur.Lines, line)
+				line++
+			}
+			prev = text
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Files with no added lines, like renames, have nothing to scan.
+	var res []scanContent
+	for _, c := range contents {
+		if len(c.Data) > 0 {
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+// hunkStart returns the first line number in the new file of a hunk header,
+// like "@@ -1,2 +3,4 @@".
+func hunkStart(header string) (int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, fmt.Errorf("Malformed hunk header: %s", header)
+	}
+
+	start := strings.SplitN(fields[2][1:], ",", 2)[0]
+	return strconv.Atoi(start)
+}
+
+// scanFindingSorter implements sort.Interface, for sorting findings by their
+// position in the file.
+type scanFindingSorter []scanFinding
+
+func (s scanFindingSorter) Len() int      { return len(s) }
+func (s scanFindingSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s scanFindingSorter) Less(i, j int) bool {
+	if s[i].Line != s[j].Line {
+		return s[i].Line < s[j].Line
+	}
+	return s[i].Column < s[j].Column
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLeakScanner(t *testing.T) {
+	fp, err := newFingerprinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := "s3cr3t/value+with=chars"
+	scanner := newLeakScanner(fp, map[string]string{
+		"token": value,
+		"port":  "8080",
+	})
+
+	content := &scanContent{
+		File: ".env",
+		Data: []byte(strings.Join([]string{
+			"PORT=8080",
+			"TOKEN=" + value,
+			"encoded: " + base64.StdEncoding.EncodeToString([]byte(value)),
+			"https://example.com/?token=" + url.QueryEscape(value),
+			"nothing to see here",
+		}, "\n")),
+	}
+
+	findings := scanner.Scan(content)
+	expected := []scanFinding{
+		{File: ".env", Line: 2, Column: 7, Name: "token", Encoding: "plaintext"},
+		{File: ".env", Line: 3, Column: 10, Name: "token", Encoding: "base64"},
+		{File: ".env", Line: 4, Column: 28, Name: "token", Encoding: "url encoded"},
+	}
+
+	if !reflect.DeepEqual(findings, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, findings)
+	}
+}
+
+func TestParseStagedDiff(t *testing.T) {
+	diff := `diff --git a/.env b/.env
+new file mode 100644
+index 0000000..1111111
+--- /dev/null
++++ b/.env
+@@ -0,0 +1,2 @@
++PORT=8080
++TOKEN=abc
+diff --git a/main.go b/main.go
+index 2222222..3333333 100644
+--- a/main.go
++++ b/main.go
+@@ -10 +10 @@ func main() {
+-	old()
++	updated()
+@@ -20,0 +21,1 @@ func main() {
++	added()
+diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+`
+
+	contents, err := parseStagedDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []scanContent{
+		{File: ".env", Data: []byte("PORT=8080\nTOKEN=abc\n"), Lines: []int{1, 2}},
+		{File: "main.go", Data: []byte("\tupdated()\n\tadded()\n"), Lines: []int{10, 21}},
+	}
+
+	if !reflect.DeepEqual(contents, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, contents)
+	}
+
+	line, col := contents[1].position(len("\tupdated()\n\t"))
+	if line != 21 || col != 2 {
+		t.Errorf("Expected position 21:2, got %d:%d", line, col)
+	}
+}
+
+func TestParseStagedDiffAddedHeaderLikeLines(t *testing.T) {
+	// Added lines starting with "++ " or "-- " look like file headers once
+	// the leading "+" is added, but are part of the hunk.
+	diff := `diff --git a/notes.md b/notes.md
+index 2222222..3333333 100644
+--- a/notes.md
++++ b/notes.md
+@@ -1,0 +1,3 @@
++-- TOKEN=abc
++++ b/other.txt
++SECRET=def
+`
+
+	contents, err := parseStagedDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []scanContent{{
+		File:  "notes.md",
+		Data:  []byte("-- TOKEN=abc\n++ b/other.txt\nSECRET=def\n"),
+		Lines: []int{1, 2, 3},
+	}}
+
+	if !reflect.DeepEqual(contents, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, contents)
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"syscall"
 )
 
-func daemonCommand(executable string) *exec.Cmd {
-	cmd := exec.Command(executable, "daemon", "start", "--foreground", "--daemonize")
+func daemonCommand(executable string, extraArgs ...string) *exec.Cmd {
+	args := append([]string{"daemon", "start", "--foreground", "--daemonize"}, extraArgs...)
+	cmd := exec.Command(executable, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{}
 
 	return cmd
@@ -10,6 +10,7 @@ import (
 	"github.com/manifoldco/torus-cli/api"
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/errs"
+	"github.com/manifoldco/torus-cli/hints"
 	"github.com/manifoldco/torus-cli/prefs"
 
 	"github.com/urfave/cli"
@@ -74,6 +75,16 @@ func statusCmd(ctx *cli.Context) error {
 		return errs.NewErrorExitError("Error fetching identity", err)
 	}
 
+	sessionStatus, err := client.Session.Get(c)
+	if err != nil {
+		return errs.NewErrorExitError("Error fetching session status", err)
+	}
+
+	mode := "read-write"
+	if sessionStatus.ReadOnly {
+		mode = "read-only"
+	}
+
 	err = checkRequiredFlags(ctx)
 	if err != nil {
 		fmt.Printf("You are not inside a linked working directory. "+
@@ -99,11 +110,13 @@ func statusCmd(ctx *cli.Context) error {
 	fmt.Fprintf(w, "Service:\t%s\n", service)
 	fmt.Fprintf(w, "Identity:\t%s\n", identity)
 	fmt.Fprintf(w, "Instance:\t%s\n", instance)
+	fmt.Fprintf(w, "Daemon Mode:\t%s\n", mode)
 	w.Flush()
 
 	parts := []string{"", org, project, env, service, identity, instance}
 	credPath := strings.Join(parts, "/")
 	fmt.Printf("\nCredential path: %s\n", credPath)
 
+	hints.Display(hints.Doctor)
 	return nil
 }
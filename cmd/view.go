@@ -150,23 +150,11 @@ func getSecrets(ctx *cli.Context) ([]apitypes.CredentialEnvelope, string, error)
 	client := api.NewClient(cfg)
 	c := context.Background()
 
-	session, err := client.Session.Who(c)
+	path, err := secretsPath(c, ctx, client)
 	if err != nil {
 		return nil, "", err
 	}
 
-	identity, err := deriveIdentity(ctx, session)
-	if err != nil {
-		return nil, "", err
-	}
-
-	parts := []string{
-		"", ctx.String("org"), ctx.String("project"), ctx.String("environment"),
-		ctx.String("service"), identity, ctx.String("instance"),
-	}
-
-	path := strings.Join(parts, "/")
-
 	secrets, err := client.Credentials.Get(c, path)
 	if err != nil {
 		return nil, "", errs.NewErrorExitError("Error fetching secrets", err)
@@ -181,3 +169,24 @@ func getSecrets(ctx *cli.Context) ([]apitypes.CredentialEnvelope, string, error)
 
 	return cset.ToSlice(), path, nil
 }
+
+// secretsPath returns the path of the secrets for the context described by
+// the org, project, environment, service, identity and instance flags.
+func secretsPath(c context.Context, ctx *cli.Context, client *api.Client) (string, error) {
+	session, err := client.Session.Who(c)
+	if err != nil {
+		return "", err
+	}
+
+	identity, err := deriveIdentity(ctx, session)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{
+		"", ctx.String("org"), ctx.String("project"), ctx.String("environment"),
+		ctx.String("service"), identity, ctx.String("instance"),
+	}
+
+	return strings.Join(parts, "/"), nil
+}
@@ -22,6 +22,9 @@ var catOrder = []apitypes.WorklogType{
 	apitypes.UserKeyringMembersWorklogType,
 	apitypes.MachineKeyringMembersWorklogType,
 	apitypes.SecretRotateWorklogType,
+	apitypes.SecretReusedWorklogType,
+	apitypes.SecretWeakWorklogType,
+	apitypes.SecretStaleWorklogType,
 }
 
 var (
@@ -41,7 +44,13 @@ func init() {
 			{
 				Name:  "list",
 				Usage: "List worklog maintenance tasks",
-				Flags: []cli.Flag{stdOrgFlag},
+				Flags: []cli.Flag{
+					stdOrgFlag,
+					cli.BoolFlag{
+						Name:  "audit",
+						Usage: "Include secrets with weak, reused or stale values",
+					},
+				},
 				Action: chain(
 					ensureDaemon, ensureSession, loadDirPrefs, loadPrefDefaults,
 					checkRequiredFlags, worklogList,
@@ -84,6 +93,12 @@ func groupMsgFor(typ apitypes.WorklogType) string {
 		return "Machines missing granted access to secrets in the %s org:"
 	case apitypes.SecretRotateWorklogType:
 		return "Secrets that should be rotated in the %s org:"
+	case apitypes.SecretReusedWorklogType:
+		return "Secrets with values reused across environments in the %s org:"
+	case apitypes.SecretWeakWorklogType:
+		return "Secrets with weak values in the %s org:"
+	case apitypes.SecretStaleWorklogType:
+		return "Secrets with values that have not changed recently in the %s org:"
 	default:
 		return ""
 	}
@@ -99,6 +114,8 @@ func subjectFor(item *apitypes.WorklogItem) string {
 		return underline(d.Name)
 	case *apitypes.SecretRotateWorklogDetails:
 		return item.Subject()
+	case *apitypes.SecretAuditWorklogDetails:
+		return item.Subject()
 	default:
 		return item.Subject()
 	}
@@ -127,6 +144,15 @@ func detailsFor(org *envelope.Org, item *apitypes.WorklogItem) {
 		u.Line("The value for this secret should be rotated for the following reasons:")
 		c := u.Child(2)
 		for _, r := range d.Reasons {
+			if r.Mark != "" {
+				c.LineIndent(2, "Marked for rotation: %s.", r.Mark)
+				continue
+			}
+			if r.Rekeyed != 0 {
+				c.LineIndent(2, "Its keyring was given a new key in version %d.", r.Rekeyed)
+				continue
+			}
+
 			var rm string
 			switch r.Type {
 			case primitive.OrgRemovalRevocationType:
@@ -139,6 +165,18 @@ func detailsFor(org *envelope.Org, item *apitypes.WorklogItem) {
 
 			c.LineIndent(2, "%s %s", underline(r.Username), rm)
 		}
+	case *apitypes.SecretAuditWorklogDetails:
+		u.Line("%s", d.Summary())
+		if len(d.ReusedBy) > 0 {
+			u.Line("The same value is used by:")
+			c := u.Child(2)
+			for _, p := range d.ReusedBy {
+				c.LineIndent(2, "%s", p)
+			}
+		}
+		if item.Type() == apitypes.SecretStaleWorklogType {
+			u.Line("It was first seen on %s.", d.FirstSeen.Format("2006-01-02"))
+		}
 	default:
 		u.Line(item.Subject())
 	}
@@ -158,7 +196,7 @@ func worklogList(ctx *cli.Context) error {
 		return err
 	}
 
-	items, err := client.Worklog.List(c, org.ID)
+	items, err := client.Worklog.List(c, org.ID, ctx.Bool("audit"))
 	if err != nil {
 		return errs.NewErrorExitError("Could not retrieve worklog items", err)
 	}
@@ -259,7 +297,14 @@ func worklogResolve(ctx *cli.Context) error {
 		idents = append(idents, ident)
 	}
 
-	items, err := client.Worklog.List(c, org.ID)
+	// Audit items are only listed if one was asked for, as finding them
+	// decrypts every secret in the org.
+	audit := false
+	for _, ident := range idents {
+		audit = audit || ident.Type()&apitypes.SecretAuditWorklogType != 0
+	}
+
+	items, err := client.Worklog.List(c, org.ID, audit)
 	if err != nil {
 		return errs.NewErrorExitError("Could not retrieve worklog items", err)
 	}
@@ -319,7 +364,7 @@ func worklogResolve(ctx *cli.Context) error {
 				default:
 					return err
 				}
-			} else if item.Type() == apitypes.SecretRotateWorklogType {
+			} else if manualWorklogType(item.Type()) {
 				displayResult(&item, nil, grouped)
 				continue
 			}
@@ -335,7 +380,7 @@ func worklogResolve(ctx *cli.Context) error {
 func displayResult(item *apitypes.WorklogItem, err error, grouped bool) {
 	icon := promptui.IconGood
 
-	if item.Type() == apitypes.SecretRotateWorklogType {
+	if manualWorklogType(item.Type()) {
 		icon = promptui.IconWarn
 	}
 
@@ -362,6 +407,9 @@ func displayResult(item *apitypes.WorklogItem, err error, grouped bool) {
 		case apitypes.MachineKeyringMembersWorklogType:
 			typ = "reconciling secret access"
 		case apitypes.SecretRotateWorklogType:
+			fallthrough
+		case apitypes.SecretReusedWorklogType, apitypes.SecretWeakWorklogType,
+			apitypes.SecretStaleWorklogType:
 			typ = "rotating secret" // this one will never happen; its manual.
 		}
 
@@ -377,6 +425,9 @@ func displayResult(item *apitypes.WorklogItem, err error, grouped bool) {
 		case apitypes.MachineKeyringMembersWorklogType:
 			message = "Secret access for machine %s has been reconciled."
 		case apitypes.SecretRotateWorklogType:
+			fallthrough
+		case apitypes.SecretReusedWorklogType, apitypes.SecretWeakWorklogType,
+			apitypes.SecretStaleWorklogType:
 			message = "Please set a new value for %s"
 		}
 
@@ -386,3 +437,15 @@ func displayResult(item *apitypes.WorklogItem, err error, grouped bool) {
 	u := ui.Child(indent)
 	u.LineIndent(4, "%s %s %s", icon, idFmt(item.ID.String()), message)
 }
+
+// manualWorklogType returns whether items of the given type must be resolved
+// by the user, by setting a new value for a secret.
+func manualWorklogType(typ apitypes.WorklogType) bool {
+	switch typ {
+	case apitypes.SecretRotateWorklogType, apitypes.SecretReusedWorklogType,
+		apitypes.SecretWeakWorklogType, apitypes.SecretStaleWorklogType:
+		return true
+	default:
+		return false
+	}
+}
@@ -33,6 +33,9 @@ type Config struct {
 	GatekeeperPidPath string
 	DBPath            string
 	LastUpdatePath    string
+	AuditLogPath      string
+	PeerPolicyPath    string
+	DaemonLogPath     string
 
 	RegistryURI *url.URL
 	ManifestURI *url.URL
@@ -81,6 +84,9 @@ func NewConfig(torusRoot string) (*Config, error) {
 		GatekeeperPidPath: path.Join(torusRoot, "gateway.pid"),
 		DBPath:            path.Join(torusRoot, "daemon.db"),
 		LastUpdatePath:    path.Join(torusRoot, "last_update"),
+		AuditLogPath:      path.Join(torusRoot, "audit.log"),
+		PeerPolicyPath:    path.Join(torusRoot, "peer_policy.json"),
+		DaemonLogPath:     path.Join(torusRoot, "daemon.log"),
 
 		RegistryURI:       registryURI,
 		ManifestURI:       manifestURI,
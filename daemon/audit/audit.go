@@ -0,0 +1,284 @@
+// Package audit provides a hash chained, append-only log of the operations
+// performed through the daemon.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/manifoldco/torus-cli/daemon/peer"
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+// Outcomes of an audited operation
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// maxEntrySize is the largest entry that can be read back from the log
+const maxEntrySize = 1024 * 1024
+
+// Entry is a single audited operation. Each entry holds the hash of the
+// entry before it, so that removing or altering an entry breaks the chain.
+type Entry struct {
+	Seq     uint64     `json:"seq"`
+	Time    time.Time  `json:"time"`
+	Peer    *peer.Peer `json:"peer"`
+	Method  string     `json:"method"`
+	Route   string     `json:"route"`
+	PathExp string     `json:"pathexp,omitempty"`
+	Names   []string   `json:"names,omitempty"`
+	Status  int        `json:"status"`
+	Outcome string     `json:"outcome"`
+	Prev    string     `json:"prev"`
+	Hash    string     `json:"hash"`
+}
+
+// digest returns the hash of the entry's contents, including the hash of the
+// previous entry.
+func (e *Entry) digest() (string, error) {
+	c := *e
+	c.Hash = ""
+
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only audit log file.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	prev string
+}
+
+// Open opens the audit log at path, creating it if it does not exist. New
+// entries continue the chain from the last entry in the file.
+func Open(path string) (*Log, error) {
+	l := &Log{}
+
+	entries, malformed, err := ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(malformed) > 0 {
+		logging.Default().Warnf("Skipping malformed audit log lines: %v", malformed)
+	}
+
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		l.seq = last.Seq
+		l.prev = last.Hash
+	}
+
+	l.f, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start on a new line if the last entry was only partially written
+	err = terminateLine(l.f)
+	if err != nil {
+		l.f.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func terminateLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	_, err = f.ReadAt(last, info.Size()-1)
+	if err != nil {
+		return err
+	}
+
+	if last[0] != '\n' {
+		_, err = f.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// Append adds the entry to the end of the log, setting its sequence number
+// and hashes.
+func (l *Log) Append(e *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq = l.seq + 1
+	e.Prev = l.prev
+
+	var err error
+	e.Hash, err = e.digest()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.f.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+
+	l.seq = e.Seq
+	l.prev = e.Hash
+	return nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// ReadFile returns every entry in the audit log at path, and the line
+// numbers of any lines that could not be parsed.
+func ReadFile(path string) ([]Entry, []int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read returns every entry in an audit log, and the line numbers of any lines
+// that could not be parsed, such as an entry left partially written by a
+// crash.
+//
+// Skipping a line does not hide a removed or altered entry, as the entry
+// after it no longer continues the hash chain.
+func Read(r io.Reader) ([]Entry, []int, error) {
+	var entries []Entry
+	var malformed []int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		e := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			malformed = append(malformed, line)
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return entries, malformed, nil
+}
+
+// Problem is a break in the audit log's hash chain.
+type Problem struct {
+	Seq     uint64 `json:"seq"`
+	Message string `json:"message"`
+}
+
+// Verify checks the hash chain of a complete audit log, returning each
+// entry that was altered, removed or reordered.
+//
+// Entries removed from the end of the log can't be detected.
+func Verify(entries []Entry) []Problem {
+	var problems []Problem
+
+	var prev string
+	var seq uint64
+	for _, e := range entries {
+		if e.Seq != seq+1 {
+			problems = append(problems, Problem{e.Seq,
+				fmt.Sprintf("Expected entry %d, found entry %d", seq+1, e.Seq)})
+		}
+
+		if e.Prev != prev {
+			problems = append(problems, Problem{e.Seq,
+				"Previous hash does not match the entry before it"})
+		}
+
+		digest, err := e.digest()
+		if err != nil || digest != e.Hash {
+			problems = append(problems, Problem{e.Seq, "Hash does not match its contents"})
+		}
+
+		seq = e.Seq
+		prev = e.Hash
+	}
+
+	return problems
+}
+
+type ctxKey struct{}
+
+// Record holds the details of an operation that are only known to the route
+// handling it.
+type Record struct {
+	mu      sync.Mutex
+	pathexp string
+	names   []string
+}
+
+// NewContext returns a copy of ctx holding a new Record.
+func NewContext(ctx context.Context) (context.Context, *Record) {
+	r := &Record{}
+	return context.WithValue(ctx, ctxKey{}, r), r
+}
+
+// Annotate records the path or path expression, and the secret names,
+// involved in the operation being handled with ctx. Only the first non-empty
+// path is kept. It does nothing if the operation is not audited.
+func Annotate(ctx context.Context, pathexp string, names ...string) {
+	r, ok := ctx.Value(ctxKey{}).(*Record)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pathexp == "" {
+		r.pathexp = pathexp
+	}
+	r.names = append(r.names, names...)
+}
+
+// Fill copies the annotations into the entry.
+func (r *Record) Fill(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.PathExp = r.pathexp
+	e.Names = append([]string(nil), r.names...)
+}
@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/torus-cli/daemon/peer"
+)
+
+func writeEntries(t *testing.T, path string, n int) {
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < n; i++ {
+		e := &Entry{
+			Time:    time.Now().UTC(),
+			Peer:    &peer.Peer{UID: 1000, GID: 1000, PID: 42, Exe: "/usr/bin/torus"},
+			Method:  "GET",
+			Route:   "/credentials",
+			Status:  200,
+			Outcome: Success,
+		}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "torus-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "audit.log"), func() { os.RemoveAll(dir) }
+}
+
+func readLog(t *testing.T, path string) []Entry {
+	entries, malformed, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(malformed) > 0 {
+		t.Fatalf("Expected no malformed lines, got %v", malformed)
+	}
+	return entries
+}
+
+func TestLogRoundTrip(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	writeEntries(t, path, 3)
+	// Reopening continues the chain
+	writeEntries(t, path, 2)
+
+	entries := readLog(t, path)
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(entries))
+	}
+	if entries[4].Seq != 5 {
+		t.Errorf("Expected last entry to be 5, got %d", entries[4].Seq)
+	}
+	if entries[0].Peer == nil || entries[0].Peer.Exe != "/usr/bin/torus" {
+		t.Errorf("Expected peer to be recorded, got %v", entries[0].Peer)
+	}
+
+	if problems := Verify(entries); len(problems) > 0 {
+		t.Errorf("Expected log to verify, got %+v", problems)
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	writeEntries(t, path, 4)
+	entries := readLog(t, path)
+
+	altered := append([]Entry{}, entries...)
+	altered[1].Route = "/keypairs"
+	if problems := Verify(altered); len(problems) != 1 || problems[0].Seq != 2 {
+		t.Errorf("Expected altered entry 2 to be reported, got %+v", problems)
+	}
+
+	removed := append(append([]Entry{}, entries[:1]...), entries[2:]...)
+	problems := Verify(removed)
+	if len(problems) == 0 || problems[0].Seq != 3 {
+		t.Errorf("Expected removal before entry 3 to be reported, got %+v", problems)
+	}
+
+	// Rehashing an altered entry still breaks the entry after it
+	rehashed := append([]Entry{}, entries...)
+	rehashed[1].Route = "/keypairs"
+	rehashed[1].Hash, _ = rehashed[1].digest()
+	problems = Verify(rehashed)
+	if len(problems) != 1 || problems[0].Seq != 3 {
+		t.Errorf("Expected rehashed entry to break entry 3, got %+v", problems)
+	}
+}
+
+func TestPartialEntry(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	writeEntries(t, path, 2)
+
+	// Simulate a crash part way through writing an entry
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write([]byte(`{"seq":3,"time":`))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeEntries(t, path, 1)
+
+	entries, malformed, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(malformed) != 1 || malformed[0] != 3 {
+		t.Errorf("Expected line 3 to be malformed, got %v", malformed)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if problems := Verify(entries); len(problems) > 0 {
+		t.Errorf("Expected complete entries to verify, got %+v", problems)
+	}
+}
+
+func TestRead(t *testing.T) {
+	entries, malformed, err := Read(bytes.NewBufferString("not json\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 || len(malformed) != 2 {
+		t.Errorf("Expected 2 malformed lines, got %d entries and %v", len(entries), malformed)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	// Annotating an unaudited operation does nothing
+	Annotate(context.Background(), "/o/p/*/*/*/*", "ignored")
+
+	ctx, r := NewContext(context.Background())
+	Annotate(ctx, "/o/p/dev/*/*/*")
+	Annotate(ctx, "/o/p/prod/*/*/*", "a", "b")
+	Annotate(ctx, "", "c")
+
+	e := &Entry{}
+	r.Fill(e)
+	if e.PathExp != "/o/p/dev/*/*/*" {
+		t.Errorf("Expected first path to be kept, got %s", e.PathExp)
+	}
+	if len(e.Names) != 3 || e.Names[2] != "c" {
+		t.Errorf("Expected names to be recorded, got %v", e.Names)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"encoding/base64"
 
 	"golang.org/x/crypto/ed25519"
-	"golang.org/x/crypto/scrypt"
 
 	base64url "github.com/manifoldco/go-base64"
 	"github.com/manifoldco/torus-cli/daemon/ctxutil"
@@ -24,6 +23,7 @@ const (
 	EasyBox    = "easybox"
 	SecretBox  = "secretbox"
 	Scrypt     = "scrypt"
+	Argon2id   = "argon2id"
 )
 
 // scrypt parameter constants
@@ -77,20 +77,16 @@ func DeriveLoginHMAC(ctx context.Context, password []byte, salt, token string) (
 	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
 }
 
+// deriveHash stretches the password with scrypt and the base64url encoded
+// salt.
 func deriveHash(ctx context.Context, password []byte, salt string) ([]byte, error) {
-	s := make([]byte, base64.RawURLEncoding.DecodedLen(len(salt)))
-	l, err := base64.RawURLEncoding.Decode(s, []byte(salt))
+	s, err := base64.RawURLEncoding.DecodeString(salt)
 	if err != nil {
 		return nil, err
 	}
 
-	err = ctxutil.ErrIfDone(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	key, err := scrypt.Key(password, s[:l], n, r, p, keyLen)
-	return key, err
+	params := loginKDFParams
+	return stretchPassword(ctx, password, Scrypt, &params, s, keyLen)
 }
 
 func derivePassword(ctx context.Context, password []byte, salt string) ([]byte, error) {
@@ -128,8 +124,10 @@ func GenerateSalt(ctx context.Context) (*base64url.Value, error) {
 // EncryptPasswordObject derives the master key (if necessary) and password hash
 // from password and salt, returning the master and password objects
 func EncryptPasswordObject(ctx context.Context, password string, currentMasterKey *[]byte) (*primitive.UserPassword, *primitive.MasterKey, error) {
+	params := loginKDFParams
 	pw := &primitive.UserPassword{
-		Alg: Scrypt,
+		Alg:    Scrypt,
+		Params: &params,
 	}
 
 	// Generate 128 bit (16 byte) salt for password
@@ -160,12 +158,9 @@ func EncryptPasswordObject(ctx context.Context, password string, currentMasterKe
 }
 
 // CreateMasterKeyObject generates a 256 byte master key which is then
-// encrypted using TripleSec-v3 using the given password.
+// encrypted using secretbox, with a key derived from the given password using
+// argon2id.
 func CreateMasterKeyObject(ctx context.Context, password []byte, masterKey *[]byte) (*primitive.MasterKey, error) {
-	m := &primitive.MasterKey{
-		Alg: Triplesec,
-	}
-
 	// We either need to generate a new key, or will use the existing
 	// key during a password change scenario
 	key := make([]byte, masterKeyBytes)
@@ -185,19 +180,7 @@ func CreateMasterKeyObject(ctx context.Context, password []byte, masterKey *[]by
 		return nil, err
 	}
 
-	ts, err := newTriplesec(ctx, password)
-	if err != nil {
-		return nil, err
-	}
-
-	ct, err := ts.Encrypt(key)
-	if err != nil {
-		return nil, err
-	}
-
-	// Encode the master key value to base64url
-	m.Value = base64url.New(ct)
-	return m, nil
+	return sealMasterKey(ctx, password, key)
 }
 
 // DeriveLoginKeypair dervies the ed25519 login keypair used for machine
@@ -132,6 +132,30 @@ func (e *Engine) Unseal(ctx context.Context, ct, nonce []byte) ([]byte, error) {
 	return ts.Decrypt(ct)
 }
 
+// DBKey derives the key used to seal values in the daemon's db from the
+// user's master key and the db's salt, via blake2b.
+//
+// Unlike deriveKey, the master key is written to the MAC, so the key can't
+// be derived from the salt alone.
+func (e *Engine) DBKey(ctx context.Context, salt []byte) (*[32]byte, error) {
+	mk, err := e.unsealMasterKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctxutil.ErrIfDone(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h := blake2b.NewMAC(32, salt)
+	h.Write(mk)
+
+	key := [32]byte{}
+	copy(key[:], h.Sum(nil))
+	return &key, nil
+}
+
 // Box encrypts the plaintext pt bytes with Box, using the private key found in
 // privKP, first decrypted with the user's master key, and encrypted for the
 // public key pubKey.
@@ -519,26 +543,15 @@ func (e *Engine) signAndID(ctx context.Context, body identity.Immutable,
 	return &id, &sig, err
 }
 
-// unsealMasterKey uses the scrypt stretched password to decrypt the master
-// password, which is encrypted with triplesec-v3
+// unsealMasterKey uses the session's password to decrypt the master key,
+// with the algorithm recorded in the master key object.
 func (e *Engine) unsealMasterKey(ctx context.Context) ([]byte, error) {
-	ts, err := newTriplesec(ctx, []byte(e.sess.Passphrase()))
-	if err != nil {
-		return nil, err
-	}
-
 	masterKey, err := e.sess.MasterKey()
 	if err != nil {
 		return nil, err
 	}
 
-	err = ctxutil.ErrIfDone(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	mk, err := ts.Decrypt(*masterKey)
-	return mk, err
+	return OpenMasterKeyObject(ctx, e.sess.Passphrase(), masterKey)
 }
 
 func newTriplesec(ctx context.Context, k []byte) (*triplesec.Cipher, error) {
@@ -555,6 +568,18 @@ func newTriplesec(ctx context.Context, k []byte) (*triplesec.Cipher, error) {
 	return ts, nil
 }
 
+// UpgradeMasterKey re-encrypts the master key with the session's password,
+// using the current key derivation function and costs. The password object
+// and login keypair are unchanged.
+func (e *Engine) UpgradeMasterKey(ctx context.Context) (*primitive.MasterKey, error) {
+	mk, err := e.unsealMasterKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateMasterKeyObject(ctx, e.sess.Passphrase(), &mk)
+}
+
 // ChangePassword creates a password object and re-encrypts the master key
 func (e *Engine) ChangePassword(ctx context.Context, newPassword string) (*primitive.UserPassword, *primitive.MasterKey, *primitive.LoginPublicKey, error) {
 	// We need to re-use the master key
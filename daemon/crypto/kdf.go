@@ -0,0 +1,179 @@
+package crypto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	base64url "github.com/manifoldco/go-base64"
+	"github.com/manifoldco/torus-cli/daemon/ctxutil"
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+// loginKDFParams are the scrypt parameters used to derive password hashes and
+// login keypairs. The registry only returns the login salt before login, so
+// they can't be recorded anywhere the daemon could read them from first.
+var loginKDFParams = primitive.KDFParams{N: n, R: r, P: p}
+
+const (
+	secretBoxKeySize   = 32
+	secretBoxNonceSize = 24
+)
+
+var errMasterKeyDecrypt = errors.New("Could not decrypt master key")
+
+func validateKDFParams(alg string, params *primitive.KDFParams) error {
+	if params == nil {
+		return errors.New("Missing key derivation parameters")
+	}
+
+	switch alg {
+	case Argon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return errors.New("argon2id parameters must be positive")
+		}
+		if params.Time > primitive.MaxKDFTime || params.Memory > primitive.MaxKDFMemory {
+			return errors.New("argon2id parameters exceed the maximum allowed costs")
+		}
+	case Scrypt:
+		if params.N <= 1 || params.R <= 0 || params.P <= 0 {
+			return errors.New("scrypt parameters must be positive")
+		}
+	default:
+		return fmt.Errorf("Unknown key derivation function: %s", alg)
+	}
+
+	return nil
+}
+
+// stretchPassword derives a key of the given size from the password and
+// salt.
+func stretchPassword(ctx context.Context, password []byte, alg string,
+	params *primitive.KDFParams, salt []byte, size int) ([]byte, error) {
+
+	err := ctxutil.ErrIfDone(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateKDFParams(alg, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == Scrypt {
+		return scrypt.Key(password, salt, params.N, params.R, params.P, size)
+	}
+
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads,
+		uint32(size)), nil
+}
+
+// weakerKDF returns whether the key derivation costs are lower than the
+// defaults.
+func weakerKDF(alg string, params *primitive.KDFParams) bool {
+	return alg != Argon2id || params == nil ||
+		params.Time < primitive.DefaultKDFParams.Time || params.Memory < primitive.DefaultKDFParams.Memory
+}
+
+// NeedsKDFUpgrade returns whether the master key object was encrypted with
+// an older algorithm, or derived with lower costs than the defaults.
+func NeedsKDFUpgrade(m *primitive.MasterKey) bool {
+	if m == nil {
+		return false
+	}
+
+	return m.Alg != SecretBox || weakerKDF(m.KDF, m.Params)
+}
+
+// sealMasterKey encrypts the master key with secretbox, using a key derived
+// from the password with argon2id.
+func sealMasterKey(ctx context.Context, password, key []byte) (*primitive.MasterKey, error) {
+	salt := make([]byte, saltBytes)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	params := primitive.DefaultKDFParams
+	k, err := stretchPassword(ctx, password, Argon2id, &params, salt, secretBoxKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	var sk [secretBoxKeySize]byte
+	copy(sk[:], k)
+
+	var nonce [secretBoxNonceSize]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &primitive.MasterKey{
+		Alg:    SecretBox,
+		KDF:    Argon2id,
+		Salt:   base64.RawURLEncoding.EncodeToString(salt),
+		Params: &params,
+		Value:  base64url.New(secretbox.Seal(nonce[:], key, &nonce, &sk)),
+	}, nil
+}
+
+// OpenMasterKeyObject decrypts the master key with the given password, using
+// the algorithm and key derivation parameters recorded in the object.
+func OpenMasterKeyObject(ctx context.Context, password []byte, m *primitive.MasterKey) ([]byte, error) {
+	if m == nil || m.Value == nil {
+		return nil, errMasterKeyDecrypt
+	}
+
+	switch m.Alg {
+	case Triplesec:
+		ts, err := newTriplesec(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctxutil.ErrIfDone(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return ts.Decrypt(*m.Value)
+	case SecretBox:
+		salt, err := base64.RawURLEncoding.DecodeString(m.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := stretchPassword(ctx, password, m.KDF, m.Params, salt, secretBoxKeySize)
+		if err != nil {
+			return nil, err
+		}
+
+		var sk [secretBoxKeySize]byte
+		copy(sk[:], k)
+
+		v := *m.Value
+		if len(v) < secretBoxNonceSize {
+			return nil, errMasterKeyDecrypt
+		}
+
+		var nonce [secretBoxNonceSize]byte
+		copy(nonce[:], v[:secretBoxNonceSize])
+
+		key, ok := secretbox.Open(nil, v[secretBoxNonceSize:], &nonce, &sk)
+		if !ok {
+			return nil, errMasterKeyDecrypt
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("Unknown master key algorithm: %s", m.Alg)
+	}
+}
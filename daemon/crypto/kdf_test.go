@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	base64url "github.com/manifoldco/go-base64"
+
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+func TestValidateKDFParams(t *testing.T) {
+	params := &primitive.KDFParams{Time: 1, Memory: primitive.MaxKDFMemory + 1, Threads: 1}
+	if err := validateKDFParams(Argon2id, params); err == nil {
+		t.Error("Expected an error for excessive argon2id memory")
+	}
+
+	if err := validateKDFParams(Argon2id, &primitive.DefaultKDFParams); err != nil {
+		t.Errorf("Expected default argon2id parameters to be valid, got %s", err)
+	}
+
+	if err := validateKDFParams(Scrypt, &loginKDFParams); err != nil {
+		t.Errorf("Expected login scrypt parameters to be valid, got %s", err)
+	}
+}
+
+func TestMasterKeyObject(t *testing.T) {
+	ctx := context.Background()
+	password := []byte("password")
+	key := bytes.Repeat([]byte{0x42}, masterKeyBytes)
+
+	m, err := CreateMasterKeyObject(ctx, password, &key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Alg != SecretBox || m.KDF != Argon2id || *m.Params != primitive.DefaultKDFParams {
+		t.Errorf("Expected argon2id secretbox master key, got %+v", m)
+	}
+
+	opened, err := OpenMasterKeyObject(ctx, password, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, key) {
+		t.Error("Expected master key to match")
+	}
+
+	_, err = OpenMasterKeyObject(ctx, []byte("wrong"), m)
+	if err != errMasterKeyDecrypt {
+		t.Errorf("Expected decrypt error for the wrong password, got %v", err)
+	}
+
+	// Master keys created before key derivation parameters were recorded
+	ts, err := newTriplesec(ctx, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := ts.Encrypt(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := &primitive.MasterKey{Alg: Triplesec, Value: base64url.New(ct)}
+	opened, err = OpenMasterKeyObject(ctx, password, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, key) {
+		t.Error("Expected legacy master key to match")
+	}
+}
+
+func TestNeedsKDFUpgrade(t *testing.T) {
+	current := primitive.DefaultKDFParams
+	weaker := primitive.DefaultKDFParams
+	weaker.Memory /= 2
+
+	tcs := []struct {
+		name   string
+		master *primitive.MasterKey
+		want   bool
+	}{
+		{"current", &primitive.MasterKey{Alg: SecretBox, KDF: Argon2id, Params: &current}, false},
+		{"triplesec", &primitive.MasterKey{Alg: Triplesec}, true},
+		{"weaker", &primitive.MasterKey{Alg: SecretBox, KDF: Argon2id, Params: &weaker}, true},
+		{"missing params", &primitive.MasterKey{Alg: SecretBox, KDF: Argon2id}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NeedsKDFUpgrade(tc.master); got != tc.want {
+				t.Errorf("Expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPasswordObjectLogin(t *testing.T) {
+	ctx := context.Background()
+
+	pw, _, err := EncryptPasswordObject(ctx, "password", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The registry returns the password salt at login; it must be enough to
+	// derive the same login keypair.
+	salt, err := base64url.NewFromString(pw.Salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := DeriveLoginKeypair(ctx, []byte("password"), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := DeriveLoginKeypair(ctx, []byte("password"), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.PublicKey().String() != b.PublicKey().String() {
+		t.Error("Expected login keypairs to match")
+	}
+
+	if pw.Alg != Scrypt || *pw.Params != loginKDFParams {
+		t.Errorf("Expected scrypt password, got %s %+v", pw.Alg, pw.Params)
+	}
+
+	// The salt is sent to the registry as is, without the parameters.
+	raw, err := base64.RawURLEncoding.DecodeString(pw.Salt)
+	if err != nil || len(raw) != saltBytes {
+		t.Errorf("Expected a plain %d byte salt, got %q", saltBytes, pw.Salt)
+	}
+}
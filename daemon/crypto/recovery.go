@@ -0,0 +1,273 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	base64url "github.com/manifoldco/go-base64"
+	"github.com/manifoldco/torus-cli/daemon/ctxutil"
+	"github.com/manifoldco/torus-cli/identity"
+)
+
+const (
+	recoveryKitVersion = 1
+	recoveryCodeBytes  = 20 // 160 bits, encoded as 32 base32 characters
+	recoveryKitBegin   = "-----BEGIN TORUS RECOVERY KIT-----"
+	recoveryKitEnd     = "-----END TORUS RECOVERY KIT-----"
+	recoveryKitSum     = "Checksum: "
+	recoveryKitWidth   = 64
+	ed25519SeedSize    = 32
+)
+
+// Recovery kit errors
+var (
+	ErrRecoveryKitMalformed = errors.New("Recovery kit is malformed")
+	ErrRecoveryKitChecksum  = errors.New("Recovery kit checksum does not match; it may have been altered or mistyped")
+	ErrRecoveryCode         = errors.New("Recovery code is incorrect, or the recovery kit has been altered")
+)
+
+// RecoveryKit holds a user's master key and login key, encrypted with
+// TripleSec-v3 under a recovery code. The login key allows the user to
+// authenticate without their password, so long as it hasn't been changed
+// since the kit was created.
+type RecoveryKit struct {
+	Version int              `json:"version"`
+	UserID  *identity.ID     `json:"user_id"`
+	Email   string           `json:"email"`
+	Created time.Time        `json:"created_at"`
+	Salt    *base64url.Value `json:"salt"`
+	Alg     string           `json:"alg"`
+	Value   *base64url.Value `json:"value"`
+}
+
+type recoverySecrets struct {
+	UserID    *identity.ID     `json:"user_id"`
+	MasterKey *base64url.Value `json:"master_key"`
+	LoginKey  *base64url.Value `json:"login_key"`
+}
+
+// NewRecoveryCode returns a random recovery code, formatted in groups of four
+// characters.
+func NewRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := base32.StdEncoding.EncodeToString(b)
+	groups := make([]string, 0, len(code)/4)
+	for i := 0; i < len(code); i += 4 {
+		groups = append(groups, code[i:i+4])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode removes the formatting from a recovery code, so it
+// can be entered with or without separators, and in any case.
+func normalizeRecoveryCode(code string) []byte {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "", "\t", "").Replace(code)
+	return []byte(code)
+}
+
+// SealRecoveryKit encrypts the master key and login keypair under the given
+// recovery code.
+func SealRecoveryKit(ctx context.Context, code string, userID *identity.ID, email string,
+	masterKey []byte, loginKP *LoginKeypair) (*RecoveryKit, error) {
+
+	secrets := recoverySecrets{
+		UserID:    userID,
+		MasterKey: base64url.New(masterKey),
+		LoginKey:  base64url.New(loginKP.private[:ed25519SeedSize]),
+	}
+
+	pt, err := json.Marshal(&secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := newTriplesec(ctx, normalizeRecoveryCode(code))
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := ts.Encrypt(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryKit{
+		Version: recoveryKitVersion,
+		UserID:  userID,
+		Email:   email,
+		Created: time.Now().UTC(),
+		Salt:    loginKP.Salt(),
+		Alg:     Triplesec,
+		Value:   base64url.New(ct),
+	}, nil
+}
+
+// RecoveryKit seals the current user's master key into a recovery kit under
+// the given recovery code, along with the login keypair derived from their
+// password and the given salt.
+func (e *Engine) RecoveryKit(ctx context.Context, code string, userID *identity.ID,
+	email string, salt *base64url.Value) (*RecoveryKit, error) {
+
+	masterKey, err := e.unsealMasterKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loginKP, err := DeriveLoginKeypair(ctx, e.sess.Passphrase(), salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return SealRecoveryKit(ctx, code, userID, email, masterKey, loginKP)
+}
+
+// Open decrypts the master key and login keypair in the recovery kit.
+func (k *RecoveryKit) Open(ctx context.Context, code string) ([]byte, *LoginKeypair, error) {
+	if k.Version != recoveryKitVersion || k.Alg != Triplesec || k.Value == nil {
+		return nil, nil, ErrRecoveryKitMalformed
+	}
+
+	ts, err := newTriplesec(ctx, normalizeRecoveryCode(code))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pt, err := ts.Decrypt(*k.Value)
+	if err != nil {
+		return nil, nil, ErrRecoveryCode
+	}
+
+	err = ctxutil.ErrIfDone(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := recoverySecrets{}
+	err = json.Unmarshal(pt, &secrets)
+	if err != nil || secrets.UserID == nil || k.UserID == nil || *secrets.UserID != *k.UserID ||
+		secrets.MasterKey == nil || secrets.LoginKey == nil ||
+		len(*secrets.LoginKey) != ed25519SeedSize {
+		return nil, nil, ErrRecoveryKitMalformed
+	}
+
+	// An ed25519 private key is its seed followed by its public key
+	public, private, err := ed25519.GenerateKey(bytes.NewReader(*secrets.LoginKey))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loginKP := &LoginKeypair{
+		public:  public,
+		private: private,
+		salt:    k.Salt,
+	}
+
+	return *secrets.MasterKey, loginKP, nil
+}
+
+// Encode returns the recovery kit as printable text. The kit is armored
+// between begin and end lines, followed by a checksum to catch transcription
+// errors.
+func (k *RecoveryKit) Encode() ([]byte, error) {
+	body, err := json.Marshal(k)
+	if err != nil {
+		return nil, err
+	}
+
+	armored := base64.StdEncoding.EncodeToString(body)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "Torus Recovery Kit")
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "Email:    %s\n", k.Email)
+	fmt.Fprintf(buf, "Created:  %s\n", k.Created.Format(time.RFC3339))
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "Keep this kit somewhere safe, apart from its recovery code. Together")
+	fmt.Fprintln(buf, "they can be used to reset your password with 'torus recovery restore'.")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, recoveryKitBegin)
+	for len(armored) > recoveryKitWidth {
+		fmt.Fprintln(buf, armored[:recoveryKitWidth])
+		armored = armored[recoveryKitWidth:]
+	}
+	fmt.Fprintln(buf, armored)
+	fmt.Fprintln(buf, recoveryKitEnd)
+	fmt.Fprintln(buf, recoveryKitSum+recoveryKitChecksum(body))
+
+	return buf.Bytes(), nil
+}
+
+// DecodeRecoveryKit parses a recovery kit from its printable text, verifying
+// its checksum.
+func DecodeRecoveryKit(b []byte) (*RecoveryKit, error) {
+	var armored, checksum string
+	var inKit, found bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == recoveryKitBegin:
+			inKit = true
+		case line == recoveryKitEnd:
+			inKit = false
+			found = true
+		case inKit:
+			armored += line
+		case found && strings.HasPrefix(line, recoveryKitSum):
+			checksum = strings.TrimPrefix(line, recoveryKitSum)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !found || checksum == "" {
+		return nil, ErrRecoveryKitMalformed
+	}
+
+	body, err := base64.StdEncoding.DecodeString(armored)
+	if err != nil {
+		return nil, ErrRecoveryKitChecksum
+	}
+
+	if !strings.EqualFold(checksum, recoveryKitChecksum(body)) {
+		return nil, ErrRecoveryKitChecksum
+	}
+
+	k := &RecoveryKit{}
+	err = json.Unmarshal(body, k)
+	if err != nil {
+		return nil, ErrRecoveryKitMalformed
+	}
+
+	return k, nil
+}
+
+// recoveryKitChecksum returns the first 8 bytes of the SHA-256 hash of the
+// kit body, in groups of four hex characters.
+func recoveryKitChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	h := hex.EncodeToString(sum[:8])
+	return strings.Join([]string{h[0:4], h[4:8], h[8:12], h[12:16]}, "-")
+}
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	base64url "github.com/manifoldco/go-base64"
+
+	"github.com/manifoldco/torus-cli/identity"
+)
+
+func newTestRecoveryKit(t *testing.T) (*RecoveryKit, string, []byte, *LoginKeypair) {
+	ctx := context.Background()
+
+	code, err := NewRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	masterKey := bytes.Repeat([]byte{0x42}, masterKeyBytes)
+	salt := base64url.New(bytes.Repeat([]byte{0x01}, saltBytes))
+	loginKP, err := DeriveLoginKeypair(ctx, []byte("password"), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := &identity.ID{0x01, 0x01, 0x01}
+	kit, err := SealRecoveryKit(ctx, code, userID, "user@example.com", masterKey, loginKP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kit, code, masterKey, loginKP
+}
+
+// reencode rebuilds a printable kit after changing its body, with a valid
+// checksum.
+func reencode(t *testing.T, kit *RecoveryKit, change func(*RecoveryKit)) []byte {
+	b, err := json.Marshal(kit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := &RecoveryKit{}
+	err = json.Unmarshal(b, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(changed)
+
+	out, err := changed.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestRecoveryKitRoundTrip(t *testing.T) {
+	kit, code, masterKey, loginKP := newTestRecoveryKit(t)
+
+	if len(normalizeRecoveryCode(code))*5 < recoveryCodeBytes*8 {
+		t.Errorf("Expected a recovery code with %d bits of entropy, got %q", recoveryCodeBytes*8, code)
+	}
+
+	b, err := kit.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeRecoveryKit(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Email != kit.Email || *decoded.UserID != *kit.UserID {
+		t.Errorf("Expected kit for %s, got %s", kit.Email, decoded.Email)
+	}
+
+	// Codes may be entered in any case, with or without separators
+	entered := strings.ToLower(strings.Replace(code, "-", " ", -1))
+	mk, kp, err := decoded.Open(context.Background(), entered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(mk, masterKey) {
+		t.Error("Expected master key to match")
+	}
+
+	if kp.PublicKey().String() != loginKP.PublicKey().String() {
+		t.Error("Expected login public key to match")
+	}
+
+	if kp.Salt().String() != loginKP.Salt().String() {
+		t.Error("Expected login salt to match")
+	}
+
+	if kp.Sign([]byte("token")).String() != loginKP.Sign([]byte("token")).String() {
+		t.Error("Expected login keypair to produce the same signatures")
+	}
+}
+
+func TestRecoveryKitTampered(t *testing.T) {
+	kit, code, _, _ := newTestRecoveryKit(t)
+
+	b, err := kit.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(b), "\n")
+	var body int
+	for i, line := range lines {
+		if line == recoveryKitBegin {
+			body = i + 1
+		}
+	}
+
+	t.Run("altered body", func(t *testing.T) {
+		altered := append([]string{}, lines...)
+		line := []byte(altered[body])
+		if line[10] == 'A' {
+			line[10] = 'B'
+		} else {
+			line[10] = 'A'
+		}
+		altered[body] = string(line)
+
+		_, err := DecodeRecoveryKit([]byte(strings.Join(altered, "\n")))
+		if err != ErrRecoveryKitChecksum {
+			t.Errorf("Expected checksum error, got %v", err)
+		}
+	})
+
+	t.Run("missing checksum", func(t *testing.T) {
+		altered := strings.Replace(string(b), recoveryKitSum, "", 1)
+		_, err := DecodeRecoveryKit([]byte(altered))
+		if err != ErrRecoveryKitMalformed {
+			t.Errorf("Expected malformed error, got %v", err)
+		}
+	})
+
+	t.Run("altered ciphertext", func(t *testing.T) {
+		altered := reencode(t, kit, func(k *RecoveryKit) {
+			v := append(base64url.Value{}, *k.Value...)
+			v[len(v)-1] ^= 0xff
+			k.Value = &v
+		})
+
+		decoded, err := DecodeRecoveryKit(altered)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = decoded.Open(context.Background(), code)
+		if err != ErrRecoveryCode {
+			t.Errorf("Expected recovery code error, got %v", err)
+		}
+	})
+
+	t.Run("altered user", func(t *testing.T) {
+		altered := reencode(t, kit, func(k *RecoveryKit) {
+			k.UserID = &identity.ID{0x01, 0x01, 0x02}
+		})
+
+		decoded, err := DecodeRecoveryKit(altered)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = decoded.Open(context.Background(), code)
+		if err != ErrRecoveryKitMalformed {
+			t.Errorf("Expected malformed error, got %v", err)
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		other, err := NewRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = kit.Open(context.Background(), other)
+		if err != ErrRecoveryCode {
+			t.Errorf("Expected recovery code error, got %v", err)
+		}
+	})
+}
@@ -3,7 +3,6 @@ package daemon
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
@@ -14,11 +13,15 @@ import (
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/metrics"
 	"github.com/manifoldco/torus-cli/registry"
 
+	"github.com/manifoldco/torus-cli/daemon/audit"
 	"github.com/manifoldco/torus-cli/daemon/crypto"
 	"github.com/manifoldco/torus-cli/daemon/db"
 	"github.com/manifoldco/torus-cli/daemon/logic"
+	"github.com/manifoldco/torus-cli/daemon/peer"
 	"github.com/manifoldco/torus-cli/daemon/session"
 	"github.com/manifoldco/torus-cli/daemon/socket"
 	"github.com/manifoldco/torus-cli/daemon/updates"
@@ -30,16 +33,18 @@ import (
 type Daemon struct {
 	proxy       *socket.AuthProxy
 	lock        lockfile.Lockfile // actually a string
-	session     session.Session
 	config      *config.Config
 	db          *db.DB
-	logic       *logic.Engine
+	audit       *audit.Log
 	updates     *updates.Engine
 	hasShutdown bool
 }
 
 // New creates a new Daemon.
-func New(cfg *config.Config, groupShared bool) (*Daemon, error) {
+//
+// If readOnly is true, the daemon only serves requests for the session and
+// reading secrets.
+func New(cfg *config.Config, groupShared, readOnly bool) (*Daemon, error) {
 	lock, err := lockfile.New(cfg.PidPath)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create lockfile object: %s", err)
@@ -65,22 +70,79 @@ func New(cfg *config.Config, groupShared bool) (*Daemon, error) {
 		}
 	}
 
+	reg := metrics.NewRegistry()
+	reg.Register(
+		db.CacheLookups,
+		logic.DecryptedCredentials,
+		utils.RegistryRequests,
+		utils.RegistryRequestDurations,
+	)
+
 	db, err := db.NewDB(cfg.DBPath)
 	if err != nil {
 		return nil, err
 	}
 
-	session := session.NewSession()
-	cryptoEngine := crypto.NewEngine(session)
+	policy, err := peer.LoadPolicy(cfg.PeerPolicyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load peer policy: %s", err)
+	}
+	if groupShared && policy == nil {
+		logging.Default().Warnf("Socket is shared with the group, and no peer policy is set at %s",
+			cfg.PeerPolicyPath)
+	}
+	if groupShared && !peer.Supported {
+		logging.Default().Warnf("Socket is shared with the group, but processes connecting " +
+			"to it can't be identified on this platform; all of its users share one session")
+	}
+
+	auditLog, err := audit.Open(cfg.AuditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open audit log: %s", err)
+	}
+
 	transport := utils.CreateHTTPTransport(cfg.CABundle, strings.Split(cfg.RegistryURI.Host, ":")[0])
-	client := registry.NewClient(cfg.RegistryURI.String(), cfg.APIVersion,
-		cfg.Version, session, transport)
-	logic := logic.NewEngine(session, db, cryptoEngine, client)
+
+	// Each user of the daemon gets their own session, with its own crypto
+	// engine and registry client.
+	newUser := func(owner bool) *socket.User {
+		session := session.NewSession()
+		cryptoEngine := crypto.NewEngine(session)
+		client := registry.NewClient(cfg.RegistryURI.String(), cfg.APIVersion,
+			cfg.Version, session, utils.InstrumentTransport(transport))
+
+		return &socket.User{
+			Session: session,
+			Client:  client,
+			Logic:   logic.NewEngine(session, db, cryptoEngine, client, owner),
+		}
+	}
 
 	mTransport := utils.CreateHTTPTransport(cfg.CABundle, strings.Split(cfg.ManifestURI.Host, ":")[0])
 	updates := updates.NewEngine(cfg, mTransport)
 
-	proxy, err := socket.NewAuthProxy(cfg, session, db, transport, client, logic, updates, groupShared)
+	reg.Register(
+		metrics.NewGaugeFunc("torus_daemon_update_available",
+			"Whether a newer version of Torus is available (1) or not (0).",
+			func() float64 {
+				if needsUpdate, _ := updates.VersionInfo(); needsUpdate {
+					return 1
+				}
+				return 0
+			}),
+		metrics.NewGaugeFunc("torus_daemon_update_last_check_timestamp_seconds",
+			"Time of the last successful update check, in seconds since the epoch.",
+			func() float64 {
+				last := updates.LastCheck()
+				if last.IsZero() {
+					return 0
+				}
+				return float64(last.Unix())
+			}),
+	)
+
+	proxy, err := socket.NewAuthProxy(cfg, db, transport, newUser, updates,
+		auditLog, policy, reg, groupShared, readOnly)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create auth proxy: %s", err)
 	}
@@ -88,10 +150,9 @@ func New(cfg *config.Config, groupShared bool) (*Daemon, error) {
 	daemon := &Daemon{
 		proxy:       proxy,
 		lock:        lock,
-		session:     session,
 		config:      cfg,
 		db:          db,
-		logic:       logic,
+		audit:       auditLog,
 		hasShutdown: false,
 		updates:     updates,
 	}
@@ -113,30 +174,30 @@ func (d *Daemon) Run() error {
 	tokenSecret, hasTokenSecret := os.LookupEnv("TORUS_TOKEN_SECRET")
 
 	if hasEmail && hasPassword {
-		log.Printf("Attempting to login as: %s", email)
+		logging.Default().Infof("Attempting to login as: %s", email)
 		userLogin := &apitypes.UserLogin{
 			Email:    email,
 			Password: password,
 		}
 
-		err := d.logic.Session.Login(context.Background(), userLogin)
+		err := d.proxy.Owner().Logic.Session.Login(context.Background(), userLogin)
 		if err != nil {
 			return err
 		}
 	}
 
 	if hasTokenID && hasTokenSecret {
-		log.Printf("Attempting to login as machine token id: %s", tokenID)
+		logging.Default().Infof("Attempting to login as machine token id: %s", tokenID)
 
 		ID, err := identity.DecodeFromString(tokenID)
 		if err != nil {
-			log.Printf("Could not parse TORUS_TOKEN_ID")
+			logging.Default().Errorf("Could not parse TORUS_TOKEN_ID")
 			return err
 		}
 
 		secret, err := base64.NewFromString(tokenSecret)
 		if err != nil {
-			log.Printf("Could not parse TORUS_TOKEN_SECRET")
+			logging.Default().Errorf("Could not parse TORUS_TOKEN_SECRET")
 			return err
 		}
 
@@ -145,14 +206,14 @@ func (d *Daemon) Run() error {
 			Secret:  secret,
 		}
 
-		err = d.logic.Session.Login(context.Background(), machineLogin)
+		err = d.proxy.Owner().Logic.Session.Login(context.Background(), machineLogin)
 		if err != nil {
 			return err
 		}
 	}
 
 	if err := d.updates.Start(); err != nil {
-		log.Printf("cannot start updates checker: %s", err)
+		logging.Default().Errorf("cannot start updates checker: %s", err)
 	}
 
 	return d.proxy.Listen()
@@ -177,6 +238,10 @@ func (d *Daemon) Shutdown() error {
 		return fmt.Errorf("Could not close db: %s", err)
 	}
 
+	if err := d.audit.Close(); err != nil {
+		return fmt.Errorf("Could not close audit log: %s", err)
+	}
+
 	if err := d.updates.Stop(); err != nil {
 		return fmt.Errorf("Could not stop update checker: %s", err)
 	}
@@ -1,58 +1,100 @@
 // Package db provides persistent storage and caching of values returned from
-// the registry. Sensitive data is stored in the db in its encrypted form.
+// the registry.
+//
+// Values are sealed with a key derived from the user's master key, so the db
+// can't be read until their session is unlocked. Only the ids that values
+// are stored under, and the types of cached objects, are in plaintext.
 package db
 
 import (
-	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"time"
 
 	"github.com/boltdb/bolt"
 
+	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/metrics"
 )
 
-var schemaVersion = []byte{0x01}
+// seenBucket holds a bucket for each user of the db, named by their auth id,
+// with the time each object was first seen by them, keyed by id
+var seenBucket = []byte("seen")
 
-// DB is a persistent store for encrypted or non-sensitvie values.
+// rotationBucket holds a bucket for each user of the db, named by their auth
+// id, with the rotation marks they made on credentials, keyed by id
+var rotationBucket = []byte("rotation")
+
+// unsealedSeenBucket and unsealedRotationBucket hold the values of seenBucket
+// and rotationBucket written before values were sealed, until they are sealed
+// by SealUnsealed.
+var (
+	unsealedSeenBucket     = []byte("seen.unsealed")
+	unsealedRotationBucket = []byte("rotation.unsealed")
+)
+
+// RotationMark records that a credential's value must be rotated, for a
+// reason other than a revoked keyring membership.
+type RotationMark struct {
+	Reason string    `json:"reason"`
+	Marked time.Time `json:"marked"`
+}
+
+// DB is a persistent store for values sealed with a user's key.
 type DB struct {
-	db *bolt.DB
+	db   *bolt.DB
+	salt []byte
 }
 
 // NewDB creates a new db or opens an existing db at the given path.
-// If the db already exists but has a mismatched version, it will be cleared
-// before being returned.
+// If the db has an older schema version, it is backed up and migrated to the
+// current version. If it has a newer schema version, a *DowngradeError is
+// returned, and the db is left untouched.
 func NewDB(path string) (*DB, error) {
+	return newDB(path, migrations)
+}
+
+func newDB(path string, ms []migration) (*DB, error) {
 	db := &DB{}
 
-	valid, err := db.initBolt(path)
-	if valid && err == nil {
-		return db, nil
-	}
+	bdb, err := bolt.Open(path, 0600, nil)
+	switch err {
+	case nil:
+	case bolt.ErrInvalid, bolt.ErrChecksum, bolt.ErrVersionMismatch:
+		// The file is not a db we can read. Move it aside, rather than
+		// removing it, in case it can be recovered.
+		corrupt := path + ".corrupt"
+		logging.Default().Warnf("Could not read db (%s); moving it to %s", err, corrupt)
 
-	if !valid {
-		log.Print("DB schema version is incorrect. Clearing db")
-	}
+		if err := os.Rename(path, corrupt); err != nil {
+			return nil, fmt.Errorf("Unable to move unreadable db! Please manually remove %s",
+				path)
+		}
 
-	err = os.Remove(path)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to remove db! Please manually remove %s",
-			path)
+		bdb, err = bolt.Open(path, 0600, nil)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
 	}
+	db.db = bdb
 
-	valid, err = db.initBolt(path)
+	err = db.migrate(path, ms)
+	if err == nil {
+		err = db.loadSalt()
+	}
 	if err != nil {
+		db.db.Close()
 		return nil, err
 	}
 
-	if !valid {
-		return nil, fmt.Errorf("Fatal error initializing db schema version")
-	}
-
 	return db, nil
 }
 
@@ -61,52 +103,19 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
-// initBolt initializes the backing bolt db, checking that the metadata schema
-// version matches what we expect
-func (db *DB) initBolt(path string) (bool, error) {
-	var err error
-	db.db, err = bolt.Open(path, 0600, nil)
-	if err != nil {
-		return false, err
-	}
-
-	return db.checkMeta()
-}
-
-// checkMeta check's the db's metadata, ensuring the version of the db is
-// correct, or setting it if it does not exist.
-func (db *DB) checkMeta() (bool, error) {
-	var version []byte
-	err := db.db.Update(func(tx *bolt.Tx) error {
-		meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
-		if err != nil {
-			return err
-		}
-
-		version = meta.Get([]byte("version"))
-		if version != nil {
-			return nil
-		}
-
-		err = meta.Put([]byte("version"), schemaVersion)
-		if err != nil {
-			return err
-		}
-		version = schemaVersion
-		return nil
-	})
-
-	return bytes.Equal(version, schemaVersion), err
-}
-
-// Set stores the serialized value of env into the db, under key id.
-// Stored values are grouped by their type.
-func (db *DB) Set(envs ...envelope.Envelope) error {
+// Set stores the serialized value of env into the db, under key id, sealed
+// with key. Stored values are grouped by their type.
+func (db *DB) Set(key *[32]byte, envs ...envelope.Envelope) error {
 	return db.db.Update(func(tx *bolt.Tx) error {
 		for _, env := range envs {
 			id := env.GetID()
 
-			b, err := json.Marshal(env)
+			pt, err := json.Marshal(env)
+			if err != nil {
+				return err
+			}
+
+			b, err := seal(key, pt)
 			if err != nil {
 				return err
 			}
@@ -125,19 +134,199 @@ func (db *DB) Set(envs ...envelope.Envelope) error {
 	})
 }
 
-// Get returns the value of id in env. It returns an error if id does not exist.
-func (db *DB) Get(id *identity.ID, env envelope.Envelope) error {
+// CacheLookups counts lookups of objects cached by the daemon, by cache and
+// result.
+var CacheLookups = metrics.NewCounterVec("torus_daemon_cache_lookups_total",
+	"Lookups of objects cached by the daemon, by cache and result (hit or miss).",
+	"cache", "result")
+
+// Get returns the value of id in env. It returns an error if id does not exist,
+// or its value was not sealed with key.
+func (db *DB) Get(key *[32]byte, id *identity.ID, env envelope.Envelope) error {
+	if key == nil {
+		return errNoKey
+	}
+
 	return db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte{id.Type()})
 		if bucket == nil {
+			CacheLookups.Inc("db", "miss")
 			return errors.New("ID not found")
 		}
 
-		b := bucket.Get(id[:])
-		if b == nil {
+		b, ok := open(key, bucket.Get(id[:]))
+		if !ok {
+			CacheLookups.Inc("db", "miss")
 			return errors.New("ID not found")
 		}
 
+		CacheLookups.Inc("db", "hit")
 		return json.Unmarshal(b, env)
 	})
 }
+
+// ownerBucket returns the bucket within parent holding the values of the user
+// with the given auth id, creating both if needed.
+func ownerBucket(tx *bolt.Tx, parent []byte, owner *identity.ID) (*bolt.Bucket, error) {
+	if owner == nil {
+		return nil, errNoKey
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket.CreateBucketIfNotExists(owner[:])
+}
+
+// FirstSeen returns the time the object with the given id was first seen by
+// the user with the given auth id. If they have not seen it before, now is
+// recorded and returned.
+func (db *DB) FirstSeen(key *[32]byte, owner, id *identity.ID, now time.Time) (time.Time, error) {
+	seen := now
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := ownerBucket(tx, seenBucket, owner)
+		if err != nil {
+			return err
+		}
+
+		if b, ok := open(key, bucket.Get(id[:])); ok {
+			return seen.UnmarshalBinary(b)
+		}
+
+		pt, err := now.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		b, err := seal(key, pt)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(id[:], b)
+	})
+
+	return seen, err
+}
+
+// Seen returns the time the object with the given id was first seen by the
+// user with the given auth id, or the zero time if they have not seen it.
+// Unlike FirstSeen, nothing is recorded.
+func (db *DB) Seen(key *[32]byte, owner, id *identity.ID) (time.Time, error) {
+	if key == nil || owner == nil {
+		return time.Time{}, errNoKey
+	}
+
+	var seen time.Time
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(seenBucket)
+		if bucket != nil {
+			bucket = bucket.Bucket(owner[:])
+		}
+		if bucket == nil {
+			return nil
+		}
+
+		if b, ok := open(key, bucket.Get(id[:])); ok {
+			return seen.UnmarshalBinary(b)
+		}
+		return nil
+	})
+
+	return seen, err
+}
+
+// MarkForRotation records that the user with the given auth id marked the
+// credential with the given id for rotation. An existing mark they made on the
+// credential is replaced.
+func (db *DB) MarkForRotation(key *[32]byte, owner, id *identity.ID, mark *RotationMark) error {
+	pt, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+
+	b, err := seal(key, pt)
+	if err != nil {
+		return err
+	}
+
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := ownerBucket(tx, rotationBucket, owner)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(id[:], b)
+	})
+}
+
+// RotationMark returns the mark made on the credential with the given id by
+// the user with the given auth id, or nil if they have not marked it.
+func (db *DB) RotationMark(key *[32]byte, owner, id *identity.ID) (*RotationMark, error) {
+	if key == nil || owner == nil {
+		return nil, errNoKey
+	}
+
+	var mark *RotationMark
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rotationBucket)
+		if bucket != nil {
+			bucket = bucket.Bucket(owner[:])
+		}
+		if bucket == nil {
+			return nil
+		}
+
+		b, ok := open(key, bucket.Get(id[:]))
+		if !ok {
+			return nil
+		}
+
+		mark = &RotationMark{}
+		return json.Unmarshal(b, mark)
+	})
+
+	return mark, err
+}
+
+// Stats returns the number of keys, and bytes of keys and values, stored in
+// each of the db's buckets, including the buckets nested within them. Buckets
+// holding objects are named by the hex encoded type of their objects.
+func (db *DB) Stats() ([]apitypes.BucketStats, error) {
+	stats := []apitypes.BucketStats{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			s := apitypes.BucketStats{Name: bucketName(name)}
+			err := bucketStats(b, &s)
+			stats = append(stats, s)
+			return err
+		})
+	})
+
+	return stats, err
+}
+
+func bucketStats(b *bolt.Bucket, s *apitypes.BucketStats) error {
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if nested := b.Bucket(k); nested != nil {
+				return bucketStats(nested, s)
+			}
+		}
+
+		s.Keys++
+		s.Bytes += len(k) + len(v)
+		return nil
+	})
+}
+
+func bucketName(name []byte) string {
+	for _, c := range name {
+		if c < 0x20 || c > 0x7e {
+			return "0x" + hex.EncodeToString(name)
+		}
+	}
+	return string(name)
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+var (
+	metaBucket = []byte("meta")
+	versionKey = []byte("version")
+)
+
+// migration upgrades the db's schema by one version.
+//
+// If removeBackup is set, the backup taken before migrating is removed once
+// the migration succeeds, as it holds values the migration protects.
+type migration struct {
+	description  string
+	migrate      func(tx *bolt.Tx) error
+	removeBackup bool
+}
+
+// migrations are the steps that upgrade the db's schema, in order. Schema
+// version 1 predates migrations, and migrations[n-1] upgrades the schema from
+// version n to version n+1, so the current schema version is one more than
+// the number of migrations.
+//
+// Migrations must never be removed or reordered once released, as the
+// schema version of existing dbs depends on them.
+var migrations = []migration{
+	{"seal cached values", sealValues, true},
+}
+
+// DowngradeError is returned when the db was written by a newer version of
+// torus, with a schema this version does not understand.
+type DowngradeError struct {
+	Path      string
+	Version   int
+	Supported int
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf("The db at %s has schema version %d, but this version "+
+		"of torus only supports version %d. Upgrade torus, or remove the db "+
+		"to start again with an empty cache.", e.Path, e.Version, e.Supported)
+}
+
+// migrate upgrades the db to the schema version of the last of ms, after
+// backing it up. All of the migrations are run in one transaction, so a
+// failed migration leaves the db as it was.
+func (db *DB) migrate(path string, ms []migration) error {
+	current := len(ms) + 1
+
+	version, err := db.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version == 0: // a new db
+		return db.db.Update(func(tx *bolt.Tx) error {
+			return setSchemaVersion(tx, current)
+		})
+	case version == current:
+		return nil
+	case version > current:
+		return &DowngradeError{Path: path, Version: version, Supported: current}
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	err = db.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(backup, 0600)
+	})
+	if err != nil {
+		return fmt.Errorf("could not back up db before migrating: %s", err)
+	}
+
+	logging.Default().Infof("Migrating db from schema version %d to %d; backed up to %s",
+		version, current, backup)
+
+	removeBackup := false
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		for v := version; v < current; v++ {
+			m := ms[v-1]
+			err := m.migrate(tx)
+			if err != nil {
+				return fmt.Errorf("could not migrate db to schema version %d (%s): %s",
+					v+1, m.description, err)
+			}
+			removeBackup = removeBackup || m.removeBackup
+		}
+
+		return setSchemaVersion(tx, current)
+	})
+	if err != nil || !removeBackup {
+		return err
+	}
+
+	logging.Default().Infof("Removing db backup %s, as it holds unsealed values", backup)
+	return os.Remove(backup)
+}
+
+// schemaVersion returns the schema version of the db, or 0 if it has none.
+func (db *DB) schemaVersion() (int, error) {
+	version := 0
+	err := db.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta == nil {
+			return nil
+		}
+
+		b := meta.Get(versionKey)
+		switch len(b) {
+		case 0:
+			return nil
+		case 1:
+			version = int(b[0])
+			return nil
+		default:
+			return fmt.Errorf("unknown db schema version: %x", b)
+		}
+	})
+
+	return version, err
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	if version > 0xff {
+		return fmt.Errorf("db schema version %d is too large", version)
+	}
+
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	return meta.Put(versionKey, []byte{byte(version)})
+}
+
+// sealValues prepares a version 1 db, whose values are in plaintext, for
+// sealed values. Cached objects are removed, as they can be fetched again
+// from the registry. First seen times and rotation marks can't be, so they're
+// moved aside until they can be sealed by SealUnsealed.
+func sealValues(tx *bolt.Tx) error {
+	var objects [][]byte
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if len(name) == 1 { // object buckets are named by type
+			objects = append(objects, append([]byte{}, name...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range objects {
+		err = tx.DeleteBucket(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = moveBucket(tx, seenBucket, unsealedSeenBucket)
+	if err != nil {
+		return err
+	}
+	return moveBucket(tx, rotationBucket, unsealedRotationBucket)
+}
+
+// moveBucket moves the keys and values of the bucket from into the bucket to,
+// removing from.
+func moveBucket(tx *bolt.Tx, from, to []byte) error {
+	src := tx.Bucket(from)
+	if src == nil {
+		return nil
+	}
+
+	dst, err := tx.CreateBucketIfNotExists(to)
+	if err != nil {
+		return err
+	}
+
+	err = src.ForEach(func(k, v []byte) error {
+		return dst.Put(append([]byte{}, k...), append([]byte{}, v...))
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.DeleteBucket(from)
+}
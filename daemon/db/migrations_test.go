@@ -0,0 +1,235 @@
+package db
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func tempDBPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "torus-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "daemon.db"), func() { os.RemoveAll(dir) }
+}
+
+func openDB(t *testing.T, path string, ms []migration) *DB {
+	db, err := newDB(path, ms)
+	if err != nil {
+		t.Fatalf("could not open db: %s", err)
+	}
+	return db
+}
+
+func assertVersion(t *testing.T, db *DB, expected int) {
+	version, err := db.schemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != expected {
+		t.Errorf("expected schema version %d, got %d", expected, version)
+	}
+}
+
+func put(bucket, key, value string) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), []byte(value))
+	}
+}
+
+func get(t *testing.T, db *DB, bucket, key string) string {
+	var value string
+	err := db.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			value = string(b.Get([]byte(key)))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestNewDBVersion(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	steps := []migration{{"a", put("a", "k", "v"), false}, {"b", put("b", "k", "v"), false}}
+
+	db := openDB(t, path, steps)
+	assertVersion(t, db, 3)
+	db.Close()
+
+	// A new db is created at the current version, without migrating.
+	db = openDB(t, path, steps)
+	defer db.Close()
+	if get(t, db, "a", "k") != "" {
+		t.Error("expected migrations not to run on a new db")
+	}
+
+	if _, err := os.Stat(path + ".v3.bak"); !os.IsNotExist(err) {
+		t.Error("expected no backup when not migrating")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, nil)
+	if err := db.db.Update(put("seen", "id", "cached")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	order := []string{}
+	step := func(name string) func(*bolt.Tx) error {
+		return func(tx *bolt.Tx) error {
+			order = append(order, name)
+			return put(name, "k", "v")(tx)
+		}
+	}
+
+	db = openDB(t, path, []migration{{"two", step("two"), false}, {"three", step("three"), false}})
+	defer db.Close()
+
+	assertVersion(t, db, 3)
+	if len(order) != 2 || order[0] != "two" || order[1] != "three" {
+		t.Errorf("expected migrations to run in order, got %v", order)
+	}
+	if get(t, db, "seen", "id") != "cached" {
+		t.Error("expected existing data to be kept")
+	}
+
+	backup, err := bolt.Open(path+".v1.bak", 0600, nil)
+	if err != nil {
+		t.Fatalf("expected a backup: %s", err)
+	}
+	defer backup.Close()
+
+	backupDB := &DB{db: backup}
+	assertVersion(t, backupDB, 1)
+	if get(t, backupDB, "seen", "id") != "cached" || get(t, backupDB, "two", "k") != "" {
+		t.Error("expected the backup to hold the db before migrating")
+	}
+}
+
+func TestMigrateFromIntermediateVersion(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	ran := []string{}
+	steps := []migration{
+		{"two", func(*bolt.Tx) error { ran = append(ran, "two"); return nil }, false},
+		{"three", func(*bolt.Tx) error { ran = append(ran, "three"); return nil }, false},
+	}
+
+	openDB(t, path, steps[:1]).Close()
+	ran = nil
+
+	db := openDB(t, path, steps)
+	defer db.Close()
+
+	assertVersion(t, db, 3)
+	if len(ran) != 1 || ran[0] != "three" {
+		t.Errorf("expected only the new migration to run, got %v", ran)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	openDB(t, path, nil).Close()
+
+	_, err := newDB(path, []migration{
+		{"two", put("two", "k", "v"), false},
+		{"three", func(*bolt.Tx) error { return errors.New("boom") }, false},
+	})
+	if err == nil {
+		t.Fatal("expected the failed migration to return an error")
+	}
+
+	db := openDB(t, path, nil)
+	defer db.Close()
+
+	assertVersion(t, db, 1)
+	if get(t, db, "two", "k") != "" {
+		t.Error("expected the earlier migration to be rolled back")
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, []migration{{"two", put("two", "k", "v"), false}})
+	if err := db.db.Update(put("seen", "id", "cached")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	_, err := newDB(path, nil)
+	dErr, ok := err.(*DowngradeError)
+	if !ok {
+		t.Fatalf("expected a DowngradeError, got %v", err)
+	}
+	if dErr.Version != 2 || dErr.Supported != 1 || dErr.Path != path {
+		t.Errorf("unexpected error: %+v", dErr)
+	}
+
+	db = openDB(t, path, []migration{{"two", put("two", "k", "v"), false}})
+	defer db.Close()
+	if get(t, db, "seen", "id") != "cached" {
+		t.Error("expected the db to be left untouched")
+	}
+}
+
+func TestUnreadableDB(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	garbage := make([]byte, 8192)
+	for i := range garbage {
+		garbage[i] = 0xab
+	}
+	if err := ioutil.WriteFile(path, garbage, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := openDB(t, path, nil)
+	defer db.Close()
+	assertVersion(t, db, 1)
+
+	kept, err := ioutil.ReadFile(path + ".corrupt")
+	if err != nil || len(kept) != len(garbage) {
+		t.Errorf("expected the unreadable db to be moved aside: %v", err)
+	}
+}
+
+// TestMigrations runs each released migration on a db at the version before
+// it.
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		t.Run(m.description, func(t *testing.T) {
+			path, cleanup := tempDBPath(t)
+			defer cleanup()
+
+			openDB(t, path, migrations[:i]).Close()
+
+			db := openDB(t, path, migrations[:i+1])
+			defer db.Close()
+			assertVersion(t, db, i+2)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"github.com/boltdb/bolt"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"github.com/manifoldco/torus-cli/identity"
+)
+
+const (
+	saltSize  = 16
+	nonceSize = 24
+)
+
+var saltKey = []byte("salt")
+
+// errNoKey is returned when reading or writing the db without a key, before
+// the session has been unlocked.
+var errNoKey = errors.New("The db is sealed; login to unlock it")
+
+// loadSalt reads the salt used when deriving keys for the db, creating it if
+// the db does not have one.
+//
+// The salt is not secret. It is stored with the db so that keys derived for
+// a new db can't open the values of an old one.
+func (db *DB) loadSalt() error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		if salt := meta.Get(saltKey); len(salt) == saltSize {
+			db.salt = append([]byte{}, salt...)
+			return nil
+		}
+
+		salt := make([]byte, saltSize)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return err
+		}
+
+		db.salt = salt
+		return meta.Put(saltKey, salt)
+	})
+}
+
+// Salt returns the salt used when deriving keys for the db.
+func (db *DB) Salt() []byte {
+	return db.salt
+}
+
+// seal encrypts pt with key via secretbox, returning the nonce followed by the
+// ciphertext.
+func seal(key *[32]byte, pt []byte) ([]byte, error) {
+	if key == nil {
+		return nil, errNoKey
+	}
+
+	nonce := [nonceSize]byte{}
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return secretbox.Seal(nonce[:], pt, &nonce, key), nil
+}
+
+// open decrypts a value sealed with seal. It returns false if the value was
+// not sealed with key, such as when it was written by another user of a shared
+// daemon.
+func open(key *[32]byte, b []byte) ([]byte, bool) {
+	if key == nil || len(b) < nonceSize {
+		return nil, false
+	}
+
+	nonce := [nonceSize]byte{}
+	copy(nonce[:], b)
+	return secretbox.Open(nil, b[nonceSize:], &nonce, key)
+}
+
+// SealUnsealed seals the values left unsealed by the migration that
+// introduced sealing, which had no key to seal them with, as values of the
+// user with the given auth id. Values they already have are kept.
+//
+// The unsealed values were written before the db held values for more than
+// one user, so it must only be called for the session of the user running
+// the daemon.
+func (db *DB) SealUnsealed(key *[32]byte, owner *identity.ID) error {
+	if key == nil || owner == nil {
+		return errNoKey
+	}
+
+	return db.db.Update(func(tx *bolt.Tx) error {
+		for _, pair := range [][2][]byte{
+			{unsealedSeenBucket, seenBucket},
+			{unsealedRotationBucket, rotationBucket},
+		} {
+			unsealed := tx.Bucket(pair[0])
+			if unsealed == nil {
+				continue
+			}
+
+			bucket, err := ownerBucket(tx, pair[1], owner)
+			if err != nil {
+				return err
+			}
+
+			err = unsealed.ForEach(func(k, v []byte) error {
+				if bucket.Get(k) != nil {
+					return nil
+				}
+
+				b, err := seal(key, v)
+				if err != nil {
+					return err
+				}
+				return bucket.Put(append([]byte{}, k...), b)
+			})
+			if err != nil {
+				return err
+			}
+
+			err = tx.DeleteBucket(pair[0])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
+)
+
+func testKey(b byte) *[32]byte {
+	key := [32]byte{}
+	for i := range key {
+		key[i] = b
+	}
+	return &key
+}
+
+func testOrg(t *testing.T, name string) *envelope.Org {
+	body := &primitive.Org{Name: name}
+	id, err := identity.NewMutable(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &envelope.Org{ID: &id, Version: 1, Body: body}
+}
+
+// contains reports if any value in the db, including in nested buckets,
+// contains b.
+func contains(t *testing.T, db *DB, b []byte) bool {
+	found := false
+	var search func(*bolt.Bucket) error
+	search = func(bucket *bolt.Bucket) error {
+		return bucket.ForEach(func(k, v []byte) error {
+			if nested := bucket.Bucket(k); nested != nil {
+				return search(nested)
+			}
+			found = found || bytes.Contains(v, b)
+			return nil
+		})
+	}
+
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, bucket *bolt.Bucket) error {
+			return search(bucket)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestSealedObjects(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, migrations)
+	defer db.Close()
+
+	key := testKey(0x01)
+	org := testOrg(t, "secret-org-name")
+	if err := db.Set(key, org); err != nil {
+		t.Fatal(err)
+	}
+
+	if contains(t, db, []byte("secret-org-name")) {
+		t.Error("expected the org to be sealed")
+	}
+
+	got := envelope.Org{}
+	if err := db.Get(key, org.ID, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Body.Name != "secret-org-name" {
+		t.Errorf("expected the org to be unsealed, got %+v", got.Body)
+	}
+
+	if err := db.Get(testKey(0x02), org.ID, &got); err == nil {
+		t.Error("expected another key not to open the org")
+	}
+	if err := db.Get(nil, org.ID, &got); err != errNoKey {
+		t.Errorf("expected errNoKey without a key, got %v", err)
+	}
+	if err := db.Set(nil, org); err != errNoKey {
+		t.Errorf("expected errNoKey without a key, got %v", err)
+	}
+}
+
+func TestSealedFirstSeen(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, migrations)
+	defer db.Close()
+
+	id := testOrg(t, "org").ID
+	alice, bob := testOrg(t, "alice").ID, testOrg(t, "bob").ID
+	key := testKey(0x01)
+	first := time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	seen, err := db.Seen(key, alice, id)
+	if err != nil || !seen.IsZero() {
+		t.Errorf("expected no time before the object is seen, got %s (%v)", seen, err)
+	}
+
+	seen, err = db.FirstSeen(key, alice, id, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen, err = db.Seen(key, alice, id)
+	if err != nil || !seen.Equal(first) {
+		t.Errorf("expected %s, got %s (%v)", first, seen, err)
+	}
+	seen, err = db.FirstSeen(key, alice, id, later)
+	if err != nil || !seen.Equal(first) {
+		t.Errorf("expected %s, got %s (%v)", first, seen, err)
+	}
+
+	// Another user of the daemon records their own time, without replacing
+	// the first user's.
+	seen, err = db.FirstSeen(testKey(0x02), bob, id, later)
+	if err != nil || !seen.Equal(later) {
+		t.Errorf("expected %s, got %s (%v)", later, seen, err)
+	}
+	seen, err = db.FirstSeen(key, alice, id, later)
+	if err != nil || !seen.Equal(first) {
+		t.Errorf("expected %s, got %s (%v)", first, seen, err)
+	}
+
+	if _, err := db.FirstSeen(nil, alice, id, later); err != errNoKey {
+		t.Errorf("expected errNoKey without a key, got %v", err)
+	}
+	if _, err := db.FirstSeen(key, nil, id, later); err != errNoKey {
+		t.Errorf("expected errNoKey without an owner, got %v", err)
+	}
+}
+
+func TestSealedRotationMark(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, migrations)
+	defer db.Close()
+
+	id := testOrg(t, "org").ID
+	alice, bob := testOrg(t, "alice").ID, testOrg(t, "bob").ID
+	key := testKey(0x01)
+	mark := &RotationMark{Reason: "pasted in chat", Marked: time.Now().UTC()}
+
+	if err := db.MarkForRotation(key, alice, id, mark); err != nil {
+		t.Fatal(err)
+	}
+	if contains(t, db, []byte("pasted in chat")) {
+		t.Error("expected the mark to be sealed")
+	}
+
+	got, err := db.RotationMark(key, alice, id)
+	if err != nil || got == nil || got.Reason != mark.Reason {
+		t.Errorf("expected %+v, got %+v (%v)", mark, got, err)
+	}
+
+	got, err = db.RotationMark(testKey(0x02), bob, id)
+	if err != nil || got != nil {
+		t.Errorf("expected no mark for another user, got %+v (%v)", got, err)
+	}
+
+	// Another user's mark does not replace the first user's.
+	other := &RotationMark{Reason: "rotated", Marked: time.Now().UTC()}
+	if err := db.MarkForRotation(testKey(0x02), bob, id, other); err != nil {
+		t.Fatal(err)
+	}
+	got, err = db.RotationMark(key, alice, id)
+	if err != nil || got == nil || got.Reason != mark.Reason {
+		t.Errorf("expected %+v, got %+v (%v)", mark, got, err)
+	}
+}
+
+func TestSalt(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	db := openDB(t, path, migrations)
+	salt := db.Salt()
+	db.Close()
+
+	if len(salt) != saltSize {
+		t.Fatalf("expected a %d byte salt, got %x", saltSize, salt)
+	}
+
+	db = openDB(t, path, migrations)
+	defer db.Close()
+	if !bytes.Equal(db.Salt(), salt) {
+		t.Error("expected the salt to be kept")
+	}
+}
+
+func TestSealValuesMigration(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	org := testOrg(t, "secret-org-name")
+	first := time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC)
+	mark := &RotationMark{Reason: "pasted in chat", Marked: first}
+
+	// Write the db as version 1 did, in plaintext.
+	db := openDB(t, path, nil)
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		b, err := json.Marshal(org)
+		if err != nil {
+			return err
+		}
+		err = put(string([]byte{org.ID.Type()}), string(org.ID[:]), string(b))(tx)
+		if err != nil {
+			return err
+		}
+
+		seen, err := first.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		err = put(string(seenBucket), string(org.ID[:]), string(seen))(tx)
+		if err != nil {
+			return err
+		}
+
+		m, err := json.Marshal(mark)
+		if err != nil {
+			return err
+		}
+		return put(string(rotationBucket), string(org.ID[:]), string(m))(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db = openDB(t, path, migrations)
+	defer db.Close()
+
+	if contains(t, db, []byte("secret-org-name")) {
+		t.Error("expected cached objects to be removed")
+	}
+	if _, err := os.Stat(path + ".v1.bak"); !os.IsNotExist(err) {
+		t.Error("expected the unsealed backup to be removed")
+	}
+
+	key := testKey(0x01)
+	owner := testOrg(t, "owner").ID
+	if err := db.SealUnsealed(key, owner); err != nil {
+		t.Fatal(err)
+	}
+	if contains(t, db, []byte("pasted in chat")) {
+		t.Error("expected the mark to be sealed")
+	}
+
+	seen, err := db.FirstSeen(key, owner, org.ID, time.Now())
+	if err != nil || !seen.Equal(first) {
+		t.Errorf("expected %s, got %s (%v)", first, seen, err)
+	}
+
+	got, err := db.RotationMark(key, owner, org.ID)
+	if err != nil || got == nil || got.Reason != mark.Reason {
+		t.Errorf("expected %+v, got %+v (%v)", mark, got, err)
+	}
+
+	err = db.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(unsealedSeenBucket) != nil || tx.Bucket(unsealedRotationBucket) != nil {
+			t.Error("expected the unsealed buckets to be removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
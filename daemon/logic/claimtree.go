@@ -0,0 +1,500 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/crypto"
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+// ClaimTreeGraph returns the public keys in the given org's claim tree, with
+// their owners, the claims made against them, and the keys that signed them.
+func (e *Engine) ClaimTreeGraph(ctx context.Context, notifier *observer.Notifier,
+	orgID *identity.ID) (*apitypes.ClaimTreeGraph, error) {
+
+	n := notifier.Notifier(2)
+
+	tree, err := e.client.ClaimTree.Get(ctx, orgID, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving claim tree: %s", err)
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Claim tree retrieved", true)
+
+	graph := claimTreeGraph(tree)
+
+	names, err := e.keyOwnerNames(ctx, orgID, graph.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, k := range graph.Keys {
+		graph.Keys[i].OwnerName = names[*k.OwnerID]
+	}
+
+	n.Notify(observer.Progress, "Key owners retrieved", true)
+
+	return graph, nil
+}
+
+// VerifyClaimTree checks every public key and claim in the given org's claim
+// tree, returning each anomaly found.
+func (e *Engine) VerifyClaimTree(ctx context.Context, notifier *observer.Notifier,
+	orgID *identity.ID) (*apitypes.ClaimTreeVerification, error) {
+
+	n := notifier.Notifier(2)
+
+	tree, err := e.client.ClaimTree.Get(ctx, orgID, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving claim tree: %s", err)
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Claim tree retrieved", true)
+
+	result := verifyClaimTree(tree)
+
+	n.Notify(observer.Progress, "Claim tree verified", true)
+
+	return result, nil
+}
+
+// keyOwnerNames returns the usernames and machine names of the given keys'
+// owners.
+func (e *Engine) keyOwnerNames(ctx context.Context, orgID *identity.ID,
+	keys []apitypes.ClaimTreeKey) (map[identity.ID]string, error) {
+
+	names := make(map[identity.ID]string)
+
+	var userIDs []identity.ID
+	var hasMachines bool
+	for _, k := range keys {
+		switch k.OwnerType {
+		case "user":
+			userIDs = append(userIDs, *k.OwnerID)
+		case "machine":
+			hasMachines = true
+		}
+	}
+
+	if len(userIDs) > 0 {
+		users, err := e.client.Profiles.ListByID(ctx, userIDs)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error looking up key owners: %s", err)
+			return nil, err
+		}
+
+		for _, user := range users {
+			names[*user.ID] = user.Body.Username
+		}
+	}
+
+	if hasMachines {
+		machines, err := e.client.Machines.List(ctx, orgID, nil, nil, nil)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error looking up machines: %s", err)
+			return nil, err
+		}
+
+		// Machine keys are owned by the machine's tokens
+		for _, m := range machines {
+			for _, t := range m.Tokens {
+				names[*t.Token.ID] = m.Machine.Body.Name
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// claimTreeGraph describes the public keys and claims in the claim tree,
+// sorted by owner and creation time.
+func claimTreeGraph(tree *registry.ClaimTree) *apitypes.ClaimTreeGraph {
+	graph := &apitypes.ClaimTreeGraph{
+		OrgID: tree.Org.ID,
+		Keys:  make([]apitypes.ClaimTreeKey, 0, len(tree.PublicKeys)),
+	}
+	if tree.Org.Body != nil {
+		graph.OrgName = tree.Org.Body.Name
+	}
+
+	for _, seg := range tree.PublicKeys {
+		pk := seg.PublicKey
+
+		key := apitypes.ClaimTreeKey{
+			ID:        pk.ID,
+			KeyType:   pk.Body.KeyType,
+			OwnerID:   pk.Body.OwnerID,
+			OwnerType: ownerType(pk.Body.OwnerID),
+			Created:   pk.Body.Created,
+			Expires:   pk.Body.Expires,
+			Revoked:   seg.Revoked(),
+			SignedBy:  signedBy(pk.ID, &pk.Signature),
+			Claims:    make([]apitypes.ClaimTreeClaim, 0, len(seg.Claims)),
+		}
+
+		for _, c := range seg.Claims {
+			key.Claims = append(key.Claims, apitypes.ClaimTreeClaim{
+				ID:        c.ID,
+				ClaimType: c.Body.ClaimType,
+				Created:   c.Body.Created,
+				Previous:  c.Body.Previous,
+				SignedBy:  c.Signature.PublicKeyID,
+			})
+		}
+
+		sort.Sort(claimTreeClaimSorter(key.Claims))
+		graph.Keys = append(graph.Keys, key)
+	}
+
+	sort.Sort(claimTreeKeySorter(graph.Keys))
+	return graph
+}
+
+// signedBy returns the id of the key that signed an object. Self-signed keys
+// don't include a public key id in their signature.
+func signedBy(id *identity.ID, sig *primitive.Signature) *identity.ID {
+	if sig.PublicKeyID == nil {
+		return id
+	}
+
+	return sig.PublicKeyID
+}
+
+// claimTreeVerifier accumulates the anomalies found in a claim tree.
+type claimTreeVerifier struct {
+	tree      *registry.ClaimTree
+	keys      map[identity.ID]*apitypes.PublicKeySegment
+	anomalies []apitypes.ClaimTreeAnomaly
+}
+
+// verifyClaimTree checks the self-signatures, claim signatures, claim chains,
+// and revocation ordering of every public key in the claim tree.
+func verifyClaimTree(tree *registry.ClaimTree) *apitypes.ClaimTreeVerification {
+	v := &claimTreeVerifier{
+		tree: tree,
+		keys: make(map[identity.ID]*apitypes.PublicKeySegment, len(tree.PublicKeys)),
+	}
+
+	result := &apitypes.ClaimTreeVerification{OrgID: tree.Org.ID}
+	for i, seg := range tree.PublicKeys {
+		if seg.PublicKey == nil || seg.PublicKey.ID == nil || seg.PublicKey.Body == nil {
+			v.add(nil, nil, "Public key is missing its id or body")
+			continue
+		}
+
+		v.keys[*seg.PublicKey.ID] = &tree.PublicKeys[i]
+	}
+
+	for _, seg := range tree.PublicKeys {
+		if seg.PublicKey == nil || seg.PublicKey.ID == nil || seg.PublicKey.Body == nil {
+			continue
+		}
+
+		result.KeyCount++
+		result.ClaimCount += len(seg.Claims)
+		v.verifyPublicKey(&seg)
+		v.verifyClaims(&seg)
+	}
+
+	result.Anomalies = v.anomalies
+	return result
+}
+
+func (v *claimTreeVerifier) add(keyID, claimID *identity.ID, format string, a ...interface{}) {
+	v.anomalies = append(v.anomalies, apitypes.ClaimTreeAnomaly{
+		PublicKeyID: keyID,
+		ClaimID:     claimID,
+		Message:     fmt.Sprintf(format, a...),
+	})
+}
+
+// verifyPublicKey checks the public key's id, org, and signature.
+func (v *claimTreeVerifier) verifyPublicKey(seg *apitypes.PublicKeySegment) {
+	pk := seg.PublicKey
+	body := pk.Body
+
+	if !idMatches(pk.ID, body, &pk.Signature) {
+		v.add(pk.ID, nil, "Public key id does not match its contents")
+	}
+
+	switch {
+	case body.OrgID == nil:
+		v.add(pk.ID, nil, "Public key is missing its org")
+	case v.tree.Org.ID != nil && *body.OrgID != *v.tree.Org.ID:
+		v.add(pk.ID, nil, "Public key belongs to a different org")
+	}
+
+	if body.OwnerID == nil {
+		v.add(pk.ID, nil, "Public key is missing its owner")
+	}
+
+	if !body.Created.Before(body.Expires) {
+		v.add(pk.ID, nil, "Public key expires before it was created")
+	}
+
+	// Signing keys sign themselves. Encryption keys are signed by a signing
+	// key of the same owner.
+	if pk.Signature.PublicKeyID == nil {
+		if body.KeyType != primitive.SigningKeyType {
+			v.add(pk.ID, nil, "Encryption key is self-signed")
+			return
+		}
+
+		if !verifySignature(seg, body, &pk.Signature) {
+			v.add(pk.ID, nil, "Public key self-signature is invalid")
+		}
+		return
+	}
+
+	signer := v.signer(pk.ID, nil, &pk.Signature, body.Created)
+	if signer == nil {
+		return
+	}
+
+	if differentOwners(signer.PublicKey.Body, body) {
+		v.add(pk.ID, nil, "Public key was signed by key %s, which belongs to another owner",
+			signer.PublicKey.ID)
+	}
+
+	if !verifySignature(signer, body, &pk.Signature) {
+		v.add(pk.ID, nil, "Public key signature by key %s is invalid", signer.PublicKey.ID)
+	}
+}
+
+// verifyClaims checks each claim's id and signature, the chain of previous
+// claims from the public key, and that nothing follows a revocation.
+func (v *claimTreeVerifier) verifyClaims(seg *apitypes.PublicKeySegment) {
+	pk := seg.PublicKey
+
+	if len(seg.Claims) == 0 {
+		v.add(pk.ID, nil, "Public key has no signature claim")
+		return
+	}
+
+	claims := make(map[identity.ID]*primitive.Claim, len(seg.Claims))
+	children := make(map[identity.ID][]*identity.ID, len(seg.Claims))
+	for _, c := range seg.Claims {
+		if c.ID == nil || c.Body == nil || c.Body.Previous == nil {
+			v.add(pk.ID, c.ID, "Claim is missing its id, body, or previous claim")
+			continue
+		}
+
+		claims[*c.ID] = c.Body
+		children[*c.Body.Previous] = append(children[*c.Body.Previous], c.ID)
+	}
+
+	for _, c := range seg.Claims {
+		if c.ID == nil || c.Body == nil || c.Body.Previous == nil {
+			continue
+		}
+
+		body := c.Body
+		if !idMatches(c.ID, body, &c.Signature) {
+			v.add(pk.ID, c.ID, "Claim id does not match its contents")
+		}
+
+		if body.PublicKeyID == nil || *body.PublicKeyID != *pk.ID {
+			v.add(pk.ID, c.ID, "Claim is made against a different public key")
+		}
+
+		switch {
+		case body.OrgID == nil:
+			v.add(pk.ID, c.ID, "Claim is missing its org")
+		case pk.Body.OrgID != nil && *body.OrgID != *pk.Body.OrgID:
+			v.add(pk.ID, c.ID, "Claim belongs to a different org")
+		}
+
+		if body.Created.Before(pk.Body.Created) {
+			v.add(pk.ID, c.ID, "Claim was made before its public key was created")
+		}
+
+		signer := v.signer(pk.ID, c.ID, &c.Signature, body.Created)
+		if signer != nil {
+			if differentOwners(signer.PublicKey.Body, pk.Body) {
+				v.add(pk.ID, c.ID, "Claim was signed by key %s, which belongs to another owner",
+					signer.PublicKey.ID)
+			}
+
+			if !verifySignature(signer, body, &c.Signature) {
+				v.add(pk.ID, c.ID, "Claim signature by key %s is invalid", signer.PublicKey.ID)
+			}
+		}
+
+		if *body.Previous == *pk.ID {
+			if body.ClaimType != primitive.SignatureClaimType {
+				v.add(pk.ID, c.ID, "First claim is a %s claim, not a signature claim", body.ClaimType)
+			}
+		} else if prev, ok := claims[*body.Previous]; !ok {
+			v.add(pk.ID, c.ID, "Previous claim %s does not exist", body.Previous)
+		} else {
+			if body.Created.Before(prev.Created) {
+				v.add(pk.ID, c.ID, "Claim was made before its previous claim")
+			}
+
+			if prev.ClaimType == primitive.RevocationClaimType {
+				v.add(pk.ID, c.ID, "Claim was made after the public key was revoked")
+			}
+		}
+
+		if len(children[*c.ID]) > 1 {
+			v.add(pk.ID, c.ID, "Claim chain forks: %d claims follow this claim",
+				len(children[*c.ID]))
+		}
+	}
+
+	roots := children[*pk.ID]
+	switch len(roots) {
+	case 0:
+		v.add(pk.ID, nil, "No claim follows from the public key")
+	case 1:
+	default:
+		v.add(pk.ID, nil, "Claim chain forks: %d claims follow the public key", len(roots))
+	}
+
+	// Claims whose previous claim exists, but that can't be reached from the
+	// public key, are part of a cycle.
+	reached := make(map[identity.ID]bool, len(claims))
+	queue := roots
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reached[*id] {
+			continue
+		}
+
+		reached[*id] = true
+		queue = append(queue, children[*id]...)
+	}
+
+	for _, c := range seg.Claims {
+		if c.ID == nil || c.Body == nil || c.Body.Previous == nil || reached[*c.ID] {
+			continue
+		}
+
+		if _, ok := claims[*c.Body.Previous]; ok {
+			v.add(pk.ID, c.ID, "Claim is part of a cycle, and does not follow from the public key")
+		}
+	}
+}
+
+// signer returns the signing key that made the signature, recording an
+// anomaly if it is missing, is not a signing key, or was revoked before the
+// signed object was created.
+func (v *claimTreeVerifier) signer(keyID, claimID *identity.ID,
+	sig *primitive.Signature, created time.Time) *apitypes.PublicKeySegment {
+
+	if sig.PublicKeyID == nil {
+		v.add(keyID, claimID, "Signature does not name a signing key")
+		return nil
+	}
+
+	signer, ok := v.keys[*sig.PublicKeyID]
+	if !ok {
+		v.add(keyID, claimID, "Signed by key %s, which is not in the claim tree", sig.PublicKeyID)
+		return nil
+	}
+
+	if signer.PublicKey.Body.KeyType != primitive.SigningKeyType {
+		v.add(keyID, claimID, "Signed by key %s, which is not a signing key", sig.PublicKeyID)
+		return nil
+	}
+
+	if revoked, ok := revokedAt(signer); ok && revoked.Before(created) {
+		v.add(keyID, claimID, "Signed by key %s after it was revoked", sig.PublicKeyID)
+	}
+
+	return signer
+}
+
+// differentOwners returns whether both keys name an owner, and the owners
+// differ. A missing owner is reported when its own key is verified, so it is
+// not compared here.
+func differentOwners(a, b *primitive.PublicKey) bool {
+	return a.OwnerID != nil && b.OwnerID != nil && *a.OwnerID != *b.OwnerID
+}
+
+// revokedAt returns the time of the earliest revocation claim against the
+// public key.
+func revokedAt(seg *apitypes.PublicKeySegment) (time.Time, bool) {
+	var revoked time.Time
+	var ok bool
+	for _, c := range seg.Claims {
+		if c.Body == nil || c.Body.ClaimType != primitive.RevocationClaimType {
+			continue
+		}
+
+		if !ok || c.Body.Created.Before(revoked) {
+			revoked, ok = c.Body.Created, true
+		}
+	}
+
+	return revoked, ok
+}
+
+// idMatches returns whether the id was derived from the body and signature.
+func idMatches(id *identity.ID, body identity.Immutable, sig *primitive.Signature) bool {
+	derived, err := identity.NewImmutable(body, sig)
+	return err == nil && derived == *id
+}
+
+// verifySignature returns whether sig is a valid signature of body by the
+// signing key in seg. Bodies are signed prefixed with their schema version.
+func verifySignature(seg *apitypes.PublicKeySegment, body identity.Immutable,
+	sig *primitive.Signature) bool {
+
+	if sig.Algorithm != crypto.EdDSA || sig.Value == nil {
+		return false
+	}
+
+	key := seg.PublicKey.Body.Key.Value
+	if key == nil || len(*key) != ed25519.PublicKeySize {
+		return false
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return false
+	}
+
+	b = append([]byte(strconv.Itoa(body.Version())), b...)
+	return ed25519.Verify(ed25519.PublicKey(*key), b, *sig.Value)
+}
+
+// claimTreeKeySorter implements sort.Interface, for sorting keys by owner,
+// then creation time.
+type claimTreeKeySorter []apitypes.ClaimTreeKey
+
+func (c claimTreeKeySorter) Len() int      { return len(c) }
+func (c claimTreeKeySorter) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c claimTreeKeySorter) Less(i, j int) bool {
+	if *c[i].OwnerID != *c[j].OwnerID {
+		return c[i].OwnerID.String() < c[j].OwnerID.String()
+	}
+
+	return c[i].Created.Before(c[j].Created)
+}
+
+// claimTreeClaimSorter implements sort.Interface, for sorting claims from
+// oldest to newest.
+type claimTreeClaimSorter []apitypes.ClaimTreeClaim
+
+func (c claimTreeClaimSorter) Len() int      { return len(c) }
+func (c claimTreeClaimSorter) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c claimTreeClaimSorter) Less(i, j int) bool {
+	return c[i].Created.Before(c[j].Created)
+}
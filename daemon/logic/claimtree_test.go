@@ -0,0 +1,240 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/go-base64"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/crypto"
+)
+
+var claimTreeOwnerID = &identity.ID{0x01, (&primitive.User{}).Type(), 0x01}
+
+func signBody(t *testing.T, body identity.Immutable, sigID *identity.ID,
+	key ed25519.PrivateKey) (*identity.ID, primitive.Signature) {
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := primitive.Signature{
+		PublicKeyID: sigID,
+		Algorithm:   crypto.EdDSA,
+		Value:       base64.New(ed25519.Sign(key, append([]byte(strconv.Itoa(body.Version())), b...))),
+	}
+
+	id, err := identity.NewImmutable(body, &sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &id, sig
+}
+
+func signedClaim(t *testing.T, pubKeyID, previous, sigID *identity.ID, key ed25519.PrivateKey,
+	claimType primitive.ClaimType, created time.Time) envelope.Claim {
+
+	body := primitive.NewClaim(keypairOrgID, claimTreeOwnerID, previous, pubKeyID, claimType)
+	body.Created = created
+
+	id, sig := signBody(t, body, sigID, key)
+	return envelope.Claim{ID: id, Version: 1, Body: body, Signature: sig}
+}
+
+// validClaimTree returns a claim tree with a signing and encryption key, where
+// the signing key has been revoked.
+func validClaimTree(t *testing.T) (*registry.ClaimTree, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newKey := func(keyType primitive.KeyType, value []byte, sigID *identity.ID) *envelope.PublicKey {
+		body := &primitive.PublicKey{
+			OrgID:     keypairOrgID,
+			OwnerID:   claimTreeOwnerID,
+			KeyType:   keyType,
+			Algorithm: crypto.EdDSA,
+			Key:       primitive.PublicKeyValue{Value: base64.New(value)},
+			Created:   created,
+			Expires:   created.Add(time.Hour * 8760),
+		}
+
+		id, sig := signBody(t, body, sigID, priv)
+		return &envelope.PublicKey{ID: id, Version: 1, Body: body, Signature: sig}
+	}
+
+	sigKey := newKey(primitive.SigningKeyType, pub, nil)
+	encKey := newKey(primitive.EncryptionKeyType, make([]byte, 32), sigKey.ID)
+
+	sigClaim := signedClaim(t, sigKey.ID, sigKey.ID, sigKey.ID, priv,
+		primitive.SignatureClaimType, created)
+	revClaim := signedClaim(t, sigKey.ID, sigClaim.ID, sigKey.ID, priv,
+		primitive.RevocationClaimType, created.Add(time.Hour))
+	encClaim := signedClaim(t, encKey.ID, encKey.ID, sigKey.ID, priv,
+		primitive.SignatureClaimType, created)
+
+	tree := &registry.ClaimTree{
+		Org: &envelope.Org{ID: keypairOrgID},
+		PublicKeys: []apitypes.PublicKeySegment{
+			{PublicKey: sigKey, Claims: []envelope.Claim{sigClaim, revClaim}},
+			{PublicKey: encKey, Claims: []envelope.Claim{encClaim}},
+		},
+	}
+
+	return tree, priv
+}
+
+func TestVerifyClaimTree(t *testing.T) {
+	t.Run("valid tree", func(t *testing.T) {
+		tree, _ := validClaimTree(t)
+
+		result := verifyClaimTree(tree)
+		if len(result.Anomalies) != 0 {
+			t.Errorf("Expected no anomalies, got %+v", result.Anomalies)
+		}
+		if result.KeyCount != 2 || result.ClaimCount != 3 {
+			t.Errorf("Expected 2 keys and 3 claims, got %d and %d", result.KeyCount, result.ClaimCount)
+		}
+	})
+
+	tcs := []struct {
+		name   string
+		tamper func(*registry.ClaimTree, ed25519.PrivateKey)
+		want   []string
+	}{
+		{
+			"tampered public key",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys[1].PublicKey.Body.Expires = time.Now()
+			},
+			[]string{
+				"Public key id does not match its contents",
+				"Public key signature by key {sig} is invalid",
+			},
+		},
+		{
+			"self-signed encryption key",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys[1].PublicKey.Signature.PublicKeyID = nil
+			},
+			[]string{
+				"Public key id does not match its contents",
+				"Encryption key is self-signed",
+			},
+		},
+		{
+			"claim after revocation",
+			func(tree *registry.ClaimTree, priv ed25519.PrivateKey) {
+				seg := &tree.PublicKeys[0]
+				c := signedClaim(t, seg.PublicKey.ID, seg.Claims[1].ID, seg.PublicKey.ID, priv,
+					primitive.SignatureClaimType, seg.Claims[1].Body.Created.Add(time.Hour))
+				seg.Claims = append(seg.Claims, c)
+			},
+			[]string{
+				"Signed by key {sig} after it was revoked",
+				"Claim was made after the public key was revoked",
+			},
+		},
+		{
+			"forked chain",
+			func(tree *registry.ClaimTree, priv ed25519.PrivateKey) {
+				seg := &tree.PublicKeys[1]
+				c := signedClaim(t, seg.PublicKey.ID, seg.PublicKey.ID,
+					tree.PublicKeys[0].PublicKey.ID, priv, primitive.RevocationClaimType,
+					seg.Claims[0].Body.Created)
+				seg.Claims = append(seg.Claims, c)
+			},
+			[]string{
+				"First claim is a revocation claim, not a signature claim",
+				"Claim chain forks: 2 claims follow the public key",
+			},
+		},
+		{
+			"missing previous claim",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys[0].Claims = tree.PublicKeys[0].Claims[1:]
+			},
+			[]string{
+				"Previous claim {prev} does not exist",
+				"No claim follows from the public key",
+			},
+		},
+		{
+			"missing org",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys[1].PublicKey.Body.OrgID = nil
+			},
+			[]string{
+				"Public key id does not match its contents",
+				"Public key is missing its org",
+				"Public key signature by key {sig} is invalid",
+			},
+		},
+		{
+			"missing owner",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys[0].PublicKey.Body.OwnerID = nil
+			},
+			[]string{
+				"Public key id does not match its contents",
+				"Public key is missing its owner",
+				"Public key self-signature is invalid",
+			},
+		},
+		{
+			"unknown signer",
+			func(tree *registry.ClaimTree, _ ed25519.PrivateKey) {
+				tree.PublicKeys = tree.PublicKeys[1:]
+			},
+			[]string{
+				"Signed by key {sig}, which is not in the claim tree",
+				"Signed by key {sig}, which is not in the claim tree",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, priv := validClaimTree(t)
+			r := strings.NewReplacer(
+				"{sig}", tree.PublicKeys[0].PublicKey.ID.String(),
+				"{prev}", tree.PublicKeys[0].Claims[0].ID.String(),
+			)
+			tc.tamper(tree, priv)
+
+			result := verifyClaimTree(tree)
+
+			var got []string
+			for _, a := range result.Anomalies {
+				got = append(got, a.Message)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("Expected %d anomalies, got %q", len(tc.want), got)
+			}
+
+			for i, want := range tc.want {
+				want = r.Replace(want)
+				if got[i] != want {
+					t.Errorf("Expected anomaly %q, got %q", want, got[i])
+				}
+			}
+		})
+	}
+}
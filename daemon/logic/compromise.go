@@ -0,0 +1,132 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/pathexp"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+// Compromise treats every secret in a keyring that intersects the given
+// pathexp as compromised.
+//
+// Every such keyring is given a new version with a new master encryption key.
+// Secrets set after this are encrypted with the new key, but existing values
+// stay readable by anyone who could read them before, until they are rotated.
+// Until then, NeedRotation reports them, so they are listed in the worklog of
+// every member of the org.
+func (e *Engine) Compromise(ctx context.Context, notifier *observer.Notifier,
+	pe *pathexp.PathExp) (*apitypes.CompromiseResult, error) {
+
+	n := notifier.Notifier(2)
+
+	graphs, err := e.client.CredentialGraph.Search(ctx,
+		"/"+pe.Org.String()+"/"+pe.Project.String()+"/*/*/*/*", e.session.AuthID())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Credentials retrieved", true)
+
+	heads, creds, err := compromisedScope(graphs, pe)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &apitypes.CompromiseResult{
+		Keyrings: []apitypes.RekeyedKeyring{},
+		Secrets:  []apitypes.CompromisedSecret{},
+	}
+
+	for _, cred := range creds {
+		result.Secrets = append(result.Secrets, apitypes.CompromisedSecret{
+			PathExp: cred.PathExp(),
+			Name:    cred.Name(),
+		})
+	}
+
+	for _, head := range heads {
+		newGraph, err := e.newKeyringVersion(ctx, head)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Keyrings = append(result.Keyrings, apitypes.RekeyedKeyring{
+			PathExp:        newGraph.GetKeyring().PathExp(),
+			KeyringVersion: newGraph.KeyringVersion(),
+		})
+	}
+
+	n.Notify(observer.Progress, "Keyrings created", true)
+
+	sort.Sort(compromisedSecretSorter(result.Secrets))
+	return result, nil
+}
+
+// compromisedScope returns the head of every keyring that intersects pe, and
+// the current secrets in those keyrings.
+//
+// A keyring intersects pe if any secret in it could be read at a path matched
+// by pe, such as /o/p/*/*/*/* for /o/p/prod/*/*/*. Every secret in the
+// keyring shares its master encryption key, so all of them are compromised.
+func compromisedScope(graphs []registry.CredentialGraph, pe *pathexp.PathExp) (
+	[]registry.CredentialGraph, []envelope.CredentialInf, error) {
+
+	cgs := newCredentialGraphSet()
+	err := cgs.Add(graphs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	var heads []registry.CredentialGraph
+	for _, graph := range graphs {
+		kpe := graph.GetKeyring().PathExp()
+		if seen[kpe.String()] || !kpe.Intersects(pe) {
+			continue
+		}
+		seen[kpe.String()] = true
+
+		head, err := cgs.Head(kpe)
+		if err != nil {
+			return nil, nil, err
+		}
+		if head == nil {
+			return nil, nil, errors.New("no head for keyring " + kpe.String())
+		}
+		heads = append(heads, head)
+	}
+
+	// Prune only keeps the head credentials in each graph. Head only uses the
+	// keyring of each graph, so it is safe to call before this.
+	pruned, err := cgs.Prune()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var creds []envelope.CredentialInf
+	for _, graph := range pruned {
+		if !seen[graph.GetKeyring().PathExp().String()] {
+			continue
+		}
+		creds = append(creds, graph.GetCredentials()...)
+	}
+
+	return heads, creds, nil
+}
+
+// compromisedSecretSorter implements sort.Interface, for sorting secrets
+// lexicographically by path.
+type compromisedSecretSorter []apitypes.CompromisedSecret
+
+func (c compromisedSecretSorter) Len() int           { return len(c) }
+func (c compromisedSecretSorter) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c compromisedSecretSorter) Less(i, j int) bool { return c[i].Path() < c[j].Path() }
@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/manifoldco/torus-cli/registry"
+)
+
+func TestCompromisedScope(t *testing.T) {
+	prodAPI := "/o/p/prod/api/*/*"
+	prodWeb := "/o/p/prod/web/*/*"
+	dev := "/o/p/dev/api/*/*"
+	shared := "/o/p/*/*/*/*"
+	id4 := mustID("04100000000000000000000001000")
+	id5 := mustID("04100000000000000000000010000")
+
+	graphs := []registry.CredentialGraph{
+		buildGraph(prodAPI, 1, cred{id: id1, pe: &prodAPI}),
+		buildGraph(prodAPI, 2, cred{id: id2, prev: id1, pe: &prodAPI}),
+		buildGraph(prodWeb, 1, cred{id: id3, pe: &prodWeb, state: &unset}),
+		buildGraph(dev, 1, cred{id: id4, pe: &dev}),
+		buildGraph(shared, 1, cred{id: id5, pe: &shared}),
+	}
+
+	testCases := []struct {
+		pe       string
+		keyrings []string
+		creds    int
+	}{
+		{"/o/p/prod/*/*/*", []string{prodAPI, prodWeb, shared}, 2},
+		{"/o/p/[prod|staging]/api/*/*", []string{prodAPI, shared}, 2},
+		{"/o/p/!prod/*/*/*", []string{dev, shared}, 2},
+		{"/o/p/staging/*/*/*", []string{shared}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pe, func(t *testing.T) {
+			heads, creds, err := compromisedScope(graphs, mustPathExp(tc.pe))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]bool)
+			for _, head := range heads {
+				kpe := head.GetKeyring().PathExp().String()
+				got[kpe] = true
+				if kpe == prodAPI && head.KeyringVersion() != 2 {
+					t.Errorf("Expected head of %s to be version 2, got %d", prodAPI, head.KeyringVersion())
+				}
+			}
+			if len(got) != len(tc.keyrings) {
+				t.Errorf("Expected keyrings %v, got %v", tc.keyrings, got)
+			}
+			for _, k := range tc.keyrings {
+				if !got[k] {
+					t.Errorf("Expected %s to be compromised", k)
+				}
+			}
+
+			if len(creds) != tc.creds {
+				t.Errorf("Expected %d secrets to be compromised, got %v", tc.creds, creds)
+			}
+		})
+	}
+}
@@ -40,20 +40,28 @@ func (cgs *credentialGraphSet) Add(graphs ...registry.CredentialGraph) error {
 	return nil
 }
 
-func (credentialGraphSet) activeCreds(parents []identity.ID,
+func (cgs credentialGraphSet) activeCreds(parents []identity.ID,
 	graph registry.CredentialGraph) ([]envelope.CredentialInf, []identity.ID, error) {
+	return cgs.headCreds(parents, graph, false)
+}
+
+// headCreds returns the credentials in graph that have not been replaced by a
+// newer version, along with the updated set of parent ids. Unset credentials
+// are only returned if includeUnset is true.
+func (credentialGraphSet) headCreds(parents []identity.ID,
+	graph registry.CredentialGraph, includeUnset bool) ([]envelope.CredentialInf, []identity.ID, error) {
 
 	creds := graph.GetCredentials()
 
 	// maybeActive is a set of potentially active credentials.
-	// It will never contain unset credentials as they can't be
-	// active.
+	// Unless includeUnset is set, it will never contain unset credentials as
+	// they can't be active.
 	maybeActive := make(map[identity.ID]envelope.CredentialInf, len(creds))
 	unchecked := make([]identity.ID, 0, len(creds))
 	for i := range creds {
 		cred := creds[i]
 		parent := cred.Previous()
-		if !cred.Unset() {
+		if includeUnset || !cred.Unset() {
 			maybeActive[*cred.GetID()] = cred
 		}
 
@@ -123,6 +131,17 @@ func (cgs *credentialGraphSet) Active() ([]registry.CredentialGraph, error) {
 // Credentials that are reachable, unlike Active, which returns all Credentials
 // within the CredentialGraph.
 func (cgs *credentialGraphSet) Prune() ([]registry.CredentialGraph, error) {
+	return cgs.prune(false)
+}
+
+// Heads returns a slice of CredentialGraphs that contain the most recent
+// version of each Credential, including those that have been unset. Like
+// Prune, each returned CredentialGraph contains *only* those Credentials.
+func (cgs *credentialGraphSet) Heads() ([]registry.CredentialGraph, error) {
+	return cgs.prune(true)
+}
+
+func (cgs *credentialGraphSet) prune(includeUnset bool) ([]registry.CredentialGraph, error) {
 	pruned := make([]registry.CredentialGraph, 0, len(cgs.graphs))
 
 	for _, graphs := range cgs.graphs {
@@ -135,7 +154,7 @@ func (cgs *credentialGraphSet) Prune() ([]registry.CredentialGraph, error) {
 		for _, graph := range graphs {
 			var activeCreds []envelope.CredentialInf
 			var err error
-			activeCreds, parents, err = cgs.activeCreds(parents, graph)
+			activeCreds, parents, err = cgs.headCreds(parents, graph, includeUnset)
 			if err != nil {
 				return nil, err
 			}
@@ -159,17 +178,23 @@ func (cgs *credentialGraphSet) Prune() ([]registry.CredentialGraph, error) {
 }
 
 // RotationReason contains a Credential, and the user ids that had access
-// changes to require the rotation.
+// changes to require the rotation. If the Keyring was given a new master
+// encryption key while the Credential was current, Rekeyed holds the version
+// of the Keyring that has the new key.
 type RotationReason struct {
 	Credential envelope.CredentialInf
 	Reasons    []primitive.KeyringMemberClaim
+	Rekeyed    int
 }
 
 // NeedRotation returns a slice of Credentials that need to be rotated.
 //
 // A Credential needs to be rotated if its most recent set version is in a
 // CredentialGraph version that contains a revocation of a user's share to
-// that Keyring.
+// that Keyring, or in a V2 CredentialGraph version without revocations that
+// has been replaced by a newer version. New versions are only created without
+// revocations when a Keyring is rekeyed or compromised, and rekeying moves
+// every current Credential to the new version.
 func (cgs *credentialGraphSet) NeedRotation() ([]RotationReason, error) {
 	var needRotation []RotationReason
 
@@ -178,7 +203,7 @@ func (cgs *credentialGraphSet) NeedRotation() ([]RotationReason, error) {
 		var parents []identity.ID
 
 		sort.Sort(graphSorter(graphs))
-		for _, graph := range graphs {
+		for i, graph := range graphs {
 			var activeCreds []envelope.CredentialInf
 			var err error
 			activeCreds, parents, err = cgs.activeCreds(parents, graph)
@@ -196,11 +221,19 @@ func (cgs *credentialGraphSet) NeedRotation() ([]RotationReason, error) {
 				}
 			}
 
-			if len(reasons) > 0 {
+			// V1 keyrings don't record revocations, so a newer version
+			// can't be told apart from a rekey.
+			rekeyed := 0
+			if _, ok := graph.(*registry.CredentialGraphV2); ok && i > 0 && !graph.HasRevocations() {
+				rekeyed = graphs[i-1].KeyringVersion()
+			}
+
+			if len(reasons) > 0 || rekeyed > 0 {
 				for _, c := range activeCreds {
 					needRotation = append(needRotation, RotationReason{
 						Credential: c,
 						Reasons:    reasons,
+						Rekeyed:    rekeyed,
 					})
 				}
 			}
@@ -486,6 +486,65 @@ func TestCredentialGraphSetPrune(t *testing.T) {
 
 }
 
+func TestCredentialGraphSetHeads(t *testing.T) {
+	t.Run("unset head is included", func(t *testing.T) {
+		cgs := newCredentialGraphSet()
+
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 2, cred{id: id2, prev: id1, state: &unset}))
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 1, cred{id: id1}))
+
+		heads, err := cgs.Heads()
+		if err != nil {
+			t.Fatal("error seen:", err)
+		}
+
+		assertActive(t, heads, 1)
+
+		v := heads[0].KeyringVersion()
+		if v != 2 {
+			t.Error("Wrong head keyring version. wanted: 2 got:", v)
+		}
+
+		creds := heads[0].GetCredentials()
+		if len(creds) != 1 || !creds[0].Unset() {
+			t.Error("Expected only the unset credential to be returned")
+		}
+	})
+
+	t.Run("set and unset heads in one keyring", func(t *testing.T) {
+		cgs := newCredentialGraphSet()
+
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 1, cred{id: id1}, cred{id: id2, state: &unset}))
+
+		heads, err := cgs.Heads()
+		if err != nil {
+			t.Fatal("error seen:", err)
+		}
+
+		assertActive(t, heads, 1)
+		if len(heads[0].GetCredentials()) != 2 {
+			t.Error("Expected both credentials to be returned")
+		}
+	})
+
+	t.Run("previous version is not a head", func(t *testing.T) {
+		cgs := newCredentialGraphSet()
+
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 2, cred{id: id3, prev: id2}))
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 1, cred{id: id2, prev: id1, state: &unset}, cred{id: id1}))
+
+		heads, err := cgs.Heads()
+		if err != nil {
+			t.Fatal("error seen:", err)
+		}
+
+		assertActive(t, heads, 1)
+		if heads[0].GetCredentials()[0].GetID() != id3 {
+			t.Error("Wrong credential returned as head")
+		}
+	})
+}
+
 func TestCredentialGraphSetHead(t *testing.T) {
 	t.Run("no match", func(t *testing.T) {
 		cgs := newCredentialGraphSet()
@@ -654,6 +713,30 @@ func TestCredentialGraphSetNeedRotation(t *testing.T) {
 		}
 	})
 
+	t.Run("version in rekeyed keyring needs rotation", func(t *testing.T) {
+		cgs := newCredentialGraphSet()
+
+		pe := "/o/p/e/s/u/i"
+		name := "cred"
+		othername := "othercred"
+
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 3, cred{id: id3, pe: &pe, name: &name}))
+		cgs.Add(buildGraph("/o/p/e/s/u/*", 2, cred{id: id2, pe: &pe, name: &othername}))
+
+		out, err := cgs.NeedRotation()
+		if err != nil {
+			t.Fatal("error seen:", err)
+		}
+
+		if len(out) != 1 {
+			t.Fatal("Wrong number of credentials needing revision found")
+		}
+
+		if out[0].Credential.GetID() != id2 || out[0].Rekeyed != 3 || len(out[0].Reasons) != 0 {
+			t.Errorf("Expected %s to need rotation after a rekey, got %+v", id2, out[0])
+		}
+	})
+
 	t.Run("already rotated value is not returned", func(t *testing.T) {
 		cgs := newCredentialGraphSet()
 
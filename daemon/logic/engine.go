@@ -6,23 +6,31 @@ package logic
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/manifoldco/go-base64"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/metrics"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
 
 	"github.com/manifoldco/torus-cli/daemon/crypto"
+	"github.com/manifoldco/torus-cli/daemon/db"
 	"github.com/manifoldco/torus-cli/daemon/observer"
 	"github.com/manifoldco/torus-cli/daemon/session"
 )
 
+// DecryptedCredentials counts the credentials decrypted by all engines, by
+// result.
+var DecryptedCredentials = metrics.NewCounterVec("torus_daemon_credentials_decrypted_total",
+	"Credentials decrypted, by result (success or failure).", "result")
+
 // Engine exposes methods for performing actions that will affect the keys,
 // keyrings, keyring memberships, or credential objects.
 //
@@ -34,6 +42,10 @@ type Engine struct {
 	crypto  *crypto.Engine
 	client  *registry.Client
 
+	// owner is set for the session of the user running the daemon, who
+	// adopts values written to the db before it held more than one user's.
+	owner bool
+
 	Worklog Worklog
 	Machine Machine
 	Session Session
@@ -41,17 +53,25 @@ type Engine struct {
 
 // Database interface for logic engine
 type Database interface {
-	Set(envs ...envelope.Envelope) error
+	Salt() []byte
+	SealUnsealed(key *[32]byte, owner *identity.ID) error
+	Set(key *[32]byte, envs ...envelope.Envelope) error
+	FirstSeen(key *[32]byte, owner, id *identity.ID, now time.Time) (time.Time, error)
+	Seen(key *[32]byte, owner, id *identity.ID) (time.Time, error)
+	MarkForRotation(key *[32]byte, owner, id *identity.ID, mark *db.RotationMark) error
+	RotationMark(key *[32]byte, owner, id *identity.ID) (*db.RotationMark, error)
 }
 
-// NewEngine returns a new Engine
+// NewEngine returns a new Engine. owner must be set only for the session of
+// the user running the daemon.
 func NewEngine(s session.Session, db Database, e *crypto.Engine,
-	client *registry.Client) *Engine {
+	client *registry.Client, owner bool) *Engine {
 	engine := &Engine{
 		session: s,
 		db:      db,
 		crypto:  e,
 		client:  client,
+		owner:   owner,
 	}
 	engine.Worklog = newWorklog(engine)
 	engine.Machine = Machine{engine: engine}
@@ -59,6 +79,39 @@ func NewEngine(s session.Session, db Database, e *crypto.Engine,
 	return engine
 }
 
+// dbKey returns the key that seals the session's values in the db, deriving
+// it from the master key the first time it's needed after login.
+func (e *Engine) dbKey(ctx context.Context) (*[32]byte, error) {
+	if key := e.session.DBKey(); key != nil {
+		return key, nil
+	}
+
+	key, err := e.crypto.DBKey(ctx, e.db.Salt())
+	if err != nil {
+		return nil, err
+	}
+	e.session.SetDBKey(key)
+
+	if e.owner {
+		err = e.db.SealUnsealed(key, e.session.AuthID())
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error sealing values in local db: %s", err)
+		}
+	}
+
+	return key, nil
+}
+
+// cache stores envs in the db, sealed with the session's key.
+func (e *Engine) cache(ctx context.Context, envs ...envelope.Envelope) error {
+	key, err := e.dbKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	return e.db.Set(key, envs...)
+}
+
 // AppendCredentials attempts to append plain-text Credential objects to the
 // Credential Graph.
 func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notifier,
@@ -85,7 +138,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 	graphs, err := e.client.CredentialGraph.List(ctx, "", cred.Body.PathExp,
 		e.session.AuthID())
 	if err != nil {
-		log.Printf("Error retrieving credential graphs: %s", err)
+		logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
 		return nil, err
 	}
 
@@ -93,19 +146,19 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 
 	keypairs, err := e.client.KeyPairs.List(ctx, cred.Body.OrgID)
 	if err != nil {
-		log.Printf("Error fetching keypairs: %s", err)
+		logging.FromContext(ctx).Errorf("Error fetching keypairs: %s", err)
 		return nil, err
 	}
 
 	claimtree, err := e.client.ClaimTree.Get(ctx, cred.Body.OrgID, nil)
 	if err != nil {
-		log.Printf("Error fetching claimtree for org[%s]: %s", cred.Body.OrgID, err)
+		logging.FromContext(ctx).Errorf("Error fetching claimtree for org[%s]: %s", cred.Body.OrgID, err)
 		return nil, err
 	}
 
 	sigID, encID, kp, err := fetchKeyPairs(keypairs, cred.Body.OrgID)
 	if err != nil {
-		log.Printf("Error fetching keypairs: %s", err)
+		logging.FromContext(ctx).Errorf("Error fetching keypairs: %s", err)
 		return nil, err
 	}
 
@@ -130,7 +183,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 		newGraph, err = createCredentialGraph(ctx, cred.Body, graph,
 			sigID, encID, kp, claimtree, e.client, e.crypto)
 		if err != nil {
-			log.Printf("error creating credential graph: %s", err)
+			logging.FromContext(ctx).Errorf("error creating credential graph: %s", err)
 			return nil, err
 		}
 		cgs.Add(newGraph)
@@ -139,13 +192,13 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 
 	krm, mekshare, err := graph.FindMember(e.session.AuthID())
 	if err != nil {
-		log.Printf("Error finding keyring membership: %s", err)
+		logging.FromContext(ctx).Errorf("Error finding keyring membership: %s", err)
 		return nil, err
 	}
 
 	encKeySegment, err := claimtree.Find(krm.EncryptingKeyID, true)
 	if err != nil {
-		log.Printf("Error finding encrypting key[%s]: %s", krm.EncryptingKeyID, err)
+		logging.FromContext(ctx).Errorf("Error finding encrypting key[%s]: %s", krm.EncryptingKeyID, err)
 		return nil, err
 	}
 	encryptingKey := encKeySegment.PublicKey.Body
@@ -157,7 +210,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 		// Find the  most recent version of this credential to act as our previous.
 		previousCred, err := cgs.HeadCredential(c.Body.PathExp, c.Body.Name)
 		if err != nil {
-			log.Printf("error finding credentials to match: %s", err)
+			logging.FromContext(ctx).Errorf("error finding credentials to match: %s", err)
 			return nil, err
 		}
 
@@ -177,7 +230,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 		}
 
 		if previousCred == nil {
-			log.Printf("no previous")
+			logging.FromContext(ctx).Warnf("no previous")
 			credBody.Previous = nil
 			credBody.CredentialVersion = 1
 		} else {
@@ -191,7 +244,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 			ctx, []byte(c.Body.Value), *mekshare.Key.Value, *mekshare.Key.Nonce,
 			&kp.Encryption, *encryptingKey.Key.Value)
 		if err != nil {
-			log.Printf("Error encrypting credential: %s", err)
+			logging.FromContext(ctx).Errorf("Error encrypting credential: %s", err)
 			return nil, err
 		}
 
@@ -202,7 +255,7 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 
 		signed, err := e.crypto.SignedCredential(ctx, &credBody, sigID, &kp.Signature)
 		if err != nil {
-			log.Printf("Error signing credential body: %s", err)
+			logging.FromContext(ctx).Errorf("Error signing credential body: %s", err)
 			return nil, err
 		}
 
@@ -219,16 +272,18 @@ func (e *Engine) AppendCredentials(ctx context.Context, notifier *observer.Notif
 	}
 
 	if err != nil {
-		log.Printf("error creating credential: %s", err)
+		logging.FromContext(ctx).Errorf("error creating credential: %s", err)
 		return nil, err
 	}
 
 	return creds, nil
 }
 
-// RetrieveCredentials returns all credentials for the given CPath string
-func (e *Engine) RetrieveCredentials(ctx context.Context,
-	notifier *observer.Notifier, cpath, cpathexp *string) ([]PlaintextCredentialEnvelope, error) {
+// RetrieveCredentials returns all credentials for the given CPath string.
+// If includeUnset is true, credentials whose most recent version is unset are
+// returned too, with an "unset" State.
+func (e *Engine) RetrieveCredentials(ctx context.Context, notifier *observer.Notifier,
+	cpath, cpathexp *string, includeUnset bool) ([]PlaintextCredentialEnvelope, error) {
 	if cpath != nil && cpathexp != nil {
 		panic("cannot use both cpath and cpathexp")
 	}
@@ -245,28 +300,33 @@ func (e *Engine) RetrieveCredentials(ctx context.Context,
 	}
 
 	if err != nil {
-		log.Printf("error retrieving credential graph: %s", err)
+		logging.FromContext(ctx).Errorf("error retrieving credential graph: %s", err)
 		return nil, err
 	}
 
 	cgs := newCredentialGraphSet()
 	err = cgs.Add(graphs...)
 	if err != nil {
-		log.Printf("error creating credential graph set: %s", err)
+		logging.FromContext(ctx).Errorf("error creating credential graph set: %s", err)
 		return nil, err
 	}
 
 	// Prune removes all unactive graphs (those without a head credential) and
-	// unset credentials.
-	activeGraphs, err := cgs.Prune()
+	// unset credentials. Heads keeps the unset credentials.
+	var activeGraphs []registry.CredentialGraph
+	if includeUnset {
+		activeGraphs, err = cgs.Heads()
+	} else {
+		activeGraphs, err = cgs.Prune()
+	}
 	if err != nil {
-		log.Printf("error encountered while pruning graph: %s", err)
+		logging.FromContext(ctx).Errorf("error encountered while pruning graph: %s", err)
 		return nil, err
 	}
 
 	creds := []PlaintextCredentialEnvelope{}
 	if len(activeGraphs) == 0 {
-		log.Printf("no active graphs found")
+		logging.FromContext(ctx).Warnf("no active graphs found")
 		return creds, nil
 	}
 
@@ -278,14 +338,170 @@ func (e *Engine) RetrieveCredentials(ctx context.Context,
 	n := notifier.Notifier(steps)
 	n.Notify(observer.Progress, "Credentials retrieved", true)
 
+	_, err = e.unboxCredentials(ctx, activeGraphs, false, func(cred envelope.CredentialInf, pt []byte) error {
+		state := "set"
+		if cred.Unset() {
+			state = "unset"
+		}
+
+		// If this is a v1 credential, then we need to unmarshal the
+		// plain text value to check whether or not we should return
+		// the credentials.
+		if cred.GetVersion() == 1 {
+			cValue := apitypes.CredentialValue{}
+			err := json.Unmarshal([]byte(strconv.Quote(string(pt))), &cValue)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("could not unmarshal credential value from v1 cred: %s", err)
+				return err
+			}
+
+			if cValue.IsUnset() {
+				if !includeUnset {
+					return nil
+				}
+				state = "unset"
+			}
+		}
+
+		plainCred := PlaintextCredentialEnvelope{
+			ID:      cred.GetID(),
+			Version: cred.GetVersion(),
+			Body: &PlaintextCredential{
+				Name:              cred.Name(),
+				PathExp:           cred.PathExp(),
+				ProjectID:         cred.ProjectID(),
+				OrgID:             cred.OrgID(),
+				Value:             string(pt),
+				State:             &state,
+				CredentialVersion: cred.CredentialVersion(),
+			},
+		}
+
+		creds = append(creds, plainCred)
+
+		n.Notify(observer.Progress, "Credential decrypted", true)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// RetrieveCredentialHistory returns every version of every credential the
+// user can read at the given CPathExp, including unset versions. Versions the
+// user can no longer decrypt are skipped, and counted in the result.
+func (e *Engine) RetrieveCredentialHistory(ctx context.Context, notifier *observer.Notifier,
+	cpathexp string) (*PlaintextCredentialHistory, error) {
+
+	graphs, err := e.client.CredentialGraph.Search(ctx, cpathexp, e.session.AuthID())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error retrieving credential graph: %s", err)
+		return nil, err
+	}
+
+	creds := []PlaintextCredentialEnvelope{}
+	if len(graphs) == 0 {
+		return &PlaintextCredentialHistory{Credentials: creds}, nil
+	}
+
+	total := 0
+	for _, graph := range graphs {
+		total += len(graph.GetCredentials())
+	}
+	steps := uint(total) + 1
+
+	n := notifier.Notifier(steps)
+	n.Notify(observer.Progress, "Credentials retrieved", true)
+
+	skipped, err := e.unboxCredentials(ctx, graphs, true, func(cred envelope.CredentialInf, pt []byte) error {
+		state := "set"
+		if cred.Unset() {
+			state = "unset"
+		} else if cred.GetVersion() == 1 {
+			_, ok, err := plaintextValue(string(pt))
+			if err != nil {
+				logging.FromContext(ctx).Errorf("could not unmarshal credential value from v1 cred: %s", err)
+				return err
+			}
+			if !ok {
+				state = "unset"
+			}
+		}
+
+		creds = append(creds, PlaintextCredentialEnvelope{
+			ID:      cred.GetID(),
+			Version: cred.GetVersion(),
+			Body: &PlaintextCredential{
+				Name:              cred.Name(),
+				PathExp:           cred.PathExp(),
+				ProjectID:         cred.ProjectID(),
+				OrgID:             cred.OrgID(),
+				Value:             string(pt),
+				State:             &state,
+				CredentialVersion: cred.CredentialVersion(),
+			},
+		})
+
+		n.Notify(observer.Progress, "Credential decrypted", true)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// unboxCredentials counts the graphs it skipped, but each graph holds
+	// many versions, and some may have been decrypted before the graph
+	// failed. The versions left out are those that were not decrypted.
+	history := &PlaintextCredentialHistory{Credentials: creds}
+	if skipped > 0 {
+		history.Skipped = total - len(creds)
+	}
+	return history, nil
+}
+
+// unboxCredentials decrypts every credential in the given graphs, which must
+// all belong to the same org, calling fn with each credential and its
+// plaintext value.
+//
+// If skipUnreadable is true, graphs that the user can no longer decrypt (such
+// as old versions shared to a revoked key) are skipped, rather than returning
+// an error. Errors returned by fn, and the context's error, are always
+// returned. The number of graphs skipped is returned, including graphs the
+// user is not a member of, which are always skipped.
+func (e *Engine) unboxCredentials(ctx context.Context, graphs []registry.CredentialGraph,
+	skipUnreadable bool, fn func(envelope.CredentialInf, []byte) error) (int, error) {
+
+	if len(graphs) == 0 {
+		return 0, nil
+	}
+
 	// Loop over the trees and unpack the credentials; later on we will
 	// actually do real work and decrypt each of these credentials but for
 	// now we just need ot return a list of them!
+	//
+	// Graphs the user is not a member of can't be decrypted, so they are left
+	// out of the index.
+	skipped := 0
 	idx := newCredentialGraphKeyIndex(*(e.session.AuthID()))
-	idx.Add(activeGraphs...)
+	for _, graph := range graphs {
+		err := idx.Add(graph)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("Skipping graph without membership: %s", err)
+			skipped++
+		}
+	}
+
+	// fnErr holds the error returned by fn, if any. Only errors from finding
+	// keys and decrypting are skipped.
+	var fnErr error
+	skip := func() bool {
+		return skipUnreadable && fnErr == nil && ctx.Err() == nil
+	}
 
 	// All graphs will belong to the same org
-	orgID := activeGraphs[0].GetKeyring().OrgID()
+	orgID := graphs[0].GetKeyring().OrgID()
 
 	var fetchKeys sync.WaitGroup
 	var kps *registry.Keypairs
@@ -308,12 +524,12 @@ func (e *Engine) RetrieveCredentials(ctx context.Context,
 
 	fetchKeys.Wait()
 	if kpsErr != nil {
-		log.Printf("Cannot fetch keypairs for org[%s]: %s", orgID, kpsErr)
-		return nil, kpsErr
+		logging.FromContext(ctx).Errorf("Cannot fetch keypairs for org[%s]: %s", orgID, kpsErr)
+		return skipped, kpsErr
 	}
 	if ctErr != nil {
-		log.Printf("Could not fetch claimtree for org[%s]: %s", orgID, ctErr)
-		return nil, ctErr
+		logging.FromContext(ctx).Errorf("Could not fetch claimtree for org[%s]: %s", orgID, ctErr)
+		return skipped, ctErr
 	}
 
 	// Cache the bundled crypto keypairs for reuse
@@ -324,76 +540,66 @@ func (e *Engine) RetrieveCredentials(ctx context.Context,
 		}
 
 		kp, ok := keypairs[*orgID]
-		if !ok {
+		if ok {
+			db.CacheLookups.Inc("keypairs", "hit")
+		} else {
+			db.CacheLookups.Inc("keypairs", "miss")
+			var err error
 			_, _, kp, err = fetchKeyPairs(kps, orgID)
 			if err != nil {
-				log.Printf("Error fetching keypairs: %s", err)
-				return nil, err
+				logging.FromContext(ctx).Errorf("Error fetching keypairs: %s", err)
+				return skipped, err
 			}
 			keypairs[*orgID] = kp
 		}
 
 		encryptingKeySegment, err := claimtree.Find(&encryptingKeyID, false)
 		if err != nil {
-			log.Printf("Could not find encrypting key[%s]: %s", encryptingKeyID, err)
-			return nil, err
+			if skip() {
+				logging.FromContext(ctx).Warnf("Skipping %d graph(s) with unknown encrypting key[%s]: %s",
+					len(graphs), encryptingKeyID, err)
+				skipped += len(graphs)
+				continue
+			}
+			logging.FromContext(ctx).Errorf("Could not find encrypting key[%s]: %s", encryptingKeyID, err)
+			return skipped, err
 		}
 
+		done := 0
 		encryptingKey := encryptingKeySegment.PublicKey.Body
 		err = e.crypto.WithUnsealer(ctx, &kp.Encryption, *encryptingKey.Key.Value, func(unsealer crypto.Unsealer) error {
 			for _, graph := range graphs {
 				mekshare, err := graph.FindMEKByKeyID(&encryptingKeyID)
 				if err != nil {
-					log.Printf("Error finding keyring membership: %s %s", encryptingKeyID, err)
-					return err
-				}
-
-				err = unsealer.WithUnboxer(ctx, *mekshare.Key.Value, *mekshare.Key.Nonce, func(u crypto.Unboxer) error {
-					for _, cred := range graph.GetCredentials() {
-						pt, err := u.Unbox(ctx, *cred.Credential().Value, *cred.Nonce(), *cred.Credential().Nonce)
-						if err != nil {
-							log.Printf("Error decrypting credential: %s", err)
-							return err
-						}
-
-						// If this is a v1 credential, then we need to unmarshal the
-						// plain text value to check whether or not we should return
-						// the credentials.
-						if cred.GetVersion() == 1 {
-							cValue := apitypes.CredentialValue{}
-							err = json.Unmarshal([]byte(strconv.Quote(string(pt))), &cValue)
+					logging.FromContext(ctx).Errorf("Error finding keyring membership: %s %s", encryptingKeyID, err)
+				} else {
+					err = unsealer.WithUnboxer(ctx, *mekshare.Key.Value, *mekshare.Key.Nonce, func(u crypto.Unboxer) error {
+						for _, cred := range graph.GetCredentials() {
+							pt, err := u.Unbox(ctx, *cred.Credential().Value, *cred.Nonce(), *cred.Credential().Nonce)
 							if err != nil {
-								log.Printf("could not unmarshal credential value from v1 cred: %s", err)
+								DecryptedCredentials.Inc("failure")
+								logging.FromContext(ctx).Errorf("Error decrypting credential: %s", err)
 								return err
 							}
+							DecryptedCredentials.Inc("success")
 
-							if cValue.IsUnset() {
-								continue
+							fnErr = fn(cred, pt)
+							if fnErr != nil {
+								return fnErr
 							}
 						}
+						return nil
+					})
+				}
 
-						state := "set"
-						plainCred := PlaintextCredentialEnvelope{
-							ID:      cred.GetID(),
-							Version: cred.GetVersion(),
-							Body: &PlaintextCredential{
-								Name:      cred.Name(),
-								PathExp:   cred.PathExp(),
-								ProjectID: cred.ProjectID(),
-								OrgID:     cred.OrgID(),
-								Value:     string(pt),
-								State:     &state,
-							},
-						}
-
-						creds = append(creds, plainCred)
-
-						n.Notify(observer.Progress, "Credential decrypted", true)
-					}
-					return nil
-				})
+				done++
+				if err != nil && skip() {
+					logging.FromContext(ctx).Warnf("Skipping graph that could not be decrypted: %s", err)
+					skipped++
+					continue
+				}
 				if err != nil {
-					log.Printf("encountered an error while unboxing: %s", err)
+					logging.FromContext(ctx).Errorf("encountered an error while unboxing: %s", err)
 					return err
 				}
 			}
@@ -401,12 +607,18 @@ func (e *Engine) RetrieveCredentials(ctx context.Context,
 			return nil
 		})
 		if err != nil {
-			log.Printf("encountered an error while unsealing: %s", err)
-			return nil, err
+			if skip() {
+				logging.FromContext(ctx).Warnf("Skipping %d graph(s) that could not be unsealed: %s",
+					len(graphs)-done, err)
+				skipped += len(graphs) - done
+				continue
+			}
+			logging.FromContext(ctx).Errorf("encountered an error while unsealing: %s", err)
+			return skipped, err
 		}
 	}
 
-	return creds, nil
+	return skipped, nil
 }
 
 // ApproveInvite approves an invitation of a user into an organzation by
@@ -418,12 +630,12 @@ func (e *Engine) ApproveInvite(ctx context.Context, notifier *observer.Notifier,
 
 	invite, err := e.client.OrgInvites.Get(ctx, InviteID)
 	if err != nil {
-		log.Printf("could not fetch org invitation: %s", err)
+		logging.FromContext(ctx).Errorf("could not fetch org invitation: %s", err)
 		return nil, err
 	}
 
 	if invite.Body.State != primitive.OrgInviteAcceptedState {
-		log.Printf("invitation not in accepted state: %s", invite.Body.State)
+		logging.FromContext(ctx).Warnf("invitation not in accepted state: %s", invite.Body.State)
 		return nil, &apitypes.Error{
 			Type: apitypes.BadRequestError,
 			Err:  []string{"Invite must be accepted before it can be approved"},
@@ -442,7 +654,7 @@ func (e *Engine) ApproveInvite(ctx context.Context, notifier *observer.Notifier,
 
 	invite, err = e.client.OrgInvites.Approve(ctx, InviteID)
 	if err != nil {
-		log.Printf("could not approve org invite: %s", err)
+		logging.FromContext(ctx).Errorf("could not approve org invite: %s", err)
 		return nil, err
 	}
 
@@ -451,7 +663,7 @@ func (e *Engine) ApproveInvite(ctx context.Context, notifier *observer.Notifier,
 	if len(v1members) != 0 {
 		_, err = e.client.KeyringMember.Post(ctx, v1members)
 		if err != nil {
-			log.Printf("error uploading memberships: %s", err)
+			logging.FromContext(ctx).Errorf("error uploading memberships: %s", err)
 			return nil, err
 		}
 	}
@@ -459,7 +671,7 @@ func (e *Engine) ApproveInvite(ctx context.Context, notifier *observer.Notifier,
 	for _, member := range v2members {
 		err = e.client.Keyring.Members.Post(ctx, member)
 		if err != nil {
-			log.Printf("error uploading memberships: %s", err)
+			logging.FromContext(ctx).Errorf("error uploading memberships: %s", err)
 			return nil, err
 		}
 	}
@@ -476,7 +688,7 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 
 	kp, err := e.crypto.GenerateKeyPairs(ctx)
 	if err != nil {
-		log.Printf("Error generating keypairs: %s", err)
+		logging.FromContext(ctx).Errorf("Error generating keypairs: %s", err)
 		return err
 	}
 
@@ -485,7 +697,7 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	pubsig, privsig, err := packageSigningKeypair(ctx, e.crypto, e.session.AuthID(),
 		OrgID, kp)
 	if err != nil {
-		log.Printf("Error packaging signing keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error packaging signing keypair: %s", err)
 		return err
 	}
 
@@ -493,7 +705,7 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 		primitive.SignatureClaimType)
 	sigclaim, err := e.crypto.SignedClaim(ctx, sigBody, pubsig.ID, &kp.Signature)
 	if err != nil {
-		log.Printf("Error creating signature claim: %s", err)
+		logging.FromContext(ctx).Errorf("Error creating signature claim: %s", err)
 		return err
 	}
 
@@ -502,7 +714,7 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	pubsig, privsig, claims, err := e.client.KeyPairs.Create(ctx, pubsig,
 		privsig, sigclaim)
 	if err != nil {
-		log.Printf("Error uploading signature keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error uploading signature keypair: %s", err)
 		return err
 	}
 
@@ -512,9 +724,9 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	for i, claim := range claims {
 		objs[i+2] = &claim
 	}
-	err = e.db.Set(objs...)
+	err = e.cache(ctx, objs...)
 	if err != nil {
-		log.Printf("Error storing signing keys in local db: %s", err)
+		logging.FromContext(ctx).Errorf("Error storing signing keys in local db: %s", err)
 		return err
 	}
 
@@ -523,14 +735,14 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	pubenc, privenc, err := packageEncryptionKeypair(ctx, e.crypto, e.session.AuthID(),
 		OrgID, kp, pubsig)
 	if err != nil {
-		log.Printf("Error packaging encryption keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error packaging encryption keypair: %s", err)
 	}
 
 	encBody := primitive.NewClaim(OrgID, e.session.AuthID(), pubenc.ID, pubenc.ID,
 		primitive.SignatureClaimType)
 	encclaim, err := e.crypto.SignedClaim(ctx, encBody, pubsig.ID, &kp.Signature)
 	if err != nil {
-		log.Printf("Error creating signature claim for encryption key: %s", err)
+		logging.FromContext(ctx).Errorf("Error creating signature claim for encryption key: %s", err)
 		return err
 	}
 
@@ -539,7 +751,7 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	pubenc, privenc, claims, err = e.client.KeyPairs.Create(ctx, pubenc,
 		privenc, encclaim)
 	if err != nil {
-		log.Printf("Error uploading encryption keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error uploading encryption keypair: %s", err)
 		return err
 	}
 
@@ -549,9 +761,9 @@ func (e *Engine) GenerateKeypairs(ctx context.Context, notifier *observer.Notifi
 	for i, claim := range claims {
 		objs[i+2] = &claim
 	}
-	err = e.db.Set(objs...)
+	err = e.cache(ctx, objs...)
 	if err != nil {
-		log.Printf("Error storing encryption keys in local db: %s", err)
+		logging.FromContext(ctx).Errorf("Error storing encryption keys in local db: %s", err)
 		return err
 	}
 
@@ -570,32 +782,40 @@ func (e *Engine) RevokeKeypairs(ctx context.Context, notifier *observer.Notifier
 
 	keypairs, err := e.client.KeyPairs.List(ctx, orgID)
 	if err != nil {
-		log.Printf("Error retrieving keypairs: %s", err)
+		logging.FromContext(ctx).Errorf("Error retrieving keypairs: %s", err)
 		return err
 	}
 
 	encKP, err := keypairs.Select(orgID, primitive.EncryptionKeyType)
 	if err == registry.ErrMissingValidKeypair {
-		log.Printf("No keys to revoke, can't find encryption keypair")
+		logging.FromContext(ctx).Warnf("No keys to revoke, can't find encryption keypair")
 		return nil
 	}
 	if err != nil {
-		log.Printf("Could not find encryption keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Could not find encryption keypair: %s", err)
 		return err
 	}
 
 	sigKP, err := keypairs.Select(orgID, primitive.SigningKeyType)
 	if err == registry.ErrMissingValidKeypair {
-		log.Printf("No keys to revoke, can't find signing keypair")
+		logging.FromContext(ctx).Warnf("No keys to revoke, can't find signing keypair")
 		return nil
 	}
 	if err != nil {
-		log.Printf("Could not find signing keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Could not find signing keypair: %s", err)
 		return err
 	}
 
 	n.Notify(observer.Progress, "Keypairs retrieved", true)
 
+	return e.revokeKeypairs(ctx, n, orgID, sigKP, encKP)
+}
+
+// revokeKeypairs creates revocation claims for the given signing and
+// encrypting keypairs. encKP may be nil if it has already been revoked.
+func (e *Engine) revokeKeypairs(ctx context.Context, n *observer.Notifier,
+	orgID *identity.ID, sigKP, encKP *registry.ClaimedKeyPair) error {
+
 	sigID := sigKP.PublicKey.ID
 	kp := bundleKeypairs(sigKP, encKP)
 
@@ -611,7 +831,7 @@ func (e *Engine) RevokeKeypairs(ctx context.Context, notifier *observer.Notifier
 			encID, primitive.RevocationClaimType)
 		encclaim, err := e.crypto.SignedClaim(ctx, encBody, sigID, &kp.Signature)
 		if err != nil {
-			log.Printf("Error creating revocation claim for encryption key: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating revocation claim for encryption key: %s", err)
 			return err
 		}
 
@@ -619,7 +839,7 @@ func (e *Engine) RevokeKeypairs(ctx context.Context, notifier *observer.Notifier
 
 		_, err = e.client.Claims.Create(ctx, encclaim)
 		if err != nil {
-			log.Printf("Error uploading encryption keypair revocation: %s", err)
+			logging.FromContext(ctx).Errorf("Error uploading encryption keypair revocation: %s", err)
 			return err
 		}
 
@@ -635,7 +855,7 @@ func (e *Engine) RevokeKeypairs(ctx context.Context, notifier *observer.Notifier
 		sigID, primitive.RevocationClaimType)
 	sigclaim, err := e.crypto.SignedClaim(ctx, sigBody, sigID, &kp.Signature)
 	if err != nil {
-		log.Printf("Error creating revocation claim for signing key: %s", err)
+		logging.FromContext(ctx).Errorf("Error creating revocation claim for signing key: %s", err)
 		return err
 	}
 
@@ -643,7 +863,7 @@ func (e *Engine) RevokeKeypairs(ctx context.Context, notifier *observer.Notifier
 
 	_, err = e.client.Claims.Create(ctx, sigclaim)
 	if err != nil {
-		log.Printf("Error uploading signature keypair revocation: %s", err)
+		logging.FromContext(ctx).Errorf("Error uploading signature keypair revocation: %s", err)
 		return err
 	}
 
@@ -0,0 +1,193 @@
+package logic
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/db"
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+// leakedMarkReason is the reason recorded when a credential is marked for
+// rotation by FindValue
+const leakedMarkReason = "value was reported as leaked"
+
+// FindValue searches every version of every credential the user can read, in
+// every org, for the given value. It returns the location of each version
+// holding the value, and the number of keyring versions skipped because they
+// could not be decrypted.
+//
+// If mark is true, every current credential holding the value is marked for
+// rotation. Previous versions have already been replaced, so they are not
+// marked.
+func (e *Engine) FindValue(ctx context.Context, notifier *observer.Notifier,
+	value string, mark bool) (*apitypes.FindValueResult, error) {
+
+	orgs, err := e.client.Orgs.List(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error listing orgs: %s", err)
+		return nil, err
+	}
+
+	n := notifier.Notifier(uint(len(orgs)))
+
+	result := &apitypes.FindValueResult{Locations: []apitypes.ValueLocation{}}
+	for _, org := range orgs {
+		projects, err := e.client.Projects.List(ctx, org.ID)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error listing projects for org[%s]: %s", org.ID, err)
+			return nil, err
+		}
+
+		for _, project := range projects {
+			found, skipped, err := e.findValueInProject(ctx, &org, &project, value, mark)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Locations = append(result.Locations, found...)
+			result.Skipped += skipped
+		}
+
+		n.Notify(observer.Progress, "Searched org "+org.Body.Name, true)
+	}
+
+	sort.Sort(valueLocationSorter(result.Locations))
+	return result, nil
+}
+
+// findValueInProject returns the locations of value in the project, and the
+// number of graphs skipped because they could not be decrypted.
+func (e *Engine) findValueInProject(ctx context.Context, org *envelope.Org,
+	project *envelope.Project, value string, mark bool) ([]apitypes.ValueLocation, int, error) {
+
+	graphs, err := e.client.CredentialGraph.Search(ctx,
+		"/"+org.Body.Name+"/"+project.Body.Name+"/*/*/*/*", e.session.AuthID())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
+		return nil, 0, err
+	}
+
+	var matches []envelope.CredentialInf
+	skipped, err := e.unboxCredentials(ctx, graphs, true, func(cred envelope.CredentialInf, pt []byte) error {
+		v, ok, err := plaintextValue(string(pt))
+		if err != nil {
+			return err
+		}
+
+		if ok && subtle.ConstantTimeCompare([]byte(v), []byte(value)) == 1 {
+			matches = append(matches, cred)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	if len(matches) == 0 {
+		return nil, skipped, nil
+	}
+
+	heads, err := headCredentialIDs(graphs)
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	var key *[32]byte
+	if mark {
+		key, err = e.dbKey(ctx)
+		if err != nil {
+			return nil, skipped, err
+		}
+	}
+
+	var locations []apitypes.ValueLocation
+	for _, cred := range matches {
+		location := apitypes.ValueLocation{
+			PathExp:           cred.PathExp(),
+			Name:              cred.Name(),
+			CredentialVersion: cred.CredentialVersion(),
+			Current:           heads[*cred.GetID()],
+		}
+
+		if mark && location.Current {
+			err = e.db.MarkForRotation(key, e.session.AuthID(), cred.GetID(), &db.RotationMark{
+				Reason: leakedMarkReason,
+				Marked: time.Now().UTC(),
+			})
+			if err != nil {
+				logging.FromContext(ctx).Errorf("Error marking credential for rotation: %s", err)
+				return nil, skipped, err
+			}
+			location.Marked = true
+		}
+
+		locations = append(locations, location)
+	}
+
+	return locations, skipped, nil
+}
+
+// headCredentialIDs returns the ids of the most recent set version of every
+// credential in the given graphs.
+func headCredentialIDs(graphs []registry.CredentialGraph) (map[identity.ID]bool, error) {
+	cgs := newCredentialGraphSet()
+	err := cgs.Add(graphs...)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned, err := cgs.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	heads := make(map[identity.ID]bool)
+	for _, graph := range pruned {
+		for _, cred := range graph.GetCredentials() {
+			heads[*cred.GetID()] = true
+		}
+	}
+
+	return heads, nil
+}
+
+// plaintextValue returns the string form of a plaintext credential value. ok
+// is false if the value is unset.
+func plaintextValue(plaintext string) (string, bool, error) {
+	cValue := apitypes.CredentialValue{}
+	err := json.Unmarshal([]byte(strconv.Quote(plaintext)), &cValue)
+	if err != nil {
+		return "", false, err
+	}
+
+	if cValue.IsUnset() {
+		return "", false, nil
+	}
+
+	return cValue.String(), true, nil
+}
+
+// valueLocationSorter implements sort.Interface, for sorting value locations
+// by path, then from newest to oldest version.
+type valueLocationSorter []apitypes.ValueLocation
+
+func (v valueLocationSorter) Len() int      { return len(v) }
+func (v valueLocationSorter) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v valueLocationSorter) Less(i, j int) bool {
+	a, b := v[i].Path(), v[j].Path()
+	if a != b {
+		return a < b
+	}
+	return v[i].CredentialVersion > v[j].CredentialVersion
+}
@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/registry"
+)
+
+func TestHeadCredentialIDs(t *testing.T) {
+	graphs := []registry.CredentialGraph{
+		buildGraph("/o/p/e/s/u/*", 1, cred{id: id1}),
+		buildGraph("/o/p/e/s/u/*", 2, cred{id: id2, prev: id1}),
+		buildGraph("/o/p/e/s/u/*", 3, cred{id: id3, prev: id2, state: &unset}),
+	}
+
+	heads, err := headCredentialIDs(graphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(heads) != 0 {
+		t.Errorf("Expected no heads for an unset credential, got %d", len(heads))
+	}
+
+	heads, err = headCredentialIDs(graphs[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(heads) != 1 || !heads[*id2] {
+		t.Errorf("Expected only %s to be a head, got %v", id2, heads)
+	}
+}
+
+func TestPlaintextValue(t *testing.T) {
+	tcs := []struct {
+		name  string
+		value *apitypes.CredentialValue
+		str   string
+		ok    bool
+	}{
+		{"string", apitypes.NewStringCredentialValue("secret"), "secret", true},
+		{"number", apitypes.NewIntCredentialValue(5432), "5432", true},
+		{"unset", apitypes.NewUnsetCredentialValue(), "", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			pt := plaintextCred(t, id1, "/o/p/e/s/u/*", "name", tc.value).Body.Value
+
+			str, ok, err := plaintextValue(pt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if str != tc.str || ok != tc.ok {
+				t.Errorf("Expected (%q, %t), got (%q, %t)", tc.str, tc.ok, str, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package logic
+
+import (
+	"context"
+	"sort"
+
+	"github.com/manifoldco/go-base64"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/crypto"
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+// RotateKeypairs replaces the current user's signing and encryption keypairs
+// for the given org.
+//
+// New keypairs are generated and claimed, every keyring membership of the
+// user is shared again to the new encryption key, and the old keypairs are
+// then revoked. Since the user shares their own memberships, other members
+// don't need to restore their access through the worklog.
+//
+// A rotation that is interrupted can be resumed by rotating again. While the
+// user has two valid keypairs of a type, the older one is treated as the one
+// being replaced, and memberships already shared to the new key are skipped.
+func (e *Engine) RotateKeypairs(ctx context.Context, notifier *observer.Notifier,
+	orgID *identity.ID) error {
+
+	n := notifier.Notifier(2)
+
+	keypairs, err := e.client.KeyPairs.List(ctx, orgID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving keypairs: %s", err)
+		return err
+	}
+
+	oldSig, oldEnc, newSig, newEnc := rotationKeypairs(keypairs, orgID)
+	if newSig == nil {
+		// Nothing is in progress, so start a new rotation
+		oldSig, err = keypairs.Select(orgID, primitive.SigningKeyType)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not find signing keypair: %s", err)
+			return err
+		}
+
+		oldEnc, err = keypairs.Select(orgID, primitive.EncryptionKeyType)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not find encryption keypair: %s", err)
+			return err
+		}
+
+		err = e.GenerateKeypairs(ctx, n, orgID)
+		if err != nil {
+			return err
+		}
+
+		keypairs, err = e.client.KeyPairs.List(ctx, orgID)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error retrieving keypairs: %s", err)
+			return err
+		}
+
+		_, _, newSig, newEnc = rotationKeypairs(keypairs, orgID)
+		if newSig == nil || newEnc == nil {
+			logging.FromContext(ctx).Errorf("Could not find generated keypairs")
+			return registry.ErrMissingValidKeypair
+		}
+	} else {
+		n.Notify(observer.Progress, "Resuming keypair rotation", true)
+	}
+
+	// The encryption keypair is revoked before the signing keypair, so if it
+	// is already revoked, every membership was shared to the new key.
+	if oldEnc != nil {
+		if newEnc == nil {
+			logging.FromContext(ctx).Infof("Rotation was interrupted before an encryption keypair was created")
+			return &apitypes.Error{
+				Type: apitypes.InternalServerError,
+				Err: []string{"The previous rotation was interrupted before an " +
+					"encryption keypair was created. Revoke the newest signing keypair, then try again."},
+			}
+		}
+
+		err = e.reshareMemberships(ctx, n, orgID, bundleKeypairs(oldSig, oldEnc),
+			newSig, newEnc)
+		if err != nil {
+			return err
+		}
+	}
+
+	n.Notify(observer.Progress, "Keyring memberships shared", true)
+
+	return e.revokeKeypairs(ctx, n.Notifier(5), orgID, oldSig, oldEnc)
+}
+
+// rotationKeypairs returns the keypairs being replaced, and their
+// replacements, if the user has more than one valid keypair of a type in the
+// given org. The oldest valid keypair is being replaced, and the newest is
+// its replacement.
+func rotationKeypairs(keypairs *registry.Keypairs, orgID *identity.ID) (
+	oldSig, oldEnc, newSig, newEnc *registry.ClaimedKeyPair) {
+
+	var sigs, encs []registry.ClaimedKeyPair
+	for _, kp := range keypairs.All() {
+		if *kp.PublicKey.Body.OrgID != *orgID || kp.Revoked() {
+			continue
+		}
+
+		switch kp.PublicKey.Body.KeyType {
+		case primitive.SigningKeyType:
+			sigs = append(sigs, kp)
+		case primitive.EncryptionKeyType:
+			encs = append(encs, kp)
+		}
+	}
+
+	sort.Sort(claimedKeyPairSorter(sigs))
+	sort.Sort(claimedKeyPairSorter(encs))
+
+	if len(sigs) > 1 {
+		oldSig, newSig = &sigs[0], &sigs[len(sigs)-1]
+	}
+	if len(encs) > 1 {
+		oldEnc, newEnc = &encs[0], &encs[len(encs)-1]
+	}
+
+	// New encryption keypairs are created after new signing keypairs, so a
+	// single encryption keypair created after the new signing keypair is the
+	// replacement, and the old one is already revoked.
+	if newSig != nil && len(encs) == 1 {
+		if encs[0].PublicKey.Body.Created.Before(newSig.PublicKey.Body.Created) {
+			oldEnc = &encs[0]
+		} else {
+			newEnc = &encs[0]
+		}
+	}
+
+	return oldSig, oldEnc, newSig, newEnc
+}
+
+// reshareMemberships shares every keyring membership of the current user in
+// the given org to the new encryption keypair, decrypting each master
+// encryption key with the old keypair.
+func (e *Engine) reshareMemberships(ctx context.Context, notifier *observer.Notifier,
+	orgID *identity.ID, oldKP *crypto.KeyPairs, newSig, newEnc *registry.ClaimedKeyPair) error {
+
+	org, err := e.client.Orgs.Get(ctx, orgID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving org: %s", err)
+		return err
+	}
+
+	projects, err := e.client.Projects.List(ctx, orgID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving projects: %s", err)
+		return err
+	}
+
+	var graphs []registry.CredentialGraph
+	for _, project := range projects {
+		projGraphs, err := e.client.CredentialGraph.Search(ctx,
+			"/"+org.Body.Name+"/"+project.Body.Name+"/*/*/*/*", e.session.AuthID())
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
+			return err
+		}
+
+		graphs = append(graphs, projGraphs...)
+	}
+
+	claimTree, err := e.client.ClaimTree.Get(ctx, orgID, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving claim tree: %s", err)
+		return err
+	}
+
+	newKP := bundleKeypairs(newSig, newEnc)
+	authID := e.session.AuthID()
+	n := notifier.Notifier(uint(len(graphs)))
+
+	for _, graph := range graphs {
+		if hasMembershipForKey(graph, authID, newEnc.PublicKey.ID) {
+			n.Notify(observer.Progress, "Keyring already shared", true)
+			continue
+		}
+
+		krm, mekshare, err := graph.FindMember(authID)
+		if err == registry.ErrMemberNotFound || (err == nil && mekshare == nil) {
+			n.Notify(observer.Progress, "Keyring skipped", true)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// Find the key that encrypted this user into the keyring
+		encPubKeySegment, err := claimTree.Find(krm.EncryptingKeyID, false)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not find encrypting public key for membership: %s", err)
+			return err
+		}
+
+		mek, err := e.crypto.Unbox(ctx, *mekshare.Key.Value, *mekshare.Key.Nonce,
+			&oldKP.Encryption, *encPubKeySegment.PublicKey.Body.Key.Value)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not decrypt keyring master key: %s", err)
+			return err
+		}
+
+		encMek, nonce, err := e.crypto.Box(ctx, mek, &newKP.Encryption,
+			*newEnc.PublicKey.Body.Key.Value)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not encrypt keyring master key: %s", err)
+			return err
+		}
+
+		key := &primitive.KeyringMemberKey{
+			Algorithm: crypto.EasyBox,
+			Nonce:     base64.New(nonce),
+			Value:     base64.New(encMek),
+		}
+
+		newEncID := newEnc.PublicKey.ID
+		switch k := graph.GetKeyring().(type) {
+		case *envelope.KeyringV1:
+			member, err := newV1KeyringMember(ctx, e.crypto, krm.OrgID, k.Body.ProjectID,
+				krm.KeyringID, authID, newEncID, newEncID, newSig.PublicKey.ID, key, newKP)
+			if err != nil {
+				return err
+			}
+
+			_, err = e.client.KeyringMember.Post(ctx, []envelope.KeyringMemberV1{*member})
+			if err != nil {
+				logging.FromContext(ctx).Errorf("Error uploading membership: %s", err)
+				return err
+			}
+		case *envelope.Keyring:
+			member, err := newV2KeyringMember(ctx, e.crypto, krm.OrgID, krm.KeyringID,
+				authID, newEncID, newEncID, newSig.PublicKey.ID, key, newKP)
+			if err != nil {
+				return err
+			}
+
+			err = e.client.Keyring.Members.Post(ctx, *member)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("Error uploading membership: %s", err)
+				return err
+			}
+		default:
+			return &apitypes.Error{
+				Type: apitypes.InternalServerError,
+				Err:  []string{"Unknown keyring schema version"},
+			}
+		}
+
+		n.Notify(observer.Progress, "Keyring shared", true)
+	}
+
+	return nil
+}
+
+// hasMembershipForKey returns whether the owner has a membership in the
+// keyring for the given public encryption key.
+func hasMembershipForKey(graph registry.CredentialGraph, ownerID, pubKeyID *identity.ID) bool {
+	switch g := graph.(type) {
+	case *registry.CredentialGraphV1:
+		for _, m := range g.Members {
+			if *m.Body.OwnerID == *ownerID && *m.Body.PublicKeyID == *pubKeyID {
+				return true
+			}
+		}
+	case *registry.CredentialGraphV2:
+		for _, m := range g.Members {
+			body := m.Member.Body
+			if *body.OwnerID == *ownerID && *body.PublicKeyID == *pubKeyID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// claimedKeyPairSorter implements sort.Interface, for sorting keypairs from
+// oldest to newest.
+type claimedKeyPairSorter []registry.ClaimedKeyPair
+
+func (c claimedKeyPairSorter) Len() int      { return len(c) }
+func (c claimedKeyPairSorter) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c claimedKeyPairSorter) Less(i, j int) bool {
+	return c[i].PublicKey.Body.Created.Before(c[j].PublicKey.Body.Created)
+}
@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+)
+
+var keypairOrgID = mustID("04100000000000000000000000001")
+
+func claimedKeyPair(t primitive.KeyType, id byte, created time.Time, revoked bool) registry.ClaimedKeyPair {
+	ckp := registry.ClaimedKeyPair{
+		PublicKeySegment: apitypes.PublicKeySegment{
+			PublicKey: &envelope.PublicKey{
+				ID: &identity.ID{0x01, 0x06, id},
+				Body: &primitive.PublicKey{
+					Created: created,
+					OrgID:   keypairOrgID,
+					KeyType: t,
+				},
+			},
+		},
+	}
+
+	if revoked {
+		ckp.Claims = append(ckp.Claims, envelope.Claim{
+			Body: &primitive.Claim{ClaimType: primitive.RevocationClaimType},
+		})
+	}
+
+	return ckp
+}
+
+func TestRotationKeypairs(t *testing.T) {
+	old := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := old.Add(365 * 24 * time.Hour)
+
+	oldSig := claimedKeyPair(primitive.SigningKeyType, 1, old, false)
+	oldEnc := claimedKeyPair(primitive.EncryptionKeyType, 2, old, false)
+	newSig := claimedKeyPair(primitive.SigningKeyType, 3, newer, false)
+	newEnc := claimedKeyPair(primitive.EncryptionKeyType, 4, newer.Add(time.Second), false)
+	revokedEnc := claimedKeyPair(primitive.EncryptionKeyType, 2, old, true)
+
+	id := func(ckp *registry.ClaimedKeyPair) byte {
+		if ckp == nil {
+			return 0
+		}
+		return ckp.PublicKey.ID[2]
+	}
+
+	tcs := []struct {
+		name     string
+		keypairs []registry.ClaimedKeyPair
+		ids      [4]byte // old sig, old enc, new sig, new enc
+	}{
+		{"not started", []registry.ClaimedKeyPair{oldSig, oldEnc}, [4]byte{0, 0, 0, 0}},
+		{"generated", []registry.ClaimedKeyPair{newSig, oldSig, newEnc, oldEnc}, [4]byte{1, 2, 3, 4}},
+		{"encryption key not generated", []registry.ClaimedKeyPair{oldSig, oldEnc, newSig}, [4]byte{1, 2, 3, 0}},
+		{"encryption key revoked", []registry.ClaimedKeyPair{oldSig, revokedEnc, newSig, newEnc}, [4]byte{1, 0, 3, 4}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			keypairs := registry.NewKeypairs()
+			keypairs.Add(tc.keypairs...)
+
+			oldSig, oldEnc, newSig, newEnc := rotationKeypairs(keypairs, keypairOrgID)
+			ids := [4]byte{id(oldSig), id(oldEnc), id(newSig), id(newEnc)}
+			if ids != tc.ids {
+				t.Errorf("Expected keypairs %v, got %v", tc.ids, ids)
+			}
+		})
+	}
+}
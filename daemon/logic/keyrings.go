@@ -0,0 +1,514 @@
+package logic
+
+import (
+	"context"
+	"sort"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/pathexp"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+var errKeyringNotFound = &apitypes.Error{
+	Type: apitypes.NotFoundError,
+	Err:  []string{"Keyring not found"},
+}
+
+// ListKeyrings returns a summary of the most recent version of every keyring
+// the user can see at the given pathexp.
+func (e *Engine) ListKeyrings(ctx context.Context, notifier *observer.Notifier,
+	cpathexp string) ([]apitypes.KeyringSummary, error) {
+
+	n := notifier.Notifier(1)
+
+	graphs, err := e.client.CredentialGraph.Search(ctx, cpathexp, e.session.AuthID())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Keyrings retrieved", true)
+
+	return summarizeKeyrings(graphs)
+}
+
+// ViewKeyring returns the details of a version of the keyring at the given
+// pathexp. If version is 0, the most recent version is returned.
+func (e *Engine) ViewKeyring(ctx context.Context, notifier *observer.Notifier,
+	pe *pathexp.PathExp, version int) (*apitypes.KeyringDetails, error) {
+
+	n := notifier.Notifier(2)
+
+	graphs, err := e.keyringGraphs(ctx, pe)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Keyrings retrieved", true)
+
+	details, err := keyringDetails(graphs, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []identity.ID
+	for _, m := range details.Members {
+		if m.OwnerType == "user" {
+			userIDs = append(userIDs, *m.OwnerID)
+		}
+	}
+
+	if len(userIDs) > 0 {
+		users, err := e.client.Profiles.ListByID(ctx, userIDs)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error looking up keyring members: %s", err)
+			return nil, err
+		}
+
+		names := make(map[identity.ID]string, len(users))
+		for _, user := range users {
+			names[*user.ID] = user.Body.Username
+		}
+
+		for i, m := range details.Members {
+			details.Members[i].Name = names[*m.OwnerID]
+		}
+	}
+
+	n.Notify(observer.Progress, "Members retrieved", true)
+
+	sort.Sort(keyringMemberSorter(details.Members))
+	return details, nil
+}
+
+// RekeyKeyring creates a new version of the keyring at the given pathexp,
+// with a new master encryption key shared only with the org's current
+// members. The current value of every secret in the keyring is then
+// re-encrypted into the new version.
+func (e *Engine) RekeyKeyring(ctx context.Context, notifier *observer.Notifier,
+	pe *pathexp.PathExp) (*apitypes.KeyringSummary, error) {
+
+	n := notifier.Notifier(3)
+
+	graphs, err := e.keyringGraphs(ctx, pe)
+	if err != nil {
+		return nil, err
+	}
+
+	cgs := newCredentialGraphSet()
+	err = cgs.Add(graphs...)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := cgs.Head(pe)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, errKeyringNotFound
+	}
+
+	n.Notify(observer.Progress, "Keyrings retrieved", true)
+
+	// Decrypt every value before creating the new version, so a failure
+	// can't leave a keyring version without its secrets.
+	active, err := cgs.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	byPathExp := make(map[string][]*PlaintextCredentialEnvelope)
+	_, err = e.unboxCredentials(ctx, active, false, func(cred envelope.CredentialInf, pt []byte) error {
+		// v1 credentials are unset by their value, rather than their state
+		_, ok, err := plaintextValue(string(pt))
+		if err != nil || !ok {
+			return err
+		}
+
+		state := "set"
+		key := cred.PathExp().String()
+		if _, ok := byPathExp[key]; !ok {
+			order = append(order, key)
+		}
+		byPathExp[key] = append(byPathExp[key], &PlaintextCredentialEnvelope{
+			Body: &PlaintextCredential{
+				Name:      cred.Name(),
+				PathExp:   cred.PathExp(),
+				ProjectID: cred.ProjectID(),
+				OrgID:     cred.OrgID(),
+				Value:     string(pt),
+				State:     &state,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n.Notify(observer.Progress, "Credentials decrypted", true)
+
+	newGraph, err := e.newKeyringVersion(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+
+	// The new version has no revocations, so appending adds each secret to
+	// it as a new credential version.
+	count := 0
+	for _, key := range order {
+		creds, err := e.AppendCredentials(ctx, n, byPathExp[key])
+		if err != nil {
+			return nil, err
+		}
+		count += len(creds)
+	}
+
+	n.Notify(observer.Progress, "Credentials re-encrypted", true)
+
+	summary := keyringSummary(newGraph)
+	summary.CredentialCount = count
+	return &summary, nil
+}
+
+// keyringGraphs returns every version of the keyring at the given pathexp.
+func (e *Engine) keyringGraphs(ctx context.Context, pe *pathexp.PathExp) ([]registry.CredentialGraph, error) {
+	// Keyrings are shared by every instance
+	kpe, err := pe.WithInstance("*")
+	if err != nil {
+		return nil, err
+	}
+
+	graphs, err := e.client.CredentialGraph.List(ctx, "", kpe, e.session.AuthID())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
+		return nil, err
+	}
+
+	var matching []registry.CredentialGraph
+	for _, graph := range graphs {
+		if graph.GetKeyring().PathExp().Equal(kpe) {
+			matching = append(matching, graph)
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil, errKeyringNotFound
+	}
+
+	return matching, nil
+}
+
+// newKeyringVersion creates and posts a new version of the keyring in head,
+// with a new master encryption key shared with every current member of the
+// org.
+func (e *Engine) newKeyringVersion(ctx context.Context,
+	head registry.CredentialGraph) (*registry.CredentialGraphV2, error) {
+
+	orgID := head.GetKeyring().OrgID()
+
+	var projectID *identity.ID
+	switch k := head.GetKeyring().(type) {
+	case *envelope.KeyringV1:
+		projectID = k.Body.ProjectID
+	case *envelope.Keyring:
+		projectID = k.Body.ProjectID
+	default:
+		return nil, errUnknownKeyringVersion
+	}
+
+	keypairs, err := e.client.KeyPairs.List(ctx, orgID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error fetching keypairs: %s", err)
+		return nil, err
+	}
+
+	claimtree, err := e.client.ClaimTree.Get(ctx, orgID, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error fetching claimtree for org[%s]: %s", orgID, err)
+		return nil, err
+	}
+
+	sigID, encID, kp, err := fetchKeyPairs(keypairs, orgID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error fetching keypairs: %s", err)
+		return nil, err
+	}
+
+	body := &PlaintextCredential{
+		OrgID:     orgID,
+		ProjectID: projectID,
+		PathExp:   head.GetKeyring().PathExp(),
+	}
+
+	newGraph, err := createCredentialGraph(ctx, body, head, sigID, encID, kp,
+		claimtree, e.client, e.crypto)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error creating credential graph: %s", err)
+		return nil, err
+	}
+
+	var graph registry.CredentialGraph = newGraph
+	_, err = e.client.CredentialGraph.Post(ctx, &graph)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Error creating credential graph: %s", err)
+		return nil, err
+	}
+
+	return newGraph, nil
+}
+
+// summarizeKeyrings returns a summary of the most recent version of every
+// keyring in graphs, ordered by pathexp.
+func summarizeKeyrings(graphs []registry.CredentialGraph) ([]apitypes.KeyringSummary, error) {
+	cgs := newCredentialGraphSet()
+	err := cgs.Add(graphs...)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var heads []registry.CredentialGraph
+	for _, graph := range graphs {
+		kpe := graph.GetKeyring().PathExp()
+		if seen[kpe.String()] {
+			continue
+		}
+		seen[kpe.String()] = true
+
+		head, err := cgs.Head(kpe)
+		if err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+
+	// Summaries must be made before pruning, which removes all but the
+	// current credentials from each graph.
+	summaries := make([]apitypes.KeyringSummary, len(heads))
+	for i, head := range heads {
+		summaries[i] = keyringSummary(head)
+	}
+
+	counts, err := currentCredentialCounts(cgs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		summaries[i].CredentialCount = counts[summaries[i].PathExp.String()]
+	}
+
+	sort.Sort(keyringSummarySorter(summaries))
+	return summaries, nil
+}
+
+// keyringDetails returns the details of the given version of the keyring in
+// graphs, or its most recent version if version is 0. Member names are not
+// set.
+func keyringDetails(graphs []registry.CredentialGraph, version int) (*apitypes.KeyringDetails, error) {
+	var graph registry.CredentialGraph
+	for _, g := range graphs {
+		if version == 0 && (graph == nil || g.KeyringVersion() > graph.KeyringVersion()) {
+			graph = g
+		}
+		if version != 0 && g.KeyringVersion() == version {
+			graph = g
+		}
+	}
+
+	if graph == nil {
+		return nil, errKeyringNotFound
+	}
+
+	details := &apitypes.KeyringDetails{
+		KeyringSummary: keyringSummary(graph),
+		Members:        keyringMembers(graph),
+		Credentials:    []apitypes.KeyringCredential{},
+	}
+
+	creds := graph.GetCredentials()
+	for _, cred := range creds {
+		details.Credentials = append(details.Credentials, apitypes.KeyringCredential{
+			PathExp:           cred.PathExp(),
+			Name:              cred.Name(),
+			CredentialVersion: cred.CredentialVersion(),
+			Unset:             cred.Unset(),
+		})
+	}
+
+	// Heads removes all but the current credentials from each graph, so the
+	// credentials must be listed first.
+	cgs := newCredentialGraphSet()
+	err := cgs.Add(graphs...)
+	if err != nil {
+		return nil, err
+	}
+
+	heads, err := cgs.Heads()
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[identity.ID]bool)
+	for _, g := range heads {
+		for _, cred := range g.GetCredentials() {
+			current[*cred.GetID()] = true
+		}
+	}
+
+	for i, cred := range creds {
+		details.Credentials[i].Current = current[*cred.GetID()]
+		if !cred.Unset() && details.Credentials[i].Current {
+			details.CredentialCount++
+		}
+	}
+
+	sort.Sort(keyringCredentialSorter(details.Credentials))
+	return details, nil
+}
+
+// currentCredentialCounts returns the number of current, set credentials for
+// each keyring pathexp in cgs.
+func currentCredentialCounts(cgs *credentialGraphSet) (map[string]int, error) {
+	active, err := cgs.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, graph := range active {
+		counts[graph.GetKeyring().PathExp().String()] += len(graph.GetCredentials())
+	}
+
+	return counts, nil
+}
+
+// keyringSummary returns the summary of a keyring version, without its
+// credential count.
+func keyringSummary(graph registry.CredentialGraph) apitypes.KeyringSummary {
+	summary := apitypes.KeyringSummary{
+		ID:             graph.GetKeyring().GetID(),
+		PathExp:        graph.GetKeyring().PathExp(),
+		KeyringVersion: graph.KeyringVersion(),
+	}
+
+	switch k := graph.GetKeyring().(type) {
+	case *envelope.KeyringV1:
+		summary.Created = k.Body.Created
+	case *envelope.Keyring:
+		summary.Created = k.Body.Created
+	}
+
+	for _, m := range keyringMembers(graph) {
+		if m.Revoked {
+			summary.RevocationCount++
+		} else {
+			summary.MemberCount++
+		}
+	}
+
+	return summary
+}
+
+// keyringMembers returns every membership in a keyring version. Member names
+// are not set.
+func keyringMembers(graph registry.CredentialGraph) []apitypes.KeyringMemberDetails {
+	members := []apitypes.KeyringMemberDetails{}
+	switch g := graph.(type) {
+	case *registry.CredentialGraphV1:
+		for _, m := range g.Members {
+			members = append(members, apitypes.KeyringMemberDetails{
+				OwnerID:         m.Body.OwnerID,
+				OwnerType:       ownerType(m.Body.OwnerID),
+				EncryptingKeyID: m.Body.EncryptingKeyID,
+				MEKShare:        m.Body.Key != nil,
+			})
+		}
+	case *registry.CredentialGraphV2:
+		for _, m := range g.Members {
+			member := apitypes.KeyringMemberDetails{
+				OwnerID:         m.Member.Body.OwnerID,
+				OwnerType:       ownerType(m.Member.Body.OwnerID),
+				EncryptingKeyID: m.Member.Body.EncryptingKeyID,
+				MEKShare:        m.MEKShare != nil,
+			}
+
+			for _, c := range g.Claims {
+				if *c.Body.KeyringMemberID != *m.Member.ID ||
+					c.Body.ClaimType != primitive.RevocationClaimType {
+					continue
+				}
+
+				member.Revoked = true
+				if c.Body.Reason != nil {
+					member.RevocationReason = c.Body.Reason.Type.String()
+				}
+			}
+
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+// ownerType returns the type of keyring member owner the id belongs to.
+func ownerType(id *identity.ID) string {
+	switch id.Type() {
+	case (&primitive.User{}).Type():
+		return "user"
+	case (&primitive.MachineToken{}).Type():
+		return "machine"
+	default:
+		return "unknown"
+	}
+}
+
+// keyringSummarySorter implements sort.Interface, for sorting keyrings
+// lexicographically by pathexp.
+type keyringSummarySorter []apitypes.KeyringSummary
+
+func (k keyringSummarySorter) Len() int      { return len(k) }
+func (k keyringSummarySorter) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k keyringSummarySorter) Less(i, j int) bool {
+	return k[i].PathExp.String() < k[j].PathExp.String()
+}
+
+// keyringMemberSorter implements sort.Interface, for sorting members by type,
+// then name, then owner id.
+type keyringMemberSorter []apitypes.KeyringMemberDetails
+
+func (k keyringMemberSorter) Len() int      { return len(k) }
+func (k keyringMemberSorter) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k keyringMemberSorter) Less(i, j int) bool {
+	if k[i].OwnerType != k[j].OwnerType {
+		return k[i].OwnerType < k[j].OwnerType
+	}
+	if k[i].Name != k[j].Name {
+		return k[i].Name < k[j].Name
+	}
+	return k[i].OwnerID.String() < k[j].OwnerID.String()
+}
+
+// keyringCredentialSorter implements sort.Interface, for sorting credentials
+// by path, then from newest to oldest version.
+type keyringCredentialSorter []apitypes.KeyringCredential
+
+func (k keyringCredentialSorter) Len() int      { return len(k) }
+func (k keyringCredentialSorter) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k keyringCredentialSorter) Less(i, j int) bool {
+	a, b := k[i].Path(), k[j].Path()
+	if a != b {
+		return a < b
+	}
+	return k[i].CredentialVersion > k[j].CredentialVersion
+}
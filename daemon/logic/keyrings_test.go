@@ -0,0 +1,119 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
+	"github.com/manifoldco/torus-cli/registry"
+)
+
+func addMember(graph registry.CredentialGraph, memberID, ownerID *identity.ID, revoked bool) {
+	g := graph.(*registry.CredentialGraphV2)
+	g.Members = append(g.Members, registry.KeyringMember{
+		Member: &envelope.KeyringMember{
+			ID:   memberID,
+			Body: &primitive.KeyringMember{OwnerID: ownerID},
+		},
+	})
+
+	if revoked {
+		g.Claims = append(g.Claims, envelope.KeyringMemberClaim{
+			Body: &primitive.KeyringMemberClaim{
+				KeyringMemberID: memberID,
+				OwnerID:         ownerID,
+				ClaimType:       primitive.RevocationClaimType,
+				Reason: &primitive.KeyringMemberClaimReason{
+					Type: primitive.OrgRemovalRevocationType,
+				},
+			},
+		})
+	}
+}
+
+func TestSummarizeKeyrings(t *testing.T) {
+	api := "/o/p/e/api/*/*"
+	web := "/o/p/e/web/*/*"
+
+	graphs := []registry.CredentialGraph{
+		buildGraph(web, 1, cred{id: id3, pe: &web, state: &unset}),
+		buildGraph(api, 1, cred{id: id1, pe: &api}),
+		buildGraph(api, 2, cred{id: id2, pe: &api}),
+	}
+
+	user := &identity.ID{0x01, (&primitive.User{}).Type()}
+	addMember(graphs[2], mustID("04100000000000000000000001000"), user, false)
+	addMember(graphs[2], mustID("04100000000000000000000010000"), user, true)
+
+	summaries, err := summarizeKeyrings(graphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 keyrings, got %d", len(summaries))
+	}
+
+	s := summaries[0]
+	if s.PathExp.String() != api || s.KeyringVersion != 2 {
+		t.Errorf("Expected version 2 of %s, got version %d of %s", api, s.KeyringVersion, s.PathExp)
+	}
+	if s.MemberCount != 1 || s.RevocationCount != 1 {
+		t.Errorf("Expected 1 member and 1 revocation, got %d and %d", s.MemberCount, s.RevocationCount)
+	}
+	if s.CredentialCount != 2 {
+		t.Errorf("Expected 2 current secrets, got %d", s.CredentialCount)
+	}
+
+	if summaries[1].CredentialCount != 0 {
+		t.Errorf("Expected unset secrets not to be counted, got %d", summaries[1].CredentialCount)
+	}
+}
+
+func TestKeyringDetails(t *testing.T) {
+	pe := "/o/p/e/s/*/*"
+	name := "name"
+	graphs := []registry.CredentialGraph{
+		buildGraph(pe, 1, cred{id: id1, pe: &pe, name: &name}),
+		buildGraph(pe, 2, cred{id: id2, prev: id1, pe: &pe, name: &name}),
+	}
+
+	machine := &identity.ID{0x01, (&primitive.MachineToken{}).Type()}
+	addMember(graphs[0], mustID("04100000000000000000000001000"), machine, true)
+
+	details, err := keyringDetails(graphs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if details.KeyringVersion != 1 {
+		t.Errorf("Expected version 1, got %d", details.KeyringVersion)
+	}
+
+	if len(details.Credentials) != 1 || details.Credentials[0].Current {
+		t.Errorf("Expected one previous version, got %+v", details.Credentials)
+	}
+
+	if len(details.Members) != 1 {
+		t.Fatalf("Expected 1 member, got %d", len(details.Members))
+	}
+	m := details.Members[0]
+	if m.OwnerType != "machine" || !m.Revoked || m.RevocationReason != "org_removal" {
+		t.Errorf("Expected revoked machine, got %+v", m)
+	}
+
+	details, err = keyringDetails(graphs, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if details.KeyringVersion != 2 || !details.Credentials[0].Current {
+		t.Errorf("Expected current secret in version 2, got %+v", details)
+	}
+
+	_, err = keyringDetails(graphs, 3)
+	if err != errKeyringNotFound {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
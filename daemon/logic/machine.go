@@ -2,7 +2,6 @@ package logic
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"github.com/manifoldco/go-base64"
@@ -10,6 +9,7 @@ import (
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
 
@@ -90,7 +90,7 @@ func (m *Machine) CreateToken(ctx context.Context, notifier *observer.Notifier,
 	n.Notify(observer.Progress, "Generating token keypairs", true)
 	kp, err := c.GenerateKeyPairs(ctx)
 	if err != nil {
-		log.Printf("Error generating machine keypairs: %s", err)
+		logging.FromContext(ctx).Errorf("Error generating machine keypairs: %s", err)
 		return nil, err
 	}
 
@@ -126,7 +126,7 @@ func (m *Machine) EncodeToken(ctx context.Context, notifier *observer.Notifier,
 	if len(v1members) != 0 {
 		_, err = m.engine.client.KeyringMember.Post(ctx, v1members)
 		if err != nil {
-			log.Printf("error uploading memberships: %s", err)
+			logging.FromContext(ctx).Errorf("error uploading memberships: %s", err)
 			return err
 		}
 	}
@@ -134,7 +134,7 @@ func (m *Machine) EncodeToken(ctx context.Context, notifier *observer.Notifier,
 	for _, member := range v2members {
 		err = m.engine.client.Keyring.Members.Post(ctx, member)
 		if err != nil {
-			log.Printf("error uploading memberships: %s", err)
+			logging.FromContext(ctx).Errorf("error uploading memberships: %s", err)
 			return err
 		}
 	}
@@ -147,27 +147,27 @@ func generateKeypairs(ctx context.Context, c *crypto.Engine, orgID, authID *iden
 
 	pubsig, privsig, err := packageSigningKeypair(ctx, c, authID, orgID, kp)
 	if err != nil {
-		log.Printf("Error packaging machine signing keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error packaging machine signing keypair: %s", err)
 		return nil, err
 	}
 
 	rawsigClaim := primitive.NewClaim(orgID, authID, pubsig.ID, pubsig.ID, primitive.SignatureClaimType)
 	sigclaim, err := c.SignedClaim(ctx, rawsigClaim, pubsig.ID, &kp.Signature)
 	if err != nil {
-		log.Printf("Error generating signature claim: %s", err)
+		logging.FromContext(ctx).Errorf("Error generating signature claim: %s", err)
 		return nil, err
 	}
 
 	pubenc, privenc, err := packageEncryptionKeypair(ctx, c, authID, orgID, kp, pubsig)
 	if err != nil {
-		log.Printf("Error packaging machine encryption keypair: %s", err)
+		logging.FromContext(ctx).Errorf("Error packaging machine encryption keypair: %s", err)
 		return nil, err
 	}
 
 	rawencClaim := primitive.NewClaim(orgID, authID, pubenc.ID, pubenc.ID, primitive.SignatureClaimType)
 	encclaim, err := c.SignedClaim(ctx, rawencClaim, pubsig.ID, &kp.Signature)
 	if err != nil {
-		log.Printf("Error generating encryption claim: %s", err)
+		logging.FromContext(ctx).Errorf("Error generating encryption claim: %s", err)
 		return nil, err
 	}
 
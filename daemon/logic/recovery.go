@@ -0,0 +1,167 @@
+package logic
+
+import (
+	"context"
+
+	"github.com/manifoldco/go-base64"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/primitive"
+
+	"github.com/manifoldco/torus-cli/daemon/crypto"
+)
+
+// ExportRecoveryKit seals the logged in user's master key and login keypair
+// into a recovery kit, under a newly generated recovery code.
+func (s *Session) ExportRecoveryKit(ctx context.Context) (*apitypes.RecoveryKit, error) {
+	if s.engine.session.Type() != apitypes.UserSession {
+		return nil, &apitypes.Error{
+			Type: apitypes.BadRequestError,
+			Err:  []string{"You must be a logged in user to export a recovery kit"},
+		}
+	}
+
+	user, ok := s.engine.session.Self().Auth.(*envelope.User)
+	if !ok || user.Body.PublicKey == nil {
+		return nil, &apitypes.Error{
+			Type: apitypes.BadRequestError,
+			Err:  []string{"User schema must be v2 to export a recovery kit"},
+		}
+	}
+
+	code, err := crypto.NewRecoveryCode()
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Could not generate recovery code: %s", err)
+		return nil, err
+	}
+
+	kit, err := s.engine.crypto.RecoveryKit(ctx, code, user.ID, user.Body.Email,
+		user.Body.PublicKey.Salt)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Could not seal recovery kit: %s", err)
+		return nil, err
+	}
+
+	b, err := kit.Encode()
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Could not encode recovery kit: %s", err)
+		return nil, err
+	}
+
+	return &apitypes.RecoveryKit{Kit: string(b), Code: code}, nil
+}
+
+// RestoreRecoveryKit resets the password of the user a recovery kit belongs
+// to. The login keypair in the kit is used to authenticate, and the master
+// key is re-encrypted with the new password. On success, the user is logged
+// in.
+func (s *Session) RestoreRecoveryKit(ctx context.Context, kitText []byte,
+	code, newPassword string) error {
+
+	if s.engine.session.Token() != "" {
+		return &apitypes.Error{
+			Type: apitypes.BadRequestError,
+			Err:  []string{"You must be logged out to restore a recovery kit"},
+		}
+	}
+
+	kit, err := crypto.DecodeRecoveryKit(kitText)
+	if err != nil {
+		return &apitypes.Error{Type: apitypes.BadRequestError, Err: []string{err.Error()}}
+	}
+
+	masterKey, loginKP, err := kit.Open(ctx, code)
+	if err != nil {
+		return &apitypes.Error{Type: apitypes.BadRequestError, Err: []string{err.Error()}}
+	}
+
+	salt, loginToken, err := s.engine.client.Tokens.PostLogin(ctx,
+		&apitypes.UserLogin{Email: kit.Email})
+	if err != nil {
+		return err
+	}
+
+	// The login keypair is derived from the password, so it is only valid
+	// while the password is unchanged.
+	if loginToken.Body.Mechanism != primitive.EdDSAAuth || salt.String() != kit.Salt.String() {
+		return &apitypes.Error{
+			Type: apitypes.BadRequestError,
+			Err: []string{"Your password has changed since this recovery kit was " +
+				"exported. Use a newer recovery kit."},
+		}
+	}
+
+	tokenString := loginToken.Body.Token
+	sig := loginKP.Sign([]byte(tokenString))
+	authToken, err := s.engine.client.Tokens.PostEdDSAAuth(ctx, tokenString, sig)
+	if err != nil {
+		return err
+	}
+
+	token := authToken.Body.Token
+	self, err := s.engine.client.Self.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if self.Type != apitypes.UserSession || *self.Identity.GetID() != *kit.UserID {
+		return &apitypes.Error{
+			Type: apitypes.BadRequestError,
+			Err:  []string{"Recovery kit does not belong to this user"},
+		}
+	}
+
+	pw, master, err := crypto.EncryptPasswordObject(ctx, newPassword, &masterKey)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Could not re-encrypt master key: %s", err)
+		return err
+	}
+
+	newSalt, err := base64.NewFromString(pw.Salt)
+	if err != nil {
+		return err
+	}
+
+	keypair, err := crypto.DeriveLoginKeypair(ctx, []byte(newPassword), newSalt)
+	if err != nil {
+		return err
+	}
+
+	// The session must be set to update the user. It holds the new password,
+	// as the master key is re-encrypted with it.
+	err = s.engine.session.Set(self.Type, self.Identity, self.Auth, []byte(newPassword), token)
+	if err != nil {
+		return err
+	}
+
+	updatedUser, err := s.engine.client.Users.Update(ctx, &updateProfile{
+		Password: pw,
+		Master:   master,
+		PublicKey: &primitive.LoginPublicKey{
+			Salt:  keypair.Salt(),
+			Value: keypair.PublicKey(),
+			Alg:   crypto.EdDSA,
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Could not update password on server due to err: %s", err)
+		if logoutErr := s.Logout(ctx); logoutErr != nil {
+			logging.FromContext(ctx).Errorf("Could not log out after failed recovery: %s", logoutErr)
+		}
+		return err
+	}
+
+	// The master key is only opened with the new password once the session
+	// holds the updated user.
+	err = s.engine.session.SetIdentity(apitypes.UserSession, updatedUser, updatedUser)
+	if err != nil {
+		return err
+	}
+
+	if err := s.engine.cache(ctx, updatedUser); err != nil {
+		logging.FromContext(ctx).Errorf("Error storing user in local db: %s", err)
+	}
+	return nil
+}
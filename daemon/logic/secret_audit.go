@@ -0,0 +1,246 @@
+package logic
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
+
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+// DefaultSecretMaxAge is the age after which a secret's value is considered
+// stale, if no other age is given.
+const DefaultSecretMaxAge = 90 * 24 * time.Hour
+
+const (
+	// minSecretLength is the shortest value not reported as short
+	minSecretLength = 12
+
+	// minSecretEntropy is the lowest Shannon entropy, in bits per character,
+	// not reported as low entropy
+	minSecretEntropy = 3.0
+
+	// fingerprintLen is the number of hex characters in a value fingerprint
+	fingerprintLen = 8
+)
+
+// placeholderRe matches values that are commonly used as placeholders, and
+// were likely never replaced with a real secret.
+var placeholderRe = regexp.MustCompile(`(?i)^(` +
+	`change[-_ ]?me|replace[-_ ]?me|fix[-_ ]?me|todo|tbd|placeholder|` +
+	`secret|password|passw0rd|p@ssw0rd|letmein|admin|root|test|testing|` +
+	`example|dummy|default|foo|bar|foobar|null|nil|none|undefined|empty|` +
+	`x+|0+|1234\d*|qwerty|abc123|your[-_ ]?\w*|<[^>]*>|\$\{[^}]*\}|\{\{[^}]*\}\}` +
+	`)$`)
+
+// AuditSecrets decrypts every secret the user can read at the given pathexp,
+// and returns the results for every secret with a weak, reused, or stale
+// value.
+//
+// Values are compared by a hash keyed with a random key for each audit, so
+// neither values nor stable hashes of values leave the daemon.
+//
+// Credentials don't record when they were set, so a value's age is measured
+// from when the daemon first saw its credential. Values that changed before
+// the daemon first saw them may be older than reported.
+func (e *Engine) AuditSecrets(ctx context.Context, n *observer.Notifier,
+	cpathexp string, maxAge time.Duration) ([]apitypes.SecretAuditResult, error) {
+	return e.auditSecrets(ctx, n, cpathexp, maxAge, true)
+}
+
+// auditSecrets audits the secrets at the given pathexp. If record is false,
+// the time each credential was first seen is read, but not recorded, so only
+// credentials seen by an earlier audit can be reported as stale.
+func (e *Engine) auditSecrets(ctx context.Context, n *observer.Notifier,
+	cpathexp string, maxAge time.Duration, record bool) ([]apitypes.SecretAuditResult, error) {
+
+	creds, err := e.RetrieveCredentials(ctx, n, nil, &cpathexp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dbKey, err := e.dbKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	firstSeen := make(map[identity.ID]time.Time, len(creds))
+	for _, cred := range creds {
+		var seen time.Time
+		if record {
+			seen, err = e.db.FirstSeen(dbKey, e.session.AuthID(), cred.ID, now)
+		} else {
+			seen, err = e.db.Seen(dbKey, e.session.AuthID(), cred.ID)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error reading credential first seen time: %s", err)
+			return nil, err
+		}
+		firstSeen[*cred.ID] = seen
+	}
+
+	key := make([]byte, sha256.Size)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return auditCredentials(creds, key, firstSeen, now, maxAge)
+}
+
+// auditCredentials returns the results for every credential in creds with at
+// least one issue, ordered by path.
+//
+// Only string values are checked. Numbers and other types are assumed to be
+// configuration, rather than secrets.
+func auditCredentials(creds []PlaintextCredentialEnvelope, key []byte,
+	firstSeen map[identity.ID]time.Time, now time.Time,
+	maxAge time.Duration) ([]apitypes.SecretAuditResult, error) {
+
+	type audited struct {
+		result *apitypes.SecretAuditResult
+		hash   string
+		env    string
+	}
+
+	var all []*audited
+	byHash := make(map[string][]*audited)
+	for _, cred := range creds {
+		value, ok, err := stringValue(cred.Body.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		hash := hex.EncodeToString(mac.Sum(nil))
+
+		a := &audited{
+			result: &apitypes.SecretAuditResult{
+				PathExp:     cred.Body.PathExp,
+				Name:        cred.Body.Name,
+				Fingerprint: hash[:fingerprintLen],
+				Issues:      valueIssues(value),
+				FirstSeen:   firstSeen[*cred.ID],
+			},
+			hash: hash,
+			env:  cred.Body.PathExp.Envs.String(),
+		}
+
+		seen := a.result.FirstSeen
+		if maxAge > 0 && !seen.IsZero() && now.Sub(seen) > maxAge {
+			a.result.Issues = append(a.result.Issues, apitypes.StaleSecretAuditIssue)
+		}
+
+		all = append(all, a)
+		byHash[hash] = append(byHash[hash], a)
+	}
+
+	// A value is reused if the same value is found in another environment.
+	// Sharing a value between services in the same environment is common
+	// enough that it isn't reported.
+	for _, a := range all {
+		for _, other := range byHash[a.hash] {
+			if other.env != a.env {
+				a.result.ReusedBy = append(a.result.ReusedBy, other.result.Path())
+			}
+		}
+
+		if len(a.result.ReusedBy) > 0 {
+			sort.Strings(a.result.ReusedBy)
+			a.result.Issues = append([]apitypes.SecretAuditIssue{apitypes.ReusedSecretAuditIssue},
+				a.result.Issues...)
+		}
+	}
+
+	results := []apitypes.SecretAuditResult{}
+	for _, a := range all {
+		if len(a.result.Issues) > 0 {
+			results = append(results, *a.result)
+		}
+	}
+
+	sort.Sort(auditResultSorter(results))
+
+	return results, nil
+}
+
+// stringValue returns the string held by a plaintext credential value. ok is
+// false if the value is unset, or is not a string.
+func stringValue(plaintext string) (string, bool, error) {
+	cValue := apitypes.CredentialValue{}
+	err := json.Unmarshal([]byte(strconv.Quote(plaintext)), &cValue)
+	if err != nil {
+		return "", false, err
+	}
+
+	if cValue.IsUnset() {
+		return "", false, nil
+	}
+
+	raw, err := cValue.Raw()
+	if err != nil {
+		return "", false, err
+	}
+
+	s, ok := raw.(string)
+	return s, ok, nil
+}
+
+// valueIssues returns the issues found with the given value on its own.
+func valueIssues(value string) []apitypes.SecretAuditIssue {
+	var issues []apitypes.SecretAuditIssue
+	if placeholderRe.MatchString(value) {
+		issues = append(issues, apitypes.PlaceholderSecretAuditIssue)
+	}
+
+	if len(value) < minSecretLength {
+		issues = append(issues, apitypes.ShortSecretAuditIssue)
+	} else if shannonEntropy(value) < minSecretEntropy {
+		issues = append(issues, apitypes.LowEntropySecretAuditIssue)
+	}
+
+	return issues
+}
+
+// shannonEntropy returns the Shannon entropy of s, in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// auditResultSorter implements sort.Interface, for sorting audit results
+// lexicographically by path.
+type auditResultSorter []apitypes.SecretAuditResult
+
+func (a auditResultSorter) Len() int           { return len(a) }
+func (a auditResultSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a auditResultSorter) Less(i, j int) bool { return a[i].Path() < a[j].Path() }
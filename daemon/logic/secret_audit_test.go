@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/identity"
+)
+
+func plaintextCred(t *testing.T, id *identity.ID, path, name string,
+	value *apitypes.CredentialValue) PlaintextCredentialEnvelope {
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, err := strconv.Unquote(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return PlaintextCredentialEnvelope{
+		ID: id,
+		Body: &PlaintextCredential{
+			Name:    name,
+			PathExp: mustPathExp(path),
+			Value:   pt,
+		},
+	}
+}
+
+func TestValueIssues(t *testing.T) {
+	tcs := []struct {
+		value  string
+		issues []apitypes.SecretAuditIssue
+	}{
+		{"Zq8#vL2!pR9@xT4$", nil},
+		{"changeme", []apitypes.SecretAuditIssue{
+			apitypes.PlaceholderSecretAuditIssue, apitypes.ShortSecretAuditIssue}},
+		{"<your-api-key-here>", []apitypes.SecretAuditIssue{apitypes.PlaceholderSecretAuditIssue}},
+		{"${DATABASE_PASSWORD}", []apitypes.SecretAuditIssue{apitypes.PlaceholderSecretAuditIssue}},
+		{"abc", []apitypes.SecretAuditIssue{apitypes.ShortSecretAuditIssue}},
+		{"aaaaaaaabbbbbbbb", []apitypes.SecretAuditIssue{apitypes.LowEntropySecretAuditIssue}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.value, func(t *testing.T) {
+			issues := valueIssues(tc.value)
+			if !reflect.DeepEqual(issues, tc.issues) {
+				t.Errorf("Expected %v, got %v", tc.issues, issues)
+			}
+		})
+	}
+}
+
+func TestAuditCredentials(t *testing.T) {
+	strong := "Zq8#vL2!pR9@xT4$"
+	now := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.Add(-100 * 24 * time.Hour)
+
+	creds := []PlaintextCredentialEnvelope{
+		plaintextCred(t, id1, "/o/p/prod/api/*/*", "db", apitypes.NewStringCredentialValue(strong)),
+		plaintextCred(t, id2, "/o/p/staging/api/*/*", "db", apitypes.NewStringCredentialValue(strong)),
+		plaintextCred(t, id3, "/o/p/prod/web/*/*", "db", apitypes.NewStringCredentialValue(strong)),
+		plaintextCred(t, mustID("04100000000000000000000001000"), "/o/p/prod/api/*/*", "port",
+			apitypes.NewIntCredentialValue(80)),
+		plaintextCred(t, mustID("04100000000000000000000010000"), "/o/p/dev/api/*/*", "token",
+			apitypes.NewStringCredentialValue("todo")),
+	}
+
+	firstSeen := map[identity.ID]time.Time{*id1: now, *id2: now, *id3: old}
+	results, err := auditCredentials(creds, []byte("key"), firstSeen, now, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{
+		"/o/p/dev/api/*/*/token",
+		"/o/p/prod/api/*/*/db",
+		"/o/p/prod/web/*/*/db",
+		"/o/p/staging/api/*/*/db",
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("Expected %d results, got %d", len(paths), len(results))
+	}
+
+	for i, p := range paths {
+		if results[i].Path() != p {
+			t.Errorf("Expected result %d to be %s, got %s", i, p, results[i].Path())
+		}
+	}
+
+	t.Run("reused across environments", func(t *testing.T) {
+		reusedBy := []string{"/o/p/staging/api/*/*/db"}
+		if !reflect.DeepEqual(results[1].ReusedBy, reusedBy) {
+			t.Errorf("Expected reused by %v, got %v", reusedBy, results[1].ReusedBy)
+		}
+
+		reusedBy = []string{"/o/p/prod/api/*/*/db", "/o/p/prod/web/*/*/db"}
+		if !reflect.DeepEqual(results[3].ReusedBy, reusedBy) {
+			t.Errorf("Expected reused by %v, got %v", reusedBy, results[3].ReusedBy)
+		}
+
+		if results[1].Fingerprint != results[3].Fingerprint {
+			t.Error("Expected equal values to have equal fingerprints")
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		if !results[2].Has(apitypes.StaleSecretAuditIssue) {
+			t.Error("Expected old value to be stale")
+		}
+		if results[1].Has(apitypes.StaleSecretAuditIssue) {
+			t.Error("Expected new value not to be stale")
+		}
+	})
+
+	t.Run("weak", func(t *testing.T) {
+		issues := []apitypes.SecretAuditIssue{
+			apitypes.PlaceholderSecretAuditIssue, apitypes.ShortSecretAuditIssue,
+		}
+		if !reflect.DeepEqual(results[0].Issues, issues) {
+			t.Errorf("Expected issues %v, got %v", issues, results[0].Issues)
+		}
+	})
+}
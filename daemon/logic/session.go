@@ -2,13 +2,15 @@ package logic
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"reflect"
 
 	"github.com/manifoldco/go-base64"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/primitive"
 
 	"github.com/manifoldco/torus-cli/daemon/crypto"
@@ -66,12 +68,31 @@ func (s *Session) Login(ctx context.Context, creds apitypes.LoginCredential) err
 		return err
 	}
 
-	s.engine.db.Set(self.Identity)
+	err = s.engine.session.Set(self.Type, self.Identity, self.Auth, creds.Passphrase(), token)
+	if err != nil {
+		return err
+	}
+
+	// Values are sealed with a key derived from the master key, so they can
+	// only be cached once the session is set.
+	objs := []envelope.Envelope{self.Identity}
+	if self.Type == apitypes.UserSession {
+		objs = append(objs, self.Auth)
+	}
+	if err := s.engine.cache(ctx, objs...); err != nil {
+		logging.FromContext(ctx).Errorf("Error storing session in local db: %s", err)
+	}
+
 	if self.Type == apitypes.UserSession {
-		s.engine.db.Set(self.Auth)
+		// The user is logged in even if the upgrade fails; it will be
+		// attempted again on their next login.
+		err = s.attemptKDFUpgrade(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not upgrade password key derivation: %s", err)
+		}
 	}
 
-	return s.engine.session.Set(self.Type, self.Identity, self.Auth, creds.Passphrase(), token)
+	return nil
 }
 
 // UpdateProfile attempts to update the root password used by a user to log
@@ -89,7 +110,7 @@ func (s *Session) UpdateProfile(ctx context.Context, newEmail, newName, newPassw
 	// Note: the auth and identity sections for a user are the same
 	user, ok := s.engine.session.Self().Auth.(envelope.UserInf)
 	if !ok {
-		log.Printf("Could not convert to UserInf during update profile")
+		logging.FromContext(ctx).Errorf("Could not convert to UserInf during update profile")
 		return nil, &apitypes.Error{
 			Type: apitypes.InternalServerError,
 			Err:  []string{"Could not convert to user interface"},
@@ -118,7 +139,7 @@ func (s *Session) UpdateProfile(ctx context.Context, newEmail, newName, newPassw
 	if newPassword != "" {
 		pw, master, keypair, err := s.engine.crypto.ChangePassword(ctx, newPassword)
 		if err != nil {
-			log.Printf("Could not re-encrypt master key: %s", err)
+			logging.FromContext(ctx).Errorf("Could not re-encrypt master key: %s", err)
 			return nil, &apitypes.Error{
 				Type: apitypes.InternalServerError,
 				Err:  []string{"Could not re-encrypt master key"},
@@ -132,7 +153,7 @@ func (s *Session) UpdateProfile(ctx context.Context, newEmail, newName, newPassw
 
 	updatedUser, err := s.engine.client.Users.Update(ctx, payload)
 	if err != nil {
-		log.Printf("Could not update password on server due to err: %s", err)
+		logging.FromContext(ctx).Errorf("Could not update password on server due to err: %s", err)
 		return nil, err
 	}
 
@@ -175,6 +196,57 @@ func (s *Session) attemptEdDSALogin(ctx context.Context, loginToken *envelope.To
 	return s.engine.client.Tokens.PostEdDSAAuth(ctx, tokenString, sig)
 }
 
+// attemptKDFUpgrade re-encrypts the user's master key with the current key
+// derivation function and costs, if it was encrypted with an older function
+// or lower costs.
+//
+// Only the master key changes. The password object and login keypair, and so
+// the login salt that recovery kits are checked against, are sent unchanged.
+func (s *Session) attemptKDFUpgrade(ctx context.Context) error {
+	user, ok := s.engine.session.Self().Auth.(*envelope.User)
+	if !ok || user.Body.PublicKey == nil {
+		return nil
+	}
+
+	if !crypto.NeedsKDFUpgrade(user.Body.Master) {
+		return nil
+	}
+
+	master, err := s.engine.crypto.UpgradeMasterKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	updatedUser, err := s.engine.client.Users.Update(ctx, &updateProfile{
+		Password:  user.Body.Password,
+		Master:    master,
+		PublicKey: user.Body.PublicKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The master key can only be opened with the parameters recorded in it,
+	// so if the registry didn't store them, put back the old master key.
+	if !reflect.DeepEqual(updatedUser.Master(), master) {
+		_, rErr := s.engine.client.Users.Update(ctx, &updateProfile{
+			Password:  user.Body.Password,
+			Master:    user.Body.Master,
+			PublicKey: user.Body.PublicKey,
+		})
+		if rErr != nil {
+			return fmt.Errorf("registry did not store the upgraded master key, "+
+				"and it could not be restored: %s", rErr)
+		}
+		return errors.New("registry did not store the upgraded master key")
+	}
+
+	if err := s.engine.cache(ctx, updatedUser); err != nil {
+		logging.FromContext(ctx).Errorf("Error storing user in local db: %s", err)
+	}
+	return s.engine.session.SetIdentity(apitypes.UserSession, updatedUser, updatedUser)
+}
+
 // Logout destroys the current session if it exists, otherwise, it returns an
 // error that the request could not be completed.
 func (s *Session) Logout(ctx context.Context) error {
@@ -202,7 +274,7 @@ func (s *Session) Logout(ctx context.Context) error {
 			//
 			// In any case, the daemon has gotten out of sync with the
 			// server. Remove our local copy of the auth token.
-			log.Printf("Got 4XX removing auth token. Treating as success")
+			logging.FromContext(ctx).Infof("Got 4XX removing auth token. Treating as success")
 			logoutErr := s.engine.session.Logout()
 			if logoutErr != nil {
 				return logoutErr
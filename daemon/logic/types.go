@@ -12,6 +12,14 @@ type PlaintextCredentialEnvelope struct {
 	Body    *PlaintextCredential `json:"body"`
 }
 
+// PlaintextCredentialHistory holds every version of the credentials at a
+// path expression that could be decrypted. Skipped is the number of versions
+// that could not be, such as versions shared with a revoked keypair.
+type PlaintextCredentialHistory struct {
+	Credentials []PlaintextCredentialEnvelope `json:"credentials"`
+	Skipped     int                           `json:"skipped"`
+}
+
 // PlaintextCredential is the body of an unencrypted Credential
 type PlaintextCredential struct {
 	Name      string           `json:"name"`
@@ -20,4 +28,6 @@ type PlaintextCredential struct {
 	ProjectID *identity.ID     `json:"project_id"`
 	Value     string           `json:"value"`
 	State     *string          `json:"state"`
+
+	CredentialVersion int `json:"credential_version,omitempty"`
 }
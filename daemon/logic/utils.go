@@ -3,7 +3,6 @@ package logic
 import (
 	"context"
 	"crypto/rand"
-	"log"
 	"time"
 
 	"golang.org/x/crypto/ed25519"
@@ -13,6 +12,7 @@ import (
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
 
@@ -197,20 +197,20 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 
 	keypairs, err := client.KeyPairs.List(ctx, orgID)
 	if err != nil {
-		log.Printf("could not fetch keypairs for org: %s", err)
+		logging.FromContext(ctx).Errorf("could not fetch keypairs for org: %s", err)
 		return nil, nil, err
 	}
 
 	// Get this user's keypairs
 	sigID, encID, kp, err := fetchKeyPairs(keypairs, orgID)
 	if err != nil {
-		log.Printf("could not fetch keypairs for org: %s", err)
+		logging.FromContext(ctx).Errorf("could not fetch keypairs for org: %s", err)
 		return nil, nil, err
 	}
 
 	claimTree, err := client.ClaimTree.Get(ctx, orgID, nil)
 	if err != nil {
-		log.Printf("could not retrieve claim tree for invite approval: %s", err)
+		logging.FromContext(ctx).Errorf("could not retrieve claim tree for invite approval: %s", err)
 		return nil, nil, err
 	}
 
@@ -232,7 +232,7 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 		projGraphs, err := client.CredentialGraph.Search(ctx,
 			"/"+org.Body.Name+"/"+project.Body.Name+"/*/*/*/*", s.AuthID())
 		if err != nil {
-			log.Printf("Error retrieving credential graphs: %s", err)
+			logging.FromContext(ctx).Errorf("Error retrieving credential graphs: %s", err)
 			return nil, nil, err
 		}
 
@@ -242,7 +242,7 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 	// Find encryption keys for user
 	targetKeySegment, err := claimTree.FindActive(ownerID, primitive.EncryptionKeyType)
 	if err != nil {
-		log.Printf("could not find encryption key for owner id: %s", ownerID.String())
+		logging.FromContext(ctx).Errorf("could not find encryption key for owner id: %s", ownerID.String())
 		return nil, nil, err
 	}
 	targetPubKey := targetKeySegment.PublicKey
@@ -263,7 +263,7 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 	for _, graph := range activeGraphs {
 		krm, mekshare, err := graph.FindMember(s.AuthID())
 		if err != nil {
-			log.Printf("could not find keyring membership: %s", err)
+			logging.FromContext(ctx).Errorf("could not find keyring membership: %s", err)
 			return nil, nil, &apitypes.Error{
 				Type: apitypes.NotFoundError,
 				Err:  []string{"Keyring membership not found."},
@@ -273,7 +273,7 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 		// Find the key that encrypted this user into the keyring
 		encPubKeySegment, err := claimTree.Find(krm.EncryptingKeyID, false)
 		if err != nil {
-			log.Printf("could not find encrypting public key for membership: %s", err)
+			logging.FromContext(ctx).Errorf("could not find encrypting public key for membership: %s", err)
 			return nil, nil, err
 		}
 		encPubKey := encPubKeySegment.PublicKey
@@ -281,7 +281,7 @@ func createKeyringMemberships(ctx context.Context, c *crypto.Engine, client *reg
 		encMek, nonce, err := c.CloneMembership(ctx, *mekshare.Key.Value,
 			*mekshare.Key.Nonce, &kp.Encryption, *encPubKey.Body.Key.Value, *targetPubKey.Body.Key.Value)
 		if err != nil {
-			log.Printf("could not clone keyring membership: %s", err)
+			logging.FromContext(ctx).Errorf("could not clone keyring membership: %s", err)
 			return nil, nil, err
 		}
 
@@ -3,7 +3,6 @@ package logic
 import (
 	"context"
 	"errors"
-	"log"
 	"sort"
 
 	"github.com/manifoldco/go-base64"
@@ -11,6 +10,7 @@ import (
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/pathexp"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
@@ -36,6 +36,8 @@ type Worklog struct {
 
 func newWorklog(e *Engine) Worklog {
 	membersType := apitypes.UserKeyringMembersWorklogType | apitypes.MachineKeyringMembersWorklogType
+	auditType := apitypes.SecretReusedWorklogType | apitypes.SecretWeakWorklogType |
+		apitypes.SecretStaleWorklogType
 	w := Worklog{
 		engine: e,
 		handlers: map[apitypes.WorklogType]worklogTypeHandler{
@@ -43,6 +45,7 @@ func newWorklog(e *Engine) Worklog {
 			apitypes.MissingKeypairsWorklogType: &missingKeypairsHandler{engine: e},
 			apitypes.InviteApproveWorklogType:   &inviteApproveHandler{engine: e},
 			membersType:                         &keyringMembersHandler{engine: e},
+			auditType:                           &secretAuditHandler{engine: e},
 		},
 	}
 
@@ -169,16 +172,23 @@ func (h *secretRotateHandler) list(ctx context.Context, org *envelope.Org) ([]ap
 			claimsByOwner[*r.OwnerID] = r
 		}
 
-		users, err := h.engine.client.Profiles.ListByID(ctx, ids)
-		if err != nil {
-			return nil, err
-		}
-
 		var reasons []apitypes.SecretRotateWorklogReason
-		for _, user := range users {
+		if len(ids) > 0 {
+			users, err := h.engine.client.Profiles.ListByID(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, user := range users {
+				reasons = append(reasons, apitypes.SecretRotateWorklogReason{
+					Username: user.Body.Username,
+					Type:     claimsByOwner[*user.ID].Reason.Type,
+				})
+			}
+		}
+		if reason.Rekeyed > 0 {
 			reasons = append(reasons, apitypes.SecretRotateWorklogReason{
-				Username: user.Body.Username,
-				Type:     claimsByOwner[*user.ID].Reason.Type,
+				Rekeyed: reason.Rekeyed,
 			})
 		}
 
@@ -195,6 +205,66 @@ func (h *secretRotateHandler) list(ctx context.Context, org *envelope.Org) ([]ap
 		items = append(items, item)
 	}
 
+	return h.addMarked(ctx, cgs, items)
+}
+
+// addMarked adds the reasons for every current credential that was marked
+// for rotation to items, creating new items as needed.
+//
+// Marks are kept for a credential version, so once a new value is set, the
+// mark no longer applies.
+func (h *secretRotateHandler) addMarked(ctx context.Context, cgs *credentialGraphSet,
+	items []apitypes.WorklogItem) ([]apitypes.WorklogItem, error) {
+
+	key, err := h.engine.dbKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prune only keeps the head credentials in each graph, so it must be
+	// called after NeedRotation.
+	graphs, err := cgs.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	bySubject := make(map[string]int, len(items))
+	for i, item := range items {
+		bySubject[item.Subject()] = i
+	}
+
+	for _, graph := range graphs {
+		for _, cred := range graph.GetCredentials() {
+			mark, err := h.engine.db.RotationMark(key, h.engine.session.AuthID(), cred.GetID())
+			if err != nil {
+				return nil, err
+			}
+			if mark == nil {
+				continue
+			}
+
+			reason := apitypes.SecretRotateWorklogReason{Mark: mark.Reason}
+			subject := cred.PathExp().String() + "/" + cred.Name()
+			if i, ok := bySubject[subject]; ok {
+				d := items[i].Details.(*apitypes.SecretRotateWorklogDetails)
+				d.Reasons = append(d.Reasons, reason)
+				continue
+			}
+
+			item := apitypes.WorklogItem{
+				Details: &apitypes.SecretRotateWorklogDetails{
+					PathExp: cred.PathExp(),
+					Name:    cred.Name(),
+					Reasons: []apitypes.SecretRotateWorklogReason{reason},
+				},
+			}
+			item.CreateID(apitypes.SecretRotateWorklogType)
+
+			bySubject[subject] = len(items)
+			items = append(items, item)
+		}
+	}
+
 	return items, nil
 }
 
@@ -203,6 +273,94 @@ func (h *secretRotateHandler) resolve(ctx context.Context, n *observer.Notifier,
 	return errManualResolve
 }
 
+type secretAuditHandler struct {
+	engine *Engine
+}
+
+func (secretAuditHandler) resolveErr() string {
+	// Like rotation, changing a weak value must be manual.
+	return "Error changing secret"
+}
+
+func (h *secretAuditHandler) list(ctx context.Context, org *envelope.Org) ([]apitypes.WorklogItem, error) {
+	projects, err := h.engine.client.Projects.List(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A project whose secrets can't be read is skipped, rather than hiding
+	// the rest of the worklog.
+	var items []apitypes.WorklogItem
+	for _, project := range projects {
+		results, err := h.engine.auditSecrets(ctx, nil,
+			"/"+org.Body.Name+"/"+project.Body.Name+"/*/*/*/*", DefaultSecretMaxAge, false)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			logging.FromContext(ctx).Warnf("Skipping audit of project %s in worklog: %s",
+				project.Body.Name, err)
+			continue
+		}
+
+		for _, r := range results {
+			items = append(items, secretAuditItems(r)...)
+		}
+	}
+
+	return items, nil
+}
+
+// secretAuditItems splits the issues in an audit result into one worklog item
+// for each worklog type.
+func secretAuditItems(r apitypes.SecretAuditResult) []apitypes.WorklogItem {
+	byType := make(map[apitypes.WorklogType][]apitypes.SecretAuditIssue)
+	for _, issue := range r.Issues {
+		var typ apitypes.WorklogType
+		switch issue {
+		case apitypes.ReusedSecretAuditIssue:
+			typ = apitypes.SecretReusedWorklogType
+		case apitypes.StaleSecretAuditIssue:
+			typ = apitypes.SecretStaleWorklogType
+		default:
+			typ = apitypes.SecretWeakWorklogType
+		}
+
+		byType[typ] = append(byType[typ], issue)
+	}
+
+	var items []apitypes.WorklogItem
+	for _, typ := range []apitypes.WorklogType{apitypes.SecretReusedWorklogType,
+		apitypes.SecretWeakWorklogType, apitypes.SecretStaleWorklogType} {
+
+		issues, ok := byType[typ]
+		if !ok {
+			continue
+		}
+
+		details := &apitypes.SecretAuditWorklogDetails{
+			PathExp:   r.PathExp,
+			Name:      r.Name,
+			Issues:    issues,
+			FirstSeen: r.FirstSeen,
+		}
+		if typ == apitypes.SecretReusedWorklogType {
+			details.ReusedBy = r.ReusedBy
+		}
+
+		item := apitypes.WorklogItem{Details: details}
+		item.CreateID(typ)
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (h *secretAuditHandler) resolve(ctx context.Context, n *observer.Notifier,
+	orgID *identity.ID, item *apitypes.WorklogItem) error {
+	return errManualResolve
+}
+
 type missingKeypairsHandler struct {
 	engine *Engine
 }
@@ -352,7 +510,7 @@ func (h *keyringMembersHandler) list(ctx context.Context, org *envelope.Org) ([]
 		// environment is deleted.
 		graphs, err := h.engine.client.CredentialGraph.List(ctx, "", pe, nil)
 		if err != nil {
-			log.Printf("Skipping inspection of graph due to error: %s", err)
+			logging.FromContext(ctx).Warnf("Skipping inspection of graph due to error: %s", err)
 			continue
 		}
 
@@ -502,7 +660,7 @@ func (h *keyringMembersHandler) resolve(ctx context.Context, n *observer.Notifie
 		// environment is deleted.
 		graphs, err := h.engine.client.CredentialGraph.List(ctx, "", &pe, nil)
 		if err != nil {
-			log.Printf("Skipping inspection of graph due to error: %s", err)
+			logging.FromContext(ctx).Warnf("Skipping inspection of graph due to error: %s", err)
 			continue
 		}
 
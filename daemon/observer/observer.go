@@ -8,9 +8,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/logging"
 )
 
 type ctxkey string
@@ -51,11 +55,20 @@ type Observer struct {
 	closed chan int
 
 	observers map[chan []byte]bool
+	count     int32 // the number of observers, for reading outside of Start
 
 	newObservers    chan chan []byte
 	closedObservers chan chan []byte
+
+	log *logging.Logger
+
+	historyMu sync.Mutex
+	history   []apitypes.ObservedEvent // the most recent events, oldest first
 }
 
+// historySize is the number of recent events kept for diagnosing problems.
+const historySize = 100
+
 type transaction struct {
 	requestID      string
 	total          uint
@@ -109,8 +122,13 @@ func (t *transaction) start() {
 	}()
 }
 
-// Notifier creates a child notifier to this Notifier
+// Notifier creates a child notifier to this Notifier. The child of a nil
+// Notifier is nil.
 func (n *Notifier) Notifier(total uint) *Notifier {
+	if n == nil {
+		return nil
+	}
+
 	notifier := &Notifier{
 		total:          total,
 		current:        0,
@@ -127,7 +145,14 @@ func (n *Notifier) Notifier(total uint) *Notifier {
 
 // Notify publishes an event to all SSE observers. This function panics when it
 // is called more often than it is supposed to have been called.
+//
+// Notifying a nil Notifier does nothing, for operations run outside of a
+// request.
 func (n *Notifier) Notify(eventType EventType, message string, increment bool) {
+	if n == nil {
+		return
+	}
+
 	notif := &notification{
 		Type:      eventType,
 		Message:   message,
@@ -170,6 +195,8 @@ func New() *Observer {
 
 		newObservers:    make(chan chan []byte),
 		closedObservers: make(chan chan []byte),
+
+		log: logging.Default().With("component", "observer"),
 	}
 }
 
@@ -254,14 +281,16 @@ func (o *Observer) Start() {
 	for {
 		select {
 		case evt := <-o.notify: // We have an event to observe
+			o.record(evt)
+
 			if len(o.observers) == 0 {
-				log.Printf("Ignoring event due to no observers: %s", evt.ID)
+				o.log.With("request_id", evt.ID).Debugf("Ignoring event due to no observers")
 				continue
 			}
 
 			evtb, err := json.Marshal(evt)
 			if err != nil {
-				log.Printf("Error marshaling event: %s", err)
+				o.log.With("request_id", evt.ID).Errorf("Error marshaling event: %s", err)
 				continue
 			}
 
@@ -274,8 +303,10 @@ func (o *Observer) Start() {
 
 		case n := <-o.newObservers:
 			o.observers[n] = true
+			atomic.StoreInt32(&o.count, int32(len(o.observers)))
 		case n := <-o.closedObservers:
 			delete(o.observers, n)
+			atomic.StoreInt32(&o.count, int32(len(o.observers)))
 
 		case <-o.closed: // The Observer has been closed.
 			return
@@ -283,6 +314,40 @@ func (o *Observer) Start() {
 	}
 }
 
+// record adds evt to the history of recent events.
+func (o *Observer) record(evt *event) {
+	o.historyMu.Lock()
+	defer o.historyMu.Unlock()
+
+	if len(o.history) == historySize {
+		o.history = o.history[1:]
+	}
+	o.history = append(o.history, apitypes.ObservedEvent{
+		Time:      time.Now().UTC(),
+		RequestID: evt.ID,
+		Type:      string(evt.Type),
+		Message:   evt.Message,
+		Completed: evt.Completed,
+		Total:     evt.Total,
+	})
+}
+
+// History returns the most recent events published, oldest first, whether or
+// not they were observed.
+func (o *Observer) History() []apitypes.ObservedEvent {
+	o.historyMu.Lock()
+	defer o.historyMu.Unlock()
+
+	history := make([]apitypes.ObservedEvent, len(o.history))
+	copy(history, o.history)
+	return history
+}
+
+// Observers returns the number of clients observing events.
+func (o *Observer) Observers() int {
+	return int(atomic.LoadInt32(&o.count))
+}
+
 // Stop terminates propagation of events through the observer
 func (o *Observer) Stop() {
 	close(o.closed)
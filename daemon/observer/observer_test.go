@@ -3,6 +3,7 @@ package observer
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net/http/httptest"
 	"testing"
 
@@ -241,3 +242,25 @@ func BenchmarkObserver_Notify(b *testing.B) {
 		}
 	})
 }
+
+func TestHistory(t *testing.T) {
+	o := New()
+
+	for i := 0; i < historySize+2; i++ {
+		o.record(&event{ID: "id", Type: Progress, Message: fmt.Sprintf("%d", i)})
+	}
+
+	history := o.History()
+	if len(history) != historySize {
+		t.Fatalf("expected %d events, got %d", historySize, len(history))
+	}
+	if history[0].Message != "2" || history[historySize-1].Message != fmt.Sprintf("%d", historySize+1) {
+		t.Errorf("expected the most recent events, oldest first; got %q to %q",
+			history[0].Message, history[historySize-1].Message)
+	}
+
+	history[0].Message = "changed"
+	if o.History()[0].Message == "changed" {
+		t.Error("History returned the observer's own slice")
+	}
+}
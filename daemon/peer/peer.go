@@ -0,0 +1,121 @@
+// Package peer identifies the processes connected to the daemon's socket.
+package peer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+type ctxKey struct{}
+
+// Peer is the process on the other end of a connection to the daemon.
+type Peer struct {
+	UID    int    `json:"uid"`
+	GID    int    `json:"gid"`
+	Groups []int  `json:"groups,omitempty"`
+	PID    int    `json:"pid"`
+	Exe    string `json:"exe,omitempty"`
+}
+
+// String returns a human readable description of the peer.
+func (p *Peer) String() string {
+	if p == nil {
+		return "unknown"
+	}
+
+	exe := p.Exe
+	if exe == "" {
+		exe = "-"
+	}
+	return fmt.Sprintf("uid=%d gid=%d pid=%d exe=%s", p.UID, p.GID, p.PID, exe)
+}
+
+// NewContext returns a copy of ctx holding the peer.
+func NewContext(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the peer held by ctx, or nil if it is not known.
+func FromContext(ctx context.Context) *Peer {
+	p, _ := ctx.Value(ctxKey{}).(*Peer)
+	return p
+}
+
+// Listener wraps a domain socket listener, looking up the credentials of the
+// peer process for each connection it accepts.
+//
+// net/http does not expose the connection a request arrived on, so each
+// connection is given a unique remote address, which is used to find its
+// peer from the request.
+type Listener struct {
+	net.Listener
+
+	mu    sync.Mutex
+	next  uint64
+	peers map[string]*Peer
+}
+
+// NewListener returns a Listener wrapping l.
+func NewListener(l net.Listener) *Listener {
+	return &Listener{Listener: l, peers: make(map[string]*Peer)}
+}
+
+// Accept waits for and returns the next connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := lookup(c)
+	if err != nil {
+		logging.Default().Errorf("Could not read peer credentials: %s", err)
+	}
+
+	l.mu.Lock()
+	l.next++
+	a := addr(fmt.Sprintf("peer-%d", l.next))
+	l.peers[string(a)] = p
+	l.mu.Unlock()
+
+	return &conn{Conn: c, addr: a, l: l}, nil
+}
+
+// FromRequest returns the peer for the connection a request arrived on, or
+// nil if it is not known.
+func (l *Listener) FromRequest(r *http.Request) *Peer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.peers[r.RemoteAddr]
+}
+
+func (l *Listener) remove(a addr) {
+	l.mu.Lock()
+	delete(l.peers, string(a))
+	l.mu.Unlock()
+}
+
+type addr string
+
+func (a addr) Network() string { return "unix" }
+func (a addr) String() string  { return string(a) }
+
+type conn struct {
+	net.Conn
+	addr addr
+	l    *Listener
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *conn) Close() error {
+	c.l.remove(c.addr)
+	return c.Conn.Close()
+}
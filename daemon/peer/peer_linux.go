@@ -0,0 +1,98 @@
+// +build linux
+
+package peer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/manifoldco/torus-cli/logging"
+)
+
+// Supported is true if the credentials of peers can be read on this platform.
+const Supported = true
+
+// procRoot is where process information is read from.
+var procRoot = "/proc"
+
+// lookup returns the credentials of the process connected to c, read with
+// SO_PEERCRED. They are those of the process at the time it connected.
+func lookup(c net.Conn) (*Peer, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("not a unix socket connection")
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	p := &Peer{
+		UID: int(cred.Uid),
+		GID: int(cred.Gid),
+		PID: int(cred.Pid),
+	}
+
+	// The executable and groups are read from /proc, which fails if the
+	// process has already exited, and the executable can't be read for
+	// processes owned by other users unless the daemon is privileged. They
+	// are left empty, keeping the credentials from SO_PEERCRED.
+	p.Exe, _ = os.Readlink(fmt.Sprintf("%s/%d/exe", procRoot, cred.Pid))
+
+	p.Groups, err = readGroups(p.PID)
+	if err != nil {
+		logging.Default().Debugf("Could not read groups of peer %s: %s", p, err)
+		p.Groups = nil
+	}
+
+	return p, nil
+}
+
+// readGroups returns the supplementary groups of the process with pid.
+// SO_PEERCRED only includes the primary group.
+func readGroups(pid int) ([]int, error) {
+	f, err := os.Open(fmt.Sprintf("%s/%d/status", procRoot, pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Groups:") {
+			continue
+		}
+
+		var groups []int
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "Groups:")) {
+			gid, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid group in process status: %s", field)
+			}
+			groups = append(groups, gid)
+		}
+		return groups, nil
+	}
+
+	return nil, scanner.Err()
+}
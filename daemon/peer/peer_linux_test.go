@@ -0,0 +1,95 @@
+// +build linux
+
+package peer
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// servePeer returns the peer found for a request made to a listener on a
+// socket in dir.
+func servePeer(t *testing.T, dir string) *Peer {
+	ul, err := net.Listen("unix", filepath.Join(dir, "test.socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(ul)
+	defer l.Close()
+
+	found := make(chan *Peer, 1)
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found <- l.FromRequest(r)
+	}))
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", filepath.Join(dir, "test.socket"))
+		},
+	}}
+	resp, err := client.Get("http://daemon/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return <-found
+}
+
+func TestListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := servePeer(t, dir)
+	if p == nil {
+		t.Fatal("Expected peer to be found")
+	}
+	if p.UID != os.Getuid() || p.PID != os.Getpid() {
+		t.Errorf("Expected peer to be this process, got %s", p)
+	}
+
+	groups, err := os.Getgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gid := range groups {
+		if !containsInt(p.Groups, gid) {
+			t.Errorf("Expected peer to be in group %d, got %v", gid, p.Groups)
+		}
+	}
+
+	self, _ := os.Executable()
+	if p.Exe != self {
+		t.Errorf("Expected peer exe %s, got %s", self, p.Exe)
+	}
+}
+
+func TestListenerWithoutProc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// As if the process had exited before it was looked up.
+	procRoot = filepath.Join(dir, "proc")
+	defer func() { procRoot = "/proc" }()
+
+	p := servePeer(t, dir)
+	if p == nil {
+		t.Fatal("Expected peer to be found")
+	}
+	if p.UID != os.Getuid() || p.GID != os.Getgid() || p.PID != os.Getpid() {
+		t.Errorf("Expected peer credentials of this process, got %s", p)
+	}
+	if p.Exe != "" || p.Groups != nil {
+		t.Errorf("Expected no exe or groups, got %q and %v", p.Exe, p.Groups)
+	}
+}
@@ -0,0 +1,182 @@
+package peer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Policy controls which peers may use the daemon, and the routes they may
+// use. Each peer is checked against the rules in order, and the first rule
+// the peer matches decides what it may do. Peers that match no rule are
+// denied.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule matches peers by user, group and executable. A peer matches if it
+// matches every list that is not empty.
+type Rule struct {
+	UIDs []int    `json:"uids,omitempty"`
+	GIDs []int    `json:"gids,omitempty"`
+	Exes []string `json:"exes,omitempty"`
+
+	// ReadOnly limits matching peers to GET requests on the daemon's own
+	// routes, without access to the registry proxy.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Routes limits matching peers to the listed routes, each in the form
+	// "METHOD /path" or "/path". A path also allows the routes beneath it.
+	// All routes are allowed if empty.
+	Routes []string `json:"routes,omitempty"`
+}
+
+// LoadPolicy reads the policy at path. It returns nil if there is no policy,
+// in which case every peer that can connect to the socket is allowed.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !Supported {
+		return nil, errors.New("peer policies are only supported on Linux")
+	}
+
+	p := &Policy{}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer policy %s: %s", path, err)
+	}
+
+	err = p.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer policy %s: %s", path, err)
+	}
+
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	if len(p.Rules) == 0 {
+		return errors.New("no rules defined")
+	}
+
+	for i, r := range p.Rules {
+		for _, route := range r.Routes {
+			_, path := splitRoute(route)
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("rule %d: route %q must be a path starting with /", i+1, route)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Allows returns whether the peer may make a request with the given method
+// to the given path. Unknown peers are never allowed.
+func (p *Policy) Allows(peer *Peer, method, path string) bool {
+	if peer == nil {
+		return false
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.matches(peer) {
+			return r.allows(method, path)
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) matches(p *Peer) bool {
+	if len(r.UIDs) > 0 && !containsInt(r.UIDs, p.UID) {
+		return false
+	}
+
+	if len(r.GIDs) > 0 {
+		found := containsInt(r.GIDs, p.GID)
+		for _, gid := range p.Groups {
+			found = found || containsInt(r.GIDs, gid)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Exes) > 0 {
+		// The executable is unknown if the daemon can't read it
+		if p.Exe == "" || !containsString(r.Exes, p.Exe) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Rule) allows(method, path string) bool {
+	if r.ReadOnly && (method != "GET" || !underPath(path, "/v1")) {
+		return false
+	}
+
+	if len(r.Routes) == 0 {
+		return true
+	}
+
+	for _, route := range r.Routes {
+		m, prefix := splitRoute(route)
+		if (m == "" || strings.EqualFold(m, method)) && underPath(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitRoute splits a route into its method, if any, and path.
+func splitRoute(route string) (string, string) {
+	parts := strings.Fields(route)
+	switch len(parts) {
+	case 1:
+		return "", parts[0]
+	case 2:
+		return parts[0], parts[1]
+	default:
+		return "", ""
+	}
+}
+
+// underPath returns whether path is prefix, or a path beneath it.
+func underPath(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+func containsInt(l []int, v int) bool {
+	for _, i := range l {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(l []string, v string) bool {
+	for _, s := range l {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package peer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	policy := &Policy{Rules: []Rule{
+		{UIDs: []int{1001}, ReadOnly: true},
+		{UIDs: []int{1002}, Routes: []string{"GET /v1/credentials", "/v1/observe"}},
+		{GIDs: []int{50}, Exes: []string{"/usr/bin/app"}, Routes: []string{"GET /v1/"}},
+		{GIDs: []int{60}},
+	}}
+
+	tcs := []struct {
+		name    string
+		peer    *Peer
+		method  string
+		path    string
+		allowed bool
+	}{
+		{"unknown peer", nil, "GET", "/v1/credentials", false},
+		{"no matching rule", &Peer{UID: 1}, "GET", "/v1/credentials", false},
+
+		{"read only get", &Peer{UID: 1001}, "GET", "/v1/credentials", true},
+		{"read only post", &Peer{UID: 1001}, "POST", "/v1/credentials", false},
+		{"read only proxy", &Peer{UID: 1001}, "GET", "/proxy/orgs", false},
+
+		{"route method", &Peer{UID: 1002}, "GET", "/v1/credentials", true},
+		{"route wrong method", &Peer{UID: 1002}, "POST", "/v1/credentials", false},
+		{"route sub path", &Peer{UID: 1002}, "POST", "/v1/credentials/compromise", false},
+		{"route any method", &Peer{UID: 1002}, "GET", "/v1/observe", true},
+		{"route prefix only", &Peer{UID: 1002}, "GET", "/v1/observed", false},
+		{"route not listed", &Peer{UID: 1002}, "GET", "/v1/keypairs", false},
+
+		{"exe and group", &Peer{UID: 1, GID: 50, Exe: "/usr/bin/app"}, "GET", "/v1/session", true},
+		{"exe and supplementary group", &Peer{UID: 1, Groups: []int{50}, Exe: "/usr/bin/app"}, "GET", "/v1/session", true},
+		{"wrong exe", &Peer{UID: 1, GID: 50, Exe: "/usr/bin/other"}, "GET", "/v1/session", false},
+		{"unknown exe", &Peer{UID: 1, GID: 50}, "GET", "/v1/session", false},
+
+		{"group full access", &Peer{UID: 1, Groups: []int{60}}, "POST", "/proxy/orgs", true},
+		{"first rule wins", &Peer{UID: 1001, GID: 60}, "POST", "/proxy/orgs", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed := policy.Allows(tc.peer, tc.method, tc.path)
+			if allowed != tc.allowed {
+				t.Errorf("Expected %t for %s %s, got %t", tc.allowed, tc.method, tc.path, allowed)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "peer_policy.json")
+
+	p, err := LoadPolicy(path)
+	if p != nil || err != nil {
+		t.Errorf("Expected no policy when file is missing, got %v, %v", p, err)
+	}
+
+	if !Supported {
+		return
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"rules": []}`,
+		`{"rules": [{"routes": ["GET credentials"]}]}`,
+		`{"rules": [{"routes": ["GET /v1 extra"]}]}`,
+	}
+	for _, c := range invalid {
+		if err := ioutil.WriteFile(path, []byte(c), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPolicy(path); err == nil {
+			t.Errorf("Expected policy %s to be invalid", c)
+		}
+	}
+
+	valid := `{"rules": [{"uids": [1001], "routes": ["GET /v1/credentials"]}]}`
+	if err := ioutil.WriteFile(path, []byte(valid), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err = LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Allows(&Peer{UID: 1001}, "GET", "/v1/credentials") {
+		t.Error("Expected loaded policy to allow credential reads")
+	}
+}
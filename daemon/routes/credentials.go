@@ -3,11 +3,17 @@ package routes
 // This file contains routes related to credentials/secrets
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
+	"time"
 
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/pathexp"
+
+	"github.com/manifoldco/torus-cli/daemon/audit"
 	"github.com/manifoldco/torus-cli/daemon/logic"
 	"github.com/manifoldco/torus-cli/daemon/observer"
 )
@@ -20,7 +26,7 @@ func credentialsGetRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 		q := r.URL.Query()
 		n, err := o.Notifier(ctx, 1)
 		if err != nil {
-			log.Printf("Error creating parent Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -29,16 +35,53 @@ func credentialsGetRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 		pathexp := q.Get("pathexp")
 		if path == "" && pathexp == "" {
 			err = errors.New("missing path or pathexp")
-			log.Printf("Error constructing request: %s", err)
+			logging.FromContext(ctx).Errorf("Error constructing request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		if path != "" {
+			audit.Annotate(ctx, path)
+		} else {
+			audit.Annotate(ctx, pathexp)
+		}
+
+		includeUnset := q.Get("include_unset") == "true"
+		includeHistory := q.Get("include_history") == "true"
+		if includeHistory && pathexp == "" {
+			err = errors.New("include_history requires a pathexp")
+			logging.FromContext(ctx).Errorf("Error constructing request: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
+		if includeHistory {
+			history, err := engine.RetrieveCredentialHistory(ctx, n, pathexp)
+			if err != nil {
+				// Rely on logs inside engine for debugging
+				encodeResponseErr(w, err)
+				return
+			}
+
+			for _, cred := range history.Credentials {
+				audit.Annotate(ctx, "", cred.Body.Name)
+			}
+			n.Notify(observer.Finished, "Completed Operation", true)
+
+			enc := json.NewEncoder(w)
+			err = enc.Encode(history)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("error encoding credentials: %s", err)
+				encodeResponseErr(w, err)
+			}
+			return
+		}
+
 		var creds []logic.PlaintextCredentialEnvelope
 		if path != "" {
-			creds, err = engine.RetrieveCredentials(ctx, n, &path, nil)
+			creds, err = engine.RetrieveCredentials(ctx, n, &path, nil, includeUnset)
 		} else {
-			creds, err = engine.RetrieveCredentials(ctx, n, nil, &pathexp)
+			creds, err = engine.RetrieveCredentials(ctx, n, nil, &pathexp, includeUnset)
 		}
 		if err != nil {
 			// Rely on logs inside engine for debugging
@@ -46,12 +89,161 @@ func credentialsGetRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 			return
 		}
 
+		for _, cred := range creds {
+			audit.Annotate(ctx, "", cred.Body.Name)
+		}
 		n.Notify(observer.Finished, "Completed Operation", true)
 
 		enc := json.NewEncoder(w)
 		err = enc.Encode(creds)
 		if err != nil {
-			log.Printf("error encoding credentials: %s", err)
+			logging.FromContext(ctx).Errorf("error encoding credentials: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func credentialsAuditRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+
+		pathexp := q.Get("pathexp")
+		if pathexp == "" {
+			err := errors.New("missing pathexp")
+			logging.FromContext(ctx).Errorf("Error constructing request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		audit.Annotate(ctx, pathexp)
+
+		maxAge := logic.DefaultSecretMaxAge
+		if raw := q.Get("max_age"); raw != "" {
+			var err error
+			maxAge, err = time.ParseDuration(raw)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("Error parsing max age: %s", err)
+				encodeResponseErr(w, err)
+				return
+			}
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		results, err := engine.AuditSecrets(ctx, n, pathexp, maxAge)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(results)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding audit results: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func credentialsFindRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req := apitypes.FindValueRequest{}
+		dec := json.NewDecoder(r.Body)
+		err := dec.Decode(&req)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error decoding find value request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		if req.Value == "" {
+			err = errors.New("missing value")
+			logging.FromContext(ctx).Errorf("Error constructing request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		result, err := engine.FindValue(ctx, n, req.Value, req.MarkForRotation)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(result)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding value locations: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func credentialsCompromiseRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req := apitypes.CompromiseRequest{}
+		dec := json.NewDecoder(r.Body)
+		err := dec.Decode(&req)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error decoding compromise request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		pe, err := pathexp.Parse(req.PathExp)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error parsing pathexp: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		audit.Annotate(ctx, pe.String())
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		result, err := engine.Compromise(ctx, n, pe)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(result)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding compromise result: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -66,14 +258,16 @@ func credentialsPostRoute(engine *logic.Engine, o *observer.Observer) http.Handl
 		dec := json.NewDecoder(r.Body)
 		err := dec.Decode(&creds)
 		if err != nil {
-			log.Printf("error decoding credential: %s", err)
+			logging.FromContext(ctx).Errorf("error decoding credential: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
+		annotateCredentials(ctx, creds)
+
 		n, err := o.Notifier(ctx, 1)
 		if err != nil {
-			log.Printf("error constructing Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("error constructing Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -90,9 +284,25 @@ func credentialsPostRoute(engine *logic.Engine, o *observer.Observer) http.Handl
 		enc := json.NewEncoder(w)
 		err = enc.Encode(creds)
 		if err != nil {
-			log.Printf("error encoding credential create resp: %s", err)
+			logging.FromContext(ctx).Errorf("error encoding credential create resp: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 	}
 }
+
+// annotateCredentials records the names and path expression of credentials
+// being set in the audit log entry for ctx. Their values are never recorded.
+func annotateCredentials(ctx context.Context, creds []*logic.PlaintextCredentialEnvelope) {
+	for _, cred := range creds {
+		if cred == nil || cred.Body == nil {
+			continue
+		}
+
+		pe := ""
+		if cred.Body.PathExp != nil {
+			pe = cred.Body.PathExp.String()
+		}
+		audit.Annotate(ctx, pe, cred.Body.Name)
+	}
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/logging"
+
+	"github.com/manifoldco/torus-cli/daemon/db"
+	"github.com/manifoldco/torus-cli/daemon/observer"
+	"github.com/manifoldco/torus-cli/daemon/updates"
+)
+
+// debugRoute returns the daemon's internal state, for inclusion in a support
+// bundle. It must never include secrets.
+func debugRoute(db *db.DB, o *observer.Observer, uEngine *updates.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		buckets, err := db.Stats()
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Error reading db stats: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		needsUpdate, version := uEngine.VersionInfo()
+		payload := &apitypes.DaemonDebug{
+			LastUpdateCheck: uEngine.LastCheck(),
+			Update: apitypes.UpdateInfo{
+				NeedsUpdate: needsUpdate,
+				Version:     version,
+			},
+			Events:  o.History(),
+			Buckets: buckets,
+		}
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(payload)
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("error encoding debug info: %s", err)
+			encodeResponseErr(w, err)
+		}
+	}
+}
@@ -4,11 +4,11 @@ package routes
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 
 	"github.com/manifoldco/torus-cli/daemon/logic"
 	"github.com/manifoldco/torus-cli/daemon/observer"
@@ -41,7 +41,7 @@ func keypairsGenerateRoute(engine *logic.Engine, o *observer.Observer) http.Hand
 
 		n, err := o.Notifier(ctx, 1)
 		if err != nil {
-			log.Printf("Error creating Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -81,7 +81,7 @@ func keypairsRevokeRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 
 		n, err := o.Notifier(ctx, 0)
 		if err != nil {
-			log.Printf("Error creating Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -95,3 +95,109 @@ func keypairsRevokeRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func keypairsRotateRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		dec := json.NewDecoder(r.Body)
+		rotReq := keyPairRequest{}
+		err := dec.Decode(&rotReq)
+		if err != nil {
+			encodeResponseErr(w, err)
+			return
+		}
+
+		if rotReq.OrgID == nil {
+			encodeResponseErr(w, &apitypes.Error{
+				Type: apitypes.BadRequestError,
+				Err:  []string{"missing or invalid OrgID provided"},
+			})
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		err = engine.RotateKeypairs(ctx, n, rotReq.OrgID)
+		if err != nil {
+			// Rely on engine for debug logging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Progress, "Old keypairs revoked", true)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func keypairsGraphRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		orgID, err := identity.DecodeFromString(r.URL.Query().Get("org_id"))
+		if err != nil {
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		graph, err := engine.ClaimTreeGraph(ctx, n, &orgID)
+		if err != nil {
+			// Rely on engine for debug logging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(graph)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding claim tree graph: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func keypairsVerifyRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		orgID, err := identity.DecodeFromString(r.URL.Query().Get("org_id"))
+		if err != nil {
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		result, err := engine.VerifyClaimTree(ctx, n, &orgID)
+		if err != nil {
+			// Rely on engine for debug logging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(result)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding claim tree verification: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
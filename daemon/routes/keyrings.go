@@ -0,0 +1,156 @@
+package routes
+
+// This file contains routes related to keyrings
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/pathexp"
+
+	"github.com/manifoldco/torus-cli/daemon/audit"
+	"github.com/manifoldco/torus-cli/daemon/logic"
+	"github.com/manifoldco/torus-cli/daemon/observer"
+)
+
+func keyringsListRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		cpathexp := r.URL.Query().Get("pathexp")
+		if cpathexp == "" {
+			err := errors.New("missing pathexp")
+			logging.FromContext(ctx).Errorf("Error constructing request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		audit.Annotate(ctx, cpathexp)
+
+		summaries, err := engine.ListKeyrings(ctx, n, cpathexp)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(summaries)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding keyrings: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func keyringsViewRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+
+		pe, err := pathexp.Parse(q.Get("pathexp"))
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error parsing pathexp: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		version := 0
+		if raw := q.Get("version"); raw != "" {
+			version, err = strconv.Atoi(raw)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("Error parsing keyring version: %s", err)
+				encodeResponseErr(w, err)
+				return
+			}
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		audit.Annotate(ctx, pe.String())
+
+		details, err := engine.ViewKeyring(ctx, n, pe, version)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(details)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding keyring: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
+
+func keyringsRekeyRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req := apitypes.RekeyRequest{}
+		dec := json.NewDecoder(r.Body)
+		err := dec.Decode(&req)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error decoding rekey request: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		pe, err := pathexp.Parse(req.PathExp)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error parsing pathexp: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n, err := o.Notifier(ctx, 1)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Error creating parent Notifier: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		audit.Annotate(ctx, pe.String())
+
+		summary, err := engine.RekeyKeyring(ctx, n, pe)
+		if err != nil {
+			// Rely on logs inside engine for debugging
+			encodeResponseErr(w, err)
+			return
+		}
+
+		n.Notify(observer.Finished, "Completed Operation", true)
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(summary)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("error encoding keyring: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+	}
+}
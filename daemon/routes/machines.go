@@ -5,13 +5,13 @@ package routes
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
 
@@ -29,14 +29,14 @@ func machinesCreateRoute(client *registry.Client, session session.Session,
 		req := apitypes.MachinesCreateRequest{}
 		err := dec.Decode(&req)
 		if err != nil {
-			log.Printf("Error decoding request: %s", err)
+			logging.FromContext(ctx).Errorf("Error decoding request: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		n, err := o.Notifier(ctx, 3)
 		if err != nil {
-			log.Printf("Error creating Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -46,14 +46,14 @@ func machinesCreateRoute(client *registry.Client, session session.Session,
 
 		machine, memberships, err := createMachine(req.OrgID, req.TeamID, session.ID(), req.Name)
 		if err != nil {
-			log.Printf("Error creating machine %s: %s", req.Name, err)
+			logging.FromContext(ctx).Errorf("Error creating machine %s: %s", req.Name, err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		token, err := engine.Machine.CreateToken(ctx, n, machine, req.Secret)
 		if err != nil {
-			log.Printf("Error creating machine token: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating machine token: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -62,14 +62,14 @@ func machinesCreateRoute(client *registry.Client, session session.Session,
 
 		segment, err := client.Machines.Create(ctx, machine, memberships, token)
 		if err != nil {
-			log.Printf("Error creating machine with registry: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating machine with registry: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		err = engine.Machine.EncodeToken(ctx, n, token.Token)
 		if err != nil {
-			log.Printf("Error encoding token into keyrings: %s", err)
+			logging.FromContext(ctx).Errorf("Error encoding token into keyrings: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -79,7 +79,7 @@ func machinesCreateRoute(client *registry.Client, session session.Session,
 		enc := json.NewEncoder(w)
 		err = enc.Encode(segment)
 		if err != nil {
-			log.Printf("Error encoding MachineSegment: %s", err)
+			logging.FromContext(ctx).Errorf("Error encoding MachineSegment: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -4,12 +4,12 @@ package routes
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/go-zoo/bone"
 
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 
 	"github.com/manifoldco/torus-cli/daemon/logic"
 	"github.com/manifoldco/torus-cli/daemon/observer"
@@ -21,14 +21,14 @@ func orgInvitesApproveRoute(engine *logic.Engine, o *observer.Observer) http.Han
 
 		n, err := o.Notifier(ctx, 1)
 		if err != nil {
-			log.Printf("Error creating Notififer: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating Notififer: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		inviteID, err := identity.DecodeFromString(bone.GetValue(r, "id"))
 		if err != nil {
-			log.Printf("Could not approve org invite; invalid id: %s", err)
+			logging.FromContext(ctx).Errorf("Could not approve org invite; invalid id: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -44,7 +44,7 @@ func orgInvitesApproveRoute(engine *logic.Engine, o *observer.Observer) http.Han
 		enc := json.NewEncoder(w)
 		err = enc.Encode(invite)
 		if err != nil {
-			log.Printf("error encoding invite approve resp: %s", err)
+			logging.FromContext(ctx).Errorf("error encoding invite approve resp: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
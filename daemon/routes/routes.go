@@ -18,28 +18,42 @@ import (
 )
 
 // NewRouteMux returns a *bone.Mux responsible for handling the cli to daemon
-// http api.
+// http api. The routes a read-only daemon serves are limited by its socket;
+// readOnly is reported in the session status, and limits logins to machines.
 func NewRouteMux(c *config.Config, s session.Session, db *db.DB,
-	t *http.Transport, o *observer.Observer, client *registry.Client, lEngine *logic.Engine, uEngine *updates.Engine) *bone.Mux {
+	t *http.Transport, o *observer.Observer, client *registry.Client, lEngine *logic.Engine, uEngine *updates.Engine,
+	readOnly bool) *bone.Mux {
 
 	mux := bone.New()
 
 	mux.Get("/observe", o)
 
 	mux.PostFunc("/signup", signupRoute(client, s, db))
-	mux.PostFunc("/login", loginRoute(lEngine))
+	mux.PostFunc("/login", loginRoute(lEngine, readOnly))
 	mux.PostFunc("/logout", logoutRoute(lEngine))
-	mux.GetFunc("/session", sessionRoute(s))
+	mux.GetFunc("/session", sessionRoute(s, readOnly))
 	mux.GetFunc("/self", selfRoute(s))
 	mux.PatchFunc("/self", updateSelfRoute(client, s, lEngine))
+	mux.PostFunc("/recovery/export", recoveryExportRoute(lEngine))
+	mux.PostFunc("/recovery/restore", recoveryRestoreRoute(lEngine))
 
 	mux.PostFunc("/machines", machinesCreateRoute(client, s, lEngine, o))
 
 	mux.PostFunc("/keypairs/generate", keypairsGenerateRoute(lEngine, o))
 	mux.PostFunc("/keypairs/revoke", keypairsRevokeRoute(lEngine, o))
+	mux.PostFunc("/keypairs/rotate", keypairsRotateRoute(lEngine, o))
+	mux.GetFunc("/keypairs/graph", keypairsGraphRoute(lEngine, o))
+	mux.GetFunc("/keypairs/verify", keypairsVerifyRoute(lEngine, o))
 
 	mux.GetFunc("/credentials", credentialsGetRoute(lEngine, o))
 	mux.PostFunc("/credentials", credentialsPostRoute(lEngine, o))
+	mux.GetFunc("/credentials/audit", credentialsAuditRoute(lEngine, o))
+	mux.PostFunc("/credentials/find", credentialsFindRoute(lEngine, o))
+	mux.PostFunc("/credentials/compromise", credentialsCompromiseRoute(lEngine, o))
+
+	mux.GetFunc("/keyrings", keyringsListRoute(lEngine, o))
+	mux.GetFunc("/keyrings/view", keyringsViewRoute(lEngine, o))
+	mux.PostFunc("/keyrings/rekey", keyringsRekeyRoute(lEngine, o))
 
 	mux.PostFunc("/org-invites/:id/approve",
 		orgInvitesApproveRoute(lEngine, o))
@@ -69,6 +83,8 @@ func NewRouteMux(c *config.Config, s session.Session, db *db.DB,
 		}
 	})
 
+	mux.GetFunc("/debug", debugRoute(db, o, uEngine))
+
 	return mux
 }
 
@@ -4,7 +4,6 @@ package routes
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/manifoldco/go-base64"
@@ -12,6 +11,7 @@ import (
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/primitive"
 	"github.com/manifoldco/torus-cli/registry"
 
@@ -21,7 +21,9 @@ import (
 	"github.com/manifoldco/torus-cli/daemon/session"
 )
 
-func loginRoute(engine *logic.Engine) http.HandlerFunc {
+// loginRoute logs in as a user or machine. A read-only daemon is meant for
+// machines, so if readOnly is true, users can't log in.
+func loginRoute(engine *logic.Engine, readOnly bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		dec := json.NewDecoder(r.Body)
@@ -47,6 +49,16 @@ func loginRoute(engine *logic.Engine) http.HandlerFunc {
 			return
 		}
 
+		if readOnly && req.Type != apitypes.MachineSession {
+			logging.FromContext(ctx).Warnf("Denied %s login in read-only mode", req.Type)
+			encodeResponseErr(w, &apitypes.Error{
+				StatusCode: http.StatusForbidden,
+				Type:       apitypes.ForbiddenError,
+				Err:        []string{"The daemon is running in read-only mode; only machines can log in"},
+			})
+			return
+		}
+
 		err = json.Unmarshal(req.Credentials, creds)
 		if err != nil {
 			encodeResponseErr(w, err)
@@ -55,7 +67,7 @@ func loginRoute(engine *logic.Engine) http.HandlerFunc {
 
 		err = engine.Session.Login(ctx, creds)
 		if err != nil {
-			log.Printf("Could not complete login: %s", err)
+			logging.FromContext(ctx).Errorf("Could not complete login: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -70,7 +82,7 @@ func logoutRoute(engine *logic.Engine) http.HandlerFunc {
 		ctx := r.Context()
 		err := engine.Session.Logout(ctx)
 		if err != nil {
-			log.Printf("Could not complete logout: %s", err)
+			logging.FromContext(ctx).Errorf("Could not complete logout: %s", err)
 			encodeResponseErr(w, err)
 		}
 
@@ -78,7 +90,7 @@ func logoutRoute(engine *logic.Engine) http.HandlerFunc {
 	}
 }
 
-func sessionRoute(s session.Session) http.HandlerFunc {
+func sessionRoute(s session.Session, readOnly bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		enc := json.NewEncoder(w)
 		if !(s.HasToken() && s.HasPassphrase()) {
@@ -96,6 +108,7 @@ func sessionRoute(s session.Session) http.HandlerFunc {
 		err := enc.Encode(&apitypes.SessionStatus{
 			Token:      s.HasToken(),
 			Passphrase: s.HasPassphrase(),
+			ReadOnly:   readOnly,
 		})
 
 		if err != nil {
@@ -179,14 +192,14 @@ func signupRoute(client *registry.Client, s session.Session, db *db.DB) http.Han
 
 		passwordObj, masterObj, err := crypto.EncryptPasswordObject(ctx, signup.Passphrase, nil)
 		if err != nil {
-			log.Printf("Error generating password object: %s", err)
+			logging.FromContext(ctx).Errorf("Error generating password object: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		b64Salt, err := base64.NewFromString(passwordObj.Salt)
 		if err != nil {
-			log.Printf("Error casting Salt into Base64: %s", err)
+			logging.FromContext(ctx).Errorf("Error casting Salt into Base64: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -194,7 +207,7 @@ func signupRoute(client *registry.Client, s session.Session, db *db.DB) http.Han
 		bPassphrase := []byte(signup.Passphrase)
 		keypair, err := crypto.DeriveLoginKeypair(ctx, bPassphrase, b64Salt)
 		if err != nil {
-			log.Printf("Error deriving login keypair: %s", err)
+			logging.FromContext(ctx).Errorf("Error deriving login keypair: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -242,3 +255,53 @@ func signupRoute(client *registry.Client, s session.Session, db *db.DB) http.Han
 		}
 	}
 }
+
+func recoveryExportRoute(engine *logic.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		kit, err := engine.Session.ExportRecoveryKit(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not export recovery kit: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		err = enc.Encode(kit)
+		if err != nil {
+			encodeResponseErr(w, err)
+		}
+	}
+}
+
+func recoveryRestoreRoute(engine *logic.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		dec := json.NewDecoder(r.Body)
+
+		req := apitypes.RecoveryRestore{}
+		err := dec.Decode(&req)
+		if err != nil {
+			encodeResponseErr(w, err)
+			return
+		}
+
+		if req.Kit == "" || req.Code == "" || req.Passphrase == "" {
+			encodeResponseErr(w, &apitypes.Error{
+				Type: apitypes.BadRequestError,
+				Err:  []string{"A recovery kit, recovery code and new password are required"},
+			})
+			return
+		}
+
+		err = engine.Session.RestoreRecoveryKit(ctx, []byte(req.Kit), req.Code, req.Passphrase)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Could not restore recovery kit: %s", err)
+			encodeResponseErr(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoginRouteReadOnly(t *testing.T) {
+	// The engine is never reached: user logins are rejected, and the machine
+	// login's credentials are malformed.
+	h := loginRoute(nil, true)
+
+	tcs := []struct {
+		body   string
+		status int
+	}{
+		{`{"type":"user","credentials":{"email":"jo@example.com","passphrase":"pass"}}`, http.StatusForbidden},
+		{`{"type":"machine","credentials":"malformed"}`, http.StatusInternalServerError},
+	}
+
+	for _, tc := range tcs {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/login", strings.NewReader(tc.body)))
+		if w.Code != tc.status {
+			t.Errorf("Expected %d for %s, got %d", tc.status, tc.body, w.Code)
+		}
+	}
+
+	// Without read-only mode, user logins reach the credentials.
+	w := httptest.NewRecorder()
+	body := `{"type":"user","credentials":"malformed"}`
+	loginRoute(nil, false).ServeHTTP(w, httptest.NewRequest("POST", "/login", strings.NewReader(body)))
+	if w.Code == http.StatusForbidden {
+		t.Error("Expected user logins to be allowed without read-only mode")
+	}
+}
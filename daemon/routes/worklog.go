@@ -2,13 +2,13 @@ package routes
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/go-zoo/bone"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/logging"
 
 	"github.com/manifoldco/torus-cli/daemon/logic"
 	"github.com/manifoldco/torus-cli/daemon/observer"
@@ -23,9 +23,14 @@ func worklogListRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFu
 			return
 		}
 
-		items, err := engine.Worklog.List(ctx, &orgID, apitypes.AnyWorklogType)
+		itemType := apitypes.AnyWorklogType &^ apitypes.SecretAuditWorklogType
+		if r.URL.Query().Get("audit") == "true" {
+			itemType = apitypes.AnyWorklogType
+		}
+
+		items, err := engine.Worklog.List(ctx, &orgID, itemType)
 		if err != nil {
-			log.Printf("error getting worklog list: %s", err)
+			logging.FromContext(ctx).Errorf("error getting worklog list: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -33,7 +38,7 @@ func worklogListRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFu
 		enc := json.NewEncoder(w)
 		err = enc.Encode(items)
 		if err != nil {
-			log.Printf("error encoding worklog list resp: %s", err)
+			logging.FromContext(ctx).Errorf("error encoding worklog list resp: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -57,7 +62,7 @@ func worklogGetRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFun
 
 		item, err := engine.Worklog.Get(ctx, &orgID, &ident)
 		if err != nil {
-			log.Printf("error getting worklog item: %s", err)
+			logging.FromContext(ctx).Errorf("error getting worklog item: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -70,7 +75,7 @@ func worklogGetRoute(engine *logic.Engine, o *observer.Observer) http.HandlerFun
 		enc := json.NewEncoder(w)
 		err = enc.Encode(item)
 		if err != nil {
-			log.Printf("error encoding worklog get resp: %s", err)
+			logging.FromContext(ctx).Errorf("error encoding worklog get resp: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
@@ -94,14 +99,14 @@ func worklogResolveRoute(engine *logic.Engine, o *observer.Observer) http.Handle
 
 		n, err := o.Notifier(ctx, 1)
 		if err != nil {
-			log.Printf("Error creating Notifier: %s", err)
+			logging.FromContext(ctx).Errorf("Error creating Notifier: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
 
 		err = engine.Worklog.Resolve(ctx, n, &orgID, &ident)
 		if err != nil {
-			log.Printf("error resolving worklog item: %s", err)
+			logging.FromContext(ctx).Errorf("error resolving worklog item: %s", err)
 			encodeResponseErr(w, err)
 			return
 		}
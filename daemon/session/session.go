@@ -6,11 +6,10 @@ import (
 	"fmt"
 	"sync"
 
-	"github.com/manifoldco/go-base64"
-
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/envelope"
 	"github.com/manifoldco/torus-cli/identity"
+	"github.com/manifoldco/torus-cli/primitive"
 )
 
 const notLoggedInError = "Please login to perform that command"
@@ -26,6 +25,7 @@ type session struct {
 	// sensitive values
 	token      string
 	passphrase []byte
+	dbKey      *[32]byte
 }
 
 // Session is the interface for access to secure session details.
@@ -37,7 +37,9 @@ type Session interface {
 	AuthID() *identity.ID
 	Token() string
 	Passphrase() []byte
-	MasterKey() (*base64.Value, error)
+	MasterKey() (*primitive.MasterKey, error)
+	DBKey() *[32]byte
+	SetDBKey(*[32]byte)
 	HasToken() bool
 	HasPassphrase() bool
 	Logout() error
@@ -170,6 +172,7 @@ func (s *session) Set(sessionType apitypes.SessionType, identity, auth envelope.
 	s.token = token
 	s.identity = identity
 	s.auth = auth
+	s.dbKey = nil
 
 	return nil
 }
@@ -197,8 +200,8 @@ func createNotLoggedInError() error {
 	}
 }
 
-// Returns the base64 representation of the identities encrypted master key
-func (s *session) MasterKey() (*base64.Value, error) {
+// Returns the identities encrypted master key object
+func (s *session) MasterKey() (*primitive.MasterKey, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -207,10 +210,28 @@ func (s *session) MasterKey() (*base64.Value, error) {
 	}
 
 	if s.Type() == apitypes.UserSession {
-		return s.auth.(envelope.UserInf).Master().Value, nil
+		return s.auth.(envelope.UserInf).Master(), nil
 	}
 
-	return s.auth.(*envelope.MachineToken).Body.Master.Value, nil
+	return s.auth.(*envelope.MachineToken).Body.Master, nil
+}
+
+// DBKey returns the key used to seal values in the db, or nil if it has not
+// been derived for this session.
+func (s *session) DBKey() *[32]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.dbKey
+}
+
+// SetDBKey stores the key used to seal values in the db until the session is
+// logged out.
+func (s *session) SetDBKey(key *[32]byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dbKey = key
 }
 
 // Self returns the Self apitype which represents the current sessions state
@@ -236,5 +257,6 @@ func (s *session) Logout() error {
 	s.auth = nil
 	s.token = ""
 	s.passphrase = []byte{}
+	s.dbKey = nil
 	return nil
 }
@@ -3,26 +3,24 @@ package socket
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/facebookgo/httpdown"
-	"github.com/go-zoo/bone"
 	"github.com/satori/go.uuid"
 
 	"github.com/manifoldco/torus-cli/apitypes"
 	"github.com/manifoldco/torus-cli/config"
-	"github.com/manifoldco/torus-cli/registry"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/metrics"
 
+	"github.com/manifoldco/torus-cli/daemon/audit"
 	"github.com/manifoldco/torus-cli/daemon/db"
-	"github.com/manifoldco/torus-cli/daemon/logic"
 	"github.com/manifoldco/torus-cli/daemon/observer"
-	"github.com/manifoldco/torus-cli/daemon/routes"
-	"github.com/manifoldco/torus-cli/daemon/session"
+	"github.com/manifoldco/torus-cli/daemon/peer"
 	"github.com/manifoldco/torus-cli/daemon/updates"
 )
 
@@ -30,78 +28,109 @@ import (
 // It handles adding auth headers to requests on the `/proxy` endpoint to
 // directly proxy requests from the cli to the registry, and exposes an
 // interface over `/v1` for secure and composite operations.
+//
+// Each user connecting to the socket has their own session.
 type AuthProxy struct {
 	u       *url.URL
-	l       net.Listener
+	l       *peer.Listener
 	s       httpdown.Server
 	c       *config.Config
 	db      *db.DB
-	sess    session.Session
 	o       *observer.Observer
 	t       *http.Transport
-	client  *registry.Client
-	logic   *logic.Engine
+	users   *users
 	updates *updates.Engine
+	audit   *audit.Log
+	policy  *peer.Policy
+	metrics *metrics.Registry
+
+	readOnly bool
 }
 
+var requests = metrics.NewCounterVec("torus_daemon_requests_total",
+	"Requests served by the daemon, by route, method and status code.",
+	"route", "method", "code")
+
+var requestDurations = metrics.NewHistogramVec("torus_daemon_request_duration_seconds",
+	"Latency of requests served by the daemon, by route and method.",
+	metrics.DefBuckets, "route", "method")
+
 // NewAuthProxy returns a new AuthProxy. It will return an error if creation
 // of the domain socket fails, or the upstream registry URL is misconfigured.
 //
+// newUser is called to create the session for each user the first time they
+// connect.
+//
 // If groupShared is true, the domain socket will be readable and writable by
 // both the user and the user's group (so daemon can be accessed by multiple
 // users). If false, the socket will only be readable and writable by the user
-// running the daemon.
-func NewAuthProxy(c *config.Config, sess session.Session, db *db.DB, t *http.Transport,
-	client *registry.Client, logic *logic.Engine, updates *updates.Engine, groupShared bool) (*AuthProxy, error) {
+// running the daemon. Where peers are identified, requests on a shared socket
+// from processes that can't be are rejected, as they can't be given the right
+// user's session.
+//
+// Operations on secrets and keypairs are recorded in the audit log, along
+// with the process that requested them. If policy is not nil, requests from
+// processes run by other users are only served if the policy allows them.
+//
+// If readOnly is true, only the routes in readOnlyRoutes are served.
+//
+// The proxy adds its own metrics to reg, and serves them at `/metrics`.
+func NewAuthProxy(c *config.Config, db *db.DB, t *http.Transport, newUser NewUserFunc,
+	updates *updates.Engine, auditLog *audit.Log, policy *peer.Policy, reg *metrics.Registry,
+	groupShared, readOnly bool) (*AuthProxy, error) {
 
 	l, err := makeSocket(c.TransportAddress, groupShared)
 	if err != nil {
 		return nil, err
 	}
 
-	return &AuthProxy{
+	p := &AuthProxy{
 		u:       c.RegistryURI,
-		l:       l,
+		l:       peer.NewListener(l),
 		c:       c,
 		db:      db,
-		sess:    sess,
 		o:       observer.New(),
 		t:       t,
-		client:  client,
-		logic:   logic,
+		users:   newUsers(newUser, groupShared),
 		updates: updates,
-	}, nil
+		audit:   auditLog,
+		policy:  policy,
+		metrics: reg,
+
+		readOnly: readOnly,
+	}
+
+	reg.Register(
+		requests,
+		requestDurations,
+		metrics.NewGaugeFunc("torus_daemon_observers",
+			"Clients subscribed to progress events.",
+			func() float64 { return float64(p.o.Observers()) }),
+		metrics.NewGaugeVecFunc("torus_daemon_sessions",
+			"Sessions of users of the daemon, by session type.",
+			"type", p.users.types),
+	)
+
+	return p, nil
 }
 
 // Listen starts the main loop of the AuthProxy. It returns on error, or when
 // the AuthProxy is closed.
 func (p *AuthProxy) Listen() error {
-	mux := bone.New()
-	proxy := &httputil.ReverseProxy{
-		Transport: p.t,
-		Director: func(r *http.Request) {
-			r.URL.Scheme = p.u.Scheme
-			r.URL.Host = p.u.Host
-			r.Host = p.u.Host
-			r.URL.Path = r.URL.Path[6:]
-
-			tok := p.sess.Token()
-			if tok != "" {
-				r.Header["Authorization"] = []string{"Bearer " + tok}
-			}
+	go p.o.Start()
 
-			r.Header["User-Agent"] = []string{"Torus-Daemon/" + p.c.Version}
-			r.Header["X-Registry-Version"] = []string{p.c.APIVersion}
-		},
+	handler := metricsRoute(p.metrics, p.userHandler())
+	if p.readOnly {
+		handler = readOnlyHandler(handler)
 	}
 
-	go p.o.Start()
-
-	mux.HandleFunc("/proxy/", proxyCanceler(proxy))
-	mux.SubRoute("/v1", routes.NewRouteMux(p.c, p.sess, p.db, p.t, p.o, p.client, p.logic, p.updates))
+	handler = requestIDHandler(peerHandler(p.l, loggingHandler(
+		auditHandler(p.audit, authorizeHandler(p.policy, handler)))))
 
 	h := httpdown.HTTP{}
-	p.s = h.Serve(&http.Server{Handler: requestIDHandler(loggingHandler(mux))}, p.l)
+	p.s = h.Serve(&http.Server{
+		Handler: metrics.InstrumentHandler(requests, requestDurations, routePattern, handler),
+	}, p.l)
 
 	return p.s.Wait()
 }
@@ -118,14 +147,173 @@ func (p *AuthProxy) Addr() string {
 	return p.l.Addr().String()
 }
 
+// metricsRoute serves the metrics at `/metrics`, passing other requests to
+// next.
+func metricsRoute(m http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/metrics" {
+			m.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loggingHandler provides the request's logger to later handlers, with the
+// request's id, route, and peer, and logs the request once it is served.
 func loggingHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		p := r.URL.Path
+
+		keyvals := []interface{}{
+			"request_id", ctx.Value(observer.CtxRequestID),
+			"method", r.Method,
+			"route", routePattern(r),
+		}
+		if pr := peer.FromContext(ctx); pr != nil {
+			keyvals = append(keyvals, "uid", pr.UID)
+		}
+		log := logging.FromContext(ctx).With(keyvals...)
+
+		start := time.Now()
+		r = r.WithContext(logging.NewContext(ctx, log))
+		next.ServeHTTP(w, r)
+
+		log.With("duration", time.Since(start).String()).Infof("%s %s", r.Method, p)
+	})
+}
+
+func peerHandler(l *peer.Listener, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := peer.NewContext(r.Context(), l.FromRequest(r))
+
+		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s", r.Method, p)
 	})
 }
 
+// authorizeHandler rejects requests from peers that the policy does not
+// allow. The user running the daemon is always allowed.
+func authorizeHandler(policy *peer.Policy, next http.Handler) http.Handler {
+	owner := os.Getuid()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := peer.FromContext(r.Context())
+		if policy == nil || (p != nil && p.UID == owner) || policy.Allows(p, r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logging.FromContext(r.Context()).Warnf("Denied %s %s for peer %s", r.Method, r.URL.Path, p)
+		writeForbidden(w, r, "The daemon's peer policy does not allow this request")
+	})
+}
+
+// readOnlyRoutes are the only routes served by a read-only daemon, allowing
+// a machine to log in and read its secrets.
+var readOnlyRoutes = map[string][]string{
+	"/v1/observe":     {"GET"},
+	"/v1/login":       {"POST"},
+	"/v1/logout":      {"POST"},
+	"/v1/session":     {"GET"},
+	"/v1/self":        {"GET"},
+	"/v1/credentials": {"GET"},
+	"/v1/version":     {"GET"},
+	"/v1/updates":     {"GET"},
+	"/v1/debug":       {"GET"},
+	"/metrics":        {"GET"},
+}
+
+// readOnlyHandler rejects requests to routes not in readOnlyRoutes,
+// including the registry proxy.
+func readOnlyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range readOnlyRoutes[r.URL.Path] {
+			if method == r.Method {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		logging.FromContext(r.Context()).Warnf("Denied %s %s in read-only mode", r.Method, r.URL.Path)
+		writeForbidden(w, r, "The daemon is running in read-only mode")
+	})
+}
+
+func writeForbidden(w http.ResponseWriter, r *http.Request, msg string) {
+	w.WriteHeader(http.StatusForbidden)
+	enc := json.NewEncoder(w)
+	err := enc.Encode(&apitypes.Error{
+		Type: apitypes.ForbiddenError,
+		Err:  []string{msg},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Error writing forbidden response: %s", err)
+	}
+}
+
+// auditedRoutes are the route prefixes recorded in the audit log: every
+// route that reads or changes secrets, keys or keyrings.
+var auditedRoutes = []string{
+	"/v1/credentials", "/v1/keypairs", "/v1/keyrings", "/v1/recovery", "/v1/worklog",
+}
+
+func isAudited(p string) bool {
+	for _, prefix := range auditedRoutes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditHandler records each audited request in the audit log once it has
+// been handled. Routes add the secrets involved via audit.Annotate.
+func auditHandler(l *audit.Log, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if l == nil || !isAudited(p) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now().UTC()
+		ctx, record := audit.NewContext(r.Context())
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		r = r.WithContext(ctx)
+		next.ServeHTTP(sw, r)
+
+		e := &audit.Entry{
+			Time:    start,
+			Peer:    peer.FromContext(ctx),
+			Method:  r.Method,
+			Route:   strings.TrimPrefix(p, "/v1"),
+			Status:  sw.status,
+			Outcome: audit.Success,
+		}
+		if sw.status >= http.StatusBadRequest {
+			e.Outcome = audit.Failure
+		}
+		record.Fill(e)
+
+		if err := l.Append(e); err != nil {
+			logging.FromContext(ctx).Errorf("Error writing audit log entry: %s", err)
+		}
+	})
+}
+
+// statusResponseWriter records the status code written to a ResponseWriter.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusResponseWriter) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
 func requestIDHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := r.Header.Get("X-Request-Id")
@@ -177,7 +365,7 @@ func proxyCanceler(proxy http.Handler) http.HandlerFunc {
 					Err:  []string{"Request timed out"},
 				})
 				if err != nil {
-					log.Printf("Error writing response timeout: %s", err)
+					logging.FromContext(ctx).Errorf("Error writing response timeout: %s", err)
 				}
 			}
 		}
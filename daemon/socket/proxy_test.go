@@ -0,0 +1,132 @@
+package socket
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/metrics"
+
+	"github.com/manifoldco/torus-cli/daemon/audit"
+)
+
+func TestReadOnlyHandler(t *testing.T) {
+	h := readOnlyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tcs := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/v1/credentials", http.StatusNoContent},
+		{"POST", "/v1/login", http.StatusNoContent},
+		{"GET", "/v1/session", http.StatusNoContent},
+		{"POST", "/v1/credentials", http.StatusForbidden},
+		{"GET", "/v1/credentials/audit", http.StatusForbidden},
+		{"POST", "/v1/keypairs/generate", http.StatusForbidden},
+		{"POST", "/v1/machines", http.StatusForbidden},
+		{"POST", "/v1/org-invites/123/approve", http.StatusForbidden},
+		{"GET", "/proxy/orgs", http.StatusForbidden},
+	}
+
+	for _, tc := range tcs {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("Expected %d for %s %s, got %d", tc.status, tc.method, tc.path, w.Code)
+		}
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	tcs := []struct {
+		method  string
+		path    string
+		pattern string
+	}{
+		{"GET", "/v1/credentials", "/v1/credentials"},
+		{"POST", "/v1/worklog/0e4pa3qej5q0e1em9dc6rntbdmf6c", "/v1/worklog/:id"},
+		{"GET", "/proxy/orgs/04000000000000000000000000000", "/proxy/"},
+		{"GET", "/metrics", "/metrics"},
+		{"GET", "/v1/aaaa", metrics.Other},
+		{"GET", "/aaaa", metrics.Other},
+		{"FOO", "/v1/credentials", metrics.Other},
+	}
+
+	for _, tc := range tcs {
+		if p := routePattern(httptest.NewRequest(tc.method, tc.path, nil)); p != tc.pattern {
+			t.Errorf("Expected %s for %s %s, got %s", tc.pattern, tc.method, tc.path, p)
+		}
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	l, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := auditHandler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.Annotate(r.Context(), "/org/project/dev/default/*/1")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Every route that reads or changes secrets, keys or keyrings.
+	audited := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/v1/credentials"},
+		{"POST", "/v1/credentials"},
+		{"GET", "/v1/credentials/audit"},
+		{"POST", "/v1/credentials/find"},
+		{"POST", "/v1/credentials/compromise"},
+		{"POST", "/v1/keypairs/generate"},
+		{"POST", "/v1/keypairs/revoke"},
+		{"POST", "/v1/keypairs/rotate"},
+		{"GET", "/v1/keypairs/verify"},
+		{"GET", "/v1/keyrings"},
+		{"GET", "/v1/keyrings/view"},
+		{"POST", "/v1/keyrings/rekey"},
+		{"POST", "/v1/recovery/export"},
+		{"POST", "/v1/recovery/restore"},
+		{"POST", "/v1/worklog/0e4pa3qej5q0e1em9dc6rntbdmf6c"},
+	}
+	for _, tc := range audited {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+	}
+
+	// Routes that don't touch secrets are not recorded.
+	for _, p := range []string{"/v1/session", "/v1/version", "/v1/observe"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+
+	entries, _, err := audit.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(audited) {
+		t.Fatalf("Expected %d entries, got %d", len(audited), len(entries))
+	}
+	for i, tc := range audited {
+		e := entries[i]
+		route := strings.TrimPrefix(tc.path, "/v1")
+		if e.Method != tc.method || e.Route != route || e.PathExp == "" {
+			t.Errorf("Expected an annotated entry for %s %s, got %+v", tc.method, route, e)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package socket
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sync"
+
+	"github.com/go-zoo/bone"
+
+	"github.com/manifoldco/torus-cli/apitypes"
+	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/logging"
+	"github.com/manifoldco/torus-cli/metrics"
+	"github.com/manifoldco/torus-cli/registry"
+
+	"github.com/manifoldco/torus-cli/daemon/logic"
+	"github.com/manifoldco/torus-cli/daemon/peer"
+	"github.com/manifoldco/torus-cli/daemon/routes"
+	"github.com/manifoldco/torus-cli/daemon/session"
+	"github.com/manifoldco/torus-cli/daemon/utils"
+)
+
+// User holds the session of a single user of the daemon, along with the
+// registry client and logic engine acting on their behalf. Users of a shared
+// daemon each log in and out separately.
+type User struct {
+	Session session.Session
+	Client  *registry.Client
+	Logic   *logic.Engine
+
+	handler http.Handler
+}
+
+// NewUserFunc creates a User with a new, logged out, session. owner is set for
+// the user running the daemon.
+type NewUserFunc func(owner bool) *User
+
+// users holds a User for each user id that has connected to the daemon.
+type users struct {
+	mu      sync.Mutex
+	owner   int
+	shared  bool
+	newUser NewUserFunc
+	byUID   map[int]*User
+}
+
+func newUsers(newUser NewUserFunc, groupShared bool) *users {
+	return &users{
+		owner:   os.Getuid(),
+		shared:  groupShared && peer.Supported,
+		newUser: newUser,
+		byUID:   make(map[int]*User),
+	}
+}
+
+// get returns the User for the peer, creating it if needed.
+//
+// Peers that can't be identified use the session of the user running the
+// daemon, unless the socket is shared with the group on a platform where
+// peers are identified. Then, nil is returned, as the peer may be another
+// user whose process couldn't be looked up.
+func (u *users) get(p *peer.Peer, build func(*User) http.Handler) *User {
+	if p == nil {
+		if u.shared {
+			return nil
+		}
+		return u.forUID(u.owner, build)
+	}
+
+	return u.forUID(p.UID, build)
+}
+
+// forUID returns the User for uid, creating it if needed.
+func (u *users) forUID(uid int, build func(*User) http.Handler) *User {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.byUID[uid]
+	if !ok {
+		user = u.newUser(uid == u.owner)
+		user.handler = build(user)
+		u.byUID[uid] = user
+	}
+
+	return user
+}
+
+// types returns the number of sessions of each type, including those not
+// logged in.
+func (u *users) types() map[string]float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	types := map[string]float64{
+		string(apitypes.UserSession):    0,
+		string(apitypes.MachineSession): 0,
+		string(apitypes.NotLoggedIn):    0,
+	}
+	for _, user := range u.byUID {
+		types[string(user.Session.Type())]++
+	}
+	return types
+}
+
+// handler returns the handler for requests made on behalf of user, which
+// proxies to the registry with the user's token, and serves the daemon's
+// routes with the user's session.
+func (p *AuthProxy) handler(user *User) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Transport: utils.InstrumentTransport(p.t),
+		Director: func(r *http.Request) {
+			r.URL.Scheme = p.u.Scheme
+			r.URL.Host = p.u.Host
+			r.Host = p.u.Host
+			r.URL.Path = r.URL.Path[6:]
+
+			tok := user.Session.Token()
+			if tok != "" {
+				r.Header["Authorization"] = []string{"Bearer " + tok}
+			}
+
+			r.Header["User-Agent"] = []string{"Torus-Daemon/" + p.c.Version}
+			r.Header["X-Registry-Version"] = []string{p.c.APIVersion}
+		},
+	}
+
+	return routeMux(proxyCanceler(proxy), routes.NewRouteMux(p.c, user.Session, p.db, p.t, p.o,
+		user.Client, user.Logic, p.updates, p.readOnly))
+}
+
+// routeMux returns a mux serving the registry proxy at `/proxy/` and the
+// daemon's own routes at `/v1`.
+func routeMux(proxy http.Handler, v1 *bone.Mux) *bone.Mux {
+	mux := bone.New()
+	mux.Handle("/proxy/", proxy)
+	mux.SubRoute("/v1", v1)
+	return mux
+}
+
+// routePattern labels requests with the pattern of the daemon route they
+// match, for metrics and logs. Requests proxied to the registry are all
+// labeled `/proxy/`, and requests for unknown routes metrics.Other.
+var routePattern = metrics.MuxRoute(routePatterns())
+
+// routePatterns returns the daemon's routes, for matching requests to their
+// patterns. Its handlers are never called.
+func routePatterns() *bone.Mux {
+	mux := routeMux(nil, routes.NewRouteMux(&config.Config{}, nil, nil, nil, nil, nil, nil, nil, false))
+	mux.Get("/metrics", nil)
+	return mux
+}
+
+// userHandler serves each request with the handler of the peer's User.
+func (p *AuthProxy) userHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := p.users.get(peer.FromContext(r.Context()), p.handler)
+		if user == nil {
+			logging.FromContext(r.Context()).Warnf("Denied %s %s for unidentified peer on shared socket",
+				r.Method, r.URL.Path)
+			writeForbidden(w, r, "The daemon could not identify the process making this request")
+			return
+		}
+
+		user.handler.ServeHTTP(w, r)
+	})
+}
+
+// Owner returns the User for the user running the daemon.
+func (p *AuthProxy) Owner() *User {
+	return p.users.forUID(p.users.owner, p.handler)
+}
@@ -0,0 +1,74 @@
+package socket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/daemon/peer"
+	"github.com/manifoldco/torus-cli/daemon/session"
+)
+
+func TestUsers(t *testing.T) {
+	created := 0
+	owners := 0
+	u := newUsers(func(owner bool) *User {
+		created++
+		if owner {
+			owners++
+		}
+		return &User{Session: session.NewSession()}
+	}, false)
+
+	built := 0
+	build := func(*User) http.Handler {
+		built++
+		return http.NotFoundHandler()
+	}
+
+	alice := u.get(&peer.Peer{UID: 1001}, build)
+	bob := u.get(&peer.Peer{UID: 1002}, build)
+	if alice == bob || alice.Session == bob.Session {
+		t.Error("Expected each user to have their own session")
+	}
+
+	if u.get(&peer.Peer{UID: 1001, PID: 2}, build) != alice {
+		t.Error("Expected processes of the same user to share a session")
+	}
+
+	owner := u.get(&peer.Peer{UID: os.Getuid()}, build)
+	if u.get(nil, build) != owner {
+		t.Error("Expected unknown peers of a private socket to use the owner's session")
+	}
+
+	if created != 3 || built != 3 {
+		t.Errorf("Expected 3 users to be created, got %d and %d handlers", created, built)
+	}
+	if owners != 1 {
+		t.Errorf("Expected only the owner's user to be created as the owner, got %d", owners)
+	}
+}
+
+func TestUsersShared(t *testing.T) {
+	if !peer.Supported {
+		t.Skip("peers can't be identified on this platform")
+	}
+
+	u := newUsers(func(bool) *User {
+		return &User{Session: session.NewSession()}
+	}, true)
+	build := func(*User) http.Handler { return http.NotFoundHandler() }
+
+	u.get(&peer.Peer{UID: os.Getuid()}, build)
+	if user := u.get(nil, build); user != nil {
+		t.Error("Expected unknown peers of a shared socket to be refused a session")
+	}
+
+	p := &AuthProxy{users: u}
+	w := httptest.NewRecorder()
+	p.userHandler().ServeHTTP(w, httptest.NewRequest("GET", "/v1/session", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected unknown peers of a shared socket to be forbidden, got %d", w.Code)
+	}
+}
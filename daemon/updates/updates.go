@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/blang/semver"
 	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/logging"
 )
 
 const (
@@ -45,6 +45,7 @@ type Engine struct {
 	targetVersion string
 	timeManager   TimeManager
 	client        *http.Client
+	log           *logging.Logger
 }
 
 // VersionInfo maps the JSON returned from the `url` endpoint, containing the latest
@@ -63,6 +64,7 @@ func NewEngine(cfg *config.Config, t *http.Transport, options ...func(*Engine))
 		stop:          make(chan struct{}),
 		targetVersion: "unknown",
 		client:        &http.Client{Transport: t},
+		log:           logging.Default().With("component", "updates"),
 	}
 
 	for _, opt := range options {
@@ -94,6 +96,12 @@ func (e *Engine) VersionInfo() (bool, string) {
 	return e.needsUpdate(), e.targetVersion
 }
 
+// LastCheck returns the time of the last successful update check, or the
+// zero time if there hasn't been one.
+func (e *Engine) LastCheck() time.Time {
+	return e.lastCheck
+}
+
 // SetTimeManager is a configuration function for `NewEngine` which sets the
 // Engine's TimeManager instance to the `manager` argument.
 func (e *Engine) SetTimeManager(manager TimeManager) func(*Engine) {
@@ -104,15 +112,15 @@ func (e *Engine) SetTimeManager(manager TimeManager) func(*Engine) {
 
 func (e *Engine) start() {
 	if err := e.getLastCheck(); err != nil {
-		log.Printf("cannot get last update: %s", err)
+		e.log.Errorf("cannot get last update: %s", err)
 	}
 
-	log.Printf("last update check: %s", e.lastCheck)
+	e.log.Infof("last update check: %s", e.lastCheck)
 	e.performCheck()
 	for {
 		select {
 		case <-e.stop:
-			log.Printf("stopped checking for updates")
+			e.log.Infof("stopped checking for updates")
 			return
 		case <-time.After(e.nextCheck()):
 			e.performCheck()
@@ -122,20 +130,20 @@ func (e *Engine) start() {
 
 // performCheck retrieves the latest version of Torus from the manifest and then
 func (e *Engine) performCheck() {
-	log.Printf("Checking for updates to Torus")
+	e.log.Infof("Checking for updates to Torus")
 
 	latest, err := e.getLatestVersion()
 	if err != nil {
-		log.Printf("Could not retrieve latest version of Tours: %s", err)
+		e.log.Errorf("Could not retrieve latest version of Tours: %s", err)
 		return
 	}
 
 	e.targetVersion = latest
 	if err := e.storeLastCheck(); err != nil {
-		log.Printf("Cannot store the last check date: %s", err)
+		e.log.Errorf("Cannot store the last check date: %s", err)
 	}
 
-	log.Printf("Successfully checked for updates; available version: %s", latest)
+	e.log.Infof("Successfully checked for updates; available version: %s", latest)
 }
 
 // nextCheck returns the time duration to wait before triggering an update check.
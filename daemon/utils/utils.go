@@ -4,6 +4,9 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"net/http"
+	"strings"
+
+	"github.com/manifoldco/torus-cli/metrics"
 )
 
 // CreateHTTPTransport creates and configures the
@@ -13,3 +16,43 @@ func CreateHTTPTransport(caBundle *x509.CertPool, hostname string) *http.Transpo
 		RootCAs:    caBundle,
 	}}
 }
+
+// RegistryRequests counts requests made to the registry, by endpoint, method
+// and status code.
+var RegistryRequests = metrics.NewCounterVec("torus_daemon_registry_requests_total",
+	"Requests made to the registry, by endpoint, method and status code.",
+	"endpoint", "method", "code")
+
+// RegistryRequestDurations records the latency of requests made to the
+// registry, by endpoint and method.
+var RegistryRequestDurations = metrics.NewHistogramVec("torus_daemon_registry_request_duration_seconds",
+	"Latency of requests made to the registry, by endpoint and method.",
+	metrics.DefBuckets, "endpoint", "method")
+
+// registryResources are the top level resources of the registry, which
+// label the requests made to it.
+var registryResources = map[string]bool{
+	"claims": true, "claimtree": true, "credentialgraph": true, "credentials": true,
+	"envs": true, "keypairs": true, "keyring-members": true, "keyrings": true,
+	"machines": true, "memberships": true, "org-invites": true, "orgs": true,
+	"orgtree": true, "policies": true, "policy-attachments": true, "profiles": true,
+	"projects": true, "projecttree": true, "self": true, "services": true,
+	"teams": true, "tokens": true, "users": true, "version": true,
+}
+
+// registryEndpoint labels a registry request with the top level resource it
+// is for, such as "/orgs". Requests proxied for the cli can be for any path,
+// so other paths are labeled metrics.Other.
+func registryEndpoint(r *http.Request) string {
+	resource := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+	if registryResources[resource] {
+		return "/" + resource
+	}
+	return metrics.Other
+}
+
+// InstrumentTransport records the requests made to the registry through t in
+// RegistryRequests and RegistryRequestDurations.
+func InstrumentTransport(t http.RoundTripper) http.RoundTripper {
+	return metrics.InstrumentRoundTripper(RegistryRequests, RegistryRequestDurations, registryEndpoint, t)
+}
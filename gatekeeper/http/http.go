@@ -13,8 +13,17 @@ import (
 	"github.com/manifoldco/torus-cli/api"
 	"github.com/manifoldco/torus-cli/config"
 	"github.com/manifoldco/torus-cli/gatekeeper/routes"
+	"github.com/manifoldco/torus-cli/metrics"
 )
 
+var requests = metrics.NewCounterVec("torus_gatekeeper_requests_total",
+	"Requests served by the gatekeeper, by route, method and status code.",
+	"route", "method", "code")
+
+var requestDurations = metrics.NewHistogramVec("torus_gatekeeper_request_duration_seconds",
+	"Latency of requests served by the gatekeeper, by route and method.",
+	metrics.DefBuckets, "route", "method")
+
 type gatekeeperDefaults struct {
 	Org  string
 	Team string
@@ -67,11 +76,7 @@ func NewGatekeeper(org, team, certpath, keypath string, cfg *config.Config, api
 
 // Listen listens on a TCP port for HTTP machine requests
 func (g *Gatekeeper) Listen() error {
-	mux := bone.New()
-
-	mux.Post("/v0/machine/aws", routes.AWSBootstrapRoute(g.defaults.Org, g.defaults.Team, g.api))
-
-	g.s.Handler = loggingHandler(mux)
+	g.s.Handler = g.handler()
 	h := httpdown.HTTP{}
 
 	var err error
@@ -83,6 +88,21 @@ func (g *Gatekeeper) Listen() error {
 	return g.hd.Wait()
 }
 
+// handler returns the gatekeeper's routes, instrumented with its metrics.
+// Requests are labeled with the pattern of the route they match, so requests
+// for unknown routes share a single label.
+func (g *Gatekeeper) handler() http.Handler {
+	mux := bone.New()
+
+	reg := metrics.NewRegistry()
+	reg.Register(requests, requestDurations)
+
+	mux.Post("/v0/machine/aws", routes.AWSBootstrapRoute(g.defaults.Org, g.defaults.Team, g.api))
+	mux.Get("/metrics", reg)
+
+	return metrics.InstrumentHandler(requests, requestDurations, metrics.MuxRoute(mux), loggingHandler(mux))
+}
+
 // Close gracefully stops the HTTP server
 func (g *Gatekeeper) Close() error {
 	return g.hd.Stop()
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manifoldco/torus-cli/metrics"
+)
+
+func requestSeries(t *testing.T) int {
+	reg := metrics.NewRegistry()
+	reg.Register(requests)
+
+	buf := &bytes.Buffer{}
+	if err := reg.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(buf.String(), "torus_gatekeeper_requests_total{")
+}
+
+func TestUnknownRoutesShareSeries(t *testing.T) {
+	h := (&Gatekeeper{}).handler()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO", "/missing", nil))
+	before := requestSeries(t)
+	notFound := requests.Value(metrics.Other, "GET", "404")
+
+	for _, path := range []string{"/aaaa", "/bbbb", "/v0/machine/0e4pa3qej5q0e1em9dc6rntbdmf6c"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to not be found, got %d", path, w.Code)
+		}
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/cccc", nil))
+	}
+
+	if n := requestSeries(t); n != before {
+		t.Errorf("Expected no new series for unknown routes, got %d new", n-before)
+	}
+	if v := requests.Value(metrics.Other, "GET", "404"); v != notFound+3 {
+		t.Errorf("Expected 3 more not found requests, got %v", v-notFound)
+	}
+}
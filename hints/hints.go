@@ -20,6 +20,9 @@ const (
 	// Deny command adds hint to `torus deny`
 	Deny
 
+	// Doctor adds hint to `torus doctor`
+	Doctor
+
 	// GettingStarted displays helpful hints when a user signs up
 	GettingStarted
 
@@ -79,6 +82,9 @@ func init() {
 		Deny: {
 			"Restrict access to secrets for a team or role using `torus deny`",
 		},
+		Doctor: {
+			"Check your daemon, session and environment for problems using `torus doctor`",
+		},
 		InvitesApprove: {
 			"Approve multiple invites with `torus worklog resolve`",
 		},
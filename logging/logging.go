@@ -0,0 +1,412 @@
+// Package logging provides a leveled, structured logger for the daemon.
+//
+// Entries are written as logfmt or JSON, one per line. Fields with sensitive
+// names, and bearer tokens in messages, are redacted before they are written.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+// The levels of log entries, from least to most severe.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level: %s", s)
+}
+
+// Format is the encoding of log entries.
+type Format string
+
+// The supported formats.
+const (
+	LogfmtFormat Format = "logfmt"
+	JSONFormat   Format = "json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case LogfmtFormat, JSONFormat:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+// Redacted replaces the values of sensitive fields.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of field names whose values are redacted.
+var sensitiveKeys = []string{
+	"password", "passphrase", "secret", "token", "authorization", "cookie",
+	"plaintext", "value",
+}
+
+var bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// output is shared by a Logger and the loggers derived from it.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format Format
+	now    func() time.Time
+}
+
+// Logger writes leveled log entries, each with the logger's fields.
+type Logger struct {
+	out    *output
+	fields []field
+}
+
+// New returns a Logger writing entries at or above level to w.
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w, level: level, format: format, now: time.Now}}
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultLogger = New(os.Stderr, InfoLevel, LogfmtFormat)
+)
+
+// Default returns the logger used where no other logger is available.
+func Default() *Logger {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the default logger.
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx holding the logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger held by ctx, such as one with the fields of
+// the request being handled, or the default logger.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return Default()
+}
+
+// With returns a logger that adds the key value pairs to each entry.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(keyvals)/2)
+	copy(fields, l.fields)
+
+	return &Logger{out: l.out, fields: appendFields(fields, keyvals)}
+}
+
+// Enabled returns whether entries at level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+// Debugf writes a debug entry with a message formatted like fmt.Sprintf.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(DebugLevel, format, args)
+}
+
+// Infof writes an info entry with a message formatted like fmt.Sprintf.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(InfoLevel, format, args)
+}
+
+// Warnf writes a warning entry with a message formatted like fmt.Sprintf.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(WarnLevel, format, args)
+}
+
+// Errorf writes an error entry with a message formatted like fmt.Sprintf.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(ErrorLevel, format, args)
+}
+
+// Log writes an entry with the message and additional key value pairs.
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(level, msg, appendFields(nil, keyvals))
+}
+
+func (l *Logger) logf(level Level, format string, args []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(level, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) write(level Level, msg string, extra []field) {
+	o := l.out
+
+	fields := make([]field, 0, 3+len(l.fields)+len(extra))
+	fields = append(fields,
+		field{"time", o.now().UTC().Format(time.RFC3339Nano)},
+		field{"level", level.String()},
+		field{"msg", redactMessage(msg)},
+	)
+	for _, f := range append(l.fields, extra...) {
+		fields = append(fields, field{f.key, redactField(f.key, f.value)})
+	}
+
+	var line []byte
+	if o.format == JSONFormat {
+		line = encodeJSON(fields)
+	} else {
+		line = encodeLogfmt(fields)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w.Write(line)
+}
+
+func appendFields(fields []field, keyvals []interface{}) []field {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "(missing)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fields = append(fields, field{key, value})
+	}
+	return fields
+}
+
+func redactMessage(msg string) string {
+	return bearerPattern.ReplaceAllString(msg, "${1}"+Redacted)
+}
+
+// Sensitive returns whether the values of fields named key are redacted.
+func Sensitive(key string) bool {
+	k := strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactField(key string, value interface{}) interface{} {
+	if Sensitive(key) {
+		return Redacted
+	}
+
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	if s, ok := value.(string); ok {
+		return redactMessage(s)
+	}
+	return value
+}
+
+func encodeJSON(fields []field) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(f.value))
+		}
+		buf.Write(v)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func encodeLogfmt(fields []field) []byte {
+	buf := &bytes.Buffer{}
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(f.key)
+		buf.WriteByte('=')
+
+		var s string
+		switch v := f.value.(type) {
+		case string:
+			s = v
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			s = fmt.Sprint(v)
+		}
+
+		if s == "" || strings.ContainsAny(s, " =\"\\\t\n") {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+var logfmtField = regexp.MustCompile(`([^\s=]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+// RedactLine redacts sensitive fields and bearer tokens from a log line in
+// either format, such as one written by an older daemon.
+func RedactLine(line string) string {
+	if strings.HasPrefix(line, "{") {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &fields); err == nil {
+			RedactMap(fields)
+			b, err := json.Marshal(fields)
+			if err == nil {
+				return string(b)
+			}
+		}
+	}
+
+	line = logfmtField.ReplaceAllStringFunc(line, func(f string) string {
+		key := f[:strings.Index(f, "=")]
+		if Sensitive(key) {
+			return key + "=" + Redacted
+		}
+		return f
+	})
+	return redactMessage(line)
+}
+
+// RedactMap redacts the values of sensitive fields in m, and in any maps it
+// holds, in place.
+func RedactMap(m map[string]interface{}) {
+	for k, v := range m {
+		if Sensitive(k) {
+			m[k] = Redacted
+			continue
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			RedactMap(val)
+		case []interface{}:
+			for _, e := range val {
+				if em, ok := e.(map[string]interface{}); ok {
+					RedactMap(em)
+				}
+			}
+		default:
+			m[k] = redactField(k, v)
+		}
+	}
+}
+
+// Entry is a log entry read back from a log file.
+type Entry struct {
+	Level  Level
+	Line   string
+	Parsed bool
+}
+
+var logfmtLevel = regexp.MustCompile(`(?:^|\s)level=("?)(\w+)`)
+
+// ParseLine reads the level of a log line in either format. Lines that can't
+// be parsed, such as those written before structured logging, are reported as
+// info entries.
+func ParseLine(line string) Entry {
+	e := Entry{Level: InfoLevel, Line: line}
+
+	if strings.HasPrefix(line, "{") {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &fields); err == nil {
+			if s, ok := fields["level"].(string); ok {
+				if level, err := ParseLevel(s); err == nil {
+					e.Level = level
+					e.Parsed = true
+				}
+			}
+		}
+		return e
+	}
+
+	if m := logfmtLevel.FindStringSubmatch(line); m != nil {
+		if level, err := ParseLevel(m[2]); err == nil {
+			e.Level = level
+			e.Parsed = true
+		}
+	}
+	return e
+}
+
+// stdWriter adapts a Logger for use by the standard library's log package.
+type stdWriter struct {
+	l     *Logger
+	level Level
+}
+
+// StdWriter returns a writer that writes each line it receives as an entry
+// at level, for use with log.SetOutput. log.SetFlags(0) should also be used,
+// as entries have their own timestamp.
+func (l *Logger) StdWriter(level Level) io.Writer {
+	return &stdWriter{l: l, level: level}
+}
+
+func (w *stdWriter) Write(b []byte) (int, error) {
+	if w.l.Enabled(w.level) {
+		w.l.write(w.level, strings.TrimRight(string(b), "\n"), nil)
+	}
+	return len(b), nil
+}
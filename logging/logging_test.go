@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(level Level, format Format) (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	l := New(buf, level, format)
+	l.out.now = func() time.Time { return time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return l, buf
+}
+
+func TestLevels(t *testing.T) {
+	l, buf := newTestLogger(WarnLevel, LogfmtFormat)
+
+	l.Debugf("debug")
+	l.Infof("info")
+	l.Warnf("warn")
+	l.Errorf("error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %q", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "level=warn") || !strings.Contains(lines[1], "level=error") {
+		t.Errorf("unexpected entries: %q", lines)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"debug", "INFO", "Warn", "error"} {
+		level, err := ParseLevel(name)
+		if err != nil {
+			t.Errorf("ParseLevel(%q) failed: %s", name, err)
+		}
+		if level.String() != strings.ToLower(name) {
+			t.Errorf("ParseLevel(%q) = %s", name, level)
+		}
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	l, buf := newTestLogger(DebugLevel, LogfmtFormat)
+
+	l.With("request_id", "abc", "route", "/v1/session").Infof("Handled %d", 200)
+
+	expected := `time=2017-01-02T03:04:05Z level=info msg="Handled 200" ` +
+		"request_id=abc route=/v1/session\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	l, buf := newTestLogger(DebugLevel, JSONFormat)
+
+	l.With("request_id", "abc").Log(ErrorLevel, "failed", "err", errors.New("boom"), "status", 500)
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("entry is not JSON: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"time":       "2017-01-02T03:04:05Z",
+		"level":      "error",
+		"msg":        "failed",
+		"request_id": "abc",
+		"err":        "boom",
+		"status":     float64(500),
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("field %s: expected %v, got %v", k, v, fields[k])
+		}
+	}
+}
+
+func TestWithDoesNotShareFields(t *testing.T) {
+	l, buf := newTestLogger(DebugLevel, LogfmtFormat)
+
+	base := l.With("a", 1)
+	base.With("b", 2)
+	base.Infof("x")
+
+	if strings.Contains(buf.String(), "b=2") {
+		t.Errorf("derived logger fields leaked: %q", buf.String())
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	l, buf := newTestLogger(DebugLevel, LogfmtFormat)
+
+	l.With("auth_token", "tok-123", "Passphrase", "hunter2", "value", "s3cret").
+		Infof("Authorization: Bearer abc.def.ghi")
+	l.Log(InfoLevel, "login", "header", "bearer xyz", "user", "jeff")
+
+	out := buf.String()
+	for _, secret := range []string{"tok-123", "hunter2", "s3cret", "abc.def.ghi", "xyz"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q was logged: %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, "user=jeff") {
+		t.Errorf("expected non-sensitive field to be logged: %q", out)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Error("expected default logger for empty context")
+	}
+
+	l, _ := newTestLogger(InfoLevel, LogfmtFormat)
+	ctx := NewContext(context.Background(), l)
+	if FromContext(ctx) != l {
+		t.Error("expected logger from context")
+	}
+}
+
+func TestStdWriter(t *testing.T) {
+	l, buf := newTestLogger(InfoLevel, LogfmtFormat)
+
+	std := log.New(l.StdWriter(WarnLevel), "", 0)
+	std.Printf("old style %s", "message")
+
+	expected := `time=2017-01-02T03:04:05Z level=warn msg="old style message"` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tcs := []struct {
+		line   string
+		level  Level
+		parsed bool
+	}{
+		{`time=2017-01-02T03:04:05Z level=error msg=x`, ErrorLevel, true},
+		{`{"time":"2017","level":"debug","msg":"x"}`, DebugLevel, true},
+		{`2017/01/02 03:04:05 Error starting daemon`, InfoLevel, false},
+		{`{not json`, InfoLevel, false},
+		{`time=x msg="level=error"`, InfoLevel, false},
+	}
+
+	for _, tc := range tcs {
+		e := ParseLine(tc.line)
+		if e.Level != tc.level || e.Parsed != tc.parsed {
+			t.Errorf("ParseLine(%q) = %s/%t, expected %s/%t",
+				tc.line, e.Level, e.Parsed, tc.level, tc.parsed)
+		}
+	}
+}
+
+func TestRedactLine(t *testing.T) {
+	tcs := []struct {
+		line     string
+		expected string
+	}{
+		{
+			`level=info msg=login password=hunter2 user=jeff`,
+			`level=info msg=login password=[REDACTED] user=jeff`,
+		},
+		{
+			`level=info msg="has space" token="a b c"`,
+			`level=info msg="has space" token=[REDACTED]`,
+		},
+		{
+			`2017/01/02 Authorization: Bearer abc.def`,
+			`2017/01/02 Authorization: Bearer [REDACTED]`,
+		},
+		{
+			`{"level":"info","msg":"x","secret":{"id":"1"}}`,
+			`{"level":"info","msg":"x","secret":"[REDACTED]"}`,
+		},
+		{
+			`{"level":"info","body":[{"value":"s3cret"}]}`,
+			`{"body":[{"value":"[REDACTED]"}],"level":"info"}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		if got := RedactLine(tc.line); got != tc.expected {
+			t.Errorf("RedactLine(%q) = %q, expected %q", tc.line, got, tc.expected)
+		}
+	}
+}
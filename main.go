@@ -8,6 +8,7 @@ import (
 
 	"github.com/manifoldco/torus-cli/cmd"
 	"github.com/manifoldco/torus-cli/config"
+	"github.com/manifoldco/torus-cli/logging"
 	"github.com/manifoldco/torus-cli/prefs"
 	"github.com/manifoldco/torus-cli/ui"
 )
@@ -20,6 +21,7 @@ func main() {
 	// For command line usage, we hide any log messages; our regular error
 	// flow will catch them. Logging is only used with the daemon.
 	log.SetOutput(devnull{})
+	logging.SetDefault(logging.New(devnull{}, logging.ErrorLevel, logging.LogfmtFormat))
 
 	preferences, _ := prefs.NewPreferences()
 	ui.Init(preferences)
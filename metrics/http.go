@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-zoo/bone"
+)
+
+// Other labels requests for unknown routes, or with non-standard methods, so
+// that clients can't create a new series for every path or method they make
+// up.
+const Other = "other"
+
+var standardMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// Method returns the label for an HTTP method: the method itself if it is a
+// standard method, or Other.
+func Method(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return Other
+}
+
+// RouteFunc returns the label for the route of a request. It must only return
+// values from a small, fixed set, as each value creates new series that are
+// never freed.
+type RouteFunc func(r *http.Request) string
+
+// MuxRoute returns a RouteFunc that labels requests with the pattern of the
+// route they match in mux, such as "/v1/worklog/:id", or Other if they match
+// none. The routes of a mux mounted with SubRoute are joined to its prefix.
+func MuxRoute(mux *bone.Mux) RouteFunc {
+	return func(r *http.Request) string {
+		if route, ok := matchRoute(mux, r.Method, r.URL.Path); ok {
+			return route
+		}
+		return Other
+	}
+}
+
+// matchRoute returns the pattern of the route in mux matching method and
+// path, in the order bone serves them.
+func matchRoute(mux *bone.Mux, method, path string) (string, bool) {
+	req := &http.Request{Method: method, URL: &url.URL{Path: path}}
+	for _, route := range mux.Routes[method] {
+		if route.Atts&bone.SUB != 0 {
+			if !strings.HasPrefix(path, route.Path) {
+				continue
+			}
+			if sub, ok := route.Handler.(*bone.Mux); ok {
+				p, ok := matchRoute(sub, method, path[len(route.Path):])
+				return route.Path + p, ok
+			}
+			return route.Path, true
+		}
+
+		if (route.Atts != 0 && route.Match(req)) || path == route.Path {
+			return route.Path, true
+		}
+	}
+
+	// Routes ending in a slash match every path they prefix. bone files
+	// them under "static" rather than a method.
+	for _, route := range mux.Routes["static"] {
+		if strings.HasPrefix(path, route.Path) {
+			return route.Path, true
+		}
+	}
+
+	return "", false
+}
+
+// InstrumentHandler counts the requests served by next, by route, method
+// and status code, and records their durations by route and method. Routes
+// are labeled by route, which is called before next serves the request.
+func InstrumentHandler(requests *CounterVec, durations *HistogramVec, route RouteFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		label := route(r)
+		method := Method(r.Method)
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		durations.Observe(time.Since(start).Seconds(), label, method)
+		requests.Inc(label, method, strconv.Itoa(sw.status))
+	})
+}
+
+// InstrumentRoundTripper counts the requests made through next, by endpoint,
+// method and status code, and records their durations by endpoint and
+// method. Endpoints are labeled by endpoint. Requests that fail without a
+// response have a code of "error".
+func InstrumentRoundTripper(requests *CounterVec, durations *HistogramVec, endpoint RouteFunc, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		label := endpoint(r)
+		method := Method(r.Method)
+		start := time.Now()
+
+		resp, err := next.RoundTrip(r)
+
+		durations.Observe(time.Since(start).Seconds(), label, method)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		requests.Inc(label, method, code)
+
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// statusWriter records the status code written to a ResponseWriter. It
+// supports flushing and close notification, for streamed responses.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusWriter) CloseNotify() <-chan bool {
+	if cn, ok := s.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,368 @@
+// Package metrics provides counters, histograms and gauges, exposed in the
+// Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suited to
+// request latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSep joins label values into a map key. It can't appear in valid UTF-8.
+const labelSep = "\xff"
+
+// Metric is a named set of samples that can be added to a Registry.
+type Metric interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry holds the metrics exposed by a server.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]Metric
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+// Register adds the metrics to the registry. It panics if a metric with the
+// same name has already been registered.
+func (r *Registry) Register(metrics ...Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range metrics {
+		if _, ok := r.metrics[m.name()]; ok {
+			panic("metric registered twice: " + m.name())
+		}
+		r.metrics[m.name()] = m
+	}
+}
+
+// WriteText writes every metric in the registry, ordered by name, in the
+// Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]Metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		if err := m.write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP implements the http.Handler interface, serving the metrics in the
+// Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteText(w)
+}
+
+type desc struct {
+	n      string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string {
+	return d.n
+}
+
+func (d *desc) writeHeader(w io.Writer, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.n, escapeHelp(d.help), d.n, typ)
+	return err
+}
+
+// writeSample writes a single sample with the metric's labels, and an extra
+// label if extraName is not empty.
+func (d *desc) writeSample(w io.Writer, suffix string, values []string,
+	extraName, extraValue string, v float64) error {
+
+	var pairs []string
+	for i, l := range d.labels {
+		pairs = append(pairs, l+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s%s %s\n", d.n, suffix, labels, formatFloat(v))
+	return err
+}
+
+// sortedKeys returns the label value keys of a vector, in order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func labelKey(d *desc, values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", d.n, len(d.labels), len(values)))
+	}
+	return strings.Join(values, labelSep)
+}
+
+// CounterVec is a set of counters, partitioned by label values.
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	keys   map[string][]string
+	values map[string]float64
+}
+
+// NewCounterVec returns a new CounterVec.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		desc:   desc{n: name, help: help, labels: labels},
+		keys:   make(map[string][]string),
+		values: make(map[string]float64),
+	}
+}
+
+// Inc increments the counter for the label values by 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter for the label values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	k := labelKey(&c.desc, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.keys[k]; !ok {
+		c.keys[k] = append([]string(nil), labelValues...)
+	}
+	c.values[k] += v
+}
+
+// Value returns the counter for the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	k := labelKey(&c.desc, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[k]
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.writeHeader(w, "counter"); err != nil {
+		return err
+	}
+
+	for _, k := range sortedKeys(c.keys) {
+		if err := c.writeSample(w, "", c.keys[k], "", "", c.values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a set of histograms, partitioned by label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	keys   map[string][]string
+	values map[string]*histogramValue
+}
+
+// NewHistogramVec returns a new HistogramVec, with the given upper bounds
+// for its buckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		desc:    desc{n: name, help: help, labels: labels},
+		buckets: buckets,
+		keys:    make(map[string][]string),
+		values:  make(map[string]*histogramValue),
+	}
+}
+
+// Observe adds v to the histogram for the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := labelKey(&h.desc, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.keys[k] = append([]string(nil), labelValues...)
+		h.values[k] = hv
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// Count returns the number of observations for the label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	k := labelKey(&h.desc, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if hv, ok := h.values[k]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+
+	for _, k := range sortedKeys(h.keys) {
+		values, hv := h.keys[k], h.values[k]
+		for i, upper := range h.buckets {
+			err := h.writeSample(w, "_bucket", values, "le", formatFloat(upper), float64(hv.counts[i]))
+			if err != nil {
+				return err
+			}
+		}
+
+		err := h.writeSample(w, "_bucket", values, "le", "+Inf", float64(hv.count))
+		if err != nil {
+			return err
+		}
+		if err := h.writeSample(w, "_sum", values, "", "", hv.sum); err != nil {
+			return err
+		}
+		if err := h.writeSample(w, "_count", values, "", "", float64(hv.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are written.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc returns a new GaugeFunc, reading its value from fn.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{desc: desc{n: name, help: help}, fn: fn}
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	if err := g.writeHeader(w, "gauge"); err != nil {
+		return err
+	}
+	return g.writeSample(w, "", nil, "", "", g.fn())
+}
+
+// GaugeVecFunc is a set of gauges with a single label, whose values are read
+// when the metrics are written.
+type GaugeVecFunc struct {
+	desc
+	fn func() map[string]float64
+}
+
+// NewGaugeVecFunc returns a new GaugeVecFunc, reading the value for each
+// value of label from fn.
+func NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) *GaugeVecFunc {
+	return &GaugeVecFunc{desc: desc{n: name, help: help, labels: []string{label}}, fn: fn}
+}
+
+func (g *GaugeVecFunc) write(w io.Writer) error {
+	if err := g.writeHeader(w, "gauge"); err != nil {
+		return err
+	}
+
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := g.writeSample(w, "", []string{k}, "", "", values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
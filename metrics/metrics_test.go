@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-zoo/bone"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+
+	c := NewCounterVec("test_requests_total", "Requests.", "route", "code")
+	c.Inc("/b", "200")
+	c.Inc("/a", "500")
+	c.Add(2, "/b", "200")
+
+	h := NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+
+	g := NewGaugeFunc("test_up", "Up.", func() float64 { return 1 })
+	gv := NewGaugeVecFunc("test_sessions", "Sessions.", "type", func() map[string]float64 {
+		return map[string]float64{"user": 2, "machine": 0}
+	})
+
+	reg.Register(g, c, h, gv)
+
+	buf := &bytes.Buffer{}
+	if err := reg.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 2
+test_duration_seconds_sum{route="/a"} 0.55
+test_duration_seconds_count{route="/a"} 2
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",code="500"} 1
+test_requests_total{route="/b",code="200"} 3
+# HELP test_sessions Sessions.
+# TYPE test_sessions gauge
+test_sessions{type="machine"} 0
+test_sessions{type="user"} 2
+# HELP test_up Up.
+# TYPE test_up gauge
+test_up 1
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nExpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	reg := NewRegistry()
+	c := NewCounterVec("test_total", "A \\ help\nline.", "path")
+	c.Inc("a\"b\\c\nd")
+	reg.Register(c)
+
+	buf := &bytes.Buffer{}
+	if err := reg.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `# HELP test_total A \\ help\nline.`) {
+		t.Errorf("Expected help to be escaped, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `test_total{path="a\"b\\c\nd"} 1`) {
+		t.Errorf("Expected label to be escaped, got %s", buf.String())
+	}
+}
+
+func testMux() *bone.Mux {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	v1 := bone.New()
+	v1.Get("/credentials", ok)
+	v1.Get("/worklog/:id", ok)
+
+	mux := bone.New()
+	mux.Get("/ok", ok)
+	mux.Handle("/proxy/", ok)
+	mux.SubRoute("/v1", v1)
+	return mux
+}
+
+func TestMuxRoute(t *testing.T) {
+	route := MuxRoute(testMux())
+
+	tcs := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", "/ok", "/ok"},
+		{"GET", "/v1/credentials", "/v1/credentials"},
+		{"GET", "/v1/worklog/0e4pa3qej5q0e1em9dc6rntbdmf6c", "/v1/worklog/:id"},
+		{"POST", "/proxy/orgs/04000000000000000000000000000/x", "/proxy/"},
+		{"POST", "/ok", Other},
+		{"GET", "/v1/missing", Other},
+		{"GET", "/aaaa", Other},
+		{"FOO", "/ok", Other},
+	}
+	for _, tc := range tcs {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if label := route(r); label != tc.expected {
+			t.Errorf("Expected %s for %s %s, got %s", tc.expected, tc.method, tc.path, label)
+		}
+	}
+}
+
+func TestMethod(t *testing.T) {
+	if m := Method("PATCH"); m != "PATCH" {
+		t.Errorf("Expected PATCH, got %s", m)
+	}
+	if m := Method("FOO"); m != Other {
+		t.Errorf("Expected %s, got %s", Other, m)
+	}
+}
+
+// series returns the number of series of the metric name written by reg.
+func series(t *testing.T, reg *Registry, name string) int {
+	buf := &bytes.Buffer{}
+	if err := reg.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, name+"{") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	requests := NewCounterVec("requests_total", "", "route", "method", "code")
+	durations := NewHistogramVec("duration_seconds", "", DefBuckets, "route", "method")
+
+	mux := testMux()
+	h := InstrumentHandler(requests, durations, MuxRoute(mux), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streamed responses still need to flush
+		w.(http.Flusher).Flush()
+		mux.ServeHTTP(w, r)
+	}))
+
+	for _, path := range []string{"/ok", "/ok", "/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if v := requests.Value("/ok", "GET", "200"); v != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", v)
+	}
+	if v := requests.Value(Other, "GET", "404"); v != 1 {
+		t.Errorf("Expected 1 not found request, got %v", v)
+	}
+	if n := durations.Count("/ok", "GET"); n != 2 {
+		t.Errorf("Expected 2 durations, got %d", n)
+	}
+}
+
+func TestInstrumentHandlerUnknownRoutes(t *testing.T) {
+	requests := NewCounterVec("requests_total", "", "route", "method", "code")
+	durations := NewHistogramVec("duration_seconds", "", []float64{1}, "route", "method")
+	reg := NewRegistry()
+	reg.Register(requests, durations)
+
+	mux := testMux()
+	h := InstrumentHandler(requests, durations, MuxRoute(mux), mux)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	requestSeries := series(t, reg, "requests_total")
+	durationSeries := series(t, reg, "duration_seconds_bucket")
+
+	for _, path := range []string{"/aaaa", "/bbbb", "/cccc/0e4pa3qej5q0e1em9dc6rntbdmf6c"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/aaaa", nil))
+	}
+
+	if n := series(t, reg, "requests_total"); n != requestSeries+1 {
+		t.Errorf("Expected only one new series for other methods, got %d new", n-requestSeries)
+	}
+	if n := series(t, reg, "duration_seconds_bucket"); n != durationSeries*2 {
+		t.Errorf("Expected only one new histogram for other methods, got %d buckets", n)
+	}
+	if v := requests.Value(Other, "GET", "404"); v != 4 {
+		t.Errorf("Expected 4 not found requests, got %v", v)
+	}
+}
+
+func TestInstrumentRoundTripper(t *testing.T) {
+	requests := NewCounterVec("requests_total", "", "endpoint", "method", "code")
+	durations := NewHistogramVec("duration_seconds", "", DefBuckets, "endpoint", "method")
+
+	endpoint := func(r *http.Request) string {
+		if r.URL.Path == "/orgs" {
+			return r.URL.Path
+		}
+		return Other
+	}
+
+	rt := InstrumentRoundTripper(requests, durations, endpoint, roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/fail" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusCreated}, nil
+	}))
+
+	for _, path := range []string{"/orgs", "/fail"} {
+		req := httptest.NewRequest("POST", "http://registry"+path, nil)
+		rt.RoundTrip(req)
+	}
+
+	if v := requests.Value("/orgs", "POST", "201"); v != 1 {
+		t.Errorf("Expected 1 created request, got %v", v)
+	}
+	if v := requests.Value(Other, "POST", "error"); v != 1 {
+		t.Errorf("Expected 1 failed request, got %v", v)
+	}
+}
@@ -16,13 +16,20 @@ Grammar:
 	<identity>    ::= <multiple>
 	<instance>    ::= <multiple>
 
-	<multiple>         ::= <alternation> | <glob-or-literal> | <full-glob>
+	<multiple>         ::= <negation> | <alternation> | <glob-or-literal> | <full-glob>
+	<negation>         ::= "!" <glob-or-literal> | "[!" <alternation-body> "]"
 	<alternation>      ::= "[" <alternation-body> "]"
 	<alternation-body> ::= <glob-or-literal> | <glob-or-literal> "|" <alternation-body>
 	<glob-or-literal>  ::= <glob> | <literal>
-	<glob>             ::= <literal> "*"
+	<glob>             ::= <literal> "*" | "*" <suffix> | <literal> "*" <suffix>
 	<literal>          ::= [a-z0-9][a-z0-9\-\_]{0,63}
+	<suffix>           ::= [a-z0-9\-\_]{1,63}
 	<fullglob>         ::= "*"
+
+A glob may contain only a single "*". Globs with a suffix (`*-eu`,
+`api-*-worker`) match subjects that start with the literal before the "*" and
+end with the literal after it. A negation matches every subject not matched by
+the negated glob, literal or alternation.
 */
 package pathexp
 
@@ -37,7 +44,7 @@ const slugstr = `[a-z\d][-_a-z\d]{0,63}`
 
 var (
 	slug           = regexp.MustCompile(`^` + slugstr + `$`)
-	globRe         = regexp.MustCompile(`^(` + slugstr + `)(\*?)$`)
+	suffix         = regexp.MustCompile(`^[-_a-z\d]{1,63}$`)
 	fullglobOrGlob = regexp.MustCompile(`(^\*$)|(?:^(` + slugstr + `)(\*?)$)`)
 	doubleGlob     = regexp.MustCompile(`^\*\*$`)
 )
@@ -71,6 +78,18 @@ type glob string
 type fullglob struct{}
 type alternation []segment
 
+// pattern is a glob with a suffix, and an optional prefix.
+type pattern struct {
+	prefix string
+	suffix string
+}
+
+// negation wraps a literal, glob, pattern or alternation, matching any subject
+// it does not.
+type negation struct {
+	segment
+}
+
 func (l literal) String() string { return string(l) }
 func (l literal) Contains(subject string) bool {
 	return string(l) == subject
@@ -87,6 +106,23 @@ func GlobContains(value, subject string) bool {
 	return gl.Contains(subject)
 }
 
+func (p pattern) String() string { return p.prefix + "*" + p.suffix }
+func (p pattern) Contains(subject string) bool {
+	return len(subject) >= len(p.prefix)+len(p.suffix) &&
+		strings.HasPrefix(subject, p.prefix) &&
+		strings.HasSuffix(subject, p.suffix)
+}
+
+func (n negation) String() string {
+	if a, ok := n.segment.(alternation); ok {
+		return "[!" + a.String()[1:]
+	}
+	return "!" + n.segment.String()
+}
+func (n negation) Contains(subject string) bool {
+	return !n.segment.Contains(subject)
+}
+
 func (f fullglob) String() string { return "*" }
 func (f fullglob) Contains(subject string) bool {
 	return true
@@ -116,33 +152,36 @@ func (a alternation) Contains(subject string) bool {
 
 // compareSegmentType ranks the segments by their type specificity
 func compareSegmentType(a, b segment) int {
-	segs := []segment{a, b}
-	ranks := make([]int, 2)
-	for i, seg := range segs {
-		switch seg.(type) {
-		case literal:
-			ranks[i] = 3
-		case glob:
-			ranks[i] = 2
-		case alternation:
-			ranks[i] = 1
-		case fullglob:
-			ranks[i] = 0
-		default:
-			panic("Bad type for segment!")
-		}
-	}
-
+	ra, rb := segmentRank(a), segmentRank(b)
 	switch {
-	case ranks[0] < ranks[1]:
+	case ra < rb:
 		return -1
-	case ranks[0] > ranks[1]:
+	case ra > rb:
 		return 1
 	default:
 		return 0
 	}
 }
 
+// segmentRank returns the type specificity of a segment. Higher ranks are
+// more specific.
+func segmentRank(seg segment) int {
+	switch seg.(type) {
+	case literal:
+		return 4
+	case glob, pattern:
+		return 3
+	case alternation:
+		return 2
+	case negation:
+		return 1
+	case fullglob:
+		return 0
+	default:
+		panic("Bad type for segment!")
+	}
+}
+
 func segmentsEqual(a, b segment) bool {
 	switch at := a.(type) {
 	case literal:
@@ -155,6 +194,16 @@ func segmentsEqual(a, b segment) bool {
 			return at == bg
 		}
 		return false
+	case pattern:
+		if bp, ok := b.(pattern); ok {
+			return at == bp
+		}
+		return false
+	case negation:
+		if bn, ok := b.(negation); ok {
+			return segmentsEqual(at.segment, bn.segment)
+		}
+		return false
 	case alternation:
 		if ba, ok := b.(alternation); ok {
 			if len(at) != len(ba) {
@@ -433,48 +482,77 @@ func Split(name, segment string) ([]string, error) {
 }
 
 func parseMultiple(name string, parts []string, mustBeComplete bool) (segment, error) {
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "!") {
+		positive := append([]string{parts[0][1:]}, parts[1:]...)
+		if positive[0] == "*" || strings.HasPrefix(positive[0], "!") {
+			return nil, errors.New("Invalid negation for " + name + ".")
+		}
+
+		seg, err := parseMultiple(name, positive, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return negation{seg}, nil
+	}
+
 	switch len(parts) {
 	case 0:
 		return nil, errors.New("Empty segment alternation for " + name + ".")
 	case 1:
-		matches := fullglobOrGlob.FindAllStringSubmatch(parts[0], -1)
-		if len(matches) != 1 {
+		if parts[0] == "*" {
+			return fullglob{}, nil
+		}
+
+		seg, ok := parseGlobOrLiteral(parts[0])
+		if !ok {
 			if mustBeComplete {
 				return nil, errors.New("Invalid " + name + ".")
 			}
 			return fullglob{}, nil
 		}
 
-		match := matches[0]
-		switch {
-		case match[1] != "": // fullglob
-			return fullglob{}, nil
-		case match[3] != "": // glob
-			return glob(match[2]), nil
-		default: // literal
-			return literal(match[2]), nil
-		}
+		return seg, nil
 	default:
 		var res alternation
 		for _, part := range parts {
-			matches := globRe.FindAllStringSubmatch(part, -1)
-			if len(matches) != 1 {
+			seg, ok := parseGlobOrLiteral(part)
+			if !ok {
 				return nil, errors.New("Invalid " + name + ".")
 			}
 
-			match := matches[0]
-			switch {
-			case match[2] != "": // glob
-				res = append(res, glob(match[1]))
-			default: // literal
-				res = append(res, literal(match[1]))
-			}
+			res = append(res, seg)
 		}
 
 		return res, nil
 	}
 }
 
+// parseGlobOrLiteral parses a single literal, glob or pattern. A full glob is
+// not accepted.
+func parseGlobOrLiteral(part string) (segment, bool) {
+	idx := strings.Index(part, "*")
+	if idx == -1 {
+		return literal(part), ValidSlug(part)
+	}
+
+	prefix, sfx := part[:idx], part[idx+1:]
+	if prefix != "" && !ValidSlug(prefix) {
+		return nil, false
+	}
+
+	switch {
+	case sfx == "" && prefix == "":
+		return nil, false
+	case sfx == "":
+		return glob(prefix), true
+	case suffix.MatchString(sfx):
+		return pattern{prefix: prefix, suffix: sfx}, true
+	default:
+		return nil, false
+	}
+}
+
 // Equal returns a bool indicating if the two PathExps are equivalent.
 func (pe *PathExp) Equal(other *PathExp) bool {
 
@@ -506,23 +584,12 @@ func (pe *PathExp) Equal(other *PathExp) bool {
 //	- <literal>
 //  - <glob>
 //  - <alternation>
+//  - <negation>
 //  - <fullglob>
 //
 // It is assumed that the provided PathExps are not disjoint.
 func (pe *PathExp) CompareSpecificity(other *PathExp) int {
-	if cmp := compareSegmentType(pe.Envs, other.Envs); cmp != 0 {
-		return cmp
-	}
-
-	if cmp := compareSegmentType(pe.Services, other.Services); cmp != 0 {
-		return cmp
-	}
-
-	if cmp := compareSegmentType(pe.Identities, other.Identities); cmp != 0 {
-		return cmp
-	}
-
-	return compareSegmentType(pe.Instances, other.Instances)
+	return pe.Specificity().Compare(other.Specificity())
 }
 
 // Contains returns whether this path contains the subject. A path Contains
@@ -45,7 +45,9 @@ func TestParse(t *testing.T) {
 	)
 
 	// Check globs and alternation for everything else. they should be valid
-	multiple := []string{"*", "thing*", "a*", "[a|bc]", "[a|bc|d]", "[a*|c]"}
+	multiple := []string{"*", "thing*", "a*", "[a|bc]", "[a|bc|d]", "[a*|c]",
+		"*-eu", "api-*-worker", "[*-eu|*-us]", "[a|api-*-worker]",
+		"!staging", "!prod*", "!*-eu", "[!dev|test]", "[!dev|test-*]"}
 	prefix := "/org/project"
 	parts = []string{"env", "service", "user", "instance"}
 	for i := 0; i < len(parts); i++ {
@@ -90,6 +92,26 @@ func TestParse(t *testing.T) {
 		valid: false,
 	})
 
+	// Multiple globs within a part, and bad suffixes, are invalid
+	testCases = append(testCases,
+		tc{path: "/o/p/e/s/u/a*b*", valid: false},
+		tc{path: "/o/p/e/s/u/**i", valid: false},
+		tc{path: "/o/p/e/s/u/*A", valid: false},
+		tc{path: "/o/p/e/s/u/*" + strings.Repeat("a", 80), valid: false},
+		tc{path: "/o/p/e/s/u/-a*b", valid: false},
+	)
+
+	// Malformed negations are invalid
+	testCases = append(testCases,
+		tc{path: "/o/p/e/s/u/!", valid: false},
+		tc{path: "/o/p/e/s/u/!*", valid: false},
+		tc{path: "/o/p/e/s/u/!!i", valid: false},
+		tc{path: "/o/p/e/s/u/[!i]", valid: false},
+		tc{path: "/o/p/e/s/u/[a|!i]", valid: false},
+		tc{path: "/o/p/e/s/u/[!a|!i]", valid: false},
+		tc{path: "/o/p/e/s/u/i!", valid: false},
+	)
+
 	// An empty segment is invalid
 	testCases = append(testCases, tc{
 		path:  "/org/project/env/service//instance",
@@ -114,6 +136,12 @@ func TestParse(t *testing.T) {
 		"/org/project/env/*/user/instance",
 		"/org/project/env/[abc|def]/user/instance",
 		"/org/project/env/[abc|def|thing-*]/user/instance",
+		"/org/project/*-eu/service/user/instance",
+		"/org/project/env/api-*-worker/user/instance",
+		"/org/project/env/[*-worker|abc|def-*]/user/instance",
+		"/org/project/!staging/service/user/instance",
+		"/org/project/[!dev|test]/service/user/instance",
+		"/org/project/env/service/user/!i-*",
 	}
 
 	for _, path := range paths {
@@ -157,6 +185,17 @@ func TestPathExpCompareSpecificity(t *testing.T) {
 		{a: "/o/p/e/[svc-*|boo]/u/i", b: "/o/p/e/sv*/u/i", res: -1},
 		{a: "/o/p/e/[svc-*|boo]/u/i", b: "/o/p/e/*/u/i", res: 1},
 		{a: "/o/p/e/s/u/i", b: "/o/p/e/s/u*/i", res: 1},
+
+		// suffix and infix globs rank with prefix globs
+		{a: "/o/p/e/*-worker/u/i", b: "/o/p/e/api-*/u/i", res: 0},
+		{a: "/o/p/e/api-*-worker/u/i", b: "/o/p/e/api-*/u/i", res: 0},
+		{a: "/o/p/e/svc/u/i", b: "/o/p/e/*-worker/u/i", res: 1},
+		{a: "/o/p/e/[a|b]/u/i", b: "/o/p/e/*-worker/u/i", res: -1},
+
+		// negations are only more specific than full globs
+		{a: "/o/p/e/!svc/u/i", b: "/o/p/e/*/u/i", res: 1},
+		{a: "/o/p/e/!svc/u/i", b: "/o/p/e/[a|b]/u/i", res: -1},
+		{a: "/o/p/e/[!a|b]/u/i", b: "/o/p/e/!svc/u/i", res: 0},
 	}
 
 	for _, test := range testCases {
@@ -200,6 +239,17 @@ func TestEqual(t *testing.T) {
 		{a: "/o/p/e/s/u/i", b: "/o/p/e/[s|b-*]/u/i", equal: false},
 		{a: "/o/p/e/[c|d|e]/u/i", b: "/o/p/e/[s|b-*]/u/i", equal: false},
 		{a: "/o/p/e/[c|d|e]/u/i", b: "/o/p/e/[c|e|f]/u/i", equal: false},
+
+		{a: "/o/p/*-eu/s/u/i", b: "/o/p/*-eu/s/u/i", equal: true},
+		{a: "/o/p/a-*-eu/s/u/i", b: "/o/p/a-*-eu/s/u/i", equal: true},
+		{a: "/o/p/!e/s/u/i", b: "/o/p/!e/s/u/i", equal: true},
+		{a: "/o/p/[!e|f]/s/u/i", b: "/o/p/[!f|e]/s/u/i", equal: true},
+
+		{a: "/o/p/*-eu/s/u/i", b: "/o/p/*-us/s/u/i", equal: false},
+		{a: "/o/p/a-*-eu/s/u/i", b: "/o/p/a-*/s/u/i", equal: false},
+		{a: "/o/p/!e/s/u/i", b: "/o/p/e/s/u/i", equal: false},
+		{a: "/o/p/!e*/s/u/i", b: "/o/p/!e/s/u/i", equal: false},
+		{a: "/o/p/[!e|f]/s/u/i", b: "/o/p/[e|f]/s/u/i", equal: false},
 	}
 
 	for _, test := range testCases {
@@ -314,6 +364,45 @@ func TestSegmentsContains(t *testing.T) {
 	}
 }
 
+func TestPatternAndNegationContains(t *testing.T) {
+	testCases := []struct {
+		path     string
+		subject  string
+		contains bool
+	}{
+		{"/o/p/*-eu/s/u/i", "prod-eu", true},
+		{"/o/p/*-eu/s/u/i", "-eu", true},
+		{"/o/p/*-eu/s/u/i", "prod-us", false},
+		{"/o/p/api-*-worker/s/u/i", "api-billing-worker", true},
+		{"/o/p/api-*-worker/s/u/i", "api--worker", true},
+		{"/o/p/api-*-worker/s/u/i", "api-worker", false},
+		{"/o/p/api-*-worker/s/u/i", "api-billing", false},
+		{"/o/p/!staging/s/u/i", "production", true},
+		{"/o/p/!staging/s/u/i", "staging", false},
+		{"/o/p/!prod*/s/u/i", "staging", true},
+		{"/o/p/!prod*/s/u/i", "prod-eu", false},
+		{"/o/p/[!dev|test]/s/u/i", "prod", true},
+		{"/o/p/[!dev|test]/s/u/i", "dev", false},
+		{"/o/p/[!dev|test]/s/u/i", "test", false},
+		{"/o/p/[*-eu|dev]/s/u/i", "prod-eu", true},
+		{"/o/p/[*-eu|dev]/s/u/i", "dev", true},
+		{"/o/p/[*-eu|dev]/s/u/i", "prod-us", false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.path+" "+test.subject, func(t *testing.T) {
+			pe, err := Parse(test.path)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.path)
+			}
+
+			if pe.Envs.Contains(test.subject) != test.contains {
+				t.Errorf("Expected %s Contain %s = %t", test.path, test.subject, test.contains)
+			}
+		})
+	}
+}
+
 func TestExpContains(t *testing.T) {
 	testCases := []struct {
 		l        string
@@ -356,3 +445,317 @@ func TestExpContains(t *testing.T) {
 		})
 	}
 }
+
+func TestIntersects(t *testing.T) {
+	testCases := []struct {
+		a          string
+		b          string
+		intersects bool
+	}{
+		{"/o/p/e/s/u/i", "/o/p/e/s/u/i", true},
+		{"/o/p/e/s/u/i", "/o/p/*/s/u/i", true},
+		{"/o/p/e-1/s/u/i", "/o/p/e-*/s/u/i", true},
+		{"/o/p/e-*/s/u/i", "/o/p/e*/s/u/i", true},
+		{"/o/p/e-*/s/u/i", "/o/p/*/s/u/i", true},
+		{"/o/p/e/[a|b]/u/i", "/o/p/e/[b|c]/u/i", true},
+		{"/o/p/e/[a*|b]/u/i", "/o/p/e/ab/u/i", true},
+		{"/o/p/e/[a*|b]/u/i", "/o/p/e/[c|ab*]/u/i", true},
+		{"/o/p/**", "/o/p/e/s/u/i", true},
+
+		{"/o/p/e/s/u/i", "/o1/p/e/s/u/i", false},
+		{"/o/p/e/s/u/i", "/o/p1/e/s/u/i", false},
+		{"/o/p/e/s/u/i", "/o/p/e1/s/u/i", false},
+		{"/o/p/e-*/s/u/i", "/o/p/f-*/s/u/i", false},
+		{"/o/p/e-*/s/u/i", "/o/p/e/s/u/i", false},
+		{"/o/p/e/[a|b]/u/i", "/o/p/e/[c|d]/u/i", false},
+		{"/o/p/e/[a*|b]/u/i", "/o/p/e/[c|ba]/u/i", false},
+		{"/o/p/*/s/u/i", "/o/p/e/s/u/i1", false},
+
+		{"/o/p/*-eu/s/u/i", "/o/p/prod-*/s/u/i", true},
+		{"/o/p/*-eu/s/u/i", "/o/p/prod-eu/s/u/i", true},
+		{"/o/p/api-*-worker/s/u/i", "/o/p/*-worker/s/u/i", true},
+		{"/o/p/!staging/s/u/i", "/o/p/prod/s/u/i", true},
+		{"/o/p/!staging/s/u/i", "/o/p/!prod/s/u/i", true},
+		{"/o/p/!prod*/s/u/i", "/o/p/p*/s/u/i", true},
+		{"/o/p/[!a|b]/s/u/i", "/o/p/[a|c]/s/u/i", true},
+
+		{"/o/p/*-eu/s/u/i", "/o/p/*-us/s/u/i", false},
+		{"/o/p/*-eu/s/u/i", "/o/p/prod-us/s/u/i", false},
+		{"/o/p/api-*/s/u/i", "/o/p/web-*-eu/s/u/i", false},
+		{"/o/p/!staging/s/u/i", "/o/p/staging/s/u/i", false},
+		{"/o/p/!prod*/s/u/i", "/o/p/prod-*/s/u/i", false},
+		{"/o/p/[!a|b]/s/u/i", "/o/p/[a|b]/s/u/i", false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, err := Parse(test.a)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.a)
+			}
+
+			b, err := Parse(test.b)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.b)
+			}
+
+			res := a.Intersects(b)
+			rev := b.Intersects(a)
+
+			if res != rev {
+				t.Error("(a intersects b) != (b intersects a)")
+			}
+
+			if res != test.intersects {
+				t.Errorf("Expected %s Intersects %s = %t", test.a, test.b, test.intersects)
+			}
+		})
+	}
+}
+
+func TestIsSubsetOf(t *testing.T) {
+	testCases := []struct {
+		a      string
+		b      string
+		subset bool
+	}{
+		{"/o/p/e/s/u/i", "/o/p/e/s/u/i", true},
+		{"/o/p/e/s/u/i", "/o/p/*/s/u/i", true},
+		{"/o/p/e/s/u/i", "/o/p/**", true},
+		{"/o/p/e-1/s/u/i", "/o/p/e-*/s/u/i", true},
+		{"/o/p/e-*/s/u/i", "/o/p/e*/s/u/i", true},
+		{"/o/p/e-*/s/u/i", "/o/p/*/s/u/i", true},
+		{"/o/p/e/a/u/i", "/o/p/e/[a|b]/u/i", true},
+		{"/o/p/e/[a|b]/u/i", "/o/p/e/[a|b|c]/u/i", true},
+		{"/o/p/e/[ab|ac*]/u/i", "/o/p/e/a*/u/i", true},
+		{"/o/p/e/[ab|ac*]/u/i", "/o/p/e/[ab|ac*]/u/i", true},
+		{"/o/p/e/ab*/u/i", "/o/p/e/[c|a*]/u/i", true},
+		{"/o/p/*/s/u/i", "/o/p/*/s/u/i", true},
+
+		{"/o/p/*/s/u/i", "/o/p/e/s/u/i", false},
+		{"/o/p/e*/s/u/i", "/o/p/e-*/s/u/i", false},
+		{"/o/p/e*/s/u/i", "/o/p/e/s/u/i", false},
+		{"/o/p/e-*/s/u/i", "/o/p/[e-1|e-2]/s/u/i", false},
+		{"/o/p/e/[a|b]/u/i", "/o/p/e/a/u/i", false},
+		{"/o/p/e/[a|b|c]/u/i", "/o/p/e/[a|b]/u/i", false},
+		{"/o/p/e/[a|b]/u/*", "/o/p/e/[a|b]/u/i", false},
+		{"/o/p/e/s/u/i", "/o1/p/e/s/u/i", false},
+		{"/o/p/e/s/u/i", "/o/p1/e/s/u/i", false},
+
+		{"/o/p/prod-eu/s/u/i", "/o/p/*-eu/s/u/i", true},
+		{"/o/p/api-*-worker/s/u/i", "/o/p/api-*/s/u/i", true},
+		{"/o/p/api-*-worker/s/u/i", "/o/p/*-worker/s/u/i", true},
+		{"/o/p/api-*-worker/s/u/i", "/o/p/a*r/s/u/i", true},
+		{"/o/p/prod/s/u/i", "/o/p/!staging/s/u/i", true},
+		{"/o/p/prod*/s/u/i", "/o/p/!staging/s/u/i", true},
+		{"/o/p/[a|b]/s/u/i", "/o/p/[!c|d]/s/u/i", true},
+		{"/o/p/[!a|b]/s/u/i", "/o/p/!a/s/u/i", true},
+		{"/o/p/!a*/s/u/i", "/o/p/!ab/s/u/i", true},
+
+		{"/o/p/api-*/s/u/i", "/o/p/api-*-worker/s/u/i", false},
+		{"/o/p/*-eu/s/u/i", "/o/p/prod-*/s/u/i", false},
+		{"/o/p/!staging/s/u/i", "/o/p/prod/s/u/i", false},
+		{"/o/p/!staging/s/u/i", "/o/p/prod*/s/u/i", false},
+		{"/o/p/s*/s/u/i", "/o/p/!staging/s/u/i", false},
+		{"/o/p/!a/s/u/i", "/o/p/[!a|b]/s/u/i", false},
+		{"/o/p/!ab/s/u/i", "/o/p/!a*/s/u/i", false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, err := Parse(test.a)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.a)
+			}
+
+			b, err := Parse(test.b)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.b)
+			}
+
+			res := a.IsSubsetOf(b)
+			if res != test.subset {
+				t.Errorf("Expected %s IsSubsetOf %s = %t", test.a, test.b, test.subset)
+			}
+
+			// A subset of b must also intersect b
+			if res && !a.Intersects(b) {
+				t.Errorf("%s is a subset of %s but does not intersect it", test.a, test.b)
+			}
+		})
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	testCases := []struct {
+		a   string
+		b   string
+		res string // empty for disjoint
+	}{
+		{"/o/p/e/s/u/i", "/o/p/e/s/u/i", "/o/p/e/s/u/i"},
+		{"/o/p/e/s/u/i", "/o/p/*/s/u/i", "/o/p/e/s/u/i"},
+		{"/o/p/**", "/o/p/e/s/u/*", "/o/p/e/s/u/*"},
+		{"/o/p/e-*/s/u/*", "/o/p/*/s/u/i", "/o/p/e-*/s/u/i"},
+		{"/o/p/e-*/s/u/i", "/o/p/e*/s/u/i", "/o/p/e-*/s/u/i"},
+		{"/o/p/e/[a|b|c]/u/i", "/o/p/e/[b|c|d]/u/i", "/o/p/e/[b|c]/u/i"},
+		{"/o/p/e/[a|b|c]/u/i", "/o/p/e/[c|d]/u/i", "/o/p/e/c/u/i"},
+		{"/o/p/e/[a*|b]/u/i", "/o/p/e/[ab*|abc|b*]/u/i", "/o/p/e/[ab*|b]/u/i"},
+		{"/o/p/e/[a*|b*]/u/i", "/o/p/e/*/u/i", "/o/p/e/[a*|b*]/u/i"},
+		{"/o/p/e/[a*|ab*]/u/i", "/o/p/e/[a*|ab]/u/i", "/o/p/e/a*/u/i"},
+
+		{"/o/p/e/s/u/i", "/o/p/e/s/u/j", ""},
+		{"/o/p/e/s/u/i", "/o1/p/e/s/u/i", ""},
+		{"/o/p/e-*/s/u/i", "/o/p/f*/s/u/i", ""},
+		{"/o/p/e/[a|b]/u/i", "/o/p/e/[c|d*]/u/i", ""},
+
+		{"/o/p/prod-*/s/u/i", "/o/p/*-eu/s/u/i", "/o/p/[prod-*-eu|prod-eu]/s/u/i"},
+		{"/o/p/ab*/s/u/i", "/o/p/*ba/s/u/i", "/o/p/[ab*ba|aba]/s/u/i"},
+		{"/o/p/api-*/s/u/i", "/o/p/*-worker/s/u/i", "/o/p/[api-*-worker|api-worker]/s/u/i"},
+		{"/o/p/api-*-worker/s/u/i", "/o/p/*/s/u/i", "/o/p/api-*-worker/s/u/i"},
+		{"/o/p/[dev|prod|test]/s/u/i", "/o/p/[!dev|test]/s/u/i", "/o/p/prod/s/u/i"},
+		{"/o/p/!dev/s/u/i", "/o/p/!test/s/u/i", "/o/p/[!dev|test]/s/u/i"},
+		{"/o/p/!dev*/s/u/i", "/o/p/!dev-eu/s/u/i", "/o/p/!dev*/s/u/i"},
+
+		{"/o/p/*-eu/s/u/i", "/o/p/*-us/s/u/i", ""},
+		{"/o/p/api-*/s/u/i", "/o/p/web-*-worker/s/u/i", ""},
+		{"/o/p/[dev|test]/s/u/i", "/o/p/[!dev|test]/s/u/i", ""},
+		{"/o/p/dev-*/s/u/i", "/o/p/!dev*/s/u/i", ""},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, err := Parse(test.a)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.a)
+			}
+
+			b, err := Parse(test.b)
+			if err != nil {
+				t.Fatalf("Failed to parse %s", test.b)
+			}
+
+			ab, abExact := a.Intersection(b)
+			ba, baExact := b.Intersection(a)
+			if !abExact || !baExact {
+				t.Errorf("Expected the intersection of %s and %s to be exact", test.a, test.b)
+			}
+
+			for _, res := range []*PathExp{ab, ba} {
+				if test.res == "" {
+					if res != nil {
+						t.Errorf("Expected %s and %s to be disjoint, got %s", test.a, test.b, res)
+					}
+					continue
+				}
+
+				if res == nil {
+					t.Fatalf("Expected %s Intersection %s = %s, got nil", test.a, test.b, test.res)
+				}
+
+				if res.String() != test.res {
+					t.Errorf("Expected %s Intersection %s = %s, got %s", test.a, test.b, test.res, res)
+				}
+
+				if !res.IsSubsetOf(a) || !res.IsSubsetOf(b) {
+					t.Errorf("Intersection %s is not a subset of both inputs", res)
+				}
+			}
+		})
+	}
+}
+
+func TestIntersectionPartialNegation(t *testing.T) {
+	a, err := Parse("/o/p/prod-*/s/u/i")
+	if err != nil {
+		t.Fatal("Failed to parse test item")
+	}
+
+	b, err := Parse("/o/p/!prod-us/s/u/i")
+	if err != nil {
+		t.Fatal("Failed to parse test item")
+	}
+
+	// prod-* minus prod-us cannot be expressed, so prod-* is used as is, and
+	// the result is marked as inexact.
+	ab, abExact := a.Intersection(b)
+	ba, baExact := b.Intersection(a)
+	for _, res := range []*PathExp{ab, ba} {
+		if res == nil || !res.Equal(a) {
+			t.Errorf("Expected intersection %s, got %s", a, res)
+		}
+	}
+	if abExact || baExact {
+		t.Error("Expected the intersection to be inexact")
+	}
+
+	// The inexact segment makes the whole PathExp inexact, even when the
+	// other segments intersect exactly.
+	c, err := Parse("/o/p/[prod-*|dev]/[s|t]/u/i")
+	if err != nil {
+		t.Fatal("Failed to parse test item")
+	}
+	res, exact := c.Intersection(b)
+	if res == nil || res.String() != "/o/p/[dev|prod-*]/s/u/i" || exact {
+		t.Errorf("Expected inexact intersection /o/p/[dev|prod-*]/s/u/i, got %s (exact %t)", res, exact)
+	}
+}
+
+func TestSortBySpecificity(t *testing.T) {
+	raw := []string{
+		"/o/p/*/*/*/*",
+		"/o/p/!e/s/u/i",
+		"/o/p/e/[a|b]/u/i",
+		"/o/p/e/s/u/i",
+		"/o/p/*/s/u/i",
+		"/o/p/e/s*/u/i",
+		"/o/p/e/s/u/*",
+	}
+
+	expected := []string{
+		"/o/p/e/s/u/i",
+		"/o/p/e/s/u/*",
+		"/o/p/e/s*/u/i",
+		"/o/p/e/[a|b]/u/i",
+		"/o/p/!e/s/u/i",
+		"/o/p/*/s/u/i",
+		"/o/p/*/*/*/*",
+	}
+
+	var pes []*PathExp
+	for _, r := range raw {
+		pe, err := Parse(r)
+		if err != nil {
+			t.Fatalf("Failed to parse %s", r)
+		}
+		pes = append(pes, pe)
+	}
+
+	SortBySpecificity(pes)
+	for i, pe := range pes {
+		if pe.String() != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, pe)
+		}
+	}
+}
+
+func TestSpecificityString(t *testing.T) {
+	pe, err := Parse("/o/p/e/s*/[a|b]/!i")
+	if err != nil {
+		t.Fatal("Failed to parse test item")
+	}
+
+	expected := "literal/glob/alternation/negation"
+	if out := pe.Specificity().String(); out != expected {
+		t.Errorf("Expected %s got %s", expected, out)
+	}
+
+	pe, err = Parse("/o/p/**")
+	if err != nil {
+		t.Fatal("Failed to parse test item")
+	}
+
+	expected = "fullglob/fullglob/fullglob/fullglob"
+	if out := pe.Specificity().String(); out != expected {
+		t.Errorf("Expected %s got %s", expected, out)
+	}
+}
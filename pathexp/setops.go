@@ -0,0 +1,363 @@
+package pathexp
+
+import (
+	"sort"
+	"strings"
+)
+
+// Intersects returns whether there exists any concrete path that is contained
+// by both this PathExp and the other.
+func (pe *PathExp) Intersects(other *PathExp) bool {
+	return pe.Org == other.Org &&
+		pe.Project == other.Project &&
+		segmentsIntersect(pe.Envs, other.Envs) &&
+		segmentsIntersect(pe.Services, other.Services) &&
+		segmentsIntersect(pe.Identities, other.Identities) &&
+		segmentsIntersect(pe.Instances, other.Instances)
+}
+
+// IsSubsetOf returns whether every concrete path contained by this PathExp is
+// also contained by the other. Equal PathExps are subsets of each other.
+//
+// Alternations are compared member by member, so a glob is only considered to
+// be covered by an alternation if a single member of the alternation covers
+// it.
+func (pe *PathExp) IsSubsetOf(other *PathExp) bool {
+	return pe.Org == other.Org &&
+		pe.Project == other.Project &&
+		segmentIsSubset(pe.Envs, other.Envs) &&
+		segmentIsSubset(pe.Services, other.Services) &&
+		segmentIsSubset(pe.Identities, other.Identities) &&
+		segmentIsSubset(pe.Instances, other.Instances)
+}
+
+// Intersection returns a new PathExp containing exactly the concrete paths
+// contained by both this PathExp and the other, and true. It returns nil and
+// true if the two PathExps are disjoint.
+//
+// The grammar can't express a glob minus some of its subjects, so when a
+// negated segment excludes only part of the other segment, the intersection
+// can't be expressed either. Intersection then returns a PathExp containing
+// the intersection along with some other paths, and false. Callers that need exact
+// answers, such as policy analysis, must treat such a result as "may match"
+// rather than "matches".
+func (pe *PathExp) Intersection(other *PathExp) (*PathExp, bool) {
+	if pe.Org != other.Org || pe.Project != other.Project {
+		return nil, true
+	}
+
+	res := PathExp{Org: pe.Org, Project: pe.Project}
+	pairs := []struct {
+		dst  *segment
+		a, b segment
+	}{
+		{&res.Envs, pe.Envs, other.Envs},
+		{&res.Services, pe.Services, other.Services},
+		{&res.Identities, pe.Identities, other.Identities},
+		{&res.Instances, pe.Instances, other.Instances},
+	}
+
+	exact := true
+	for _, p := range pairs {
+		seg, segExact := segmentIntersection(p.a, p.b)
+		if seg == nil {
+			return nil, true
+		}
+		*p.dst = seg
+		exact = exact && segExact
+	}
+
+	return &res, exact
+}
+
+// Specificity is the per segment type ranking of a PathExp's environment,
+// service, identity and instance segments. Higher ranks are more specific.
+type Specificity [4]int
+
+// rankNames maps segment ranks, as returned by segmentRank, to segment type
+// names.
+var rankNames = []string{"fullglob", "negation", "alternation", "glob", "literal"}
+
+// Names returns the segment type name for each ranked segment.
+func (s Specificity) Names() []string {
+	names := make([]string, len(s))
+	for i, r := range s {
+		names[i] = rankNames[r]
+	}
+	return names
+}
+
+// String returns the slash separated segment type names of the ranking, for
+// example "literal/glob/fullglob/fullglob".
+func (s Specificity) String() string {
+	return strings.Join(s.Names(), "/")
+}
+
+// Specificity returns the specificity ranking of this PathExp.
+func (pe *PathExp) Specificity() Specificity {
+	return Specificity{
+		segmentRank(pe.Envs),
+		segmentRank(pe.Services),
+		segmentRank(pe.Identities),
+		segmentRank(pe.Instances),
+	}
+}
+
+// Compare returns 1 if s is more specific than other, -1 if it is less
+// specific, and 0 if they are equally specific. Segments are compared in
+// order; the first segment that differs decides the result.
+func (s Specificity) Compare(other Specificity) int {
+	for i := range s {
+		switch {
+		case s[i] < other[i]:
+			return -1
+		case s[i] > other[i]:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// BySpecificity implements sort.Interface, ordering PathExps from most to
+// least specific. Equally specific PathExps are ordered by their string form,
+// so the result is deterministic.
+type BySpecificity []*PathExp
+
+func (b BySpecificity) Len() int      { return len(b) }
+func (b BySpecificity) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b BySpecificity) Less(i, j int) bool {
+	if cmp := b[i].CompareSpecificity(b[j]); cmp != 0 {
+		return cmp > 0
+	}
+	return b[i].String() < b[j].String()
+}
+
+// SortBySpecificity sorts the given PathExps from most to least specific.
+func SortBySpecificity(pes []*PathExp) {
+	sort.Sort(BySpecificity(pes))
+}
+
+// members returns the individual non-alternation segments making up seg.
+func members(seg segment) []segment {
+	if a, ok := seg.(alternation); ok {
+		return a
+	}
+	return []segment{seg}
+}
+
+// globBounds returns the literal prefix and suffix that any subject matched
+// by a glob or pattern must have. ok is false for any other segment type.
+func globBounds(seg segment) (prefix, suffix string, ok bool) {
+	switch s := seg.(type) {
+	case glob:
+		return string(s), "", true
+	case pattern:
+		return s.prefix, s.suffix, true
+	default:
+		return "", "", false
+	}
+}
+
+func segmentsIntersect(a, b segment) bool {
+	for _, am := range members(a) {
+		for _, bm := range members(b) {
+			if atomsIntersect(am, bm) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// atomsIntersect returns whether any subject is matched by both a and b.
+// Neither a nor b may be an alternation.
+func atomsIntersect(a, b segment) bool {
+	an, aNeg := a.(negation)
+	bn, bNeg := b.(negation)
+	switch {
+	case aNeg && bNeg:
+		// Only a finite number of subjects can ever be excluded
+		return true
+	case aNeg:
+		return !segmentIsSubset(b, an.segment)
+	case bNeg:
+		return !segmentIsSubset(a, bn.segment)
+	default:
+		seg, _ := atomIntersection(a, b)
+		return seg != nil
+	}
+}
+
+func segmentIsSubset(a, b segment) bool {
+	for _, am := range members(a) {
+		covered := false
+		for _, bm := range members(b) {
+			if atomIsSubset(am, bm) {
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			return false
+		}
+	}
+
+	return true
+}
+
+// atomIsSubset returns whether every subject matched by a is matched by b.
+// Neither a nor b may be an alternation.
+func atomIsSubset(a, b segment) bool {
+	if _, ok := b.(fullglob); ok {
+		return true
+	}
+
+	if bn, ok := b.(negation); ok {
+		if an, ok := a.(negation); ok {
+			return segmentIsSubset(bn.segment, an.segment)
+		}
+		return !segmentsIntersect(a, bn.segment)
+	}
+
+	switch at := a.(type) {
+	case literal:
+		return b.Contains(string(at))
+	case glob, pattern:
+		ap, as, _ := globBounds(at)
+		bp, bs, ok := globBounds(b)
+		return ok && strings.HasPrefix(ap, bp) && strings.HasSuffix(as, bs)
+	case fullglob, negation:
+		return false
+	default:
+		panic("Bad type for segment!")
+	}
+}
+
+// segmentIntersection returns the segment matching subjects matched by both a
+// and b, or nil if there are none. exact is false if the segment also matches
+// other subjects, as described by atomIntersection.
+func segmentIntersection(a, b segment) (seg segment, exact bool) {
+	var res alternation
+	exact = true
+	for _, am := range members(a) {
+		for _, bm := range members(b) {
+			seg, atomExact := atomIntersection(am, bm)
+			if seg != nil {
+				res = append(res, members(seg)...)
+				exact = exact && atomExact
+			}
+		}
+	}
+
+	res = reduceAlternation(res)
+	switch len(res) {
+	case 0:
+		return nil, true
+	case 1:
+		return res[0], exact
+	default:
+		return res, exact
+	}
+}
+
+// atomIntersection returns the segment matching subjects matched by both a
+// and b, or nil if there are none. Neither a nor b may be an alternation.
+//
+// The grammar cannot express a glob minus some of its subjects, so when a
+// negation only partially excludes the other segment, the other segment is
+// returned unchanged, and exact is false.
+func atomIntersection(a, b segment) (seg segment, exact bool) {
+	switch {
+	case atomIsSubset(a, b):
+		return a, true
+	case atomIsSubset(b, a):
+		return b, true
+	}
+
+	an, aNeg := a.(negation)
+	bn, bNeg := b.(negation)
+	switch {
+	case aNeg && bNeg:
+		excluded := reduceAlternation(append(
+			append(alternation{}, members(an.segment)...), members(bn.segment)...))
+		if len(excluded) == 1 {
+			return negation{excluded[0]}, true
+		}
+		return negation{excluded}, true
+	case aNeg:
+		if segmentIsSubset(b, an.segment) {
+			return nil, true
+		}
+		return b, false
+	case bNeg:
+		return atomIntersection(b, a)
+	}
+
+	ap, as, aGlob := globBounds(a)
+	bp, bs, bGlob := globBounds(b)
+	if !aGlob || !bGlob {
+		// Two literals, or a literal not matched by a glob, can never match
+		// the same subject.
+		return nil, true
+	}
+
+	// The longer prefix and suffix must each extend the shorter one.
+	prefix, suffix := ap, as
+	if len(bp) > len(prefix) {
+		prefix = bp
+	}
+	if len(bs) > len(suffix) {
+		suffix = bs
+	}
+	if !strings.HasPrefix(prefix, ap) || !strings.HasPrefix(prefix, bp) ||
+		!strings.HasSuffix(suffix, as) || !strings.HasSuffix(suffix, bs) {
+		return nil, true
+	}
+
+	// Subjects where the prefix and suffix overlap are matched by both, but
+	// not by prefix*suffix, so they are included as literals.
+	res := alternation{pattern{prefix: prefix, suffix: suffix}}
+	if suffix == "" {
+		res[0] = glob(prefix)
+	}
+	for i := 1; i <= len(prefix) && i <= len(suffix); i++ {
+		subject := prefix + suffix[i:]
+		if strings.HasSuffix(prefix, suffix[:i]) && a.Contains(subject) && b.Contains(subject) {
+			res = append(res, literal(subject))
+		}
+	}
+
+	if len(res) == 1 {
+		return res[0], true
+	}
+	return res, true
+}
+
+// reduceAlternation removes duplicate members, and members covered by other
+// members, from an alternation.
+func reduceAlternation(a alternation) alternation {
+	var res alternation
+	for i, seg := range a {
+		redundant := false
+		for j, other := range a {
+			if i == j || !atomIsSubset(seg, other) {
+				continue
+			}
+
+			// For equal members, keep only the first.
+			if !atomIsSubset(other, seg) || j < i {
+				redundant = true
+				break
+			}
+		}
+
+		if !redundant {
+			res = append(res, seg)
+		}
+	}
+
+	return res
+}
@@ -75,18 +75,53 @@ type UserV1 struct { // type: 0x01
 }
 
 // MasterKey is the body.master object for a user and machine token
+//
+// Master keys encrypted with triplesec-v3 use triplesec's own key
+// derivation, so KDF, Salt and Params are only set for other algorithms.
 type MasterKey struct {
-	Value *base64.Value `json:"value"`
-	Alg   string        `json:"alg"`
+	Value  *base64.Value `json:"value"`
+	Alg    string        `json:"alg"`
+	KDF    string        `json:"kdf,omitempty"`
+	Salt   string        `json:"salt,omitempty"`
+	Params *KDFParams    `json:"params,omitempty"`
 }
 
 // UserPassword is the body.password object for a user
 type UserPassword struct {
-	Salt  string        `json:"salt"`
-	Value *base64.Value `json:"value"`
-	Alg   string        `json:"alg"`
+	Salt   string        `json:"salt"`
+	Value  *base64.Value `json:"value"`
+	Alg    string        `json:"alg"`
+	Params *KDFParams    `json:"params,omitempty"`
 }
 
+// KDFParams holds the cost parameters used to derive a key from a password.
+// Time, Memory and Threads apply to argon2id, while N, R and P apply to
+// scrypt.
+type KDFParams struct {
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"` // in KiB
+	Threads uint8  `json:"threads,omitempty"`
+
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// DefaultKDFParams are the argon2id cost parameters used when deriving new
+// keys from a password or passphrase, for master keys and bundles alike.
+var DefaultKDFParams = KDFParams{
+	Time:    3,
+	Memory:  64 * 1024, // 64 MiB
+	Threads: 4,
+}
+
+// Upper bounds on argon2id costs accepted from stored KDFParams, so a tampered
+// master key object or bundle can't exhaust the memory of whoever opens it.
+const (
+	MaxKDFTime   = 64
+	MaxKDFMemory = 1024 * 1024 // 1 GiB
+)
+
 // TokenType represents the different types of tokens
 type TokenType string
 
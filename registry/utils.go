@@ -2,9 +2,10 @@ package registry
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"net/url"
+
+	"github.com/manifoldco/torus-cli/logging"
 )
 
 func replaceAuthToken(req *http.Request, token string) {
@@ -21,13 +22,13 @@ func tokenRoundTrip(ctx context.Context, rd RequestDoer, token, method,
 	req, err := rd.NewRequest(method, path, query, body)
 	replaceAuthToken(req, token)
 	if err != nil {
-		log.Printf("Error building request: %s", err)
+		logging.FromContext(ctx).Errorf("Error building request: %s", err)
 		return err
 	}
 
 	_, err = rd.Do(ctx, req, response)
 	if err != nil {
-		log.Printf("Error making request: %s", err)
+		logging.FromContext(ctx).Errorf("Error making request: %s", err)
 	}
 
 	return err